@@ -93,7 +93,7 @@ export interface ListMessagesResult {
 }
 
 // gRPC-Web client configuration
-const GRPC_WEB_ENDPOINT = 'http://localhost:8080'; // Envoy proxy endpoint
+const GRPC_WEB_ENDPOINT = 'http://localhost:50051'; // chatsh server, which speaks gRPC-Web itself
 
 export class ChatshGrpcClient {
     private client: PromiseClient<typeof ChatshService>;
@@ -0,0 +1,134 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/spf13/cobra"
+)
+
+// isTerminal reports whether f is a terminal, which binary data should neither go to nor come from
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// adminCmd represents the admin command
+var adminCmd = &cobra.Command{
+	Use:   "admin",
+	Short: "Runs server maintenance; only for the owner tokens the server lists as admins.",
+}
+
+var adminBackupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Downloads a consistent snapshot of the server's database.",
+	Long: `Writes a snapshot of the whole database, taken while the server keeps running,
+to standard output or to the file given with -o:
+
+  chatsh admin backup > chatsh-backup.db
+
+The snapshot is a SQLite database; chatsh-server restore <file> puts it back
+on a stopped server.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		output, _ := cmd.Flags().GetString("output")
+		if output == "" {
+			if isTerminal(os.Stdout) {
+				fmt.Fprintln(os.Stderr, "admin: refusing to write a database to a terminal; redirect it or use -o")
+				exitStatus = unixErrors["INVALID_ARGUMENT"].code
+				return
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		stream, err := chatshClient.Backup(ctx, &pb.BackupRequest{OwnerToken: ownerToken})
+		if err != nil {
+			reportError("admin: cannot back up", err)
+			return
+		}
+		// The first message tells whether the server agreed to the backup at all
+		chunk, err := stream.Recv()
+		if err != nil {
+			reportError("admin: cannot back up", err)
+			return
+		}
+
+		var out io.Writer = os.Stdout
+		var file *os.File
+		if output != "" {
+			// Write next to the output and rename at the end, so that a failed backup leaves no partial file
+			file, err = os.CreateTemp(filepath.Dir(output), filepath.Base(output)+".*.part")
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "admin: cannot create '%s': %v\n", output, err)
+				exitStatus = 1
+				return
+			}
+			defer os.Remove(file.Name())
+			defer file.Close()
+			out = file
+		}
+
+		size, hash, written := chunk.Size, sha256.New(), int64(0)
+		digest := ""
+		for {
+			if _, err := out.Write(chunk.Data); err != nil {
+				fmt.Fprintf(os.Stderr, "admin: cannot write backup: %v\n", err)
+				exitStatus = 1
+				return
+			}
+			hash.Write(chunk.Data)
+			written += int64(len(chunk.Data))
+			if chunk.Sha256 != "" {
+				digest = chunk.Sha256
+			}
+			chunk, err = stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				reportError("admin: backup interrupted", err)
+				return
+			}
+		}
+		if written != size || digest != hex.EncodeToString(hash.Sum(nil)) {
+			reportFailure("admin: backup is corrupt", fmt.Sprintf("received %d of %d bytes, sha256 %x instead of %s", written, size, hash.Sum(nil), digest))
+			return
+		}
+
+		if file != nil {
+			if err := file.Sync(); err != nil {
+				fmt.Fprintf(os.Stderr, "admin: cannot write backup: %v\n", err)
+				exitStatus = 1
+				return
+			}
+			if err := file.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "admin: cannot write backup: %v\n", err)
+				exitStatus = 1
+				return
+			}
+			if err := os.Rename(file.Name(), output); err != nil {
+				fmt.Fprintf(os.Stderr, "admin: cannot create '%s': %v\n", output, err)
+				exitStatus = 1
+				return
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Backed up %d bytes, sha256 %s\n", written, digest)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(adminCmd)
+	adminCmd.AddCommand(adminBackupCmd)
+	adminBackupCmd.Flags().StringP("output", "o", "", "Write the backup to this file instead of standard output")
+}
@@ -0,0 +1,268 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/ponyo877/chatsh/importer"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// importBatchSize is how many messages of a room go into each message of an import
+const importBatchSize = 500
+
+// errImportClosed tells that the server stopped reading the import, and why comes with CloseAndRecv
+var errImportClosed = errors.New("import closed by the server")
+
+var adminImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Imports the history of other chat systems, keeping authors and times.",
+	Long: `Imports messages from a Slack export, IRC logs or JSON lines with the authors and
+times they had. Every message is keyed by its id in its source, or by what it says,
+so an import can run again: the messages a room has already imported are skipped.
+
+Imported messages are not broadcast and reach neither webhooks nor mentions.`,
+}
+
+var adminImportSlackCmd = &cobra.Command{
+	Use:   "slack <export.zip>",
+	Short: "Imports a Slack export, a room per channel.",
+	Long: `Imports the channels of a Slack export zip, each into the room of its name in the
+directory given with --to, or where --channel puts it:
+
+  chatsh admin import slack export.zip --to /srv/slack --create-rooms
+  chatsh admin import slack export.zip --to /srv/slack --channel general=/srv/general`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		to, _ := cmd.Flags().GetString("to")
+		mapped, _ := cmd.Flags().GetStringToString("channel")
+		createRooms, _ := cmd.Flags().GetBool("create-rooms")
+		zr, err := zip.OpenReader(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "admin: cannot open '%s': %v\n", args[0], err)
+			exitStatus = unixErrors["INVALID_ARGUMENT"].code
+			if errors.Is(err, fs.ErrNotExist) {
+				exitStatus = unixErrors["NOT_FOUND"].code
+			}
+			return
+		}
+		defer zr.Close()
+		directory := resolveRoomPath(to)
+		roomPath := func(message importer.Message) string {
+			if room, ok := mapped[message.Room]; ok {
+				return resolveRoomPath(room)
+			}
+			return path.Join(directory, message.Room)
+		}
+		importMessages(args[0], createRooms, roomPath, func(fn func(importer.Message) error) error {
+			return importer.ReadSlack(&zr.Reader, fn)
+		})
+	},
+}
+
+var adminImportIRCCmd = &cobra.Command{
+	Use:   "irc <log...> --room <path>",
+	Short: "Imports IRC logs of irssi, WeeChat, ZNC or HexChat into a room.",
+	Long: `Imports the messages and actions of IRC logs into one room. Logs that only have
+times take their day from their name, like ZNC's #channel_20240102.log, or from --date:
+
+  chatsh admin import irc ~/irclogs/libera/#go-nuts.log --room /srv/irc/go-nuts --tz Europe/Berlin
+  chatsh admin import irc logs/#ops_2024*.log --room /srv/ops`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		room, _ := cmd.Flags().GetString("room")
+		date, _ := cmd.Flags().GetString("date")
+		zone, _ := cmd.Flags().GetString("tz")
+		createRooms, _ := cmd.Flags().GetBool("create-rooms")
+		if room == "" {
+			fmt.Fprintln(os.Stderr, "admin: give the room to import into with --room")
+			exitStatus = unixErrors["INVALID_ARGUMENT"].code
+			return
+		}
+		options := importer.IRCOptions{Location: time.Local}
+		if zone != "" {
+			location, err := time.LoadLocation(zone)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "admin: unknown time zone '%s'\n", zone)
+				exitStatus = unixErrors["INVALID_ARGUMENT"].code
+				return
+			}
+			options.Location = location
+		}
+		var day time.Time
+		if date != "" {
+			var err error
+			if day, err = time.Parse(time.DateOnly, date); err != nil {
+				fmt.Fprintf(os.Stderr, "admin: --date must be like 2024-01-02, not '%s'\n", date)
+				exitStatus = unixErrors["INVALID_ARGUMENT"].code
+				return
+			}
+		}
+		roomPath := resolveRoomPath(room)
+		for _, file := range args {
+			options.Date = day
+			if day.IsZero() {
+				options.Date, _ = importer.LogDate(filepath.Base(file))
+			}
+			importMessages(file, createRooms, func(importer.Message) string { return roomPath }, func(fn func(importer.Message) error) error {
+				f, err := os.Open(file)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				err = importer.ReadIRC(f, options, fn)
+				if errors.Is(err, importer.ErrNoDate) {
+					return fmt.Errorf("%w; give it with --date", err)
+				}
+				return err
+			})
+		}
+	},
+}
+
+var adminImportJSONLCmd = &cobra.Command{
+	Use:   "jsonl <file|->",
+	Short: "Imports messages written as JSON lines, like those of cat --format jsonl.",
+	Long: `Imports a message per line, each naming its room unless --room gives one for all:
+
+  {"room":"/srv/proj/general","id":12,"author":"alice","text":"hello","time":"2025-06-01T10:02:03+09:00"}
+
+id, a number or a string, keys the message; without one, the message is keyed by
+its room, author, text and time. "-" reads standard input, so that one server's
+history goes to another with
+
+  chatsh cat --format jsonl /srv/proj/general | chatsh --grpc-server other:50051 admin import jsonl -`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		room, _ := cmd.Flags().GetString("room")
+		createRooms, _ := cmd.Flags().GetBool("create-rooms")
+		roomPath := func(message importer.Message) string {
+			if room != "" {
+				return resolveRoomPath(room)
+			}
+			return resolveRoomPath(message.Room)
+		}
+		source := args[0]
+		if source == "-" {
+			source = "standard input"
+		}
+		importMessages(source, createRooms, roomPath, func(fn func(importer.Message) error) error {
+			in := os.Stdin
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			return importer.ReadJSONLines(in, func(message importer.Message) error {
+				if room == "" && message.Room == "" {
+					return fmt.Errorf("message %s names no room; give one with --room", message.Key)
+				}
+				return fn(message)
+			})
+		})
+	},
+}
+
+// importMessages sends the messages read from source to the server, in batches of a room each,
+// and prints what the server did with them
+func importMessages(source string, createRooms bool, roomPath func(importer.Message) string, read func(func(importer.Message) error) error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := chatshClient.ImportMessages(ctx)
+	if err != nil {
+		reportError("admin: cannot import", err)
+		return
+	}
+	req := &pb.ImportMessagesRequest{OwnerToken: ownerToken, CreateRooms: createRooms}
+	send := func() error {
+		// The server may stop reading early, and then tells why in CloseAndRecv
+		if err := stream.Send(req); err != nil {
+			return errImportClosed
+		}
+		req = &pb.ImportMessagesRequest{}
+		return nil
+	}
+	readErr := read(func(message importer.Message) error {
+		room := roomPath(message)
+		if len(req.Messages) > 0 && (req.RoomPath != room || len(req.Messages) == importBatchSize) {
+			if err := send(); err != nil {
+				return err
+			}
+		}
+		req.RoomPath = room
+		req.Messages = append(req.Messages, &pb.ImportedMessage{
+			Key:         message.Key,
+			OwnerName:   message.Author,
+			TextContent: message.Text,
+			Created:     timestamppb.New(message.Time),
+		})
+		return nil
+	})
+	if readErr == nil && len(req.Messages) > 0 {
+		readErr = send()
+	}
+	if readErr != nil && !errors.Is(readErr, errImportClosed) {
+		fmt.Fprintf(os.Stderr, "admin: cannot read '%s': %v\n", source, readErr)
+		exitStatus = 1
+		return
+	}
+
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		if path := errorPath(err, ""); path != "" {
+			reportError(fmt.Sprintf("admin: cannot import into '%s'", path), err)
+			return
+		}
+		reportError(fmt.Sprintf("admin: cannot import '%s'", source), err)
+		return
+	}
+	for _, room := range res.Rooms {
+		created := ""
+		if room.Created {
+			created = " (created)"
+		}
+		dropped := ""
+		if room.Dropped > 0 {
+			dropped = fmt.Sprintf(" and %d left empty without their control characters", room.Dropped)
+		}
+		fmt.Printf("Imported %d messages into %s%s, skipped %d imported before%s\n", room.Messages, room.Path, created, room.Duplicates, dropped)
+	}
+	if !res.Status.Ok {
+		reportFailure(fmt.Sprintf("admin: cannot import '%s'", source), res.Status.Message)
+		return
+	}
+	if len(res.Rooms) == 0 {
+		fmt.Printf("No messages in %s\n", source)
+	}
+}
+
+func init() {
+	adminCmd.AddCommand(adminImportCmd)
+	adminImportCmd.AddCommand(adminImportSlackCmd, adminImportIRCCmd, adminImportJSONLCmd)
+	adminImportCmd.PersistentFlags().Bool("create-rooms", false, "Create missing rooms; their directories must exist")
+
+	adminImportSlackCmd.Flags().String("to", ".", "Import every channel into the room of its name in this directory")
+	adminImportSlackCmd.Flags().StringToString("channel", nil, "Import a channel into another room, as name=path")
+
+	adminImportIRCCmd.Flags().String("room", "", "Room to import into")
+	adminImportIRCCmd.Flags().String("date", "", "Day of logs that do not say it, as 2024-01-02 (default: from the file name)")
+	adminImportIRCCmd.Flags().String("tz", "", "Time zone the logs were written in, such as UTC or Europe/Berlin (default: local)")
+
+	adminImportJSONLCmd.Flags().String("room", "", "Import every message into this room instead of the one it names")
+}
@@ -8,10 +8,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/ponyo877/chatsh/transcript"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -20,11 +22,41 @@ import (
 var catCmd = &cobra.Command{
 	Use:   "cat <file_path...>",
 	Short: "Displays the content of files (rooms).",
-	Long:  `Displays the content of one or more files (interpreted as rooms) on the chatsh server.`,
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Displays the content of one or more files (interpreted as rooms) on the chatsh server.
+
+With --format, writes the whole history of the rooms, with the author and time
+of every message, for reading elsewhere:
+
+  chatsh cat --format md /srv/proj/incident > incident.md
+  chatsh cat --format html --tz UTC /srv/proj/incident > incident.html
+  chatsh cat --format mbox /srv/proj/incident > incident.mbox
+
+The formats are txt, md, jsonl, html (a page that needs no other files) and
+mbox, which mail clients import as a thread per room. Times are in the local
+time zone unless --tz or time_zone in ~/.chatsh.yaml names another.`,
+	Args: cobra.MinimumNArgs(1),
 	// Add ValidArgsFunction for path completion
 	ValidArgsFunction: PathCompletionFunc,
 	Run: func(cmd *cobra.Command, args []string) {
+		if format, _ := cmd.Flags().GetString("format"); format != "" {
+			if !slices.Contains(transcript.Formats, transcript.Format(format)) {
+				fmt.Fprintf(os.Stderr, "cat: --format must be txt, md, jsonl, html or mbox, not '%s'\n", format)
+				exitStatus = unixErrors["INVALID_ARGUMENT"].code
+				return
+			}
+			location := time.Local
+			if name := viper.GetString(timeZoneKey); name != "" {
+				var err error
+				if location, err = time.LoadLocation(name); err != nil {
+					fmt.Fprintf(os.Stderr, "cat: unknown time zone '%s'\n", name)
+					exitStatus = unixErrors["INVALID_ARGUMENT"].code
+					return
+				}
+			}
+			catTranscript(args, transcript.Format(format), location)
+			return
+		}
+
 		currentBaseDir := viper.GetString(currentDirectoryKey)
 		if currentBaseDir == "" {
 			currentBaseDir = viper.GetString(homeDirectoryKey)
@@ -50,7 +82,7 @@ var catCmd = &cobra.Command{
 
 			res, err := chatshClient.ListMessages(ctx, req)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error calling ListMessages for %s: %v\n", targetPath, err)
+				reportError("cat: "+targetPath, err)
 				continue
 			}
 
@@ -67,18 +99,97 @@ var catCmd = &cobra.Command{
 			// StreamMessage might be better if the server supports streaming a whole "file".
 			var contentBuilder strings.Builder
 			for _, msg := range res.Messages {
-				contentBuilder.WriteString(msg.TextContent)
+				contentBuilder.WriteString(sanitizeForTerminal(msg.TextContent))
 				// Assuming messages don't inherently have newlines and `cat` should preserve them if they do.
 				// If each message is a line, then add a newline:
 				// contentBuilder.WriteString("\n")
 			}
 			fmt.Print(contentBuilder.String())
+
+			// Only what was printed is read: older messages past the limit and newer ones are not
+			if err := markRoomRead(targetPath, lastMessageID(res.Messages)); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to mark %s as read: %v\n", targetPath, err)
+			}
 		}
 	},
 }
 
+// catPageSize is how many messages cat asks for at a time when it writes whole histories
+const catPageSize = 500
+
+// catTranscript writes the whole history of the rooms to standard output in format, paging
+// through it oldest first
+func catTranscript(args []string, format transcript.Format, location *time.Location) {
+	w, err := transcript.NewWriter(os.Stdout, format, transcript.Options{Location: location})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cat: %v\n", err)
+		exitStatus = unixErrors["INVALID_ARGUMENT"].code
+		return
+	}
+	// Names and texts could hold escape sequences, which only matter on a terminal
+	clean := func(s string) string { return s }
+	if isTerminal(os.Stdout) {
+		clean = sanitizeForTerminal
+	}
+
+	for _, arg := range args {
+		targetPath := resolveRoomPath(arg)
+		req := &pb.ListMessagesRequest{RoomPath: targetPath, Limit: catPageSize, FromStart: true}
+		var lastID int64
+		for page := 0; ; page++ {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+			res, err := chatshClient.ListMessages(ctx, req)
+			cancel()
+			if err != nil {
+				reportError("cat: "+targetPath, err)
+				break
+			}
+			if page == 0 {
+				if err := w.StartRoom(targetPath); err != nil {
+					fmt.Fprintf(os.Stderr, "cat: write error: %v\n", err)
+					exitStatus = 1
+					return
+				}
+			}
+			for _, msg := range res.Messages {
+				message := transcript.Message{
+					ID:     msg.Id,
+					Author: clean(msg.OwnerName),
+					Text:   clean(msg.TextContent),
+					Time:   msg.Created.AsTime(),
+				}
+				if err := w.WriteMessage(message); err != nil {
+					fmt.Fprintf(os.Stderr, "cat: write error: %v\n", err)
+					exitStatus = 1
+					return
+				}
+			}
+			lastID = max(lastID, lastMessageID(res.Messages))
+			if res.NextPageToken == "" {
+				// Only what was written is read, not messages posted while the pages were read;
+				// an empty room has nothing to mark, and zero would mark up to the latest message
+				if lastID == 0 {
+					break
+				}
+				if err := markRoomRead(targetPath, lastID); err != nil {
+					fmt.Fprintf(os.Stderr, "Warning: failed to mark %s as read: %v\n", targetPath, err)
+				}
+				break
+			}
+			req = &pb.ListMessagesRequest{RoomPath: targetPath, Limit: catPageSize, PageToken: res.NextPageToken}
+		}
+	}
+	if err := w.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "cat: write error: %v\n", err)
+		exitStatus = 1
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(catCmd)
+	catCmd.Flags().String("format", "", "Write the whole history with authors and times as txt, md, jsonl, html or mbox")
+	catCmd.Flags().String("tz", "", "Time zone of the times written with --format, such as UTC or Asia/Tokyo (default: local)")
+	viper.BindPFlag(timeZoneKey, catCmd.Flags().Lookup("tz"))
 
 	// Here you will define your flags and configuration settings.
 
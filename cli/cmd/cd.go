@@ -61,11 +61,12 @@ This command updates the directory path stored in the configuration file.`,
 
 		res, err := chatshClient.CheckDirectoryExists(ctx, req)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error calling ListNodes: %v\n", err)
+			reportError("cd: "+absTargetDir, err)
 			return
 		}
 		if !res.Exists {
-			fmt.Fprintf(os.Stderr, "Directory does not exist: %s\n", absTargetDir)
+			fmt.Fprintf(os.Stderr, "cd: %s: %s\n", absTargetDir, unixErrors["NOT_FOUND"].message)
+			exitStatus = unixErrors["NOT_FOUND"].code
 			return
 		}
 
@@ -6,7 +6,6 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 	"time"
 
@@ -57,14 +56,14 @@ var cpCmd = &cobra.Command{
 
 		res, err := chatshClient.CopyPath(ctx, req)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error calling CopyPath from %s to %s: %v\n", sourcePath, destinationPath, err)
+			reportError(transferErrorPrefix("cp", "copy", sourcePath, err), err)
 			return
 		}
 
 		if res.Status.Ok {
 			fmt.Printf("Copied %s to %s\n", sourcePath, destinationPath)
 		} else {
-			fmt.Fprintf(os.Stderr, "Failed to copy %s to %s: %s\n", sourcePath, destinationPath, res.Status.Message)
+			reportFailure(fmt.Sprintf("cp: cannot copy '%s' to '%s'", sourcePath, destinationPath), res.Status.Message)
 		}
 	},
 }
@@ -6,8 +6,8 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	pb "github.com/ponyo877/chatsh/grpc"
@@ -26,6 +26,9 @@ var echoCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		textContent := args[0]
 		destinationPathArg := args[1]
+		if interpret, _ := cmd.Flags().GetBool("escapes"); interpret {
+			textContent = interpretEscapes(textContent)
+		}
 
 		currentBaseDir := viper.GetString(currentDirectoryKey)
 		if currentBaseDir == "" {
@@ -50,7 +53,7 @@ var echoCmd = &cobra.Command{
 
 		res, err := chatshClient.WriteMessage(ctx, req)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error calling WriteMessage to %s: %v\n", targetPath, err)
+			reportError("echo: "+targetPath, err)
 			return
 		}
 
@@ -61,7 +64,7 @@ var echoCmd = &cobra.Command{
 			// For now, let's print a confirmation.
 			fmt.Printf("Text written to %s\n", targetPath)
 		} else {
-			fmt.Fprintf(os.Stderr, "Failed to write text to %s: %s\n", targetPath, res.Status.Message)
+			reportFailure("echo: "+targetPath, res.Status.Message)
 		}
 	},
 }
@@ -78,4 +81,11 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// echoCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	echoCmd.Flags().BoolP("escapes", "e", false, `Interpret backslash escapes such as \n for multi-line messages`)
+}
+
+// interpretEscapes expands the backslash escapes supported by "echo -e"
+func interpretEscapes(s string) string {
+	replacer := strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\t`, "\t")
+	return replacer.Replace(s)
 }
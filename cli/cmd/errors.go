@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// exitStatus is the status chatsh exits with once the command has processed all of its arguments
+var exitStatus int
+
+// unixErrors maps the ErrorInfo reasons of the server to the strerror text and errno value
+// that coreutils would print and exit with for the same failure on a local file system
+var unixErrors = map[string]struct {
+	message string
+	code    int
+}{
+	"NOT_FOUND":         {"No such file or directory", 2},     // ENOENT
+	"PERMISSION_DENIED": {"Permission denied", 13},            // EACCES
+	"ALREADY_EXISTS":    {"File exists", 17},                  // EEXIST
+	"NOT_A_DIRECTORY":   {"Not a directory", 20},              // ENOTDIR
+	"NOT_A_ROOM":        {"Is a directory", 21},               // EISDIR
+	"NOT_EMPTY":         {"Directory not empty", 39},          // ENOTEMPTY
+	"UNSUPPORTED":       {"Operation not supported", 95},      // EOPNOTSUPP
+	"INVALID_ARGUMENT":  {"Invalid argument", 22},             // EINVAL
+	"UNAVAILABLE":       {"Server is not reachable", 69},      // EX_UNAVAILABLE
+	"RATE_LIMITED":      {"Too many requests, try later", 75}, // EX_TEMPFAIL
+}
+
+// errorInfo returns the ErrorInfo attached to a gRPC error, if any
+func errorInfo(err error) *errdetails.ErrorInfo {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info
+		}
+	}
+	return nil
+}
+
+// errorPath returns the path the server blamed for err, or fallback when it named none
+func errorPath(err error, fallback string) string {
+	if info := errorInfo(err); info != nil && info.Metadata["path"] != "" {
+		return info.Metadata["path"]
+	}
+	return fallback
+}
+
+// describeError returns the Unix-style reason for err and the exit status matching it.
+// Errors about something other than a path, such as an unknown user, keep the server's wording.
+func describeError(err error) (string, int) {
+	st := status.Convert(err)
+	if info := errorInfo(err); info != nil {
+		if unixErr, ok := unixErrors[info.Reason]; ok {
+			if info.Metadata["path"] == "" {
+				return st.Message(), unixErr.code
+			}
+			return unixErr.message, unixErr.code
+		}
+	}
+	switch st.Code() {
+	case codes.InvalidArgument:
+		// Validation failures explain themselves better than EINVAL would
+		return st.Message(), unixErrors["INVALID_ARGUMENT"].code
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return unixErrors["UNAVAILABLE"].message, unixErrors["UNAVAILABLE"].code
+	case codes.ResourceExhausted:
+		return unixErrors["RATE_LIMITED"].message, unixErrors["RATE_LIMITED"].code
+	}
+	return st.Message(), 1
+}
+
+// transferErrorPrefix words the failure of cp or mv after the path the server blamed,
+// e.g. "cp: cannot stat '/a'" when the source is missing or "cp: cannot create '/b/c'" for the destination
+func transferErrorPrefix(command, verb, sourcePath string, err error) string {
+	path := errorPath(err, sourcePath)
+	switch {
+	case path != sourcePath:
+		return fmt.Sprintf("%s: cannot create '%s'", command, path)
+	case status.Code(err) == codes.NotFound:
+		return fmt.Sprintf("%s: cannot stat '%s'", command, path)
+	default:
+		return fmt.Sprintf("%s: cannot %s '%s'", command, verb, path)
+	}
+}
+
+// reportError prints err after prefix the way coreutils does, e.g. "rm: cannot remove '/x': Permission denied",
+// and records the matching exit status
+func reportError(prefix string, err error) {
+	message, code := describeError(err)
+	fmt.Fprintf(os.Stderr, "%s: %s\n", prefix, message)
+	exitStatus = code
+}
+
+// reportFailure is reportError for failures the server only described in a response Status
+func reportFailure(prefix, message string) {
+	fmt.Fprintf(os.Stderr, "%s: %s\n", prefix, message)
+	exitStatus = 1
+}
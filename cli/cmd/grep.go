@@ -6,7 +6,6 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 	"time"
 
@@ -50,7 +49,7 @@ var grepCmd = &cobra.Command{
 
 		res, err := chatshClient.SearchMessage(ctx, req)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error calling SearchMessage for pattern '%s' in %s: %v\n", pattern, targetPath, err)
+			reportError("grep: "+targetPath, err)
 			return
 		}
 
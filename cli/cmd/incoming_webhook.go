@@ -0,0 +1,168 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/spf13/cobra"
+)
+
+// incomingWebhookURL turns the path returned by the server into a URL on the server the CLI talks to.
+// Unix sockets have no URL, so their path is printed as is for curl --unix-socket.
+func incomingWebhookURL(urlPath string) string {
+	if strings.HasPrefix(grpcServerAddress, "unix:") {
+		return urlPath
+	}
+	scheme, host := "http", grpcServerAddress
+	if isSecure {
+		scheme = "https"
+		if h, port, err := net.SplitHostPort(host); err == nil && port == "443" {
+			host = h
+		}
+	}
+	return scheme + "://" + host + urlPath
+}
+
+// webhookIncomingCmd represents the webhook incoming command
+var webhookIncomingCmd = &cobra.Command{
+	Use:     "incoming",
+	Aliases: []string{"in"},
+	Short:   "Manages the URLs that post into your rooms.",
+	Long: `An incoming webhook is a secret URL that writes the body of every POST to a room,
+attributed to a bot name. The body is either plain text or JSON like {"text": "..."}:
+
+  curl -d 'build #42 passed' https://chatsh.example.com/hooks/<secret>
+
+Anyone holding the URL can post, so rotate it when it leaks and revoke it when unused.`,
+}
+
+var webhookIncomingAddCmd = &cobra.Command{
+	Use:               "add <room>",
+	Short:             "Creates an incoming webhook URL for a room you own.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: PathCompletionFunc,
+	Run: func(cmd *cobra.Command, args []string) {
+		path := resolveRoomPath(args[0])
+		botName, _ := cmd.Flags().GetString("name")
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := chatshClient.CreateIncomingWebhook(ctx, &pb.CreateIncomingWebhookRequest{
+			RoomPath:   path,
+			OwnerToken: ownerToken, // ownerToken is loaded in root.go
+			BotName:    botName,
+		})
+		if err != nil {
+			reportError(fmt.Sprintf("webhook: cannot add an incoming webhook to '%s'", path), err)
+			return
+		}
+		if !res.Status.Ok {
+			reportFailure(fmt.Sprintf("webhook: cannot add an incoming webhook to '%s'", path), res.Status.Message)
+			return
+		}
+		fmt.Printf("Incoming webhook %d posts to %s as %s\n", res.Webhook.Id, path, res.Webhook.BotName)
+		fmt.Printf("URL: %s\n", incomingWebhookURL(res.UrlPath))
+		fmt.Println("Keep it now: the URL is not shown again.")
+	},
+}
+
+var webhookIncomingListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "Lists your incoming webhooks.",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := chatshClient.ListIncomingWebhooks(ctx, &pb.ListIncomingWebhooksRequest{OwnerToken: ownerToken})
+		if err != nil {
+			reportError("webhook: cannot list incoming webhooks", err)
+			return
+		}
+		if len(res.Webhooks) == 0 {
+			fmt.Println("No incoming webhooks.")
+			return
+		}
+		for _, webhook := range res.Webhooks {
+			room := webhook.RoomPath
+			if room == "" {
+				room = "(room deleted)"
+			}
+			lastUsed := "never used"
+			if webhook.LastUsed != nil {
+				lastUsed = "last used " + webhook.LastUsed.AsTime().Local().Format("Jan _2 15:04")
+			}
+			fmt.Printf("%3d %-24s %-16s %s\n", webhook.Id, room, webhook.BotName, lastUsed)
+		}
+	},
+}
+
+var webhookIncomingRotateCmd = &cobra.Command{
+	Use:   "rotate <id>",
+	Short: "Replaces the secret of an incoming webhook; the old URL stops working.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, ok := parseWebhookID(args[0])
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := chatshClient.RotateIncomingWebhook(ctx, &pb.RotateIncomingWebhookRequest{Id: id, OwnerToken: ownerToken})
+		if err != nil {
+			reportError(fmt.Sprintf("webhook: cannot rotate incoming webhook %d", id), err)
+			return
+		}
+		if !res.Status.Ok {
+			reportFailure(fmt.Sprintf("webhook: cannot rotate incoming webhook %d", id), res.Status.Message)
+			return
+		}
+		fmt.Printf("URL: %s\n", incomingWebhookURL(res.UrlPath))
+		fmt.Println("Keep it now: the URL is not shown again.")
+	},
+}
+
+var webhookIncomingRevokeCmd = &cobra.Command{
+	Use:     "revoke <id>",
+	Aliases: []string{"rm"},
+	Short:   "Deletes an incoming webhook so that its URL stops working.",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, ok := parseWebhookID(args[0])
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := chatshClient.RevokeIncomingWebhook(ctx, &pb.RevokeIncomingWebhookRequest{Id: id, OwnerToken: ownerToken})
+		if err != nil {
+			reportError(fmt.Sprintf("webhook: cannot revoke incoming webhook %d", id), err)
+			return
+		}
+		if !res.Status.Ok {
+			reportFailure(fmt.Sprintf("webhook: cannot revoke incoming webhook %d", id), res.Status.Message)
+			return
+		}
+		fmt.Printf("Incoming webhook %d revoked\n", id)
+	},
+}
+
+func init() {
+	webhookCmd.AddCommand(webhookIncomingCmd)
+
+	webhookIncomingCmd.AddCommand(webhookIncomingAddCmd, webhookIncomingListCmd, webhookIncomingRotateCmd, webhookIncomingRevokeCmd)
+	webhookIncomingAddCmd.Flags().String("name", "", "Name the messages are attributed to (default \"webhook\")")
+}
@@ -6,7 +6,6 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 	"time"
 
@@ -42,13 +41,19 @@ of the current directory managed by this CLI.`,
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 		defer cancel()
 
+		unreadOnly, _ := cmd.Flags().GetBool("unread")
+		long, _ := cmd.Flags().GetBool("long")
+
 		req := &pb.ListNodesRequest{
-			Path: targetPath,
+			Path:          targetPath,
+			OwnerToken:    ownerToken, // ownerToken is loaded in root.go
+			WithUnread:    true,
+			WithRetention: long,
 		}
 
 		res, err := chatshClient.ListNodes(ctx, req)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error calling ListNodes: %v\n", err)
+			reportError(fmt.Sprintf("ls: cannot access '%s'", targetPath), err)
 			return
 		}
 
@@ -58,6 +63,9 @@ of the current directory managed by this CLI.`,
 		}
 
 		for _, entry := range res.Entries {
+			if unreadOnly && entry.UnreadCount == 0 {
+				continue
+			}
 			nodeType := ""
 			switch entry.Type {
 			case pb.NodeType_ROOM:
@@ -76,7 +84,16 @@ of the current directory managed by this CLI.`,
 				timeStr := t.Format("15:04")
 				formattedTime = fmt.Sprintf("%s %s %s", monthStr, dayStr, timeStr)
 			}
-			fmt.Printf("%-4s  %s %s\n", nodeType, formattedTime, entry.Name)
+			unread := ""
+			if entry.UnreadCount > 0 {
+				unread = fmt.Sprintf(" (%d new)", entry.UnreadCount)
+			}
+			if long {
+				retention := formatRetention(entry.Retention, filepath.Join(targetPath, entry.Name))
+				fmt.Printf("%-4s  %-12s %s %s%s  [%s]\n", nodeType, entry.OwnerName, formattedTime, entry.Name, unread, retention)
+				continue
+			}
+			fmt.Printf("%-4s  %s %s%s\n", nodeType, formattedTime, entry.Name, unread)
 		}
 	},
 }
@@ -93,4 +110,6 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// lsCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	lsCmd.Flags().BoolP("unread", "u", false, "Only list rooms with new messages")
+	lsCmd.Flags().BoolP("long", "l", false, "Also list the owner and the retention policy in effect")
 }
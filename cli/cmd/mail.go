@@ -0,0 +1,95 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/spf13/cobra"
+)
+
+// mailCmd represents the mail command
+var mailCmd = &cobra.Command{
+	Use:   "mail",
+	Short: "Lists messages that mention you.",
+	Long: `Lists the messages in which other users mentioned you with @<display_name>,
+together with the room and the text they were written in.
+Listed mentions are marked as read unless --keep is given.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		showAll, _ := cmd.Flags().GetBool("all")
+		keep, _ := cmd.Flags().GetBool("keep")
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		req := &pb.ListMentionsRequest{
+			OwnerToken: ownerToken, // ownerToken is loaded in root.go
+			UnreadOnly: !showAll,
+		}
+		res, err := chatshClient.ListMentions(ctx, req)
+		if err != nil {
+			reportError("mail: cannot read mentions", err)
+			return
+		}
+
+		if len(res.Mentions) == 0 {
+			fmt.Println("No mail.")
+			return
+		}
+
+		var unreadIDs []int64
+		for i, mention := range res.Mentions {
+			status := " "
+			if !mention.Read {
+				status = "N"
+				unreadIDs = append(unreadIDs, mention.Id)
+			}
+			created := mention.GetCreated().AsTime().Local().Format("Jan _2 15:04")
+			fmt.Printf("%s %3d %-12s %s  %s\n", status, i+1, mention.SenderName, created, mention.RoomPath)
+			fmt.Printf("        %s\n", strings.ReplaceAll(mention.TextContent, "\n", "\n        "))
+		}
+
+		if keep || len(unreadIDs) == 0 {
+			return
+		}
+		markRes, err := chatshClient.MarkMentionsRead(ctx, &pb.MarkMentionsReadRequest{
+			OwnerToken: ownerToken,
+			Ids:        unreadIDs,
+		})
+		if err != nil {
+			reportError("mail: cannot mark mentions as read", err)
+			return
+		}
+		if !markRes.Status.Ok {
+			reportFailure("mail: cannot mark mentions as read", markRes.Status.Message)
+		}
+	},
+}
+
+// printMentionBanner tells the user about unread mentions, like the login "You have new mail."
+func printMentionBanner() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	res, err := chatshClient.ListMentions(ctx, &pb.ListMentionsRequest{
+		OwnerToken: ownerToken,
+		UnreadOnly: true,
+	})
+	if err != nil || len(res.Mentions) == 0 {
+		return
+	}
+	fmt.Println("You have new mentions.")
+}
+
+func init() {
+	rootCmd.AddCommand(mailCmd)
+
+	mailCmd.Flags().BoolP("all", "a", false, "Show mentions that were already read")
+	mailCmd.Flags().BoolP("keep", "k", false, "Do not mark the listed mentions as read")
+}
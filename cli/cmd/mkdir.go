@@ -6,7 +6,6 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 	"time"
 
@@ -51,14 +50,14 @@ var mkdirCmd = &cobra.Command{
 
 			res, err := chatshClient.CreateDirectory(ctx, req)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error calling CreateDirectory for %s: %v\n", targetPath, err)
+				reportError(fmt.Sprintf("mkdir: cannot create directory '%s'", targetPath), err)
 				continue // Continue with the next directory if one fails
 			}
 
 			if res.Status.Ok {
 				fmt.Printf("Directory created: %s\n", targetPath)
 			} else {
-				fmt.Fprintf(os.Stderr, "Failed to create directory %s: %s\n", targetPath, res.Status.Message)
+				reportFailure(fmt.Sprintf("mkdir: cannot create directory '%s'", targetPath), res.Status.Message)
 			}
 		}
 	},
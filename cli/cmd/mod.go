@@ -0,0 +1,186 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// modCmd represents the mod command
+var modCmd = &cobra.Command{
+	Use:   "mod",
+	Short: "Moderates a room you own.",
+	Long: `Moderation tools for room owners: kick, ban, mute and slow mode.
+Every moderation action is recorded in the room's moderation log, which can be shown with "mod show".`,
+}
+
+func resolveRoomPath(pathArg string) string {
+	if filepath.IsAbs(pathArg) {
+		return pathArg
+	}
+	currentBaseDir := viper.GetString(currentDirectoryKey)
+	if currentBaseDir == "" {
+		currentBaseDir = viper.GetString(homeDirectoryKey)
+	}
+	return filepath.Join(currentBaseDir, pathArg)
+}
+
+func moderateRoom(roomPath string, action pb.ModerationAction, targetName string, slowModeSeconds int32, reason string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	req := &pb.ModerateRoomRequest{
+		RoomPath:        roomPath,
+		OwnerToken:      ownerToken, // ownerToken is loaded in root.go
+		Action:          action,
+		TargetName:      targetName,
+		SlowModeSeconds: slowModeSeconds,
+		Reason:          reason,
+	}
+	res, err := chatshClient.ModerateRoom(ctx, req)
+	if err != nil {
+		reportError(fmt.Sprintf("mod: cannot %s in '%s'", strings.ToLower(action.String()), roomPath), err)
+		return
+	}
+	if !res.Status.Ok {
+		reportFailure(fmt.Sprintf("mod: cannot %s in '%s'", strings.ToLower(action.String()), roomPath), res.Status.Message)
+		return
+	}
+	if action == pb.ModerationAction_SLOW_MODE {
+		fmt.Printf("Slow mode of %s set to %ds\n", roomPath, slowModeSeconds)
+		return
+	}
+	fmt.Printf("%s: %s in %s\n", strings.ToLower(action.String()), targetName, roomPath)
+}
+
+func newModUserCmd(use, short string, action pb.ModerationAction) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               use + " <room_path> <user>",
+		Short:             short,
+		Args:              cobra.ExactArgs(2),
+		ValidArgsFunction: PathCompletionFunc,
+		Run: func(cmd *cobra.Command, args []string) {
+			reason, _ := cmd.Flags().GetString("reason")
+			moderateRoom(resolveRoomPath(args[0]), action, args[1], 0, reason)
+		},
+	}
+	cmd.Flags().StringP("reason", "r", "", "Reason recorded in the moderation log")
+	return cmd
+}
+
+var modSlowModeCmd = &cobra.Command{
+	Use:               "slowmode <room_path> <seconds>",
+	Short:             "Limits each user to one message per <seconds> (0 turns it off).",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: PathCompletionFunc,
+	Run: func(cmd *cobra.Command, args []string) {
+		seconds, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid number of seconds: %s\n", args[1])
+			return
+		}
+		moderateRoom(resolveRoomPath(args[0]), pb.ModerationAction_SLOW_MODE, "", int32(seconds), "")
+	},
+}
+
+var modLimitsCmd = &cobra.Command{
+	Use:               "limits <room_path>",
+	Short:             "Sets the maximum length and line count of messages in a room (0 uses the server default).",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: PathCompletionFunc,
+	Run: func(cmd *cobra.Command, args []string) {
+		roomPath := resolveRoomPath(args[0])
+		maxLength, _ := cmd.Flags().GetInt32("max-length")
+		maxLines, _ := cmd.Flags().GetInt32("max-lines")
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := chatshClient.SetRoomLimits(ctx, &pb.SetRoomLimitsRequest{
+			RoomPath:         roomPath,
+			OwnerToken:       ownerToken,
+			MaxMessageLength: maxLength,
+			MaxMessageLines:  maxLines,
+		})
+		if err != nil {
+			reportError(fmt.Sprintf("mod: cannot set limits of '%s'", roomPath), err)
+			return
+		}
+		if !res.Status.Ok {
+			reportFailure(fmt.Sprintf("mod: cannot set limits of '%s'", roomPath), res.Status.Message)
+			return
+		}
+		fmt.Printf("Message limits of %s set to %d characters, %d lines\n", roomPath, maxLength, maxLines)
+	},
+}
+
+var modShowCmd = &cobra.Command{
+	Use:               "show <room_path>",
+	Short:             "Shows the bans, mutes, slow mode and moderation log of a room.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: PathCompletionFunc,
+	Run: func(cmd *cobra.Command, args []string) {
+		roomPath := resolveRoomPath(args[0])
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := chatshClient.GetRoomModeration(ctx, &pb.GetRoomModerationRequest{
+			RoomPath:   roomPath,
+			OwnerToken: ownerToken,
+		})
+		if err != nil {
+			reportError(fmt.Sprintf("mod: cannot show '%s'", roomPath), err)
+			return
+		}
+
+		slowMode := "off"
+		if res.SlowModeSeconds > 0 {
+			slowMode = fmt.Sprintf("%ds", res.SlowModeSeconds)
+		}
+		fmt.Printf("Slow mode: %s\n", slowMode)
+		fmt.Printf("Limits:    %d characters, %d lines per message\n", res.MaxMessageLength, res.MaxMessageLines)
+		fmt.Printf("Banned:    %s\n", strings.Join(res.BannedNames, ", "))
+		fmt.Printf("Muted:     %s\n", strings.Join(res.MutedNames, ", "))
+		if len(res.Logs) == 0 {
+			return
+		}
+		fmt.Println("Log:")
+		for _, entry := range res.Logs {
+			fmt.Printf("  %s %-10s %-9s %-12s %s\n",
+				entry.GetCreated().AsTime().Local().Format("Jan _2 15:04"),
+				entry.ActorName,
+				strings.ToLower(entry.Action.String()),
+				entry.TargetName,
+				entry.Detail)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(modCmd)
+
+	modCmd.AddCommand(
+		newModUserCmd("kick", "Ends the live chat sessions of a user in the room.", pb.ModerationAction_KICK),
+		newModUserCmd("ban", "Bans a user from joining or writing to the room.", pb.ModerationAction_BAN),
+		newModUserCmd("unban", "Lifts a ban.", pb.ModerationAction_UNBAN),
+		newModUserCmd("mute", "Prevents a user from writing to the room.", pb.ModerationAction_MUTE),
+		newModUserCmd("unmute", "Lifts a mute.", pb.ModerationAction_UNMUTE),
+		modSlowModeCmd,
+		modLimitsCmd,
+		modShowCmd,
+	)
+	modLimitsCmd.Flags().Int32("max-length", 0, "Maximum characters per message")
+	modLimitsCmd.Flags().Int32("max-lines", 0, "Maximum lines per message")
+}
@@ -6,7 +6,6 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 	"time"
 
@@ -57,14 +56,14 @@ var mvCmd = &cobra.Command{
 
 		res, err := chatshClient.MovePath(ctx, req)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error calling MovePath from %s to %s: %v\n", sourcePath, destinationPath, err)
+			reportError(transferErrorPrefix("mv", "move", sourcePath, err), err)
 			return
 		}
 
 		if res.Status.Ok {
 			fmt.Printf("Moved %s to %s\n", sourcePath, destinationPath)
 		} else {
-			fmt.Fprintf(os.Stderr, "Failed to move %s to %s: %s\n", sourcePath, destinationPath, res.Status.Message)
+			reportFailure(fmt.Sprintf("mv: cannot move '%s' to '%s'", sourcePath, destinationPath), res.Status.Message)
 		}
 	},
 }
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	maxRateLimitRetries = 3
+	retryAfterKey       = "retry-after"
+)
+
+// rateLimitRetryDelay reads how long the server asked us to wait, falling back to exponential backoff
+func rateLimitRetryDelay(err error, trailer metadata.MD, attempt int) time.Duration {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			return info.GetRetryDelay().AsDuration()
+		}
+	}
+	if values := trailer.Get(retryAfterKey); len(values) > 0 {
+		if seconds, err := strconv.Atoi(values[0]); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return time.Second << attempt
+}
+
+// retryOnRateLimit backs off and retries unary calls rejected with ResourceExhausted
+func retryOnRateLimit(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	for attempt := 0; ; attempt++ {
+		var trailer metadata.MD
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Trailer(&trailer))...)
+		if status.Code(err) != codes.ResourceExhausted || attempt >= maxRateLimitRetries {
+			return err
+		}
+
+		wait := rateLimitRetryDelay(err, trailer, attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Rate limited by server, retrying in %s...\n", wait.Round(time.Millisecond))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/spf13/cobra"
+)
+
+// retentionCmd represents the retention command
+var retentionCmd = &cobra.Command{
+	Use:   "retention <path>",
+	Short: "Shows or sets how long a room, or the rooms below a directory, keep messages.",
+	Long: `Shows the retention policy in effect for a room or directory, or sets its own.
+
+A policy expires messages older than --max-age, the oldest messages beyond
+--max-messages, and deletes rooms nothing was written to for --idle. A policy
+set on a directory applies to every room below it without a closer one.
+Setting a policy replaces the whole policy of the node, and needs its owner.
+Durations take the units of Go durations and d for days: 90m, 24h, 7d.
+
+  chatsh retention /tmp                      # show: max-age=1d idle=1d
+  chatsh retention general --max-messages 1000
+  chatsh retention /srv/logs --max-age 7d --idle 30d
+  chatsh retention general --keep            # keep everything, whatever the directory says
+  chatsh retention general --inherit         # back to the policy of the directory`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: PathCompletionFunc,
+	Run: func(cmd *cobra.Command, args []string) {
+		nodePath := resolveRoomPath(args[0])
+		inherit, _ := cmd.Flags().GetBool("inherit")
+		keep, _ := cmd.Flags().GetBool("keep")
+		maxMessages, _ := cmd.Flags().GetInt32("max-messages")
+		setting := inherit || keep
+		for _, name := range []string{"max-age", "max-messages", "idle"} {
+			setting = setting || cmd.Flags().Changed(name)
+		}
+		if !setting {
+			showRetention(nodePath)
+			return
+		}
+		if inherit && (keep || cmd.Flags().Changed("max-age") || cmd.Flags().Changed("max-messages") || cmd.Flags().Changed("idle")) {
+			fmt.Fprintln(os.Stderr, "retention: --inherit cannot be combined with other options")
+			exitStatus = unixErrors["INVALID_ARGUMENT"].code
+			return
+		}
+		var maxAge, idle int64
+		for _, flag := range []struct {
+			name    string
+			seconds *int64
+		}{{"max-age", &maxAge}, {"idle", &idle}} {
+			name, seconds := flag.name, flag.seconds
+			value, _ := cmd.Flags().GetString(name)
+			if value == "" {
+				continue
+			}
+			parsed, err := parseRetentionDuration(value)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "retention: invalid --%s: %v\n", name, err)
+				exitStatus = unixErrors["INVALID_ARGUMENT"].code
+				return
+			}
+			*seconds = parsed
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := chatshClient.SetRetention(ctx, &pb.SetRetentionRequest{
+			Path:          nodePath,
+			OwnerToken:    ownerToken, // ownerToken is loaded in root.go
+			MaxAgeSeconds: maxAge,
+			MaxMessages:   maxMessages,
+			IdleSeconds:   idle,
+			Inherit:       inherit,
+		})
+		if err != nil {
+			reportError(fmt.Sprintf("retention: cannot set the policy of '%s'", nodePath), err)
+			return
+		}
+		if !res.Status.Ok {
+			reportFailure(fmt.Sprintf("retention: cannot set the policy of '%s'", nodePath), res.Status.Message)
+			return
+		}
+		showRetention(nodePath)
+	},
+}
+
+func showRetention(nodePath string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	res, err := chatshClient.StatPath(ctx, &pb.StatPathRequest{Path: nodePath, OwnerToken: ownerToken})
+	if err != nil {
+		reportError(fmt.Sprintf("retention: cannot access '%s'", nodePath), err)
+		return
+	}
+	fmt.Printf("%s: %s\n", nodePath, formatRetention(res.GetNode().GetRetention(), nodePath))
+}
+
+// parseRetentionDuration parses a Go duration, or a whole number of days like "7d", into seconds
+func parseRetentionDuration(value string) (int64, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.ParseInt(days, 10, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a number of days", value)
+		}
+		return n * 24 * 60 * 60, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 || d%time.Second != 0 {
+		return 0, fmt.Errorf("%q is not a whole number of seconds", value)
+	}
+	return int64(d / time.Second), nil
+}
+
+// formatSeconds is the reverse of parseRetentionDuration, without the zero units: 1d, 90m, 1h30m
+func formatSeconds(seconds int64) string {
+	if seconds%(24*60*60) == 0 {
+		return fmt.Sprintf("%dd", seconds/(24*60*60))
+	}
+	s := (time.Duration(seconds) * time.Second).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// formatRetention describes the policy in effect for the node at nodePath, and where it comes
+// from when that is one of the directories above
+func formatRetention(retention *pb.Retention, nodePath string) string {
+	if retention == nil {
+		return "keep (no policy)"
+	}
+	var bounds []string
+	if retention.MaxAgeSeconds > 0 {
+		bounds = append(bounds, "max-age="+formatSeconds(retention.MaxAgeSeconds))
+	}
+	if retention.MaxMessages > 0 {
+		bounds = append(bounds, fmt.Sprintf("max-messages=%d", retention.MaxMessages))
+	}
+	if retention.IdleSeconds > 0 {
+		bounds = append(bounds, "idle="+formatSeconds(retention.IdleSeconds))
+	}
+	description := "keep"
+	if len(bounds) > 0 {
+		description = strings.Join(bounds, " ")
+	}
+	if retention.Source != filepath.Clean(nodePath) {
+		description += " (from " + retention.Source + ")"
+	}
+	return description
+}
+
+func init() {
+	rootCmd.AddCommand(retentionCmd)
+	retentionCmd.Flags().String("max-age", "", "Expire messages older than this")
+	retentionCmd.Flags().Int32("max-messages", 0, "Expire the oldest messages beyond this many")
+	retentionCmd.Flags().String("idle", "", "Delete rooms nothing was written to for this long")
+	retentionCmd.Flags().Bool("keep", false, "Keep everything, overriding the policy of the directories above")
+	retentionCmd.Flags().Bool("inherit", false, "Remove the policy, so that the one of the directory applies")
+}
@@ -6,7 +6,6 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 	"time"
 
@@ -19,8 +18,11 @@ import (
 var rmCmd = &cobra.Command{
 	Use:   "rm <path...>",
 	Short: "Removes files or directories.",
-	Long:  `Removes one or more files or directories on the chatsh server.`,
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Removes one or more files or directories on the chatsh server.
+
+Removed rooms and directories go to your trash, from which chatsh restore brings
+them back until the server purges them; see chatsh trash list.`,
+	Args: cobra.MinimumNArgs(1),
 	// Add ValidArgsFunction for path completion
 	ValidArgsFunction: PathCompletionFunc,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -47,14 +49,14 @@ var rmCmd = &cobra.Command{
 
 			res, err := chatshClient.DeletePath(ctx, req)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error calling DeletePath for %s: %v\n", targetPath, err)
+				reportError(fmt.Sprintf("rm: cannot remove '%s'", targetPath), err)
 				continue
 			}
 
 			if res.Status.Ok {
 				fmt.Printf("Removed: %s\n", targetPath)
 			} else {
-				fmt.Fprintf(os.Stderr, "Failed to remove %s: %s\n", targetPath, res.Status.Message)
+				reportFailure(fmt.Sprintf("rm: cannot remove '%s'", targetPath), res.Status.Message)
 			}
 		}
 	},
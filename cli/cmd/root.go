@@ -6,6 +6,7 @@ package cmd
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"math/rand"
 	"os"
@@ -40,6 +41,10 @@ const (
 	ownerTokenKey        = "owner_token"
 	grpcServerAddressKey = "grpc_server_address"
 	isSecureKey          = "is_secure"
+	tlsCertFileKey       = "tls_cert_file"
+	tlsKeyFileKey        = "tls_key_file"
+	tlsCAFileKey         = "tls_ca_file"
+	timeZoneKey          = "time_zone"
 	defaultServerAddress = "chatsh-app-1083612487436.asia-northeast1.run.app:443"
 )
 
@@ -70,10 +75,18 @@ to quickly create a Cobra application.`,
 		ownerToken = viper.GetString(ownerTokenKey)
 
 		credential := insecure.NewCredentials()
-		if isSecure {
-			credential = credentials.NewTLS(&tls.Config{})
+		// Unix sockets are protected by their file permissions and never use TLS
+		if isSecure && !strings.HasPrefix(grpcServerAddress, "unix:") {
+			tlsConfig, err := clientTLSConfig()
+			if err != nil {
+				return err
+			}
+			credential = credentials.NewTLS(tlsConfig)
 		}
-		conn, err := grpc.NewClient(grpcServerAddress, grpc.WithTransportCredentials(credential))
+		conn, err := grpc.NewClient(grpcServerAddress,
+			grpc.WithTransportCredentials(credential),
+			grpc.WithUnaryInterceptor(retryOnRateLimit),
+		)
 		if err != nil {
 			return fmt.Errorf("did not connect to gRPC server: %w", err)
 		}
@@ -111,6 +124,34 @@ to quickly create a Cobra application.`,
 	},
 }
 
+// clientTLSConfig builds the TLS settings from the --cert, --key and --ca options
+func clientTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	certFile, keyFile := viper.GetString(tlsCertFileKey), viper.GetString(tlsKeyFileKey)
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("--cert and --key must be given together")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if caFile := viper.GetString(tlsCAFileKey); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
 func PathCompletionFunc(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	return completionFuncHelper(cmd, args, toComplete, true)
 }
@@ -230,6 +271,49 @@ func completer(d prompt.Document) []prompt.Suggest {
 	return s
 }
 
+// sanitizeForTerminal drops control characters other than newline and tab,
+// which disarms escape sequences stored before the server started stripping them
+func sanitizeForTerminal(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if r < 0x20 || r == 0x7f || (r >= 0x80 && r <= 0x9f) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// markRoomRead moves the caller's read marker up to messageID, the last message shown, or to
+// the latest message of the room when it is zero
+func markRoomRead(roomPath string, messageID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	res, err := chatshClient.MarkRead(ctx, &pb.MarkReadRequest{
+		RoomPath:   roomPath,
+		OwnerToken: ownerToken,
+		MessageId:  messageID,
+	})
+	if err != nil {
+		return err
+	}
+	if !res.Status.Ok {
+		return fmt.Errorf("%s", res.Status.Message)
+	}
+	return nil
+}
+
+// lastMessageID is the ID of the newest of the messages, whatever order they are listed in
+func lastMessageID(messages []*pb.Message) int64 {
+	var last int64
+	for _, msg := range messages {
+		last = max(last, msg.GetId())
+	}
+	return last
+}
+
 func executor(line string) {
 	line = strings.TrimSpace(line)
 	if line == "" {
@@ -263,6 +347,9 @@ func Execute() {
 		if err := rootCmd.Execute(); err != nil {
 			os.Exit(1)
 		}
+		if exitStatus != 0 {
+			os.Exit(exitStatus)
+		}
 		if os.Args[1] == "completion" {
 			return
 		}
@@ -294,6 +381,7 @@ func Execute() {
      [ Interactive Shell for Smart Conversation ]
             v1.0.0 - Type 'exit' to quit
 		`)
+	printMentionBanner()
 	p := prompt.New(
 		executor,
 		completer,
@@ -309,13 +397,19 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default: $HOME/.chatsh.yaml)")
 	rootCmd.PersistentFlags().String("home-directory", "", "Home directory for the CLI")
 	rootCmd.PersistentFlags().String("owner-token", "", "Owner token for authentication with the chatsh server")
-	rootCmd.PersistentFlags().String("grpc-server", defaultServerAddress, "chatsh server")
+	rootCmd.PersistentFlags().String("grpc-server", defaultServerAddress, "chatsh server as host:port or unix:///path/to/socket")
 	rootCmd.PersistentFlags().Bool("is-secure", true, "Use secure gRPC connection (default: true)")
+	rootCmd.PersistentFlags().String("cert", "", "Client certificate file for servers that require mutual TLS")
+	rootCmd.PersistentFlags().String("key", "", "Private key file of the client certificate")
+	rootCmd.PersistentFlags().String("ca", "", "CA file used to verify the server instead of the system roots")
 
 	viper.BindPFlag(homeDirectoryKey, rootCmd.PersistentFlags().Lookup("home-directory"))
 	viper.BindPFlag(ownerTokenKey, rootCmd.PersistentFlags().Lookup("owner-token"))
 	viper.BindPFlag(grpcServerAddressKey, rootCmd.PersistentFlags().Lookup("grpc-server"))
 	viper.BindPFlag(isSecureKey, rootCmd.PersistentFlags().Lookup("is-secure"))
+	viper.BindPFlag(tlsCertFileKey, rootCmd.PersistentFlags().Lookup("cert"))
+	viper.BindPFlag(tlsKeyFileKey, rootCmd.PersistentFlags().Lookup("key"))
+	viper.BindPFlag(tlsCAFileKey, rootCmd.PersistentFlags().Lookup("ca"))
 	viper.SetDefault(homeDirectoryKey, "/home/chatsh")
 	viper.SetDefault(currentDirectoryKey, "/")
 	viper.SetDefault(ownerTokenKey, "")
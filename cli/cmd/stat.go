@@ -0,0 +1,50 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/spf13/cobra"
+)
+
+// statCmd represents the stat command
+var statCmd = &cobra.Command{
+	Use:   "stat <path>...",
+	Short: "Displays the status of rooms and directories.",
+	Long: `Displays the type, owner and modification time of rooms and directories,
+and the retention policy in effect for them.`,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: PathCompletionFunc,
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, arg := range args {
+			nodePath := resolveRoomPath(arg)
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+			res, err := chatshClient.StatPath(ctx, &pb.StatPathRequest{Path: nodePath, OwnerToken: ownerToken})
+			cancel()
+			if err != nil {
+				reportError(fmt.Sprintf("stat: cannot statx '%s'", nodePath), err)
+				continue
+			}
+			node := res.GetNode()
+			nodeType := "directory"
+			if node.GetType() == pb.NodeType_ROOM {
+				nodeType = "room"
+			}
+			fmt.Printf("     Path: %s\n", nodePath)
+			fmt.Printf("     Type: %s\n", nodeType)
+			fmt.Printf("    Owner: %s\n", node.GetOwnerName())
+			fmt.Printf("   Modify: %s\n", node.GetModified().AsTime().Local().Format("2006-01-02 15:04:05 -0700"))
+			fmt.Printf("Retention: %s\n", formatRetention(node.GetRetention(), nodePath))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statCmd)
+}
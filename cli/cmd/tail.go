@@ -7,6 +7,7 @@ import (
 	"log"
 	"path/filepath"
 	"slices"
+	"strings"
 	"time"
 
 	pb "github.com/ponyo877/chatsh/grpc"
@@ -40,14 +41,12 @@ var tailCmd = &cobra.Command{
 		listReq := &pb.ListMessagesRequest{RoomPath: targetPath, Limit: pastMessagesLimit}
 		pastMsgsResp, err := chatshClient.ListMessages(ctx, listReq)
 		if err != nil {
-			log.Printf("Warning: Failed to load past messages for %s: %v", targetPath, err)
+			reportError(fmt.Sprintf("tail: cannot open '%s' for reading", targetPath), err)
+			return
 		}
 		if len(pastMsgsResp.Messages) > 0 {
 			for _, msg := range slices.Backward(pastMsgsResp.Messages) {
-				fmt.Printf("[%s] %s: %s\n",
-					msg.GetCreated().AsTime().Format("15:04:05"),
-					msg.GetOwnerName(),
-					msg.GetTextContent())
+				printTailLine(msg.GetCreated().AsTime(), msg.GetOwnerName(), msg.GetTextContent())
 			}
 		}
 
@@ -60,7 +59,7 @@ var tailCmd = &cobra.Command{
 		// Send TailRequest
 		tailReq := &pb.ClientMessage{
 			Payload: &pb.ClientMessage_Tail{
-				Tail: &pb.Tail{RoomPath: targetPath},
+				Tail: &pb.Tail{RoomPath: targetPath, OwnerToken: ownerToken},
 			},
 		}
 		if err := stream.Send(tailReq); err != nil {
@@ -85,18 +84,21 @@ var tailCmd = &cobra.Command{
 					if ctx.Err() == context.Canceled {
 						return
 					}
-					log.Printf("Error receiving message: %v", err)
+					reportError("tail: "+targetPath, err)
 					return
 				}
-				fmt.Printf("[%s] %s: %s\n",
-					time.Now().Format("15:04:05"),
-					serverMsg.GetName(),
-					serverMsg.GetText())
+				printTailLine(time.Now(), serverMsg.GetName(), serverMsg.GetText())
 			}
 		}
 	},
 }
 
+// printTailLine prints a message with its continuation lines indented under the header
+func printTailLine(at time.Time, name, text string) {
+	text = strings.ReplaceAll(sanitizeForTerminal(text), "\n", "\n    ")
+	fmt.Printf("[%s] %s: %s\n", at.Format("15:04:05"), sanitizeForTerminal(name), text)
+}
+
 func init() {
 	rootCmd.AddCommand(tailCmd)
 	// No flags needed for tail for now, room_path is an argument.
@@ -0,0 +1,214 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/spf13/cobra"
+)
+
+// tarChunkSize is how much of an archive goes into each message of an import
+const tarChunkSize = 64 * 1024
+
+var conflictPolicies = map[string]pb.ConflictPolicy{
+	"fail":   pb.ConflictPolicy_CONFLICT_FAIL,
+	"skip":   pb.ConflictPolicy_CONFLICT_SKIP,
+	"rename": pb.ConflictPolicy_CONFLICT_RENAME,
+}
+
+// tarCmd represents the tar command
+var tarCmd = &cobra.Command{
+	Use:   "tar (-c <path> | -x [-C <directory>])",
+	Short: "Exports a directory or room to an archive, or imports one.",
+	Long: `Exports a room or directory with everything below it, messages and all, to a tar
+archive, or imports such an archive, from this server or another one:
+
+  chatsh tar -c /srv/proj > proj.tar
+  chatsh tar -x -C /srv < proj.tar
+  chatsh tar -x -C /srv --name proj-copy --on-conflict rename -f proj.tar
+
+The archive keeps the authors and times of the messages and the limits and
+retention policies of the rooms. What it creates belongs to you. An existing
+directory is merged into; when a room's path is taken, --on-conflict fails the
+whole import (the default), skips the room or renames it to name.1, name.2...`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		create, _ := cmd.Flags().GetBool("create")
+		extract, _ := cmd.Flags().GetBool("extract")
+		file, _ := cmd.Flags().GetString("file")
+		switch {
+		case create == extract:
+			fmt.Fprintln(os.Stderr, "tar: give one of -c and -x")
+			exitStatus = unixErrors["INVALID_ARGUMENT"].code
+		case create && len(args) != 1:
+			fmt.Fprintln(os.Stderr, "tar: -c needs the room or directory to export")
+			exitStatus = unixErrors["INVALID_ARGUMENT"].code
+		case extract && len(args) != 0:
+			fmt.Fprintln(os.Stderr, "tar: -x reads the archive from standard input or -f; give the directory with -C")
+			exitStatus = unixErrors["INVALID_ARGUMENT"].code
+		case create:
+			exportArchive(resolveRoomPath(args[0]), file)
+		default:
+			directory, _ := cmd.Flags().GetString("directory")
+			name, _ := cmd.Flags().GetString("name")
+			onConflict, _ := cmd.Flags().GetString("on-conflict")
+			policy, ok := conflictPolicies[onConflict]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "tar: --on-conflict must be fail, skip or rename, not '%s'\n", onConflict)
+				exitStatus = unixErrors["INVALID_ARGUMENT"].code
+				return
+			}
+			importArchive(resolveRoomPath(directory), name, policy, file)
+		}
+	},
+}
+
+func exportArchive(path, file string) {
+	if file == "" && isTerminal(os.Stdout) {
+		fmt.Fprintln(os.Stderr, "tar: refusing to write an archive to a terminal; redirect it or use -f")
+		exitStatus = unixErrors["INVALID_ARGUMENT"].code
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := chatshClient.Export(ctx, &pb.ExportRequest{Path: path, OwnerToken: ownerToken})
+	if err != nil {
+		reportError(fmt.Sprintf("tar: cannot export '%s'", path), err)
+		return
+	}
+	// The first message tells whether the export started at all
+	chunk, err := stream.Recv()
+	if err != nil {
+		reportError(fmt.Sprintf("tar: cannot export '%s'", path), err)
+		return
+	}
+
+	out := os.Stdout
+	if file != "" {
+		if out, err = os.Create(file); err != nil {
+			fmt.Fprintf(os.Stderr, "tar: cannot create '%s': %v\n", file, err)
+			exitStatus = 1
+			return
+		}
+		defer out.Close()
+	}
+	for {
+		if _, err := out.Write(chunk.Data); err != nil {
+			fmt.Fprintf(os.Stderr, "tar: cannot write archive: %v\n", err)
+			exitStatus = 1
+			return
+		}
+		chunk, err = stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			reportError(fmt.Sprintf("tar: export of '%s' interrupted", path), err)
+			if file != "" {
+				os.Remove(file)
+			}
+			return
+		}
+	}
+	if file != "" {
+		if err := out.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "tar: cannot write archive: %v\n", err)
+			exitStatus = 1
+		}
+	}
+}
+
+func importArchive(directory, name string, policy pb.ConflictPolicy, file string) {
+	in := os.Stdin
+	if file != "" {
+		var err error
+		if in, err = os.Open(file); err != nil {
+			fmt.Fprintf(os.Stderr, "tar: cannot open '%s': %v\n", file, err)
+			exitStatus = unixErrors["NOT_FOUND"].code
+			return
+		}
+		defer in.Close()
+	} else if isTerminal(os.Stdin) {
+		fmt.Fprintln(os.Stderr, "tar: refusing to read an archive from a terminal; redirect it or use -f")
+		exitStatus = unixErrors["INVALID_ARGUMENT"].code
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := chatshClient.Import(ctx)
+	if err != nil {
+		reportError(fmt.Sprintf("tar: cannot import into '%s'", directory), err)
+		return
+	}
+	req := &pb.ImportRequest{
+		DestinationPath: directory,
+		OwnerToken:      ownerToken, // ownerToken is loaded in root.go
+		Name:            name,
+		OnConflict:      policy,
+	}
+	buf := make([]byte, tarChunkSize)
+	first := true
+	for {
+		n, readErr := in.Read(buf)
+		if n > 0 || first {
+			req.Data = buf[:n]
+			// The server may stop reading early, and then tells why in CloseAndRecv
+			if err := stream.Send(req); err != nil {
+				break
+			}
+			req, first = &pb.ImportRequest{}, false
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			fmt.Fprintf(os.Stderr, "tar: cannot read archive: %v\n", readErr)
+			exitStatus = 1
+			return
+		}
+	}
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		if path := errorPath(err, ""); path != "" {
+			reportError(fmt.Sprintf("tar: cannot import '%s'", path), err)
+			return
+		}
+		reportError(fmt.Sprintf("tar: cannot import into '%s'", directory), err)
+		return
+	}
+	if !res.Status.Ok {
+		reportFailure(fmt.Sprintf("tar: cannot import into '%s'", directory), res.Status.Message)
+		return
+	}
+	for _, path := range res.Skipped {
+		fmt.Printf("Skipped %s: %s\n", path, unixErrors["ALREADY_EXISTS"].message)
+	}
+	taken := make([]string, 0, len(res.Renamed))
+	for path := range res.Renamed {
+		taken = append(taken, path)
+	}
+	sort.Strings(taken)
+	for _, path := range taken {
+		fmt.Printf("Renamed %s to %s\n", path, res.Renamed[path])
+	}
+	fmt.Printf("Imported %s: %d directories, %d rooms, %d messages\n", res.Path, res.Directories, res.Rooms, res.Messages)
+}
+
+func init() {
+	rootCmd.AddCommand(tarCmd)
+	tarCmd.Flags().BoolP("create", "c", false, "Export the room or directory given")
+	tarCmd.Flags().BoolP("extract", "x", false, "Import an archive")
+	tarCmd.Flags().StringP("file", "f", "", "Write or read the archive in this file instead of standard output or input")
+	tarCmd.Flags().StringP("directory", "C", ".", "Import into this directory")
+	tarCmd.Flags().String("name", "", "Import the root of the archive under this name")
+	tarCmd.Flags().String("on-conflict", "fail", "What to do with rooms whose path is taken: fail, skip or rename")
+}
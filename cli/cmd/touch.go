@@ -6,13 +6,14 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"os"
 	"path/filepath"
 	"time"
 
 	pb "github.com/ponyo877/chatsh/grpc"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // touchCmd represents the touch command
@@ -49,7 +50,11 @@ Standard touch behavior of updating timestamps for existing files is not directl
 
 			res, err := chatshClient.CreateRoom(ctx, req)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error calling CreateRoom for %s: %v\n", targetPath, err)
+				// Like touch(1), an existing room is left as it is
+				if status.Code(err) == codes.AlreadyExists {
+					continue
+				}
+				reportError(fmt.Sprintf("touch: cannot touch '%s'", targetPath), err)
 				continue
 			}
 
@@ -59,7 +64,7 @@ Standard touch behavior of updating timestamps for existing files is not directl
 			} else {
 				// If server indicates "already exists" as not an error, this message might be misleading.
 				// However, if it's a genuine failure to create, it's appropriate.
-				fmt.Fprintf(os.Stderr, "Failed to touch (create room) %s: %s\n", targetPath, res.Status.Message)
+				reportFailure(fmt.Sprintf("touch: cannot touch '%s'", targetPath), res.Status.Message)
 			}
 		}
 	},
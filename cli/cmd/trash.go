@@ -0,0 +1,136 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// trashCmd represents the trash command
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Lists or empties the rooms and directories you removed.",
+	Long: `rm moves rooms and directories to your trash, messages and all, where they stay
+until the server purges them. Bring one back with chatsh restore <id>.`,
+}
+
+var trashListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "Lists what you removed, the most recent first.",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := chatshClient.ListTrash(ctx, &pb.ListTrashRequest{OwnerToken: ownerToken})
+		if err != nil {
+			reportError("trash: cannot list trash", err)
+			return
+		}
+		if len(res.Entries) == 0 {
+			fmt.Println("Trash is empty.")
+			return
+		}
+		for _, entry := range res.Entries {
+			kind, contents := "dir", ""
+			if entry.Type == pb.NodeType_ROOM {
+				kind, contents = "room", fmt.Sprintf("%d messages", entry.MessageCount)
+			}
+			purge := ""
+			if entry.Purge != nil {
+				purge = "purged " + entry.Purge.AsTime().Local().Format("Jan _2 15:04")
+			}
+			deleted := entry.Deleted.AsTime().Local().Format("Jan _2 15:04")
+			fmt.Printf("%3d %-4s %s  %-24s %-14s %s\n", entry.Id, kind, deleted, entry.Path, contents, purge)
+		}
+	},
+}
+
+var trashEmptyCmd = &cobra.Command{
+	Use:   "empty",
+	Short: "Deletes everything in your trash for good.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := chatshClient.EmptyTrash(ctx, &pb.EmptyTrashRequest{OwnerToken: ownerToken})
+		if err != nil {
+			reportError("trash: cannot empty trash", err)
+			return
+		}
+		if !res.Status.Ok {
+			reportFailure("trash: cannot empty trash", res.Status.Message)
+			return
+		}
+		fmt.Printf("Deleted %d entries for good\n", res.Purged)
+	},
+}
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore <id>",
+	Short: "Brings back a room or directory from your trash.",
+	Long: `Restores the trash entry with the id shown by chatsh trash list to the path it
+was removed from. When the directory it was in is gone, it goes to /lost+found.
+When something else took its path, restore it elsewhere with --to, which works
+like the destination of mv:
+
+  chatsh restore 3
+  chatsh restore 3 --to /tmp/general.old`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || id < 1 {
+			fmt.Fprintf(os.Stderr, "restore: invalid trash entry id: %s\n", args[0])
+			exitStatus = unixErrors["INVALID_ARGUMENT"].code
+			return
+		}
+		destination, _ := cmd.Flags().GetString("to")
+		if destination != "" {
+			destination = resolveRoomPath(destination)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := chatshClient.RestoreTrash(ctx, &pb.RestoreTrashRequest{
+			Id:              id,
+			OwnerToken:      ownerToken, // ownerToken is loaded in root.go
+			DestinationPath: destination,
+		})
+		if err != nil {
+			if path := errorPath(err, ""); path != "" {
+				reportError(fmt.Sprintf("restore: cannot restore to '%s'", path), err)
+				if status.Code(err) == codes.AlreadyExists && destination == "" {
+					fmt.Fprintf(os.Stderr, "restore: use --to to restore entry %d elsewhere\n", id)
+				}
+				return
+			}
+			reportError(fmt.Sprintf("restore: cannot restore entry %d", id), err)
+			return
+		}
+		if !res.Status.Ok {
+			reportFailure(fmt.Sprintf("restore: cannot restore entry %d", id), res.Status.Message)
+			return
+		}
+		fmt.Printf("Restored: %s\n", res.Path)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(trashCmd, restoreCmd)
+	trashCmd.AddCommand(trashListCmd, trashEmptyCmd)
+	restoreCmd.Flags().String("to", "", "Restore to this path, or into this directory, instead")
+}
@@ -97,20 +97,21 @@ func runChatUITview(client pb.ChatshServiceClient, userName string, roomPath str
 	defer cancel()
 
 	// Load past messages first
-	pastMessagesLimit := int32(50)
-	// Use ListMessages as per user feedback and current proto definition
-	pastMsgsResp, err := client.ListMessages(ctx, &pb.ListMessagesRequest{RoomPath: roomPath, Limit: pastMessagesLimit})
+	pastMsgsResp, err := listPastMessages(ctx, client, roomPath)
 	if err != nil {
 		fmt.Fprintf(textView, "[red]Error loading past messages: %v\n", err)
 	} else {
-		for _, msg := range slices.Backward(pastMsgsResp.Messages) {
-			fmt.Fprintf(textView, "[white][%s] [blue]%s[white]: %s\n",
-				msg.GetCreated().AsTime().Format("15:04:05"),
-				msg.GetOwnerName(),
-				msg.GetTextContent())
+		showPastMessages(textView, pastMsgsResp, true)
+		// Everything shown so far counts as read, but not what was posted since it was listed
+		if lastID := lastMessageID(pastMsgsResp.GetMessages()); lastID > 0 {
+			if err := markRoomRead(roomPath, lastID); err != nil {
+				fmt.Fprintf(textView, "[red]Failed to mark room as read: %v\n", err)
+			}
 		}
 	}
 	textView.ScrollToEnd()
+	// Leaving the room, everything received while it was open has been shown as well
+	defer markRoomRead(roomPath, 0)
 
 	stream, err := client.StreamMessage(ctx)
 	if err != nil {
@@ -120,7 +121,7 @@ func runChatUITview(client pb.ChatshServiceClient, userName string, roomPath str
 	// Join the room
 	joinMsg := &pb.ClientMessage{
 		Payload: &pb.ClientMessage_Join{
-			Join: &pb.Join{Name: userName, Room: roomPath},
+			Join: &pb.Join{Name: userName, Room: roomPath, OwnerToken: ownerToken},
 		},
 	}
 	if err := stream.Send(joinMsg); err != nil {
@@ -149,11 +150,34 @@ func runChatUITview(client pb.ChatshServiceClient, userName string, roomPath str
 					cancel()
 					return
 				}
+				// Messages removed by the room's retention policy vanish: the history is drawn anew
+				if len(serverMsg.GetExpiredMessageIds()) > 0 {
+					pastMsgsResp, err := listPastMessages(ctx, client, roomPath)
+					app.QueueUpdateDraw(func() {
+						if err != nil {
+							fmt.Fprintf(textView, "[red]Error reloading messages: %v\n", err)
+							return
+						}
+						textView.Clear()
+						showPastMessages(textView, pastMsgsResp, false)
+						fmt.Fprintf(textView, "[gray]%s\n", tview.Escape(sanitizeForTerminal(serverMsg.GetText())))
+						textView.ScrollToEnd()
+					})
+					continue
+				}
+				// A rejected message was not posted; the server says why and when to try again
+				if serverMsg.GetRejection() != nil {
+					app.QueueUpdateDraw(func() {
+						fmt.Fprintf(textView, "[red]%s\n", tview.Escape(sanitizeForTerminal(serverMsg.GetText())))
+						textView.ScrollToEnd()
+					})
+					continue
+				}
 				app.QueueUpdateDraw(func() {
 					fmt.Fprintf(textView, "[white][%s] [blue]%s[white]: %s\n",
 						time.Now().Format("15:04:05"),
-						serverMsg.GetName(),
-						serverMsg.GetText())
+						tview.Escape(sanitizeForTerminal(serverMsg.GetName())),
+						tview.Escape(sanitizeForTerminal(serverMsg.GetText())))
 					textView.ScrollToEnd()
 				})
 			}
@@ -200,3 +224,24 @@ func runChatUITview(client pb.ChatshServiceClient, userName string, roomPath str
 	cancel()
 	return nil
 }
+
+// pastMessagesLimit is how many of the latest messages the chat UI shows on entering a room
+const pastMessagesLimit = 50
+
+func listPastMessages(ctx context.Context, client pb.ChatshServiceClient, roomPath string) (*pb.ListMessagesResponse, error) {
+	return client.ListMessages(ctx, &pb.ListMessagesRequest{RoomPath: roomPath, Limit: pastMessagesLimit, OwnerToken: ownerToken})
+}
+
+// showPastMessages writes the messages oldest first, with a divider before the unread ones if asked
+func showPastMessages(textView *tview.TextView, res *pb.ListMessagesResponse, divider bool) {
+	for _, msg := range slices.Backward(res.Messages) {
+		if divider && msg.GetId() > res.GetLastReadMessageId() {
+			fmt.Fprintln(textView, "[red]──────── new messages ────────")
+			divider = false
+		}
+		fmt.Fprintf(textView, "[white][%s] [blue]%s[white]: %s\n",
+			msg.GetCreated().AsTime().Format("15:04:05"),
+			tview.Escape(sanitizeForTerminal(msg.GetOwnerName())),
+			tview.Escape(sanitizeForTerminal(msg.GetTextContent())))
+	}
+}
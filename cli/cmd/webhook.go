@@ -0,0 +1,199 @@
+/*
+Copyright © 2025 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/spf13/cobra"
+)
+
+// webhookEventNames maps the names accepted by --events to their protobuf values
+var webhookEventNames = map[string]pb.WebhookEvent{
+	"message": pb.WebhookEvent_WEBHOOK_MESSAGE,
+	"create":  pb.WebhookEvent_WEBHOOK_CREATE,
+	"delete":  pb.WebhookEvent_WEBHOOK_DELETE,
+	"move":    pb.WebhookEvent_WEBHOOK_MOVE,
+}
+
+func webhookEventName(event pb.WebhookEvent) string {
+	return strings.ToLower(strings.TrimPrefix(event.String(), "WEBHOOK_"))
+}
+
+// webhookCmd represents the webhook command
+var webhookCmd = &cobra.Command{
+	Use:     "webhook",
+	Aliases: []string{"hook"},
+	Short:   "Posts the events of your rooms and directories to HTTP endpoints.",
+	Long: `Webhooks POST a JSON payload to a URL whenever a message is written to a room,
+or a node is created, deleted or moved below a directory you own.
+
+Every request carries an X-Chatsh-Signature header, "sha256=" followed by the
+hex HMAC-SHA256 of the body keyed with the webhook's secret, and an
+X-Chatsh-Delivery id. Failed deliveries are retried with backoff, so receivers
+should ignore ids they have already seen.`,
+}
+
+func parseWebhookID(arg string) (int64, bool) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || id < 1 {
+		reportFailure("webhook", fmt.Sprintf("invalid webhook id: %s", arg))
+		return 0, false
+	}
+	return id, true
+}
+
+var webhookAddCmd = &cobra.Command{
+	Use:               "add <path> <url>",
+	Short:             "Registers a webhook on a room or on every node below a directory.",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: PathCompletionFunc,
+	Run: func(cmd *cobra.Command, args []string) {
+		path := resolveRoomPath(args[0])
+		secret, _ := cmd.Flags().GetString("secret")
+		eventNames, _ := cmd.Flags().GetStringSlice("events")
+		pathGlob, _ := cmd.Flags().GetString("glob")
+
+		var events []pb.WebhookEvent
+		for _, name := range eventNames {
+			event, ok := webhookEventNames[strings.ToLower(name)]
+			if !ok {
+				reportFailure("webhook", fmt.Sprintf("unknown event '%s' (message, create, delete or move)", name))
+				return
+			}
+			events = append(events, event)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := chatshClient.CreateWebhook(ctx, &pb.CreateWebhookRequest{
+			Path:       path,
+			OwnerToken: ownerToken, // ownerToken is loaded in root.go
+			Url:        args[1],
+			Secret:     secret,
+			Events:     events,
+			PathGlob:   pathGlob,
+		})
+		if err != nil {
+			reportError(fmt.Sprintf("webhook: cannot add a webhook to '%s'", path), err)
+			return
+		}
+		if !res.Status.Ok {
+			reportFailure(fmt.Sprintf("webhook: cannot add a webhook to '%s'", path), res.Status.Message)
+			return
+		}
+		fmt.Printf("Webhook %d added to %s\n", res.Webhook.Id, path)
+		if secret == "" {
+			fmt.Printf("Secret: %s\n", res.Secret)
+			fmt.Println("Keep it now: the secret is not shown again.")
+		}
+	},
+}
+
+var webhookListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "Lists your webhooks with their queued and failed deliveries.",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := chatshClient.ListWebhooks(ctx, &pb.ListWebhooksRequest{OwnerToken: ownerToken})
+		if err != nil {
+			reportError("webhook: cannot list webhooks", err)
+			return
+		}
+		if len(res.Webhooks) == 0 {
+			fmt.Println("No webhooks.")
+			return
+		}
+		for _, webhook := range res.Webhooks {
+			events := "all"
+			if len(webhook.Events) > 0 {
+				names := make([]string, len(webhook.Events))
+				for i, event := range webhook.Events {
+					names[i] = webhookEventName(event)
+				}
+				events = strings.Join(names, ",")
+			}
+			filter := webhook.Path
+			if webhook.PathGlob != "" {
+				filter += " (" + webhook.PathGlob + ")"
+			}
+			fmt.Printf("%3d %-24s %-22s %s\n", webhook.Id, filter, events, webhook.Url)
+			if webhook.PendingDeliveries > 0 || webhook.FailedDeliveries > 0 {
+				fmt.Printf("    %d pending, %d failed deliveries\n", webhook.PendingDeliveries, webhook.FailedDeliveries)
+			}
+		}
+	},
+}
+
+var webhookTestCmd = &cobra.Command{
+	Use:   "test <id>",
+	Short: "Sends a ping event to a webhook right away and shows the response.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, ok := parseWebhookID(args[0])
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		res, err := chatshClient.TestWebhook(ctx, &pb.TestWebhookRequest{Id: id, OwnerToken: ownerToken})
+		if err != nil {
+			reportError(fmt.Sprintf("webhook: cannot test webhook %d", id), err)
+			return
+		}
+		if !res.Status.Ok {
+			reportFailure(fmt.Sprintf("webhook: test of webhook %d failed", id), res.Status.Message)
+			return
+		}
+		fmt.Printf("Webhook %d answered %d in %dms\n", id, res.StatusCode, res.DurationMs)
+	},
+}
+
+var webhookDeleteCmd = &cobra.Command{
+	Use:     "delete <id>",
+	Aliases: []string{"rm"},
+	Short:   "Deletes a webhook and drops its queued deliveries.",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, ok := parseWebhookID(args[0])
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		res, err := chatshClient.DeleteWebhook(ctx, &pb.DeleteWebhookRequest{Id: id, OwnerToken: ownerToken})
+		if err != nil {
+			reportError(fmt.Sprintf("webhook: cannot delete webhook %d", id), err)
+			return
+		}
+		if !res.Status.Ok {
+			reportFailure(fmt.Sprintf("webhook: cannot delete webhook %d", id), res.Status.Message)
+			return
+		}
+		fmt.Printf("Webhook %d deleted\n", id)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(webhookCmd)
+
+	webhookCmd.AddCommand(webhookAddCmd, webhookListCmd, webhookTestCmd, webhookDeleteCmd)
+	webhookAddCmd.Flags().String("secret", "", "HMAC secret; generated and shown once when empty")
+	webhookAddCmd.Flags().StringSlice("events", nil, "Events to send: message, create, delete, move (default all)")
+	webhookAddCmd.Flags().String("glob", "", "Only send events for paths matching this pattern, relative to <path> unless it starts with /")
+}
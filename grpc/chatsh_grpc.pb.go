@@ -19,19 +19,42 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	ChatshService_CheckDirectoryExists_FullMethodName = "/fs.ChatshService/CheckDirectoryExists"
-	ChatshService_GetConfig_FullMethodName            = "/fs.ChatshService/GetConfig"
-	ChatshService_SetConfig_FullMethodName            = "/fs.ChatshService/SetConfig"
-	ChatshService_CreateRoom_FullMethodName           = "/fs.ChatshService/CreateRoom"
-	ChatshService_CreateDirectory_FullMethodName      = "/fs.ChatshService/CreateDirectory"
-	ChatshService_DeletePath_FullMethodName           = "/fs.ChatshService/DeletePath"
-	ChatshService_CopyPath_FullMethodName             = "/fs.ChatshService/CopyPath"
-	ChatshService_MovePath_FullMethodName             = "/fs.ChatshService/MovePath"
-	ChatshService_ListNodes_FullMethodName            = "/fs.ChatshService/ListNodes"
-	ChatshService_StreamMessage_FullMethodName        = "/fs.ChatshService/StreamMessage"
-	ChatshService_SearchMessage_FullMethodName        = "/fs.ChatshService/SearchMessage"
-	ChatshService_WriteMessage_FullMethodName         = "/fs.ChatshService/WriteMessage"
-	ChatshService_ListMessages_FullMethodName         = "/fs.ChatshService/ListMessages"
+	ChatshService_CheckDirectoryExists_FullMethodName  = "/fs.ChatshService/CheckDirectoryExists"
+	ChatshService_GetConfig_FullMethodName             = "/fs.ChatshService/GetConfig"
+	ChatshService_SetConfig_FullMethodName             = "/fs.ChatshService/SetConfig"
+	ChatshService_CreateRoom_FullMethodName            = "/fs.ChatshService/CreateRoom"
+	ChatshService_CreateDirectory_FullMethodName       = "/fs.ChatshService/CreateDirectory"
+	ChatshService_DeletePath_FullMethodName            = "/fs.ChatshService/DeletePath"
+	ChatshService_CopyPath_FullMethodName              = "/fs.ChatshService/CopyPath"
+	ChatshService_MovePath_FullMethodName              = "/fs.ChatshService/MovePath"
+	ChatshService_ListNodes_FullMethodName             = "/fs.ChatshService/ListNodes"
+	ChatshService_StatPath_FullMethodName              = "/fs.ChatshService/StatPath"
+	ChatshService_StreamMessage_FullMethodName         = "/fs.ChatshService/StreamMessage"
+	ChatshService_SearchMessage_FullMethodName         = "/fs.ChatshService/SearchMessage"
+	ChatshService_WriteMessage_FullMethodName          = "/fs.ChatshService/WriteMessage"
+	ChatshService_ListMessages_FullMethodName          = "/fs.ChatshService/ListMessages"
+	ChatshService_ListMentions_FullMethodName          = "/fs.ChatshService/ListMentions"
+	ChatshService_MarkMentionsRead_FullMethodName      = "/fs.ChatshService/MarkMentionsRead"
+	ChatshService_MarkRead_FullMethodName              = "/fs.ChatshService/MarkRead"
+	ChatshService_ModerateRoom_FullMethodName          = "/fs.ChatshService/ModerateRoom"
+	ChatshService_GetRoomModeration_FullMethodName     = "/fs.ChatshService/GetRoomModeration"
+	ChatshService_SetRoomLimits_FullMethodName         = "/fs.ChatshService/SetRoomLimits"
+	ChatshService_CreateWebhook_FullMethodName         = "/fs.ChatshService/CreateWebhook"
+	ChatshService_ListWebhooks_FullMethodName          = "/fs.ChatshService/ListWebhooks"
+	ChatshService_TestWebhook_FullMethodName           = "/fs.ChatshService/TestWebhook"
+	ChatshService_DeleteWebhook_FullMethodName         = "/fs.ChatshService/DeleteWebhook"
+	ChatshService_CreateIncomingWebhook_FullMethodName = "/fs.ChatshService/CreateIncomingWebhook"
+	ChatshService_ListIncomingWebhooks_FullMethodName  = "/fs.ChatshService/ListIncomingWebhooks"
+	ChatshService_RotateIncomingWebhook_FullMethodName = "/fs.ChatshService/RotateIncomingWebhook"
+	ChatshService_RevokeIncomingWebhook_FullMethodName = "/fs.ChatshService/RevokeIncomingWebhook"
+	ChatshService_SetRetention_FullMethodName          = "/fs.ChatshService/SetRetention"
+	ChatshService_ListTrash_FullMethodName             = "/fs.ChatshService/ListTrash"
+	ChatshService_RestoreTrash_FullMethodName          = "/fs.ChatshService/RestoreTrash"
+	ChatshService_EmptyTrash_FullMethodName            = "/fs.ChatshService/EmptyTrash"
+	ChatshService_Backup_FullMethodName                = "/fs.ChatshService/Backup"
+	ChatshService_Export_FullMethodName                = "/fs.ChatshService/Export"
+	ChatshService_Import_FullMethodName                = "/fs.ChatshService/Import"
+	ChatshService_ImportMessages_FullMethodName        = "/fs.ChatshService/ImportMessages"
 )
 
 // ChatshServiceClient is the client API for ChatshService service.
@@ -47,10 +70,38 @@ type ChatshServiceClient interface {
 	CopyPath(ctx context.Context, in *CopyPathRequest, opts ...grpc.CallOption) (*CopyPathResponse, error)
 	MovePath(ctx context.Context, in *MovePathRequest, opts ...grpc.CallOption) (*MovePathResponse, error)
 	ListNodes(ctx context.Context, in *ListNodesRequest, opts ...grpc.CallOption) (*ListNodesResponse, error)
+	StatPath(ctx context.Context, in *StatPathRequest, opts ...grpc.CallOption) (*StatPathResponse, error)
 	StreamMessage(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ClientMessage, ServerMessage], error)
 	SearchMessage(ctx context.Context, in *SearchMessageRequest, opts ...grpc.CallOption) (*SearchMessageResponse, error)
 	WriteMessage(ctx context.Context, in *WriteMessageRequest, opts ...grpc.CallOption) (*WriteMessageResponse, error)
 	ListMessages(ctx context.Context, in *ListMessagesRequest, opts ...grpc.CallOption) (*ListMessagesResponse, error)
+	ListMentions(ctx context.Context, in *ListMentionsRequest, opts ...grpc.CallOption) (*ListMentionsResponse, error)
+	MarkMentionsRead(ctx context.Context, in *MarkMentionsReadRequest, opts ...grpc.CallOption) (*MarkMentionsReadResponse, error)
+	MarkRead(ctx context.Context, in *MarkReadRequest, opts ...grpc.CallOption) (*MarkReadResponse, error)
+	ModerateRoom(ctx context.Context, in *ModerateRoomRequest, opts ...grpc.CallOption) (*ModerateRoomResponse, error)
+	GetRoomModeration(ctx context.Context, in *GetRoomModerationRequest, opts ...grpc.CallOption) (*GetRoomModerationResponse, error)
+	SetRoomLimits(ctx context.Context, in *SetRoomLimitsRequest, opts ...grpc.CallOption) (*SetRoomLimitsResponse, error)
+	CreateWebhook(ctx context.Context, in *CreateWebhookRequest, opts ...grpc.CallOption) (*CreateWebhookResponse, error)
+	ListWebhooks(ctx context.Context, in *ListWebhooksRequest, opts ...grpc.CallOption) (*ListWebhooksResponse, error)
+	TestWebhook(ctx context.Context, in *TestWebhookRequest, opts ...grpc.CallOption) (*TestWebhookResponse, error)
+	DeleteWebhook(ctx context.Context, in *DeleteWebhookRequest, opts ...grpc.CallOption) (*DeleteWebhookResponse, error)
+	CreateIncomingWebhook(ctx context.Context, in *CreateIncomingWebhookRequest, opts ...grpc.CallOption) (*CreateIncomingWebhookResponse, error)
+	ListIncomingWebhooks(ctx context.Context, in *ListIncomingWebhooksRequest, opts ...grpc.CallOption) (*ListIncomingWebhooksResponse, error)
+	RotateIncomingWebhook(ctx context.Context, in *RotateIncomingWebhookRequest, opts ...grpc.CallOption) (*RotateIncomingWebhookResponse, error)
+	RevokeIncomingWebhook(ctx context.Context, in *RevokeIncomingWebhookRequest, opts ...grpc.CallOption) (*RevokeIncomingWebhookResponse, error)
+	SetRetention(ctx context.Context, in *SetRetentionRequest, opts ...grpc.CallOption) (*SetRetentionResponse, error)
+	ListTrash(ctx context.Context, in *ListTrashRequest, opts ...grpc.CallOption) (*ListTrashResponse, error)
+	RestoreTrash(ctx context.Context, in *RestoreTrashRequest, opts ...grpc.CallOption) (*RestoreTrashResponse, error)
+	EmptyTrash(ctx context.Context, in *EmptyTrashRequest, opts ...grpc.CallOption) (*EmptyTrashResponse, error)
+	// Backup streams a consistent snapshot of the database to an admin
+	Backup(ctx context.Context, in *BackupRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BackupChunk], error)
+	// Export streams a tar archive of a room or directory and everything below it
+	Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ArchiveChunk], error)
+	// Import recreates an archive made by Export below a directory
+	Import(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImportRequest, ImportResponse], error)
+	// ImportMessages adds an admin's messages from other chat systems with their own authors and
+	// times; messages whose keys a room has seen are skipped, so an import can run again
+	ImportMessages(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImportMessagesRequest, ImportMessagesResponse], error)
 }
 
 type chatshServiceClient struct {
@@ -151,6 +202,16 @@ func (c *chatshServiceClient) ListNodes(ctx context.Context, in *ListNodesReques
 	return out, nil
 }
 
+func (c *chatshServiceClient) StatPath(ctx context.Context, in *StatPathRequest, opts ...grpc.CallOption) (*StatPathResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StatPathResponse)
+	err := c.cc.Invoke(ctx, ChatshService_StatPath_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *chatshServiceClient) StreamMessage(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ClientMessage, ServerMessage], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	stream, err := c.cc.NewStream(ctx, &ChatshService_ServiceDesc.Streams[0], ChatshService_StreamMessage_FullMethodName, cOpts...)
@@ -194,6 +255,250 @@ func (c *chatshServiceClient) ListMessages(ctx context.Context, in *ListMessages
 	return out, nil
 }
 
+func (c *chatshServiceClient) ListMentions(ctx context.Context, in *ListMentionsRequest, opts ...grpc.CallOption) (*ListMentionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListMentionsResponse)
+	err := c.cc.Invoke(ctx, ChatshService_ListMentions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) MarkMentionsRead(ctx context.Context, in *MarkMentionsReadRequest, opts ...grpc.CallOption) (*MarkMentionsReadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MarkMentionsReadResponse)
+	err := c.cc.Invoke(ctx, ChatshService_MarkMentionsRead_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) MarkRead(ctx context.Context, in *MarkReadRequest, opts ...grpc.CallOption) (*MarkReadResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MarkReadResponse)
+	err := c.cc.Invoke(ctx, ChatshService_MarkRead_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) ModerateRoom(ctx context.Context, in *ModerateRoomRequest, opts ...grpc.CallOption) (*ModerateRoomResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ModerateRoomResponse)
+	err := c.cc.Invoke(ctx, ChatshService_ModerateRoom_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) GetRoomModeration(ctx context.Context, in *GetRoomModerationRequest, opts ...grpc.CallOption) (*GetRoomModerationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetRoomModerationResponse)
+	err := c.cc.Invoke(ctx, ChatshService_GetRoomModeration_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) SetRoomLimits(ctx context.Context, in *SetRoomLimitsRequest, opts ...grpc.CallOption) (*SetRoomLimitsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetRoomLimitsResponse)
+	err := c.cc.Invoke(ctx, ChatshService_SetRoomLimits_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) CreateWebhook(ctx context.Context, in *CreateWebhookRequest, opts ...grpc.CallOption) (*CreateWebhookResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateWebhookResponse)
+	err := c.cc.Invoke(ctx, ChatshService_CreateWebhook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) ListWebhooks(ctx context.Context, in *ListWebhooksRequest, opts ...grpc.CallOption) (*ListWebhooksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListWebhooksResponse)
+	err := c.cc.Invoke(ctx, ChatshService_ListWebhooks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) TestWebhook(ctx context.Context, in *TestWebhookRequest, opts ...grpc.CallOption) (*TestWebhookResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TestWebhookResponse)
+	err := c.cc.Invoke(ctx, ChatshService_TestWebhook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) DeleteWebhook(ctx context.Context, in *DeleteWebhookRequest, opts ...grpc.CallOption) (*DeleteWebhookResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteWebhookResponse)
+	err := c.cc.Invoke(ctx, ChatshService_DeleteWebhook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) CreateIncomingWebhook(ctx context.Context, in *CreateIncomingWebhookRequest, opts ...grpc.CallOption) (*CreateIncomingWebhookResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateIncomingWebhookResponse)
+	err := c.cc.Invoke(ctx, ChatshService_CreateIncomingWebhook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) ListIncomingWebhooks(ctx context.Context, in *ListIncomingWebhooksRequest, opts ...grpc.CallOption) (*ListIncomingWebhooksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListIncomingWebhooksResponse)
+	err := c.cc.Invoke(ctx, ChatshService_ListIncomingWebhooks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) RotateIncomingWebhook(ctx context.Context, in *RotateIncomingWebhookRequest, opts ...grpc.CallOption) (*RotateIncomingWebhookResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RotateIncomingWebhookResponse)
+	err := c.cc.Invoke(ctx, ChatshService_RotateIncomingWebhook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) RevokeIncomingWebhook(ctx context.Context, in *RevokeIncomingWebhookRequest, opts ...grpc.CallOption) (*RevokeIncomingWebhookResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RevokeIncomingWebhookResponse)
+	err := c.cc.Invoke(ctx, ChatshService_RevokeIncomingWebhook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) SetRetention(ctx context.Context, in *SetRetentionRequest, opts ...grpc.CallOption) (*SetRetentionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetRetentionResponse)
+	err := c.cc.Invoke(ctx, ChatshService_SetRetention_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) ListTrash(ctx context.Context, in *ListTrashRequest, opts ...grpc.CallOption) (*ListTrashResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTrashResponse)
+	err := c.cc.Invoke(ctx, ChatshService_ListTrash_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) RestoreTrash(ctx context.Context, in *RestoreTrashRequest, opts ...grpc.CallOption) (*RestoreTrashResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RestoreTrashResponse)
+	err := c.cc.Invoke(ctx, ChatshService_RestoreTrash_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) EmptyTrash(ctx context.Context, in *EmptyTrashRequest, opts ...grpc.CallOption) (*EmptyTrashResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EmptyTrashResponse)
+	err := c.cc.Invoke(ctx, ChatshService_EmptyTrash_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chatshServiceClient) Backup(ctx context.Context, in *BackupRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BackupChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ChatshService_ServiceDesc.Streams[1], ChatshService_Backup_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BackupRequest, BackupChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChatshService_BackupClient = grpc.ServerStreamingClient[BackupChunk]
+
+func (c *chatshServiceClient) Export(ctx context.Context, in *ExportRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ArchiveChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ChatshService_ServiceDesc.Streams[2], ChatshService_Export_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ExportRequest, ArchiveChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChatshService_ExportClient = grpc.ServerStreamingClient[ArchiveChunk]
+
+func (c *chatshServiceClient) Import(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImportRequest, ImportResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ChatshService_ServiceDesc.Streams[3], ChatshService_Import_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ImportRequest, ImportResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChatshService_ImportClient = grpc.ClientStreamingClient[ImportRequest, ImportResponse]
+
+func (c *chatshServiceClient) ImportMessages(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImportMessagesRequest, ImportMessagesResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ChatshService_ServiceDesc.Streams[4], ChatshService_ImportMessages_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ImportMessagesRequest, ImportMessagesResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChatshService_ImportMessagesClient = grpc.ClientStreamingClient[ImportMessagesRequest, ImportMessagesResponse]
+
 // ChatshServiceServer is the server API for ChatshService service.
 // All implementations must embed UnimplementedChatshServiceServer
 // for forward compatibility.
@@ -207,10 +512,38 @@ type ChatshServiceServer interface {
 	CopyPath(context.Context, *CopyPathRequest) (*CopyPathResponse, error)
 	MovePath(context.Context, *MovePathRequest) (*MovePathResponse, error)
 	ListNodes(context.Context, *ListNodesRequest) (*ListNodesResponse, error)
+	StatPath(context.Context, *StatPathRequest) (*StatPathResponse, error)
 	StreamMessage(grpc.BidiStreamingServer[ClientMessage, ServerMessage]) error
 	SearchMessage(context.Context, *SearchMessageRequest) (*SearchMessageResponse, error)
 	WriteMessage(context.Context, *WriteMessageRequest) (*WriteMessageResponse, error)
 	ListMessages(context.Context, *ListMessagesRequest) (*ListMessagesResponse, error)
+	ListMentions(context.Context, *ListMentionsRequest) (*ListMentionsResponse, error)
+	MarkMentionsRead(context.Context, *MarkMentionsReadRequest) (*MarkMentionsReadResponse, error)
+	MarkRead(context.Context, *MarkReadRequest) (*MarkReadResponse, error)
+	ModerateRoom(context.Context, *ModerateRoomRequest) (*ModerateRoomResponse, error)
+	GetRoomModeration(context.Context, *GetRoomModerationRequest) (*GetRoomModerationResponse, error)
+	SetRoomLimits(context.Context, *SetRoomLimitsRequest) (*SetRoomLimitsResponse, error)
+	CreateWebhook(context.Context, *CreateWebhookRequest) (*CreateWebhookResponse, error)
+	ListWebhooks(context.Context, *ListWebhooksRequest) (*ListWebhooksResponse, error)
+	TestWebhook(context.Context, *TestWebhookRequest) (*TestWebhookResponse, error)
+	DeleteWebhook(context.Context, *DeleteWebhookRequest) (*DeleteWebhookResponse, error)
+	CreateIncomingWebhook(context.Context, *CreateIncomingWebhookRequest) (*CreateIncomingWebhookResponse, error)
+	ListIncomingWebhooks(context.Context, *ListIncomingWebhooksRequest) (*ListIncomingWebhooksResponse, error)
+	RotateIncomingWebhook(context.Context, *RotateIncomingWebhookRequest) (*RotateIncomingWebhookResponse, error)
+	RevokeIncomingWebhook(context.Context, *RevokeIncomingWebhookRequest) (*RevokeIncomingWebhookResponse, error)
+	SetRetention(context.Context, *SetRetentionRequest) (*SetRetentionResponse, error)
+	ListTrash(context.Context, *ListTrashRequest) (*ListTrashResponse, error)
+	RestoreTrash(context.Context, *RestoreTrashRequest) (*RestoreTrashResponse, error)
+	EmptyTrash(context.Context, *EmptyTrashRequest) (*EmptyTrashResponse, error)
+	// Backup streams a consistent snapshot of the database to an admin
+	Backup(*BackupRequest, grpc.ServerStreamingServer[BackupChunk]) error
+	// Export streams a tar archive of a room or directory and everything below it
+	Export(*ExportRequest, grpc.ServerStreamingServer[ArchiveChunk]) error
+	// Import recreates an archive made by Export below a directory
+	Import(grpc.ClientStreamingServer[ImportRequest, ImportResponse]) error
+	// ImportMessages adds an admin's messages from other chat systems with their own authors and
+	// times; messages whose keys a room has seen are skipped, so an import can run again
+	ImportMessages(grpc.ClientStreamingServer[ImportMessagesRequest, ImportMessagesResponse]) error
 	mustEmbedUnimplementedChatshServiceServer()
 }
 
@@ -248,6 +581,9 @@ func (UnimplementedChatshServiceServer) MovePath(context.Context, *MovePathReque
 func (UnimplementedChatshServiceServer) ListNodes(context.Context, *ListNodesRequest) (*ListNodesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListNodes not implemented")
 }
+func (UnimplementedChatshServiceServer) StatPath(context.Context, *StatPathRequest) (*StatPathResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StatPath not implemented")
+}
 func (UnimplementedChatshServiceServer) StreamMessage(grpc.BidiStreamingServer[ClientMessage, ServerMessage]) error {
 	return status.Errorf(codes.Unimplemented, "method StreamMessage not implemented")
 }
@@ -260,6 +596,72 @@ func (UnimplementedChatshServiceServer) WriteMessage(context.Context, *WriteMess
 func (UnimplementedChatshServiceServer) ListMessages(context.Context, *ListMessagesRequest) (*ListMessagesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListMessages not implemented")
 }
+func (UnimplementedChatshServiceServer) ListMentions(context.Context, *ListMentionsRequest) (*ListMentionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMentions not implemented")
+}
+func (UnimplementedChatshServiceServer) MarkMentionsRead(context.Context, *MarkMentionsReadRequest) (*MarkMentionsReadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MarkMentionsRead not implemented")
+}
+func (UnimplementedChatshServiceServer) MarkRead(context.Context, *MarkReadRequest) (*MarkReadResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MarkRead not implemented")
+}
+func (UnimplementedChatshServiceServer) ModerateRoom(context.Context, *ModerateRoomRequest) (*ModerateRoomResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ModerateRoom not implemented")
+}
+func (UnimplementedChatshServiceServer) GetRoomModeration(context.Context, *GetRoomModerationRequest) (*GetRoomModerationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRoomModeration not implemented")
+}
+func (UnimplementedChatshServiceServer) SetRoomLimits(context.Context, *SetRoomLimitsRequest) (*SetRoomLimitsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRoomLimits not implemented")
+}
+func (UnimplementedChatshServiceServer) CreateWebhook(context.Context, *CreateWebhookRequest) (*CreateWebhookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateWebhook not implemented")
+}
+func (UnimplementedChatshServiceServer) ListWebhooks(context.Context, *ListWebhooksRequest) (*ListWebhooksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWebhooks not implemented")
+}
+func (UnimplementedChatshServiceServer) TestWebhook(context.Context, *TestWebhookRequest) (*TestWebhookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TestWebhook not implemented")
+}
+func (UnimplementedChatshServiceServer) DeleteWebhook(context.Context, *DeleteWebhookRequest) (*DeleteWebhookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteWebhook not implemented")
+}
+func (UnimplementedChatshServiceServer) CreateIncomingWebhook(context.Context, *CreateIncomingWebhookRequest) (*CreateIncomingWebhookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateIncomingWebhook not implemented")
+}
+func (UnimplementedChatshServiceServer) ListIncomingWebhooks(context.Context, *ListIncomingWebhooksRequest) (*ListIncomingWebhooksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListIncomingWebhooks not implemented")
+}
+func (UnimplementedChatshServiceServer) RotateIncomingWebhook(context.Context, *RotateIncomingWebhookRequest) (*RotateIncomingWebhookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RotateIncomingWebhook not implemented")
+}
+func (UnimplementedChatshServiceServer) RevokeIncomingWebhook(context.Context, *RevokeIncomingWebhookRequest) (*RevokeIncomingWebhookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeIncomingWebhook not implemented")
+}
+func (UnimplementedChatshServiceServer) SetRetention(context.Context, *SetRetentionRequest) (*SetRetentionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetRetention not implemented")
+}
+func (UnimplementedChatshServiceServer) ListTrash(context.Context, *ListTrashRequest) (*ListTrashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTrash not implemented")
+}
+func (UnimplementedChatshServiceServer) RestoreTrash(context.Context, *RestoreTrashRequest) (*RestoreTrashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreTrash not implemented")
+}
+func (UnimplementedChatshServiceServer) EmptyTrash(context.Context, *EmptyTrashRequest) (*EmptyTrashResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EmptyTrash not implemented")
+}
+func (UnimplementedChatshServiceServer) Backup(*BackupRequest, grpc.ServerStreamingServer[BackupChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method Backup not implemented")
+}
+func (UnimplementedChatshServiceServer) Export(*ExportRequest, grpc.ServerStreamingServer[ArchiveChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method Export not implemented")
+}
+func (UnimplementedChatshServiceServer) Import(grpc.ClientStreamingServer[ImportRequest, ImportResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Import not implemented")
+}
+func (UnimplementedChatshServiceServer) ImportMessages(grpc.ClientStreamingServer[ImportMessagesRequest, ImportMessagesResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ImportMessages not implemented")
+}
 func (UnimplementedChatshServiceServer) mustEmbedUnimplementedChatshServiceServer() {}
 func (UnimplementedChatshServiceServer) testEmbeddedByValue()                       {}
 
@@ -443,6 +845,24 @@ func _ChatshService_ListNodes_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ChatshService_StatPath_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatPathRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).StatPath(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_StatPath_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).StatPath(ctx, req.(*StatPathRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _ChatshService_StreamMessage_Handler(srv interface{}, stream grpc.ServerStream) error {
 	return srv.(ChatshServiceServer).StreamMessage(&grpc.GenericServerStream[ClientMessage, ServerMessage]{ServerStream: stream})
 }
@@ -504,49 +924,413 @@ func _ChatshService_ListMessages_Handler(srv interface{}, ctx context.Context, d
 	return interceptor(ctx, in, info, handler)
 }
 
-// ChatshService_ServiceDesc is the grpc.ServiceDesc for ChatshService service.
-// It's only intended for direct use with grpc.RegisterService,
-// and not to be introspected or modified (even as a copy)
-var ChatshService_ServiceDesc = grpc.ServiceDesc{
-	ServiceName: "fs.ChatshService",
-	HandlerType: (*ChatshServiceServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "CheckDirectoryExists",
-			Handler:    _ChatshService_CheckDirectoryExists_Handler,
-		},
-		{
-			MethodName: "GetConfig",
-			Handler:    _ChatshService_GetConfig_Handler,
-		},
-		{
-			MethodName: "SetConfig",
-			Handler:    _ChatshService_SetConfig_Handler,
-		},
-		{
-			MethodName: "CreateRoom",
-			Handler:    _ChatshService_CreateRoom_Handler,
-		},
-		{
-			MethodName: "CreateDirectory",
-			Handler:    _ChatshService_CreateDirectory_Handler,
-		},
-		{
-			MethodName: "DeletePath",
-			Handler:    _ChatshService_DeletePath_Handler,
-		},
-		{
-			MethodName: "CopyPath",
-			Handler:    _ChatshService_CopyPath_Handler,
-		},
-		{
-			MethodName: "MovePath",
-			Handler:    _ChatshService_MovePath_Handler,
-		},
-		{
-			MethodName: "ListNodes",
-			Handler:    _ChatshService_ListNodes_Handler,
-		},
+func _ChatshService_ListMentions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMentionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).ListMentions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_ListMentions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).ListMentions(ctx, req.(*ListMentionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_MarkMentionsRead_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MarkMentionsReadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).MarkMentionsRead(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_MarkMentionsRead_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).MarkMentionsRead(ctx, req.(*MarkMentionsReadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_MarkRead_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MarkReadRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).MarkRead(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_MarkRead_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).MarkRead(ctx, req.(*MarkReadRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_ModerateRoom_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ModerateRoomRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).ModerateRoom(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_ModerateRoom_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).ModerateRoom(ctx, req.(*ModerateRoomRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_GetRoomModeration_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRoomModerationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).GetRoomModeration(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_GetRoomModeration_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).GetRoomModeration(ctx, req.(*GetRoomModerationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_SetRoomLimits_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRoomLimitsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).SetRoomLimits(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_SetRoomLimits_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).SetRoomLimits(ctx, req.(*SetRoomLimitsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_CreateWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).CreateWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_CreateWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).CreateWebhook(ctx, req.(*CreateWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_ListWebhooks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListWebhooksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).ListWebhooks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_ListWebhooks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).ListWebhooks(ctx, req.(*ListWebhooksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_TestWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TestWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).TestWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_TestWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).TestWebhook(ctx, req.(*TestWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_DeleteWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).DeleteWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_DeleteWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).DeleteWebhook(ctx, req.(*DeleteWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_CreateIncomingWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateIncomingWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).CreateIncomingWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_CreateIncomingWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).CreateIncomingWebhook(ctx, req.(*CreateIncomingWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_ListIncomingWebhooks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListIncomingWebhooksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).ListIncomingWebhooks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_ListIncomingWebhooks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).ListIncomingWebhooks(ctx, req.(*ListIncomingWebhooksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_RotateIncomingWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RotateIncomingWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).RotateIncomingWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_RotateIncomingWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).RotateIncomingWebhook(ctx, req.(*RotateIncomingWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_RevokeIncomingWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeIncomingWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).RevokeIncomingWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_RevokeIncomingWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).RevokeIncomingWebhook(ctx, req.(*RevokeIncomingWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_SetRetention_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRetentionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).SetRetention(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_SetRetention_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).SetRetention(ctx, req.(*SetRetentionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_ListTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTrashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).ListTrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_ListTrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).ListTrash(ctx, req.(*ListTrashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_RestoreTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreTrashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).RestoreTrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_RestoreTrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).RestoreTrash(ctx, req.(*RestoreTrashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_EmptyTrash_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmptyTrashRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChatshServiceServer).EmptyTrash(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ChatshService_EmptyTrash_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChatshServiceServer).EmptyTrash(ctx, req.(*EmptyTrashRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ChatshService_Backup_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BackupRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChatshServiceServer).Backup(m, &grpc.GenericServerStream[BackupRequest, BackupChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChatshService_BackupServer = grpc.ServerStreamingServer[BackupChunk]
+
+func _ChatshService_Export_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChatshServiceServer).Export(m, &grpc.GenericServerStream[ExportRequest, ArchiveChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChatshService_ExportServer = grpc.ServerStreamingServer[ArchiveChunk]
+
+func _ChatshService_Import_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ChatshServiceServer).Import(&grpc.GenericServerStream[ImportRequest, ImportResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChatshService_ImportServer = grpc.ClientStreamingServer[ImportRequest, ImportResponse]
+
+func _ChatshService_ImportMessages_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ChatshServiceServer).ImportMessages(&grpc.GenericServerStream[ImportMessagesRequest, ImportMessagesResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ChatshService_ImportMessagesServer = grpc.ClientStreamingServer[ImportMessagesRequest, ImportMessagesResponse]
+
+// ChatshService_ServiceDesc is the grpc.ServiceDesc for ChatshService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ChatshService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "fs.ChatshService",
+	HandlerType: (*ChatshServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CheckDirectoryExists",
+			Handler:    _ChatshService_CheckDirectoryExists_Handler,
+		},
+		{
+			MethodName: "GetConfig",
+			Handler:    _ChatshService_GetConfig_Handler,
+		},
+		{
+			MethodName: "SetConfig",
+			Handler:    _ChatshService_SetConfig_Handler,
+		},
+		{
+			MethodName: "CreateRoom",
+			Handler:    _ChatshService_CreateRoom_Handler,
+		},
+		{
+			MethodName: "CreateDirectory",
+			Handler:    _ChatshService_CreateDirectory_Handler,
+		},
+		{
+			MethodName: "DeletePath",
+			Handler:    _ChatshService_DeletePath_Handler,
+		},
+		{
+			MethodName: "CopyPath",
+			Handler:    _ChatshService_CopyPath_Handler,
+		},
+		{
+			MethodName: "MovePath",
+			Handler:    _ChatshService_MovePath_Handler,
+		},
+		{
+			MethodName: "ListNodes",
+			Handler:    _ChatshService_ListNodes_Handler,
+		},
+		{
+			MethodName: "StatPath",
+			Handler:    _ChatshService_StatPath_Handler,
+		},
 		{
 			MethodName: "SearchMessage",
 			Handler:    _ChatshService_SearchMessage_Handler,
@@ -559,6 +1343,78 @@ var ChatshService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ListMessages",
 			Handler:    _ChatshService_ListMessages_Handler,
 		},
+		{
+			MethodName: "ListMentions",
+			Handler:    _ChatshService_ListMentions_Handler,
+		},
+		{
+			MethodName: "MarkMentionsRead",
+			Handler:    _ChatshService_MarkMentionsRead_Handler,
+		},
+		{
+			MethodName: "MarkRead",
+			Handler:    _ChatshService_MarkRead_Handler,
+		},
+		{
+			MethodName: "ModerateRoom",
+			Handler:    _ChatshService_ModerateRoom_Handler,
+		},
+		{
+			MethodName: "GetRoomModeration",
+			Handler:    _ChatshService_GetRoomModeration_Handler,
+		},
+		{
+			MethodName: "SetRoomLimits",
+			Handler:    _ChatshService_SetRoomLimits_Handler,
+		},
+		{
+			MethodName: "CreateWebhook",
+			Handler:    _ChatshService_CreateWebhook_Handler,
+		},
+		{
+			MethodName: "ListWebhooks",
+			Handler:    _ChatshService_ListWebhooks_Handler,
+		},
+		{
+			MethodName: "TestWebhook",
+			Handler:    _ChatshService_TestWebhook_Handler,
+		},
+		{
+			MethodName: "DeleteWebhook",
+			Handler:    _ChatshService_DeleteWebhook_Handler,
+		},
+		{
+			MethodName: "CreateIncomingWebhook",
+			Handler:    _ChatshService_CreateIncomingWebhook_Handler,
+		},
+		{
+			MethodName: "ListIncomingWebhooks",
+			Handler:    _ChatshService_ListIncomingWebhooks_Handler,
+		},
+		{
+			MethodName: "RotateIncomingWebhook",
+			Handler:    _ChatshService_RotateIncomingWebhook_Handler,
+		},
+		{
+			MethodName: "RevokeIncomingWebhook",
+			Handler:    _ChatshService_RevokeIncomingWebhook_Handler,
+		},
+		{
+			MethodName: "SetRetention",
+			Handler:    _ChatshService_SetRetention_Handler,
+		},
+		{
+			MethodName: "ListTrash",
+			Handler:    _ChatshService_ListTrash_Handler,
+		},
+		{
+			MethodName: "RestoreTrash",
+			Handler:    _ChatshService_RestoreTrash_Handler,
+		},
+		{
+			MethodName: "EmptyTrash",
+			Handler:    _ChatshService_EmptyTrash_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -567,6 +1423,26 @@ var ChatshService_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "Backup",
+			Handler:       _ChatshService_Backup_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Export",
+			Handler:       _ChatshService_Export_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Import",
+			Handler:       _ChatshService_Import_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ImportMessages",
+			Handler:       _ChatshService_ImportMessages_Handler,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "grpc/chatsh.proto",
 }
@@ -0,0 +1,47 @@
+// Package importer reads the history of other chat systems for chatsh admin import: Slack
+// export zips, IRC logs and JSON lines like those of chatsh cat --format jsonl.
+//
+// Every reader calls a function with the messages it reads, in the order they were written, so
+// that a history of any size goes through without being held in memory. Reading the same
+// history again gives the messages the same keys, which is what lets an import run twice.
+package importer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+// Message is a message of another chat system
+type Message struct {
+	// Room names where the message was written: the channel of a Slack message, the room of a
+	// JSON line and nothing for IRC logs, which hold a single channel
+	Room string
+	// Key identifies the message in its source
+	Key    string
+	Author string
+	Text   string
+	Time   time.Time
+}
+
+// contentKeys keys the messages of sources without ids by what they say, numbering the
+// messages that say the same at the same time
+type contentKeys struct {
+	prefix string
+	seen   map[string]int
+}
+
+func newContentKeys(prefix string) *contentKeys {
+	return &contentKeys{prefix: prefix, seen: map[string]int{}}
+}
+
+func (k *contentKeys) key(room, author, text string, t time.Time) string {
+	sum := sha256.Sum256([]byte(room + "\x00" + author + "\x00" + text + "\x00" + t.UTC().Format(time.RFC3339Nano)))
+	hash := hex.EncodeToString(sum[:12])
+	k.seen[hash]++
+	if n := k.seen[hash]; n > 1 {
+		return k.prefix + hash + "." + strconv.Itoa(n)
+	}
+	return k.prefix + hash
+}
@@ -0,0 +1,84 @@
+package importer_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ponyo877/chatsh/importer"
+	"github.com/ponyo877/chatsh/server/domain"
+	"github.com/ponyo877/chatsh/server/repository/memory"
+	"github.com/ponyo877/chatsh/server/usecase"
+)
+
+// importInto reads messages with read and imports them as an admin into /tmp/logs, the way
+// chatsh admin import does
+func importInto(t *testing.T, read func(fn func(importer.Message) error) error) (domain.MessageImport, []domain.Message) {
+	t.Helper()
+	uc := usecase.NewUsecase(memory.NewRepository(), domain.NewMessageLimits(4000, 100), time.Minute,
+		domain.WebhookOptions{}, domain.RetentionOptions{}, domain.NewMessageHooks(), []string{"tokRoot"})
+	if err := uc.SetConfig(domain.NewConfig("root", "tokRoot")); err != nil {
+		t.Fatal(err)
+	}
+	var messages []domain.ImportedMessage
+	if err := read(func(m importer.Message) error {
+		messages = append(messages, domain.NewImportedMessage(m.Key, m.Author, m.Text, m.Time))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := uc.ImportMessages("tokRoot", domain.NewPath("/tmp/logs"), true, messages)
+	if err != nil {
+		t.Fatalf("ImportMessages: %v", err)
+	}
+	stored, err := uc.ListMessages(domain.NewPath("/tmp/logs"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result, stored
+}
+
+func contents(messages []domain.Message) []string {
+	texts := make([]string, len(messages))
+	for i, message := range messages {
+		texts[len(messages)-1-i] = message.Content
+	}
+	return texts
+}
+
+// TestImportControlSequences checks that the escapes a log brings along never reach the
+// terminals of those who cat the room, and that messages made only of them are dropped
+func TestImportControlSequences(t *testing.T) {
+	t.Run("jsonl", func(t *testing.T) {
+		log := `{"id":1,"author":"alice","text":"\u001b[31mred\u001b[0m alert","time":"2024-01-02T10:00:00Z"}
+{"id":2,"author":"\u001b]0;pwned\u0007bob","text":"\u001b[2J\u001b[H","time":"2024-01-02T10:01:00Z"}
+{"id":3,"author":"carol","text":"bell\u0007 and tab\tkept","time":"2024-01-02T10:02:00Z"}
+`
+		result, stored := importInto(t, func(fn func(importer.Message) error) error {
+			return importer.ReadJSONLines(strings.NewReader(log), fn)
+		})
+		if result.Messages != 2 || result.Dropped != 1 {
+			t.Errorf("result = %+v, want 2 messages and 1 dropped", result)
+		}
+		want := []string{"red alert", "bell and tab\tkept"}
+		if got := contents(stored); strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("stored %q, want %q", got, want)
+		}
+	})
+
+	t.Run("irc", func(t *testing.T) {
+		log := "[2024-01-02 10:00:00] <alice> \x0304,12red\x03 and \x02bold\x02\n" +
+			"[2024-01-02 10:01:00] <bob> \x1b[1mloud\x1b[0m\n" +
+			"[2024-01-02 10:02:00] <carol> \x0313\x02\x0f\n"
+		result, stored := importInto(t, func(fn func(importer.Message) error) error {
+			return importer.ReadIRC(strings.NewReader(log), importer.IRCOptions{}, fn)
+		})
+		if result.Messages != 2 {
+			t.Errorf("result = %+v, want 2 messages", result)
+		}
+		want := []string{"red and bold", "loud"}
+		if got := contents(stored); strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("stored %q, want %q", got, want)
+		}
+	})
+}
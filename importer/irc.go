@@ -0,0 +1,178 @@
+package importer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrNoDate reports a log with times whose day neither the log nor IRCOptions tells
+var ErrNoDate = errors.New("the log does not say its day")
+
+type IRCOptions struct {
+	// Date is the day of a log whose lines only have times and which does not say its day itself,
+	// like the daily logs of ZNC; LogDate reads it from their names
+	Date time.Time
+	// Location is the time zone the log was written in; nil means UTC
+	Location *time.Location
+}
+
+// The formats of IRC logs, told apart line by line. Messages and actions follow the stamp.
+var (
+	// WeeChat: "2024-01-02 10:00:00\tnick\ttext", actions with the prefix " *" and "nick does" as text
+	weechatLine = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2})\t([^\t]*)\t(.*)$`)
+	// ZNC and others: "[10:00:00] <nick> text", or with the date, "[2024-01-02 10:00:00] <nick> text"
+	bracketedDateTimeLine = regexp.MustCompile(`^\[(\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}(?::\d{2})?)\] (.*)$`)
+	bracketedTimeLine     = regexp.MustCompile(`^\[(\d{2}:\d{2}(?::\d{2})?)\] (.*)$`)
+	// irssi: "10:00 <@nick> text", "10:00:12 < nick> text"
+	irssiLine = regexp.MustCompile(`^(\d{2}:\d{2}(?::\d{2})?) (.*)$`)
+	// HexChat: "Jan 02 10:00:00 <nick>\ttext"
+	hexchatLine = regexp.MustCompile(`^([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}) (.*)$`)
+
+	// weechatEventPrefixes stand in for the nick on the lines of joins, parts and the network
+	weechatEventPrefixes = map[string]bool{"-->": true, "<--": true, "--": true, "=!=": true}
+
+	ircMessage = regexp.MustCompile(`^<[ @+%&~]?([^>]+)>\s?(.*)$`)
+	ircAction  = regexp.MustCompile(`^\*\s+([^\s*]+) (.*)$`)
+	// ircFormatting matches mIRC colours with their numbers, and bold, italics, underline,
+	// strike-through, reverse and reset, which clients log as they were sent
+	ircFormatting = regexp.MustCompile(`\x03(?:\d{1,2}(?:,\d{1,2})?)?|[\x02\x0f\x16\x1d\x1e\x1f]`)
+
+	// The lines that tell the day: irssi's, HexChat's, and a date in the name of a log file
+	irssiLogOpened  = regexp.MustCompile(`^--- Log opened (.*)$`)
+	irssiDayChanged = regexp.MustCompile(`^--- Day changed (.*)$`)
+	hexchatBegin    = regexp.MustCompile(`^\*\*\*\* BEGIN LOGGING AT (.*)$`)
+	fileNameDate    = regexp.MustCompile(`(\d{4})-?(\d{2})-?(\d{2})`)
+)
+
+// ReadIRC calls fn with the messages and actions of an IRC log of irssi, WeeChat, ZNC or
+// HexChat; joins, parts and the other events are left out. Actions are written "* nick does".
+// The logs have no ids, so messages are keyed by their time, author and text.
+func ReadIRC(r io.Reader, options IRCOptions, fn func(Message) error) error {
+	location := options.Location
+	if location == nil {
+		location = time.UTC
+	}
+	keys := newContentKeys("irc:")
+	day := options.Date
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		var stamp time.Time
+		var body string
+		if m := weechatLine.FindStringSubmatch(line); m != nil {
+			t, err := time.ParseInLocation(time.DateTime, m[1], location)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+			prefix := strings.TrimLeft(m[2], " @+%&~")
+			switch {
+			case prefix == "*":
+				author, text, _ := strings.Cut(m[3], " ")
+				stamp, body = t, "* "+author+" "+text
+			case prefix == "" || weechatEventPrefixes[prefix]:
+				continue
+			default:
+				stamp, body = t, "<"+prefix+"> "+m[3]
+			}
+		} else if m := irssiLogOpened.FindStringSubmatch(line); m != nil {
+			t, err := time.ParseInLocation("Mon Jan 02 15:04:05 2006", m[1], location)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+			day = t
+			continue
+		} else if m := irssiDayChanged.FindStringSubmatch(line); m != nil {
+			t, err := time.ParseInLocation("Mon Jan 02 2006", m[1], location)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+			day = t
+			continue
+		} else if m := hexchatBegin.FindStringSubmatch(line); m != nil {
+			t, err := time.ParseInLocation(time.ANSIC, m[1], location)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+			day = t
+			continue
+		} else if m := bracketedDateTimeLine.FindStringSubmatch(line); m != nil {
+			t, err := parseIRCDateTime(strings.Replace(m[1], "T", " ", 1), location)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+			stamp, body = t, m[2]
+		} else if m := hexchatLine.FindStringSubmatch(line); m != nil {
+			if day.IsZero() {
+				return fmt.Errorf("line %d: %w", n, ErrNoDate)
+			}
+			t, err := time.ParseInLocation("Jan _2 15:04:05 2006", m[1]+" "+day.Format("2006"), location)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+			stamp, body = t, m[2]
+		} else if m := firstMatch(line, bracketedTimeLine, irssiLine); m != nil {
+			if day.IsZero() {
+				return fmt.Errorf("line %d: %w", n, ErrNoDate)
+			}
+			t, err := parseIRCDateTime(day.Format(time.DateOnly)+" "+m[1], location)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+			stamp, body = t, m[2]
+		} else {
+			continue
+		}
+
+		var author, text string
+		if m := ircMessage.FindStringSubmatch(body); m != nil {
+			author, text = strings.TrimSpace(m[1]), m[2]
+		} else if m := ircAction.FindStringSubmatch(strings.TrimSpace(body)); m != nil {
+			author, text = m[1], "* "+m[1]+" "+m[2]
+		}
+		if author == "" || strings.TrimSpace(text) == "" {
+			continue
+		}
+		// Keyed on the line as logged, so that imports made before the formatting was removed match
+		key := keys.key("", author, text, stamp)
+		if text = ircFormatting.ReplaceAllString(text, ""); strings.TrimSpace(text) == "" {
+			continue
+		}
+		message := Message{Key: key, Author: author, Text: text, Time: stamp}
+		if err := fn(message); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// LogDate reads the day of a daily log from its name, as in "#go-nuts_20240102.log" or "2024-01-02.log"
+func LogDate(fileName string) (time.Time, bool) {
+	m := fileNameDate.FindStringSubmatch(fileName)
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.DateOnly, m[1]+"-"+m[2]+"-"+m[3])
+	return t, err == nil
+}
+
+func parseIRCDateTime(value string, location *time.Location) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateTime, value, location); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02 15:04", value, location)
+}
+
+func firstMatch(line string, patterns ...*regexp.Regexp) []string {
+	for _, pattern := range patterns {
+		if m := pattern.FindStringSubmatch(line); m != nil {
+			return m
+		}
+	}
+	return nil
+}
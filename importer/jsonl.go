@@ -0,0 +1,72 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// jsonLine is a message in the format of chatsh cat --format jsonl, which other tools can write too
+type jsonLine struct {
+	Room string `json:"room"`
+	// ID is a number or a string
+	ID     json.RawMessage `json:"id"`
+	Author string          `json:"author"`
+	Text   string          `json:"text"`
+	Time   time.Time       `json:"time"`
+}
+
+// ReadJSONLines calls fn with a message per line of r, in the format of chatsh cat --format jsonl:
+//
+//	{"room":"/srv/proj/general","id":12,"author":"alice","text":"hello","time":"2025-06-01T10:02:03+09:00"}
+//
+// room and id may be left out. Messages are keyed by their room and id, or by what they say
+// when they have no id. Blank lines are skipped.
+func ReadJSONLines(r io.Reader, fn func(Message) error) error {
+	keys := newContentKeys("jsonl:")
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var m jsonLine
+		if err := json.Unmarshal(line, &m); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		switch {
+		case m.Author == "":
+			return fmt.Errorf("line %d: no author", n)
+		case m.Text == "":
+			return fmt.Errorf("line %d: no text", n)
+		case m.Time.IsZero():
+			return fmt.Errorf("line %d: no time", n)
+		}
+		message := Message{Room: m.Room, Author: m.Author, Text: m.Text, Time: m.Time}
+		if id := jsonID(m.ID); id == "" {
+			message.Key = keys.key(m.Room, m.Author, m.Text, m.Time)
+		} else {
+			message.Key = "jsonl:" + m.Room + "#" + id
+		}
+		if err := fn(message); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// jsonID reads an id given as a string or a number
+func jsonID(raw json.RawMessage) string {
+	var id string
+	if err := json.Unmarshal(raw, &id); err == nil {
+		return id
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
@@ -0,0 +1,214 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"html"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// slackSkippedSubtypes are the messages Slack writes about a channel rather than in it
+var slackSkippedSubtypes = map[string]bool{
+	"channel_join":  true,
+	"channel_leave": true,
+	"group_join":    true,
+	"group_leave":   true,
+}
+
+// slackLink matches the <...> markup Slack puts around links, mentions and channels
+var slackLink = regexp.MustCompile(`<([^<>|]*)(?:\|([^<>]*))?>`)
+
+type slackChannel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type slackUser struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Profile struct {
+		DisplayName string `json:"display_name"`
+		RealName    string `json:"real_name"`
+	} `json:"profile"`
+}
+
+type slackMessage struct {
+	Type        string `json:"type"`
+	Subtype     string `json:"subtype"`
+	User        string `json:"user"`
+	Username    string `json:"username"`
+	Text        string `json:"text"`
+	TS          string `json:"ts"`
+	UserProfile *struct {
+		DisplayName string `json:"display_name"`
+		RealName    string `json:"real_name"`
+	} `json:"user_profile"`
+	BotProfile *struct {
+		Name string `json:"name"`
+	} `json:"bot_profile"`
+	Files []struct {
+		Name string `json:"name"`
+	} `json:"files"`
+}
+
+// ReadSlack calls fn with the messages of every channel of a Slack export, channel by channel
+// in the order of their names. Room is the name of the channel; messages are keyed by the id of
+// their channel and their Slack timestamp. Joins and leaves are left out, files only named.
+func ReadSlack(zr *zip.Reader, fn func(Message) error) error {
+	ids := map[string]string{}
+	for _, name := range []string{"channels.json", "groups.json"} {
+		var channels []slackChannel
+		if err := readSlackJSON(zr, name, &channels); err != nil {
+			return err
+		}
+		for _, channel := range channels {
+			ids[channel.Name] = channel.ID
+		}
+	}
+	var users []slackUser
+	if err := readSlackJSON(zr, "users.json", &users); err != nil {
+		return err
+	}
+	names := make(map[string]string, len(users))
+	for _, user := range users {
+		names[user.ID] = firstNonEmpty(user.Profile.DisplayName, user.Profile.RealName, user.Name, user.ID)
+	}
+
+	// A channel is a directory of a file per day, named so that they sort by date
+	days := map[string][]*zip.File{}
+	for _, f := range zr.File {
+		dir, base := path.Split(f.Name)
+		channel := strings.TrimSuffix(dir, "/")
+		if channel == "" || strings.Contains(channel, "/") || path.Ext(base) != ".json" {
+			continue
+		}
+		days[channel] = append(days[channel], f)
+	}
+	channels := make([]string, 0, len(days))
+	for channel := range days {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	if len(channels) == 0 {
+		return fmt.Errorf("not a Slack export: no channel directories")
+	}
+
+	for _, channel := range channels {
+		id := firstNonEmpty(ids[channel], channel)
+		files := days[channel]
+		sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+		for _, f := range files {
+			var messages []slackMessage
+			if err := decodeZipJSON(f, &messages); err != nil {
+				return err
+			}
+			sort.SliceStable(messages, func(i, j int) bool { return slackTime(messages[i].TS).Before(slackTime(messages[j].TS)) })
+			for _, m := range messages {
+				if m.Type != "message" || slackSkippedSubtypes[m.Subtype] || m.TS == "" {
+					continue
+				}
+				text := slackText(m.Text, names)
+				for _, file := range m.Files {
+					text = strings.TrimSpace(text + "\n[file: " + file.Name + "]")
+				}
+				if text == "" {
+					continue
+				}
+				message := Message{
+					Room:   channel,
+					Key:    "slack:" + id + ":" + m.TS,
+					Author: slackAuthor(m, names),
+					Text:   text,
+					Time:   slackTime(m.TS),
+				}
+				if err := fn(message); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// readSlackJSON decodes the file of the export named name into v, leaving v alone if it is missing
+func readSlackJSON(zr *zip.Reader, name string, v any) error {
+	for _, f := range zr.File {
+		if f.Name == name {
+			return decodeZipJSON(f, v)
+		}
+	}
+	return nil
+}
+
+func decodeZipJSON(f *zip.File, v any) error {
+	r, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.Name, err)
+	}
+	defer r.Close()
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", f.Name, err)
+	}
+	return nil
+}
+
+func slackAuthor(m slackMessage, names map[string]string) string {
+	if name, ok := names[m.User]; ok {
+		return name
+	}
+	author := m.Username
+	if m.UserProfile != nil {
+		author = firstNonEmpty(m.UserProfile.DisplayName, m.UserProfile.RealName, author)
+	}
+	if m.BotProfile != nil {
+		author = firstNonEmpty(author, m.BotProfile.Name)
+	}
+	return firstNonEmpty(author, m.User, "unknown")
+}
+
+// slackTime reads a Slack timestamp, seconds and microseconds since the epoch as "1622505600.000200"
+func slackTime(ts string) time.Time {
+	seconds, fraction, _ := strings.Cut(ts, ".")
+	sec, _ := strconv.ParseInt(seconds, 10, 64)
+	micro, _ := strconv.ParseInt((fraction + "000000")[:6], 10, 64)
+	return time.Unix(sec, micro*int64(time.Microsecond))
+}
+
+// slackText turns Slack's markup into plain text: mentions become @name, channels #name and
+// links their label followed by the address
+func slackText(text string, names map[string]string) string {
+	text = slackLink.ReplaceAllStringFunc(text, func(link string) string {
+		parts := slackLink.FindStringSubmatch(link)
+		target, label := parts[1], parts[2]
+		switch {
+		case strings.HasPrefix(target, "@"):
+			return "@" + firstNonEmpty(names[target[1:]], label, target[1:])
+		case strings.HasPrefix(target, "#"):
+			return "#" + firstNonEmpty(label, target[1:])
+		case strings.HasPrefix(target, "!subteam^"):
+			return firstNonEmpty(label, "@team")
+		case strings.HasPrefix(target, "!"):
+			return "@" + strings.TrimPrefix(firstNonEmpty(label, target[1:]), "@")
+		case label != "" && label != target:
+			return label + " (" + target + ")"
+		default:
+			return target
+		}
+	})
+	return strings.TrimSpace(html.UnescapeString(text))
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -63,4 +63,4 @@ INSERT INTO directories (name, parent_id, owner_token, path, created_at) VALUES
 ('sys', 1, 'admin', '/sys', '2025-05-01 00:00:00');
 
 INSERT INTO directories (name, parent_id, owner_token, path, created_at) VALUES
-('chatsh', 10, 'admin', '/home/chatsh', '2025-05-01 00:00:00');
\ No newline at end of file
+('chatsh', 10, 'admin', '/home/chatsh', '2025-05-01 00:00:00');
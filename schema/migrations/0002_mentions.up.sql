@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS mentions (
+    id          INTEGER  PRIMARY KEY AUTOINCREMENT,
+    user_token  TEXT     NOT NULL REFERENCES users(token),
+    room_id     INTEGER  NOT NULL REFERENCES rooms(id),
+    sender_name TEXT     NOT NULL,
+    content     TEXT     NOT NULL,
+    is_read     BOOLEAN  NOT NULL DEFAULT FALSE,
+    created_at  DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_mentions_user_read ON mentions (user_token, is_read, created_at DESC);
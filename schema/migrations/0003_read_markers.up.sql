@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS read_markers (
+    user_token      TEXT     NOT NULL REFERENCES users(token),
+    room_id         INTEGER  NOT NULL REFERENCES rooms(id),
+    last_message_id INTEGER  NOT NULL,
+    updated_at      DATETIME NOT NULL,
+    PRIMARY KEY (user_token, room_id)
+);
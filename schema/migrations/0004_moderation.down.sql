@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS moderation_logs;
+DROP TABLE IF EXISTS room_restrictions;
+DROP TABLE IF EXISTS room_settings;
@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS room_settings (
+    room_id           INTEGER  PRIMARY KEY REFERENCES rooms(id),
+    slow_mode_seconds  INTEGER  NOT NULL DEFAULT 0,
+    max_message_length INTEGER  NOT NULL DEFAULT 0,
+    max_message_lines  INTEGER  NOT NULL DEFAULT 0,
+    updated_at         DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS room_restrictions (
+    room_id     INTEGER  NOT NULL REFERENCES rooms(id),
+    user_token  TEXT     NOT NULL REFERENCES users(token),
+    kind        INTEGER  NOT NULL,
+    created_by  TEXT     NOT NULL REFERENCES users(token),
+    created_at  DATETIME NOT NULL,
+    PRIMARY KEY (room_id, user_token, kind)
+);
+
+CREATE TABLE IF NOT EXISTS moderation_logs (
+    id          INTEGER  PRIMARY KEY AUTOINCREMENT,
+    room_id     INTEGER  NOT NULL REFERENCES rooms(id),
+    actor_name  TEXT     NOT NULL,
+    action      INTEGER  NOT NULL,
+    target_name TEXT     NOT NULL,
+    detail      TEXT     NOT NULL,
+    created_at  DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_moderation_logs_room_created ON moderation_logs (room_id, created_at DESC);
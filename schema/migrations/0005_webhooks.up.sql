@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+    id          INTEGER  PRIMARY KEY AUTOINCREMENT,
+    owner_token TEXT     NOT NULL REFERENCES users(token),
+    path        TEXT     NOT NULL,
+    url         TEXT     NOT NULL,
+    secret      TEXT     NOT NULL,
+    events      TEXT     NOT NULL DEFAULT '',
+    path_glob   TEXT     NOT NULL DEFAULT '',
+    created_at  DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webhooks_path ON webhooks (path);
+CREATE INDEX IF NOT EXISTS idx_webhooks_owner ON webhooks (owner_token);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id              INTEGER  PRIMARY KEY AUTOINCREMENT,
+    webhook_id      INTEGER  NOT NULL REFERENCES webhooks(id),
+    event           INTEGER  NOT NULL,
+    payload         BLOB     NOT NULL,
+    attempts        INTEGER  NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME NOT NULL,
+    last_error      TEXT     NOT NULL DEFAULT '',
+    state           INTEGER  NOT NULL DEFAULT 0,
+    created_at      DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (state, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries (webhook_id);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS incoming_webhooks (
+    id           INTEGER  PRIMARY KEY AUTOINCREMENT,
+    room_id      INTEGER  NOT NULL REFERENCES rooms(id),
+    owner_token  TEXT     NOT NULL REFERENCES users(token),
+    bot_name     TEXT     NOT NULL,
+    secret_hash  TEXT     NOT NULL UNIQUE,
+    created_at   DATETIME NOT NULL,
+    last_used_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_incoming_webhooks_owner ON incoming_webhooks (owner_token);
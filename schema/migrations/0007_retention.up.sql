@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS retention_policies (
+    node_type       INTEGER  NOT NULL, -- 1 for directories, 2 for rooms
+    node_id         INTEGER  NOT NULL,
+    max_age_seconds INTEGER  NOT NULL DEFAULT 0,
+    max_messages    INTEGER  NOT NULL DEFAULT 0,
+    idle_seconds    INTEGER  NOT NULL DEFAULT 0,
+    updated_at      DATETIME NOT NULL,
+    PRIMARY KEY (node_type, node_id)
+);
+
+-- Like a real /tmp, rooms there go away after a day without messages, and messages after a day
+INSERT OR IGNORE INTO retention_policies (node_type, node_id, max_age_seconds, max_messages, idle_seconds, updated_at)
+SELECT 1, id, 86400, 0, 86400, '2025-05-01 00:00:00' FROM directories WHERE path = '/tmp';
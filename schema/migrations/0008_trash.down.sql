@@ -0,0 +1,2 @@
+DELETE FROM messages WHERE room_id IN (SELECT node_id FROM trash WHERE node_type = 2);
+DROP TABLE IF EXISTS trash;
@@ -0,0 +1,14 @@
+-- A removed node leaves its row here; whatever refers to it by id, like the messages of a
+-- room, stays where it is until the entry is purged, and is found again when it is restored
+CREATE TABLE IF NOT EXISTS trash (
+    id          INTEGER  PRIMARY KEY AUTOINCREMENT,
+    node_type   INTEGER  NOT NULL, -- 1 for directories, 2 for rooms
+    node_id     INTEGER  NOT NULL,
+    name        TEXT     NOT NULL,
+    path        TEXT     NOT NULL, -- where the node was removed from
+    owner_token TEXT     NOT NULL REFERENCES users(token),
+    created_at  DATETIME NOT NULL,
+    deleted_at  DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_trash_owner_deleted ON trash (owner_token, deleted_at DESC);
+CREATE INDEX IF NOT EXISTS idx_trash_deleted ON trash (deleted_at);
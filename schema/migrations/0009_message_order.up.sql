@@ -0,0 +1,2 @@
+-- Walks the messages of a room in the order they were written, as exports do page by page
+CREATE INDEX IF NOT EXISTS idx_messages_room_id ON messages (room_id, id);
@@ -0,0 +1,8 @@
+-- Remembers the messages imported from other chat systems by the key they had there, so that
+-- importing the same history again adds nothing; deleting a message keeps its key
+CREATE TABLE IF NOT EXISTS message_imports (
+    room_id    INTEGER  NOT NULL REFERENCES rooms(id),
+    import_key TEXT     NOT NULL,
+    message_id INTEGER  NOT NULL,
+    PRIMARY KEY (room_id, import_key)
+);
@@ -0,0 +1,11 @@
+// Package schema embeds the versioned migrations of the chatsh database.
+//
+// Each version has a NNNN_name.up.sql file and a matching NNNN_name.down.sql file.
+// Migrations after 0001 use IF NOT EXISTS because databases created before versioning
+// may already contain their tables.
+package schema
+
+import "embed"
+
+//go:embed migrations/*.sql
+var Migrations embed.FS
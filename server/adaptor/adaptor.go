@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"strconv"
+	"sync"
 	"time"
 
 	pb "github.com/ponyo877/chatsh/grpc"
@@ -14,29 +16,62 @@ import (
 )
 
 type Adaptor struct {
-	uc Usecase
+	uc            Usecase
+	writeLimiter  *keyedLimiter
+	streamLimiter *keyedLimiter
+	identities    *identityResolver
 	pb.UnimplementedChatshServiceServer
 }
 
-func NewAdaptor(uc Usecase) *Adaptor {
-	return &Adaptor{uc: uc}
+func NewAdaptor(uc Usecase, limits RateLimits, identities Identities) *Adaptor {
+	return &Adaptor{
+		uc:            uc,
+		writeLimiter:  newKeyedLimiter(limits.WritePerSecond, limits.WriteBurst),
+		streamLimiter: newKeyedLimiter(limits.StreamPerSecond, limits.StreamBurst),
+		identities:    newIdentityResolver(identities),
+	}
 }
 
-func toPbNodeInfo(node domain.Node) *pb.NodeInfo {
-	var nodeType pb.NodeType
-	switch node.Type {
+func toPbNodeType(nodeType domain.NodeType) pb.NodeType {
+	switch nodeType {
 	case domain.NodeTypeDirectory:
-		nodeType = pb.NodeType_DIRECTORY
+		return pb.NodeType_DIRECTORY
 	case domain.NodeTypeRoom:
-		nodeType = pb.NodeType_ROOM
+		return pb.NodeType_ROOM
 	default:
-		nodeType = pb.NodeType_UNKNOWN
+		return pb.NodeType_UNKNOWN
 	}
+}
+
+func toPbNodeInfo(node domain.Node) *pb.NodeInfo {
 	return &pb.NodeInfo{
-		Name:      node.Name,
-		OwnerName: node.OwnerName,
-		Type:      nodeType,
-		Modified:  timestamppb.New(node.CreatedAt),
+		Name:        node.Name,
+		OwnerName:   node.OwnerName,
+		Type:        toPbNodeType(node.Type),
+		Modified:    timestamppb.New(node.CreatedAt),
+		UnreadCount: int32(node.UnreadCount),
+		Retention:   toPbRetention(node.Retention),
+	}
+}
+
+func toPbRetention(policy *domain.RetentionPolicy) *pb.Retention {
+	if policy == nil {
+		return nil
+	}
+	return &pb.Retention{
+		MaxAgeSeconds: int64(policy.MaxAge / time.Second),
+		MaxMessages:   int32(policy.MaxMessages),
+		IdleSeconds:   int64(policy.IdleTTL / time.Second),
+		Source:        policy.Path,
+	}
+}
+
+func toPbMessage(message domain.Message) *pb.Message {
+	return &pb.Message{
+		Id:          int64(message.ID),
+		TextContent: message.Content,
+		OwnerName:   message.DisplayName,
+		Created:     timestamppb.New(message.CreatedAt),
 	}
 }
 
@@ -44,7 +79,7 @@ func (a *Adaptor) GetConfig(ctx context.Context, in *pb.GetConfigRequest) (*pb.G
 	config, err := a.uc.GetConfig(in.GetOwnerToken())
 	if err != nil {
 		log.Printf("Error getting config: %v", err)
-		return nil, err
+		return nil, grpcError(err)
 	}
 	return &pb.GetConfigResponse{
 		DisplayName: config.DisplayName,
@@ -55,7 +90,7 @@ func (a *Adaptor) CheckDirectoryExists(ctx context.Context, in *pb.CheckDirector
 	exists, err := a.uc.CheckDirectoryExists(domain.NewPath(in.GetPath()))
 	if err != nil {
 		log.Printf("Error checking directory existence: %v", err)
-		return nil, err
+		return nil, grpcError(err)
 	}
 	return &pb.CheckDirectoryExistsResponse{Exists: exists}, nil
 }
@@ -64,6 +99,9 @@ func (a *Adaptor) SetConfig(ctx context.Context, in *pb.SetConfigRequest) (*pb.S
 	config := domain.NewConfig(in.GetDisplayName(), in.GetOwnerToken())
 	if err := a.uc.SetConfig(config); err != nil {
 		log.Printf("Error setting config: %v", err)
+		if statusErr, ok := statusError(err, "display_name"); ok {
+			return nil, statusErr
+		}
 		return &pb.SetConfigResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
 	}
 	return &pb.SetConfigResponse{Status: &pb.Status{Ok: true}}, nil
@@ -73,6 +111,9 @@ func (a *Adaptor) CreateRoom(ctx context.Context, in *pb.CreateRoomRequest) (*pb
 	err := a.uc.CreateRoom(domain.NewPath(in.GetPath()), in.GetOwnerToken())
 	if err != nil {
 		log.Printf("Error creating room: %v", err)
+		if statusErr, ok := statusError(err, "path"); ok {
+			return nil, statusErr
+		}
 		return &pb.CreateRoomResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
 	}
 	return &pb.CreateRoomResponse{Status: &pb.Status{Ok: true}}, nil
@@ -82,6 +123,9 @@ func (a *Adaptor) CreateDirectory(ctx context.Context, in *pb.CreateDirectoryReq
 	err := a.uc.CreateDirectory(domain.NewPath(in.GetPath()), in.GetOwnerToken())
 	if err != nil {
 		log.Printf("Error creating directory: %v", err)
+		if statusErr, ok := statusError(err, "path"); ok {
+			return nil, statusErr
+		}
 		return &pb.CreateDirectoryResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
 	}
 	return &pb.CreateDirectoryResponse{Status: &pb.Status{Ok: true}}, nil
@@ -91,6 +135,9 @@ func (a *Adaptor) DeletePath(ctx context.Context, in *pb.DeletePathRequest) (*pb
 	err := a.uc.DeletePath(domain.NewPath(in.GetPath()), in.GetOwnerToken())
 	if err != nil {
 		log.Printf("Error deleting path: %v", err)
+		if statusErr, ok := statusError(err, "path"); ok {
+			return nil, statusErr
+		}
 		return &pb.DeletePathResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
 	}
 	return &pb.DeletePathResponse{Status: &pb.Status{Ok: true}}, nil
@@ -100,6 +147,9 @@ func (a *Adaptor) CopyPath(ctx context.Context, in *pb.CopyPathRequest) (*pb.Cop
 	err := a.uc.CopyPath(domain.NewPath(in.GetSourcePath()), domain.NewPath(in.GetDestinationPath()), in.GetOwnerToken())
 	if err != nil {
 		log.Printf("Error copying path: %v", err)
+		if statusErr, ok := statusError(err, "source_path"); ok {
+			return nil, statusErr
+		}
 		return &pb.CopyPathResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
 	}
 	return &pb.CopyPathResponse{Status: &pb.Status{Ok: true}}, nil
@@ -109,16 +159,19 @@ func (a *Adaptor) MovePath(ctx context.Context, in *pb.MovePathRequest) (*pb.Mov
 	err := a.uc.MovePath(domain.NewPath(in.GetSourcePath()), domain.NewPath(in.GetDestinationPath()), in.GetOwnerToken())
 	if err != nil {
 		log.Printf("Error moving path: %v", err)
+		if statusErr, ok := statusError(err, "source_path"); ok {
+			return nil, statusErr
+		}
 		return &pb.MovePathResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
 	}
 	return &pb.MovePathResponse{Status: &pb.Status{Ok: true}}, nil
 }
 
 func (a *Adaptor) ListNodes(ctx context.Context, in *pb.ListNodesRequest) (*pb.ListNodesResponse, error) {
-	nodes, err := a.uc.ListNodes(domain.NewPath(in.GetPath()))
+	nodes, err := a.uc.ListNodes(domain.NewPath(in.GetPath()), in.GetOwnerToken(), in.GetWithUnread(), in.GetWithRetention())
 	if err != nil {
 		log.Printf("Error listing nodes: %v", err)
-		return nil, err
+		return nil, grpcError(err)
 	}
 
 	pbNodeInfos := make([]*pb.NodeInfo, len(nodes))
@@ -128,20 +181,25 @@ func (a *Adaptor) ListNodes(ctx context.Context, in *pb.ListNodesRequest) (*pb.L
 	return &pb.ListNodesResponse{Entries: pbNodeInfos}, nil
 }
 
+func (a *Adaptor) StatPath(ctx context.Context, in *pb.StatPathRequest) (*pb.StatPathResponse, error) {
+	node, err := a.uc.StatPath(domain.NewPath(in.GetPath()), in.GetOwnerToken())
+	if err != nil {
+		log.Printf("Error getting path: %v", err)
+		return nil, grpcError(err)
+	}
+	return &pb.StatPathResponse{Node: toPbNodeInfo(node)}, nil
+}
+
 func (a *Adaptor) SearchMessage(ctx context.Context, in *pb.SearchMessageRequest) (*pb.SearchMessageResponse, error) {
 	messages, err := a.uc.SearchMessage(domain.NewPath(in.GetPath()), in.GetPattern())
 	if err != nil {
 		log.Printf("Error searching messages: %v", err)
-		return nil, err
+		return nil, grpcError(err)
 	}
 
 	pbMessages := make([]*pb.Message, len(messages))
 	for i, message := range messages {
-		pbMessages[i] = &pb.Message{
-			TextContent: message.Content,
-			OwnerName:   message.DisplayName,
-			Created:     timestamppb.New(message.CreatedAt),
-		}
+		pbMessages[i] = toPbMessage(message)
 	}
 	return &pb.SearchMessageResponse{Messages: pbMessages}, nil
 }
@@ -150,6 +208,9 @@ func (a *Adaptor) WriteMessage(ctx context.Context, in *pb.WriteMessageRequest)
 	err := a.uc.WriteMessage(domain.NewPath(in.GetDestinationPath()), in.GetTextContent(), in.GetOwnerToken())
 	if err != nil {
 		log.Printf("Error writing message: %v", err)
+		if statusErr, ok := statusError(err, "text_content"); ok {
+			return nil, statusErr
+		}
 		return &pb.WriteMessageResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
 	}
 	return &pb.WriteMessageResponse{Status: &pb.Status{Ok: true}}, nil
@@ -164,6 +225,7 @@ func (a *Adaptor) StreamMessage(stream pb.ChatshService_StreamMessageServer) err
 
 	requestChan := make(chan domain.StreamRequest, 32)
 	responseChan := make(chan domain.StreamResponse, 32)
+	defer a.streamLimiter.forget(sessionID)
 
 	usecaseErr := make(chan error, 1)
 	go func() {
@@ -173,8 +235,18 @@ func (a *Adaptor) StreamMessage(stream pb.ChatshService_StreamMessageServer) err
 		}
 	}()
 
+	// Responses and rejections are sent from different goroutines, and gRPC streams take one sender at a time
+	var sendMu sync.Mutex
+	send := func(msg *pb.ServerMessage) error {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		return stream.Send(msg)
+	}
+
 	responseErr := make(chan error, 1)
+	responseDone := make(chan struct{})
 	go func() {
+		defer close(responseDone)
 		for response := range responseChan {
 			if response.IsError() {
 				log.Printf("StreamMessage: error response: %v", response.Error)
@@ -185,55 +257,79 @@ func (a *Adaptor) StreamMessage(stream pb.ChatshService_StreamMessageServer) err
 				Name: response.Name,
 				Text: response.Message,
 			}
+			for _, id := range response.ExpiredIDs {
+				pbMessage.ExpiredMessageIds = append(pbMessage.ExpiredMessageIds, int64(id))
+			}
 
-			if err := stream.Send(pbMessage); err != nil {
+			if err := send(pbMessage); err != nil {
 				responseErr <- fmt.Errorf("failed to send response: %w", err)
 				return
 			}
 		}
 	}()
 
-	defer close(requestChan)
+	// Receive in the background so that the usecase can end the stream, e.g. when the client is kicked
+	// recvErr ends the stream with a broken receive or a rejected request
+	recvErr := make(chan error, 1)
+	go func() {
+		defer close(requestChan)
+		for {
+			in, err := stream.Recv()
+			if err != nil {
+				if err == io.EOF {
+					log.Printf("Client %s disconnected normally", sessionID)
+					// Half-closed clients such as tail keep receiving until the stream ends
+					<-stream.Context().Done()
+				} else {
+					log.Printf("Client %s disconnected with error: %v", sessionID, err)
+					recvErr <- err
+				}
+				return
+			}
 
-	for {
-		in, err := stream.Recv()
-		if err != nil {
-			if err == io.EOF {
-				log.Printf("Client %s disconnected normally", sessionID)
-			} else {
-				log.Printf("Client %s disconnected with error: %v", sessionID, err)
+			domainRequest, err := a.convertPbToDomainRequest(in)
+			if err != nil {
+				log.Printf("StreamMessage: failed to convert request: %v", err)
+				continue
 			}
-			break
-		}
 
-		domainRequest, err := a.convertPbToDomainRequest(in)
-		if err != nil {
-			log.Printf("StreamMessage: failed to convert request: %v", err)
-			continue
-		}
+			// Chat messages beyond the session's rate are dropped so one client cannot flood the room;
+			// the client is told when to retry and keeps its session
+			if domainRequest.Type == domain.RequestChat {
+				if ok, retryAfter := a.streamLimiter.allow(sessionID); !ok {
+					log.Printf("StreamMessage: client %s: dropped a chat message over the rate limit", sessionID)
+					if err := send(streamRejection(retryAfter)); err != nil {
+						recvErr <- fmt.Errorf("failed to send rejection: %w", err)
+						return
+					}
+					continue
+				}
+			}
 
-		select {
-		case requestChan <- domainRequest:
-		case <-stream.Context().Done():
-			return stream.Context().Err()
-		case err := <-usecaseErr:
-			return err
-		case err := <-responseErr:
-			return err
+			select {
+			case requestChan <- domainRequest:
+			case <-stream.Context().Done():
+				return
+			}
 		}
-	}
+	}()
 
 	select {
 	case err := <-usecaseErr:
 		if err != nil {
 			log.Printf("StreamMessage: usecase error: %v", err)
-			return err
+			// Deliver the responses queued before the session ended
+			<-responseDone
+			return grpcError(err)
 		}
 	case err := <-responseErr:
 		if err != nil {
 			log.Printf("StreamMessage: response error: %v", err)
 			return err
 		}
+	case err := <-recvErr:
+		log.Printf("StreamMessage: client %s: %v", sessionID, err)
+		return err
 	case <-stream.Context().Done():
 		return stream.Context().Err()
 	}
@@ -243,11 +339,11 @@ func (a *Adaptor) StreamMessage(stream pb.ChatshService_StreamMessageServer) err
 
 func (a *Adaptor) convertPbToDomainRequest(in *pb.ClientMessage) (domain.StreamRequest, error) {
 	if join := in.GetJoin(); join != nil {
-		return domain.NewJoinRequest(join.GetName(), join.GetRoom()), nil
+		return domain.NewJoinRequest(join.GetName(), join.GetOwnerToken(), join.GetRoom()), nil
 	}
 
 	if tail := in.GetTail(); tail != nil {
-		return domain.NewTailRequest(tail.GetRoomPath()), nil
+		return domain.NewTailRequest(tail.GetOwnerToken(), tail.GetRoomPath()), nil
 	}
 
 	if chat := in.GetChat(); chat != nil {
@@ -257,20 +353,203 @@ func (a *Adaptor) convertPbToDomainRequest(in *pb.ClientMessage) (domain.StreamR
 	return domain.StreamRequest{}, fmt.Errorf("unknown request type")
 }
 
+// maxMessagePage is the most messages a page of ListMessages holds, and the size of pages asked for without a limit
+const maxMessagePage = 1000
+
 func (a *Adaptor) ListMessages(ctx context.Context, in *pb.ListMessagesRequest) (*pb.ListMessagesResponse, error) {
-	messages, err := a.uc.ListMessages(domain.NewPath(in.GetRoomPath()), in.GetLimit())
+	var messages []domain.Message
+	var nextPageToken string
+	var err error
+	if in.GetFromStart() || in.GetPageToken() != "" {
+		messages, nextPageToken, err = a.listMessagePage(in)
+	} else {
+		messages, err = a.uc.ListMessages(domain.NewPath(in.GetRoomPath()), in.GetLimit())
+	}
 	if err != nil {
 		log.Printf("Error getting past messages for room %s: %v", in.GetRoomPath(), err)
-		return nil, err
+		return nil, grpcError(err)
 	}
 
 	pbMessages := make([]*pb.Message, len(messages))
 	for i, message := range messages {
-		pbMessages[i] = &pb.Message{
-			TextContent: message.Content,
-			OwnerName:   message.DisplayName,
-			Created:     timestamppb.New(message.CreatedAt),
+		pbMessages[i] = toPbMessage(message)
+	}
+
+	var lastReadID int
+	if in.GetOwnerToken() != "" {
+		lastReadID, err = a.uc.GetReadMarker(domain.NewPath(in.GetRoomPath()), in.GetOwnerToken())
+		if err != nil {
+			log.Printf("Error getting read marker for room %s: %v", in.GetRoomPath(), err)
+			return nil, grpcError(err)
+		}
+	}
+	return &pb.ListMessagesResponse{Messages: pbMessages, LastReadMessageId: int64(lastReadID), NextPageToken: nextPageToken}, nil
+}
+
+// listMessagePage returns the page of messages the request asks for, oldest first, and the token
+// of the next page. The token is the id of the last message, which a full page cannot tell apart
+// from the end of the history, so the last page may come back empty.
+func (a *Adaptor) listMessagePage(in *pb.ListMessagesRequest) ([]domain.Message, string, error) {
+	afterID := 0
+	if token := in.GetPageToken(); token != "" {
+		id, err := strconv.Atoi(token)
+		if err != nil || id < 0 {
+			return nil, "", &domain.InvalidMessageError{Field: "page_token", Reason: "is not a token returned by ListMessages"}
+		}
+		afterID = id
+	}
+	limit := int(in.GetLimit())
+	if limit <= 0 || limit > maxMessagePage {
+		limit = maxMessagePage
+	}
+	messages, err := a.uc.ListMessagesAfter(domain.NewPath(in.GetRoomPath()), afterID, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(messages) < limit {
+		return messages, "", nil
+	}
+	return messages, strconv.Itoa(messages[len(messages)-1].ID), nil
+}
+
+func (a *Adaptor) ListMentions(ctx context.Context, in *pb.ListMentionsRequest) (*pb.ListMentionsResponse, error) {
+	mentions, err := a.uc.ListMentions(in.GetOwnerToken(), in.GetUnreadOnly())
+	if err != nil {
+		log.Printf("Error listing mentions: %v", err)
+		return nil, grpcError(err)
+	}
+
+	pbMentions := make([]*pb.Mention, len(mentions))
+	for i, mention := range mentions {
+		pbMentions[i] = &pb.Mention{
+			Id:          int64(mention.ID),
+			RoomPath:    mention.RoomPath,
+			SenderName:  mention.SenderName,
+			TextContent: mention.Content,
+			Created:     timestamppb.New(mention.CreatedAt),
+			Read:        mention.IsRead,
+		}
+	}
+	return &pb.ListMentionsResponse{Mentions: pbMentions}, nil
+}
+
+func (a *Adaptor) MarkMentionsRead(ctx context.Context, in *pb.MarkMentionsReadRequest) (*pb.MarkMentionsReadResponse, error) {
+	mentionIDs := make([]int, len(in.GetIds()))
+	for i, id := range in.GetIds() {
+		mentionIDs[i] = int(id)
+	}
+	if err := a.uc.MarkMentionsRead(in.GetOwnerToken(), mentionIDs); err != nil {
+		log.Printf("Error marking mentions as read: %v", err)
+		if statusErr, ok := statusError(err, "ids"); ok {
+			return nil, statusErr
+		}
+		return &pb.MarkMentionsReadResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
+	}
+	return &pb.MarkMentionsReadResponse{Status: &pb.Status{Ok: true}}, nil
+}
+
+func (a *Adaptor) MarkRead(ctx context.Context, in *pb.MarkReadRequest) (*pb.MarkReadResponse, error) {
+	if err := a.uc.MarkRead(domain.NewPath(in.GetRoomPath()), in.GetOwnerToken(), int(in.GetMessageId())); err != nil {
+		log.Printf("Error marking room %s as read: %v", in.GetRoomPath(), err)
+		if statusErr, ok := statusError(err, "room_path"); ok {
+			return nil, statusErr
+		}
+		return &pb.MarkReadResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
+	}
+	return &pb.MarkReadResponse{Status: &pb.Status{Ok: true}}, nil
+}
+
+var moderationActions = map[pb.ModerationAction]domain.ModerationAction{
+	pb.ModerationAction_KICK:      domain.ModerationKick,
+	pb.ModerationAction_BAN:       domain.ModerationBan,
+	pb.ModerationAction_UNBAN:     domain.ModerationUnban,
+	pb.ModerationAction_MUTE:      domain.ModerationMute,
+	pb.ModerationAction_UNMUTE:    domain.ModerationUnmute,
+	pb.ModerationAction_SLOW_MODE: domain.ModerationSlowMode,
+	pb.ModerationAction_LIMITS:    domain.ModerationLimits,
+}
+
+func toPbModerationAction(action domain.ModerationAction) pb.ModerationAction {
+	for pbAction, domainAction := range moderationActions {
+		if domainAction == action {
+			return pbAction
+		}
+	}
+	return pb.ModerationAction_MODERATION_UNKNOWN
+}
+
+func (a *Adaptor) ModerateRoom(ctx context.Context, in *pb.ModerateRoomRequest) (*pb.ModerateRoomResponse, error) {
+	err := a.uc.ModerateRoom(
+		domain.NewPath(in.GetRoomPath()),
+		in.GetOwnerToken(),
+		moderationActions[in.GetAction()],
+		in.GetTargetName(),
+		int(in.GetSlowModeSeconds()),
+		in.GetReason(),
+	)
+	if err != nil {
+		log.Printf("Error moderating room %s: %v", in.GetRoomPath(), err)
+		if statusErr, ok := statusError(err, "room_path"); ok {
+			return nil, statusErr
+		}
+		return &pb.ModerateRoomResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
+	}
+	return &pb.ModerateRoomResponse{Status: &pb.Status{Ok: true}}, nil
+}
+
+func (a *Adaptor) GetRoomModeration(ctx context.Context, in *pb.GetRoomModerationRequest) (*pb.GetRoomModerationResponse, error) {
+	moderation, err := a.uc.GetRoomModeration(domain.NewPath(in.GetRoomPath()), in.GetOwnerToken())
+	if err != nil {
+		log.Printf("Error getting moderation of room %s: %v", in.GetRoomPath(), err)
+		return nil, grpcError(err)
+	}
+
+	res := &pb.GetRoomModerationResponse{
+		SlowModeSeconds:  int32(moderation.Settings.SlowModeSeconds),
+		MaxMessageLength: int32(moderation.Limits.MaxLength),
+		MaxMessageLines:  int32(moderation.Limits.MaxLines),
+	}
+	for _, restriction := range moderation.Restrictions {
+		switch restriction.Type {
+		case domain.RestrictionBan:
+			res.BannedNames = append(res.BannedNames, restriction.DisplayName)
+		case domain.RestrictionMute:
+			res.MutedNames = append(res.MutedNames, restriction.DisplayName)
+		}
+	}
+	for _, entry := range moderation.Logs {
+		res.Logs = append(res.Logs, &pb.ModerationLogEntry{
+			ActorName:  entry.ActorName,
+			Action:     toPbModerationAction(entry.Action),
+			TargetName: entry.TargetName,
+			Detail:     entry.Detail,
+			Created:    timestamppb.New(entry.CreatedAt),
+		})
+	}
+	return res, nil
+}
+
+func (a *Adaptor) SetRoomLimits(ctx context.Context, in *pb.SetRoomLimitsRequest) (*pb.SetRoomLimitsResponse, error) {
+	limits := domain.NewMessageLimits(int(in.GetMaxMessageLength()), int(in.GetMaxMessageLines()))
+	if err := a.uc.SetRoomLimits(domain.NewPath(in.GetRoomPath()), in.GetOwnerToken(), limits); err != nil {
+		log.Printf("Error setting limits of room %s: %v", in.GetRoomPath(), err)
+		if statusErr, ok := statusError(err, "max_message_length"); ok {
+			return nil, statusErr
+		}
+		return &pb.SetRoomLimitsResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
+	}
+	return &pb.SetRoomLimitsResponse{Status: &pb.Status{Ok: true}}, nil
+}
+
+func (a *Adaptor) SetRetention(ctx context.Context, in *pb.SetRetentionRequest) (*pb.SetRetentionResponse, error) {
+	policy := domain.NewRetentionPolicy(domain.NodeTypeUnknown, 0, in.GetPath(),
+		time.Duration(in.GetMaxAgeSeconds())*time.Second, int(in.GetMaxMessages()), time.Duration(in.GetIdleSeconds())*time.Second, time.Time{})
+	if err := a.uc.SetRetention(domain.NewPath(in.GetPath()), in.GetOwnerToken(), policy, in.GetInherit()); err != nil {
+		log.Printf("Error setting retention policy of %s: %v", in.GetPath(), err)
+		if statusErr, ok := statusError(err, "max_age_seconds"); ok {
+			return nil, statusErr
 		}
+		return &pb.SetRetentionResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
 	}
-	return &pb.ListMessagesResponse{Messages: pbMessages}, nil
+	return &pb.SetRetentionResponse{Status: &pb.Status{Ok: true}}, nil
 }
@@ -0,0 +1,61 @@
+package adaptor_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/ponyo877/chatsh/server/adaptor"
+	"github.com/ponyo877/chatsh/server/domain"
+	"github.com/ponyo877/chatsh/server/repository/memory"
+	"github.com/ponyo877/chatsh/server/usecase"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// newAdaptor returns an Adaptor over an empty in-memory store, and the usecase behind it
+func newAdaptor(t *testing.T, limits adaptor.RateLimits, identities adaptor.Identities) (*adaptor.Adaptor, adaptor.Usecase) {
+	t.Helper()
+	uc := usecase.NewUsecase(memory.NewRepository(), domain.NewMessageLimits(4000, 100), time.Minute,
+		domain.WebhookOptions{}, domain.RetentionOptions{}, domain.NewMessageHooks(), nil)
+	return adaptor.NewAdaptor(uc, limits, identities), uc
+}
+
+// serve serves ad on lis with the interceptors of the server and returns a client of it
+func serve(t *testing.T, ad *adaptor.Adaptor, lis net.Listener, creds credentials.TransportCredentials) pb.ChatshServiceClient {
+	t.Helper()
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(ad.UnaryIdentityInterceptor(), ad.UnaryRateLimitInterceptor()),
+		grpc.StreamInterceptor(ad.StreamIdentityInterceptor()),
+	}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+	s := grpc.NewServer(opts...)
+	pb.RegisterChatshServiceServer(s, ad)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	target := "passthrough:///" + lis.Addr().String()
+	if lis.Addr().Network() == "unix" {
+		target = "unix://" + lis.Addr().String()
+	}
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewChatshServiceClient(conn)
+}
+
+// serveTCP serves ad on a plaintext TCP port of the loopback interface
+func serveTCP(t *testing.T, ad *adaptor.Adaptor) pb.ChatshServiceClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	return serve(t, ad, lis, nil)
+}
@@ -0,0 +1,103 @@
+package adaptor
+
+import (
+	"bufio"
+	"io"
+	"log"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/ponyo877/chatsh/server/domain"
+	"google.golang.org/grpc"
+)
+
+// archiveChunkWriter sends everything written to it as ArchiveChunk messages
+type archiveChunkWriter struct {
+	stream grpc.ServerStreamingServer[pb.ArchiveChunk]
+}
+
+func (w archiveChunkWriter) Write(p []byte) (int, error) {
+	if err := w.stream.Send(&pb.ArchiveChunk{Data: p}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (a *Adaptor) Export(in *pb.ExportRequest, stream grpc.ServerStreamingServer[pb.ArchiveChunk]) error {
+	path := domain.NewPath(in.GetPath())
+	// Chunks of backupChunkSize keep messages small without sending every JSON line on its own
+	w := bufio.NewWriterSize(archiveChunkWriter{stream: stream}, backupChunkSize)
+	if err := a.uc.Export(path, w); err != nil {
+		log.Printf("Error exporting %s: %v", path, err)
+		return grpcError(err)
+	}
+	if err := w.Flush(); err != nil {
+		log.Printf("Error sending export of %s: %v", path, err)
+		return err
+	}
+	return nil
+}
+
+// importReader reads the archive out of the data of ImportRequest messages
+type importReader struct {
+	stream grpc.ClientStreamingServer[pb.ImportRequest, pb.ImportResponse]
+	data   []byte
+}
+
+func (r *importReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		in, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.data = in.GetData()
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func toDomainConflictPolicy(policy pb.ConflictPolicy) domain.ConflictPolicy {
+	switch policy {
+	case pb.ConflictPolicy_CONFLICT_SKIP:
+		return domain.ConflictSkip
+	case pb.ConflictPolicy_CONFLICT_RENAME:
+		return domain.ConflictRename
+	default:
+		return domain.ConflictFail
+	}
+}
+
+func (a *Adaptor) Import(stream grpc.ClientStreamingServer[pb.ImportRequest, pb.ImportResponse]) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return stream.SendAndClose(&pb.ImportResponse{Status: &pb.Status{Ok: false, Message: "no archive given"}})
+	}
+	if err != nil {
+		return err
+	}
+	// Imports write like any mutating RPC, which only the unary interceptor limits
+	if ok, retryAfter := a.writeLimiter.allow(a.rateLimitKey(stream.Context(), first.GetOwnerToken())); !ok {
+		stream.SetTrailer(retryAfterTrailer(retryAfter))
+		return rateLimitError(retryAfter)
+	}
+	path := domain.NewPath(first.GetDestinationPath())
+	reader := &importReader{stream: stream, data: first.GetData()}
+	result, err := a.uc.Import(path, first.GetName(), first.GetOwnerToken(), toDomainConflictPolicy(first.GetOnConflict()), reader)
+	if err != nil {
+		log.Printf("Error importing into %s: %v", path, err)
+		if statusErr, ok := statusError(err, "destination_path"); ok {
+			return statusErr
+		}
+		return stream.SendAndClose(&pb.ImportResponse{Status: &pb.Status{Ok: false, Message: err.Error()}})
+	}
+	log.Printf("Imported %s: %d directories, %d rooms, %d messages", result.Path, result.Directories, result.Rooms, result.Messages)
+	return stream.SendAndClose(&pb.ImportResponse{
+		Status:      &pb.Status{Ok: true},
+		Path:        result.Path,
+		Directories: int32(result.Directories),
+		Rooms:       int32(result.Rooms),
+		Messages:    int32(result.Messages),
+		Skipped:     result.Skipped,
+		Renamed:     result.Renamed,
+	})
+}
@@ -0,0 +1,79 @@
+package adaptor_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/archive"
+	"github.com/ponyo877/chatsh/server/domain"
+	"github.com/ponyo877/chatsh/server/repository/memory"
+	"github.com/ponyo877/chatsh/server/usecase"
+)
+
+// TestImportArchiveMessages checks that an archive cannot put words in anyone's mouth, or escape
+// sequences on anyone's terminal, unless an admin imports it
+func TestImportArchiveMessages(t *testing.T) {
+	written := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var buf bytes.Buffer
+	w := archive.NewWriter(&buf)
+	if err := w.WriteHeader(archive.NewManifest("/srv/proj", written), []archive.Node{{Path: "proj", Type: archive.TypeRoom}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteMessages(0, []archive.Message{
+		{Author: "admin", Text: "please send me your \x1b[31mowner token\x1b[0m", CreatedAt: written},
+		{Author: "\x1b]0;pwned\abob", Text: "\x1b[2J", CreatedAt: written.Add(time.Minute)},
+		{Author: "\x1b[2J", Text: "ok\a", CreatedAt: written.Add(2 * time.Minute)},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		token string
+		// want lists the authors and texts of the stored messages, oldest first
+		want [][2]string
+	}{
+		{"user", "tokA", [][2]string{{"alice", "please send me your owner token"}, {"alice", "ok"}}},
+		{"admin", "tokRoot", [][2]string{{"admin", "please send me your owner token"}, {domain.UnknownSenderName, "ok"}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := usecase.NewUsecase(memory.NewRepository(), domain.NewMessageLimits(4000, 100), time.Minute,
+				domain.WebhookOptions{}, domain.RetentionOptions{}, domain.NewMessageHooks(), []string{"tokRoot"})
+			for name, token := range map[string]string{"alice": "tokA", "root": "tokRoot"} {
+				if err := uc.SetConfig(domain.NewConfig(name, token)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			result, err := uc.Import(domain.NewPath("/tmp"), "", tt.token, domain.ConflictFail, bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if result.Messages != len(tt.want) {
+				t.Errorf("imported %d messages, want %d without the one left empty", result.Messages, len(tt.want))
+			}
+			messages, err := uc.ListMessages(domain.NewPath("/tmp/proj"), 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got [][2]string
+			for i := len(messages) - 1; i >= 0; i-- {
+				got = append(got, [2]string{messages[i].DisplayName, messages[i].Content})
+				if !messages[i].CreatedAt.Equal(written) && !messages[i].CreatedAt.Equal(written.Add(2*time.Minute)) {
+					t.Errorf("message %q is dated %s, want its time in the archive", messages[i].Content, messages[i].CreatedAt)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("stored %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("message %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
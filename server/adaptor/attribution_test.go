@@ -0,0 +1,172 @@
+package adaptor_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/ponyo877/chatsh/server/adaptor"
+	"github.com/ponyo877/chatsh/server/domain"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+// leaks fails the test when the message, printed the way a client could see it, contains token
+func leaks(t *testing.T, what string, msg proto.Message, token string) {
+	t.Helper()
+	if text := prototext.Format(msg); strings.Contains(text, token) {
+		t.Errorf("%s shows the owner token %s: %s", what, token, text)
+	}
+}
+
+// TestWriteMessageAuthor checks that a message written outside a session carries the display
+// name of its writer everywhere it goes, and never their owner token
+func TestWriteMessageAuthor(t *testing.T) {
+	const aliceToken, strangerToken = "secret-alice", "secret-stranger"
+	ad, uc := newAdaptor(t, adaptor.RateLimits{}, adaptor.Identities{})
+	for name, token := range map[string]string{"alice": aliceToken, "bob": "secret-bob", "carol": "secret-carol"} {
+		if err := uc.SetConfig(domain.NewConfig(name, token)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := uc.CreateRoom(domain.NewPath("/tmp/room"), aliceToken); err != nil {
+		t.Fatal(err)
+	}
+	client := serveTCP(t, ad)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stream, err := client.StreamMessage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	join := &pb.ClientMessage{Payload: &pb.ClientMessage_Join{Join: &pb.Join{Name: "bob", Room: "/tmp/room", OwnerToken: "secret-bob"}}}
+	if err := stream.Send(join); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		token string
+		want  string
+	}{
+		{aliceToken, "alice"},
+		{strangerToken, domain.UnknownSenderName},
+	} {
+		if _, err := client.WriteMessage(ctx, &pb.WriteMessageRequest{DestinationPath: "/tmp/room", TextContent: "hi @carol", OwnerToken: tt.token}); err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+		msg, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.GetName() != tt.want {
+			t.Errorf("broadcast from %s, want %s", msg.GetName(), tt.want)
+		}
+		leaks(t, "broadcast", msg, tt.token)
+	}
+
+	messages, err := client.ListMessages(ctx, &pb.ListMessagesRequest{RoomPath: "/tmp/room", Limit: 10, OwnerToken: aliceToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages.GetMessages()) != 3 || messages.GetMessages()[0].GetOwnerName() != domain.UnknownSenderName || messages.GetMessages()[1].GetOwnerName() != "alice" {
+		t.Errorf("ListMessages = %v, want the messages of alice and of %s after bob's join", messages.GetMessages(), domain.UnknownSenderName)
+	}
+	mentions, err := client.ListMentions(ctx, &pb.ListMentionsRequest{OwnerToken: "secret-carol"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mentions.GetMentions()) != 2 {
+		t.Errorf("ListMentions = %v, want both mentions of carol", mentions.GetMentions())
+	}
+	for _, token := range []string{aliceToken, strangerToken} {
+		leaks(t, "ListMessages", messages, token)
+		leaks(t, "ListMentions", mentions, token)
+	}
+}
+
+// TestRESTPostAuthor checks that a message posted over REST, as a CI job would with its bearer
+// token, is shown under the caller's display name to readers of the list and of the stream
+func TestRESTPostAuthor(t *testing.T) {
+	const ciToken = "secret-ci"
+	ad, uc := newAdaptor(t, adaptor.RateLimits{}, adaptor.Identities{})
+	for name, token := range map[string]string{"deploy-bot": ciToken, "bob": "secret-bob"} {
+		if err := uc.SetConfig(domain.NewConfig(name, token)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := uc.CreateRoom(domain.NewPath("/tmp/deploys"), ciToken); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := serveTCP(t, ad).StreamMessage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	join := &pb.ClientMessage{Payload: &pb.ClientMessage_Join{Join: &pb.Join{Name: "bob", Room: "/tmp/deploys", OwnerToken: "secret-bob"}}}
+	if err := stream.Send(join); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(ad.RESTHandler())
+	defer server.Close()
+	do := func(method, body string) []byte {
+		t.Helper()
+		req, err := http.NewRequest(method, server.URL+adaptor.RESTPrefix+"rooms/tmp/deploys/messages", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+ciToken)
+		req.Header.Set("Content-Type", "application/json")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		data, err := io.ReadAll(res.Body)
+		if err != nil || res.StatusCode >= 300 {
+			t.Fatalf("%s = %s %s, %v", method, res.Status, data, err)
+		}
+		return data
+	}
+	do(http.MethodPost, `{"text": "deployed v1.2"}`)
+
+	msg, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.GetName() != "deploy-bot" || msg.GetText() != "deployed v1.2" {
+		t.Errorf("stream got %v, want deploy-bot's message", msg)
+	}
+	leaks(t, "stream", msg, ciToken)
+
+	data := do(http.MethodGet, "")
+	if strings.Contains(string(data), ciToken) {
+		t.Errorf("GET messages shows the owner token: %s", data)
+	}
+	var list struct {
+		Messages []struct {
+			Author string `json:"author"`
+			Text   string `json:"text"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Messages) == 0 || list.Messages[0].Author != "deploy-bot" || list.Messages[0].Text != "deployed v1.2" {
+		t.Errorf("GET messages = %s, want deploy-bot's message first", data)
+	}
+}
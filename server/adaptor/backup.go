@@ -0,0 +1,53 @@
+package adaptor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"google.golang.org/grpc"
+)
+
+// backupChunkSize keeps every message far below the default 4 MiB limit of gRPC
+const backupChunkSize = 64 * 1024
+
+func (a *Adaptor) Backup(in *pb.BackupRequest, stream grpc.ServerStreamingServer[pb.BackupChunk]) error {
+	snapshot, err := a.uc.Backup(in.GetOwnerToken())
+	if err != nil {
+		log.Printf("Error taking backup: %v", err)
+		return grpcError(err)
+	}
+	defer snapshot.Close()
+
+	hash := sha256.New()
+	buf := make([]byte, backupChunkSize)
+	chunk := &pb.BackupChunk{Size: snapshot.Size}
+	for {
+		n, err := snapshot.Read(buf)
+		if n > 0 {
+			hash.Write(buf[:n])
+			chunk.Data = buf[:n]
+			if err := stream.Send(chunk); err != nil {
+				log.Printf("Error sending backup: %v", err)
+				return err
+			}
+			chunk = &pb.BackupChunk{}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("Error reading backup: %v", err)
+			return err
+		}
+	}
+	chunk.Sha256 = hex.EncodeToString(hash.Sum(nil))
+	if err := stream.Send(chunk); err != nil {
+		log.Printf("Error sending backup: %v", err)
+		return err
+	}
+	log.Printf("Sent a backup of %d bytes", snapshot.Size)
+	return nil
+}
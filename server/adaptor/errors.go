@@ -0,0 +1,88 @@
+package adaptor
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ponyo877/chatsh/server/domain"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorDomain identifies chatsh in the ErrorInfo of error details
+const errorDomain = "chatsh"
+
+// domainErrors maps each typed domain error to its gRPC code, HTTP status and the ErrorInfo
+// reason clients switch on
+var domainErrors = []struct {
+	err        error
+	code       codes.Code
+	httpStatus int
+	reason     string
+}{
+	{domain.ErrNotFound, codes.NotFound, http.StatusNotFound, "NOT_FOUND"},
+	{domain.ErrAlreadyExists, codes.AlreadyExists, http.StatusConflict, "ALREADY_EXISTS"},
+	{domain.ErrPermissionDenied, codes.PermissionDenied, http.StatusForbidden, "PERMISSION_DENIED"},
+	{domain.ErrNotARoom, codes.FailedPrecondition, http.StatusConflict, "NOT_A_ROOM"},
+	{domain.ErrNotADirectory, codes.FailedPrecondition, http.StatusConflict, "NOT_A_DIRECTORY"},
+	{domain.ErrNotEmpty, codes.FailedPrecondition, http.StatusConflict, "NOT_EMPTY"},
+	{domain.ErrUnsupported, codes.Unimplemented, http.StatusNotImplemented, "UNSUPPORTED"},
+}
+
+// statusError converts typed domain errors into gRPC statuses whose details carry the offending path.
+// field names the request field blamed for invalid arguments unless the error names one itself.
+// ok is false for errors without a type, which callers report as before.
+func statusError(err error, field string) (error, bool) {
+	var invalid *domain.InvalidMessageError
+	if errors.As(err, &invalid) {
+		return invalidArgumentError(field, invalid), true
+	}
+	for _, de := range domainErrors {
+		if !errors.Is(err, de.err) {
+			continue
+		}
+		st := status.New(de.code, err.Error())
+		info := &errdetails.ErrorInfo{Reason: de.reason, Domain: errorDomain}
+		var detailed *status.Status
+		var detailErr error
+		var pathErr *domain.PathError
+		if errors.As(err, &pathErr) {
+			info.Metadata = map[string]string{"path": pathErr.Path}
+			detailed, detailErr = st.WithDetails(info, &errdetails.ResourceInfo{
+				ResourceType: "node",
+				ResourceName: pathErr.Path,
+				Description:  de.err.Error(),
+			})
+		} else {
+			detailed, detailErr = st.WithDetails(info)
+		}
+		if detailErr == nil {
+			st = detailed
+		}
+		return st.Err(), true
+	}
+	return nil, false
+}
+
+// grpcError is statusError for RPCs without a Status field, which return untyped errors unchanged
+func grpcError(err error) error {
+	if statusErr, ok := statusError(err, ""); ok {
+		return statusErr
+	}
+	return err
+}
+
+// invalidArgumentError converts validation failures into InvalidArgument statuses naming the offending field
+func invalidArgumentError(field string, invalid *domain.InvalidMessageError) error {
+	if invalid.Field != "" {
+		field = invalid.Field
+	}
+	st := status.New(codes.InvalidArgument, invalid.Error())
+	if detailed, detailErr := st.WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: invalid.Reason}},
+	}); detailErr == nil {
+		st = detailed
+	}
+	return st.Err()
+}
@@ -0,0 +1,238 @@
+package adaptor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ponyo877/chatsh/server/domain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// certTokenPrefix marks owner tokens derived from a client certificate common name
+	certTokenPrefix = "cert:"
+	// peerTokenPrefix marks owner tokens derived from the local user of a Unix socket client
+	peerTokenPrefix = "unix:"
+)
+
+// Identities maps callers authenticated by the transport to chatsh users, as an alternative to
+// the owner token they send
+type Identities struct {
+	// CertTokens maps a verified client certificate subject, as printed by openssl -nameopt RFC2253,
+	// to an owner token. Other certificates become the user "cert:<common name>".
+	CertTokens map[string]string
+	// PeerTokens maps the uid of a Unix socket client to an owner token
+	PeerTokens map[uint32]string
+	// PeerUsers lets other Unix socket clients act as the user "unix:<login name>"
+	PeerUsers bool
+}
+
+// errReservedToken rejects owner tokens in the form of a transport identity from callers the
+// transport did not identify, who would otherwise act as any certificate or local user by name
+var errReservedToken = status.Error(codes.Unauthenticated, "owner tokens starting with "+certTokenPrefix+" or "+peerTokenPrefix+" are only given by a TLS client certificate or a Unix socket")
+
+// reservedToken reports whether token has the form of a transport identity
+func reservedToken(token string) bool {
+	return strings.HasPrefix(token, certTokenPrefix) || strings.HasPrefix(token, peerTokenPrefix)
+}
+
+// identityResolver resolves the owner token of the caller from its transport credentials
+type identityResolver struct {
+	Identities
+	// known remembers the tokens whose user row is known to exist
+	known sync.Map
+}
+
+func newIdentityResolver(identities Identities) *identityResolver {
+	return &identityResolver{Identities: identities}
+}
+
+// peerCertificate returns the verified leaf certificate of the caller, if it presented one
+func peerCertificate(authInfo credentials.AuthInfo) (*x509.Certificate, bool) {
+	info, ok := authInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return info.State.VerifiedChains[0][0], true
+}
+
+// certToken returns the owner token and default display name for cert
+func (r *identityResolver) certToken(cert *x509.Certificate) (string, string, bool) {
+	if token, ok := r.CertTokens[cert.Subject.String()]; ok {
+		return token, cert.Subject.CommonName, true
+	}
+	if cert.Subject.CommonName == "" {
+		return "", "", false
+	}
+	return certTokenPrefix + cert.Subject.CommonName, cert.Subject.CommonName, true
+}
+
+// peerToken returns the owner token and default display name for the local user uid
+func (r *identityResolver) peerToken(uid uint32) (string, string, bool) {
+	name := strconv.FormatUint(uint64(uid), 10)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	if token, ok := r.PeerTokens[uid]; ok {
+		return token, name, true
+	}
+	if !r.PeerUsers {
+		return "", "", false
+	}
+	return peerTokenPrefix + name, name, true
+}
+
+// resolve returns the owner token and default display name of the caller, if the transport identifies it
+func (r *identityResolver) resolve(ctx context.Context) (string, string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", "", false
+	}
+	if cert, ok := peerCertificate(p.AuthInfo); ok {
+		return r.certToken(cert)
+	}
+	if info, ok := p.AuthInfo.(PeerCredInfo); ok {
+		return r.peerToken(info.UID)
+	}
+	return "", "", false
+}
+
+// resolveTLS returns the owner token and default display name of an HTTPS caller with a verified
+// client certificate
+func (r *identityResolver) resolveTLS(state *tls.ConnectionState) (string, string, bool) {
+	if state == nil {
+		return "", "", false
+	}
+	if cert, ok := peerCertificate(credentials.TLSInfo{State: *state}); ok {
+		return r.certToken(cert)
+	}
+	return "", "", false
+}
+
+// callerToken resolves the transport identity of the caller and makes sure its user exists
+func (a *Adaptor) callerToken(ctx context.Context) (string, bool) {
+	token, displayName, ok := a.identities.resolve(ctx)
+	if !ok {
+		return "", false
+	}
+	return token, a.ensureUser(token, displayName)
+}
+
+// ensureUser creates the user of a transport identity on first use. It always reports true so
+// that a failing database does not turn the caller into somebody else.
+func (a *Adaptor) ensureUser(token, displayName string) bool {
+	if _, known := a.identities.known.Load(token); known {
+		return true
+	}
+	if _, err := a.uc.GetConfig(token); errors.Is(err, domain.ErrNotFound) {
+		if err := a.uc.SetConfig(domain.NewConfig(displayName, token)); err != nil {
+			log.Printf("Error creating user %s: %v", displayName, err)
+			return true
+		}
+	} else if err != nil {
+		log.Printf("Error looking up user %s: %v", displayName, err)
+		return true
+	}
+	a.identities.known.Store(token, struct{}{})
+	return true
+}
+
+// setOwnerToken overwrites every owner_token field of msg, including those of nested messages
+// such as the Join and Tail payloads of a stream
+func setOwnerToken(msg proto.Message, token string) {
+	setOwnerTokenFields(msg.ProtoReflect(), token)
+}
+
+// hasReservedToken reports whether an owner_token field of msg, or of its nested messages,
+// holds a reserved token
+func hasReservedToken(msg proto.Message) bool {
+	return hasReservedTokenField(msg.ProtoReflect())
+}
+
+func hasReservedTokenField(m protoreflect.Message) bool {
+	if fd := m.Descriptor().Fields().ByName("owner_token"); fd != nil && fd.Kind() == protoreflect.StringKind {
+		if reservedToken(m.Get(fd).String()) {
+			return true
+		}
+	}
+	found := false
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Message() != nil && !fd.IsList() && !fd.IsMap() {
+			found = hasReservedTokenField(v.Message())
+		}
+		return !found
+	})
+	return found
+}
+
+func setOwnerTokenFields(m protoreflect.Message, token string) {
+	if fd := m.Descriptor().Fields().ByName("owner_token"); fd != nil && fd.Kind() == protoreflect.StringKind {
+		m.Set(fd, protoreflect.ValueOfString(token))
+	}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Message() != nil && !fd.IsList() && !fd.IsMap() {
+			setOwnerTokenFields(v.Message(), token)
+		}
+		return true
+	})
+}
+
+// UnaryIdentityInterceptor replaces the owner token of requests from callers identified by a
+// verified client certificate or by Unix peer credentials with the token of their user. Other
+// callers may not send the tokens of such users.
+func (a *Adaptor) UnaryIdentityInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		msg, isProto := req.(proto.Message)
+		if token, ok := a.callerToken(ctx); ok {
+			if isProto {
+				setOwnerToken(msg, token)
+			}
+		} else if isProto && hasReservedToken(msg) {
+			return nil, errReservedToken
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamIdentityInterceptor does the same as UnaryIdentityInterceptor for every message received on a stream
+func (a *Adaptor) StreamIdentityInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		token, ok := a.callerToken(ss.Context())
+		return handler(srv, &identityServerStream{ServerStream: ss, token: token, identified: ok})
+	}
+}
+
+type identityServerStream struct {
+	grpc.ServerStream
+	token string
+	// identified is false for callers the transport did not identify, whose tokens are kept
+	identified bool
+}
+
+func (s *identityServerStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	msg, ok := m.(proto.Message)
+	switch {
+	case !ok:
+	case s.identified:
+		setOwnerToken(msg, s.token)
+	case hasReservedToken(msg):
+		return errReservedToken
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package adaptor_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/ponyo877/chatsh/server/adaptor"
+	"github.com/ponyo877/chatsh/server/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestReservedTokens sends the tokens of users that a client certificate or a Unix socket
+// identifies over plaintext TCP, which must not let anybody act as them
+func TestReservedTokens(t *testing.T) {
+	ad, uc := newAdaptor(t, adaptor.RateLimits{}, adaptor.Identities{PeerUsers: true})
+	for _, token := range []string{"cert:carol", "unix:carol"} {
+		// As if carol had connected with their certificate or over the socket before
+		if err := uc.SetConfig(domain.NewConfig("carol", token)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := uc.SetConfig(domain.NewConfig("alice", "tokA")); err != nil {
+		t.Fatal(err)
+	}
+	client := serveTCP(t, ad)
+	ctx := context.Background()
+
+	if _, err := client.GetConfig(ctx, &pb.GetConfigRequest{OwnerToken: "tokA"}); err != nil {
+		t.Fatalf("GetConfig with an ordinary token: %v", err)
+	}
+	for _, token := range []string{"cert:carol", "unix:carol"} {
+		t.Run(token, func(t *testing.T) {
+			_, err := client.GetConfig(ctx, &pb.GetConfigRequest{OwnerToken: token})
+			if status.Code(err) != codes.Unauthenticated {
+				t.Errorf("GetConfig = %v, want Unauthenticated", err)
+			}
+			_, err = client.SetConfig(ctx, &pb.SetConfigRequest{OwnerToken: token, DisplayName: "mallory"})
+			if status.Code(err) != codes.Unauthenticated {
+				t.Errorf("SetConfig = %v, want Unauthenticated", err)
+			}
+			if config, _ := uc.GetConfig(token); config.DisplayName != "carol" {
+				t.Errorf("display name of %s = %q, want carol", token, config.DisplayName)
+			}
+
+			// Tokens nested in the messages of a stream are checked too
+			stream, err := client.StreamMessage(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := stream.Send(&pb.ClientMessage{Payload: &pb.ClientMessage_Join{Join: &pb.Join{Room: "/tmp", OwnerToken: token}}}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := stream.Recv(); status.Code(err) != codes.Unauthenticated {
+				t.Errorf("StreamMessage = %v, want Unauthenticated", err)
+			}
+		})
+	}
+}
+
+// TestReservedBearerTokens does the same over the REST API, whose bearer token is an owner token
+func TestReservedBearerTokens(t *testing.T) {
+	ad, uc := newAdaptor(t, adaptor.RateLimits{}, adaptor.Identities{})
+	for _, token := range []string{"cert:carol", "unix:carol", "tokA"} {
+		if err := uc.SetConfig(domain.NewConfig("carol", token)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := httptest.NewServer(ad.RESTHandler())
+	defer server.Close()
+	for _, tt := range []struct {
+		token string
+		want  int
+	}{
+		{"tokA", http.StatusOK},
+		{"cert:carol", http.StatusUnauthorized},
+		{"unix:carol", http.StatusUnauthorized},
+	} {
+		req, err := http.NewRequest(http.MethodGet, server.URL+adaptor.RESTPrefix+"fs/tmp", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tt.want {
+			t.Errorf("GET with bearer %s = %s, want %d", tt.token, res.Status, tt.want)
+		}
+	}
+}
+
+// TestPeerUsers checks that a Unix socket client still acts as its local user, whatever token it sends
+func TestPeerUsers(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials are read on Linux only")
+	}
+	me, err := user.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+	ad, uc := newAdaptor(t, adaptor.RateLimits{}, adaptor.Identities{PeerUsers: true})
+	lis, err := net.Listen("unix", filepath.Join(t.TempDir(), "chatsh.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := serve(t, ad, lis, adaptor.NewPeerCredentials())
+	for _, token := range []string{"", "tokA", "unix:somebody-else"} {
+		res, err := client.GetConfig(context.Background(), &pb.GetConfigRequest{OwnerToken: token})
+		if err != nil {
+			t.Fatalf("GetConfig(%q) over the socket: %v", token, err)
+		}
+		if res.DisplayName != me.Username {
+			t.Errorf("GetConfig(%q) over the socket = %q, want %q", token, res.DisplayName, me.Username)
+		}
+	}
+	if _, err := uc.GetConfig("unix:" + me.Username); err != nil {
+		t.Errorf("user unix:%s was not created: %v", me.Username, err)
+	}
+}
@@ -0,0 +1,120 @@
+package adaptor
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/ponyo877/chatsh/server/domain"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// IncomingWebhookPrefix is the path under which incoming webhooks accept posts, followed by their secret
+const IncomingWebhookPrefix = "/hooks/"
+
+func incomingWebhookURLPath(secret string) string {
+	return IncomingWebhookPrefix + secret
+}
+
+func toPbIncomingWebhook(webhook domain.IncomingWebhook) *pb.IncomingWebhook {
+	pbWebhook := &pb.IncomingWebhook{
+		Id:       int64(webhook.ID),
+		RoomPath: webhook.RoomPath,
+		BotName:  webhook.BotName,
+		Created:  timestamppb.New(webhook.CreatedAt),
+	}
+	if !webhook.LastUsedAt.IsZero() {
+		pbWebhook.LastUsed = timestamppb.New(webhook.LastUsedAt)
+	}
+	return pbWebhook
+}
+
+func (a *Adaptor) CreateIncomingWebhook(ctx context.Context, in *pb.CreateIncomingWebhookRequest) (*pb.CreateIncomingWebhookResponse, error) {
+	webhook, err := a.uc.CreateIncomingWebhook(domain.NewPath(in.GetRoomPath()), in.GetOwnerToken(), in.GetBotName())
+	if err != nil {
+		log.Printf("Error creating incoming webhook on %s: %v", in.GetRoomPath(), err)
+		if statusErr, ok := statusError(err, "room_path"); ok {
+			return nil, statusErr
+		}
+		return &pb.CreateIncomingWebhookResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
+	}
+	return &pb.CreateIncomingWebhookResponse{
+		Status:  &pb.Status{Ok: true},
+		Webhook: toPbIncomingWebhook(webhook),
+		UrlPath: incomingWebhookURLPath(webhook.Secret),
+	}, nil
+}
+
+func (a *Adaptor) ListIncomingWebhooks(ctx context.Context, in *pb.ListIncomingWebhooksRequest) (*pb.ListIncomingWebhooksResponse, error) {
+	webhooks, err := a.uc.ListIncomingWebhooks(in.GetOwnerToken())
+	if err != nil {
+		log.Printf("Error listing incoming webhooks: %v", err)
+		return nil, grpcError(err)
+	}
+	pbWebhooks := make([]*pb.IncomingWebhook, len(webhooks))
+	for i, webhook := range webhooks {
+		pbWebhooks[i] = toPbIncomingWebhook(webhook)
+	}
+	return &pb.ListIncomingWebhooksResponse{Webhooks: pbWebhooks}, nil
+}
+
+func (a *Adaptor) RotateIncomingWebhook(ctx context.Context, in *pb.RotateIncomingWebhookRequest) (*pb.RotateIncomingWebhookResponse, error) {
+	webhook, err := a.uc.RotateIncomingWebhook(int(in.GetId()), in.GetOwnerToken())
+	if err != nil {
+		log.Printf("Error rotating incoming webhook %d: %v", in.GetId(), err)
+		if statusErr, ok := statusError(err, "id"); ok {
+			return nil, statusErr
+		}
+		return &pb.RotateIncomingWebhookResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
+	}
+	return &pb.RotateIncomingWebhookResponse{Status: &pb.Status{Ok: true}, UrlPath: incomingWebhookURLPath(webhook.Secret)}, nil
+}
+
+func (a *Adaptor) RevokeIncomingWebhook(ctx context.Context, in *pb.RevokeIncomingWebhookRequest) (*pb.RevokeIncomingWebhookResponse, error) {
+	if err := a.uc.RevokeIncomingWebhook(int(in.GetId()), in.GetOwnerToken()); err != nil {
+		log.Printf("Error revoking incoming webhook %d: %v", in.GetId(), err)
+		if statusErr, ok := statusError(err, "id"); ok {
+			return nil, statusErr
+		}
+		return &pb.RevokeIncomingWebhookResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
+	}
+	return &pb.RevokeIncomingWebhookResponse{Status: &pb.Status{Ok: true}}, nil
+}
+
+// IncomingWebhookHandler accepts POST IncomingWebhookPrefix+secret with a text/plain body or
+// a JSON body like {"text": "..."}, and posts the text to the room of the webhook.
+// The secret is the credential, so the requests carry no other authentication.
+func (a *Adaptor) IncomingWebhookHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeRESTError(w, newHTTPError(http.StatusMethodNotAllowed, "INVALID_ARGUMENT", "incoming webhooks only accept POST"))
+			return
+		}
+		secret := strings.TrimPrefix(r.URL.Path, IncomingWebhookPrefix)
+		if secret == "" || strings.Contains(secret, "/") {
+			writeRESTError(w, newHTTPError(http.StatusNotFound, "NOT_FOUND", "no route for %s %s", r.Method, r.URL.Path))
+			return
+		}
+		if ok, retryAfter := a.writeLimiter.allow("hook:" + secret); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			writeRESTError(w, newHTTPError(http.StatusTooManyRequests, "RATE_LIMITED", "rate limit exceeded, retry after %s", retryAfter.Round(time.Millisecond)))
+			return
+		}
+		text, err := readMessageBody(w, r)
+		if err != nil {
+			writeRESTError(w, err)
+			return
+		}
+		if err := a.uc.PostIncomingWebhook(secret, text); err != nil {
+			log.Printf("Error posting to incoming webhook: %v", err)
+			writeRESTError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -1,6 +1,8 @@
 package adaptor
 
 import (
+	"io"
+
 	"github.com/ponyo877/chatsh/server/domain"
 )
 
@@ -12,7 +14,8 @@ type Usecase interface {
 	CreateRoom(path domain.Path, ownerToken string) error
 	CreateDirectory(path domain.Path, ownerToken string) error
 	DeletePath(path domain.Path, ownerToken string) error
-	ListNodes(path domain.Path) ([]domain.Node, error)
+	ListNodes(path domain.Path, ownerToken string, withUnread, withRetention bool) ([]domain.Node, error)
+	StatPath(path domain.Path, ownerToken string) (domain.Node, error)
 	MovePath(srcPath domain.Path, dstPath domain.Path, ownerToken string) error
 	SearchMessage(path domain.Path, pattern string) ([]domain.Message, error)
 	HandleStreamSession(
@@ -22,4 +25,29 @@ type Usecase interface {
 	) error
 	WriteMessage(path domain.Path, message, ownerToken string) error
 	ListMessages(path domain.Path, limit int32) ([]domain.Message, error)
+	ListMessagesAfter(path domain.Path, afterID, limit int) ([]domain.Message, error)
+	ListMentions(ownerToken string, unreadOnly bool) ([]domain.Mention, error)
+	MarkMentionsRead(ownerToken string, mentionIDs []int) error
+	GetReadMarker(path domain.Path, ownerToken string) (int, error)
+	MarkRead(path domain.Path, ownerToken string, messageID int) error
+	ModerateRoom(path domain.Path, ownerToken string, action domain.ModerationAction, targetName string, slowModeSeconds int, reason string) error
+	GetRoomModeration(path domain.Path, ownerToken string) (domain.RoomModeration, error)
+	SetRoomLimits(path domain.Path, ownerToken string, limits domain.MessageLimits) error
+	SetRetention(path domain.Path, ownerToken string, policy domain.RetentionPolicy, inherit bool) error
+	ListTrash(ownerToken string) ([]domain.TrashEntry, error)
+	RestoreTrash(id int, ownerToken, dstPath string) (domain.Path, error)
+	EmptyTrash(ownerToken string) (int, error)
+	Backup(ownerToken string) (domain.Snapshot, error)
+	ImportMessages(ownerToken string, roomPath domain.Path, createRoom bool, messages []domain.ImportedMessage) (domain.MessageImport, error)
+	Export(path domain.Path, w io.Writer) error
+	Import(dstPath domain.Path, name, ownerToken string, onConflict domain.ConflictPolicy, archive io.Reader) (domain.ImportResult, error)
+	CreateWebhook(path domain.Path, ownerToken, url, secret string, events []domain.WebhookEvent, pathGlob string) (domain.Webhook, error)
+	ListWebhooks(ownerToken string) ([]domain.Webhook, error)
+	TestWebhook(id int, ownerToken string) (domain.WebhookTestResult, error)
+	DeleteWebhook(id int, ownerToken string) error
+	CreateIncomingWebhook(roomPath domain.Path, ownerToken, botName string) (domain.IncomingWebhook, error)
+	ListIncomingWebhooks(ownerToken string) ([]domain.IncomingWebhook, error)
+	RotateIncomingWebhook(id int, ownerToken string) (domain.IncomingWebhook, error)
+	RevokeIncomingWebhook(id int, ownerToken string) error
+	PostIncomingWebhook(secret, message string) error
 }
@@ -0,0 +1,74 @@
+package adaptor
+
+import (
+	"io"
+	"log"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/ponyo877/chatsh/server/domain"
+	"google.golang.org/grpc"
+)
+
+func toDomainImportedMessages(messages []*pb.ImportedMessage) []domain.ImportedMessage {
+	imported := make([]domain.ImportedMessage, len(messages))
+	for i, message := range messages {
+		// A message without a time keeps the zero time, which the usecase refuses, rather than the epoch
+		var createdAt time.Time
+		if message.GetCreated() != nil {
+			createdAt = message.GetCreated().AsTime()
+		}
+		imported[i] = domain.NewImportedMessage(message.GetKey(), message.GetOwnerName(), message.GetTextContent(), createdAt)
+	}
+	return imported
+}
+
+func (a *Adaptor) ImportMessages(stream grpc.ClientStreamingServer[pb.ImportMessagesRequest, pb.ImportMessagesResponse]) error {
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return stream.SendAndClose(&pb.ImportMessagesResponse{Status: &pb.Status{Ok: true}})
+	}
+	if err != nil {
+		return err
+	}
+	// Imports write like any mutating RPC, which only the unary interceptor limits
+	if ok, retryAfter := a.writeLimiter.allow(a.rateLimitKey(stream.Context(), first.GetOwnerToken())); !ok {
+		stream.SetTrailer(retryAfterTrailer(retryAfter))
+		return rateLimitError(retryAfter)
+	}
+
+	ownerToken, createRooms := first.GetOwnerToken(), first.GetCreateRooms()
+	var rooms []*pb.ImportedRoom
+	indexes := map[string]int{}
+	for req := first; ; {
+		path := domain.NewPath(req.GetRoomPath())
+		result, err := a.uc.ImportMessages(ownerToken, path, createRooms, toDomainImportedMessages(req.GetMessages()))
+		if err != nil {
+			log.Printf("Error importing messages into %s: %v", path, err)
+			if statusErr, ok := statusError(err, "room_path"); ok {
+				return statusErr
+			}
+			return stream.SendAndClose(&pb.ImportMessagesResponse{Status: &pb.Status{Ok: false, Message: err.Error()}, Rooms: rooms})
+		}
+		i, ok := indexes[result.Path]
+		if !ok {
+			i, indexes[result.Path] = len(rooms), len(rooms)
+			rooms = append(rooms, &pb.ImportedRoom{Path: result.Path})
+		}
+		rooms[i].Created = rooms[i].Created || result.Created
+		rooms[i].Messages += int32(result.Messages)
+		rooms[i].Duplicates += int32(result.Duplicates)
+		rooms[i].Dropped += int32(result.Dropped)
+
+		if req, err = stream.Recv(); err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, room := range rooms {
+		log.Printf("Imported %d messages into %s, skipping %d imported before and %d left empty", room.Messages, room.Path, room.Duplicates, room.Dropped)
+	}
+	return stream.SendAndClose(&pb.ImportMessagesResponse{Status: &pb.Status{Ok: true}, Rooms: rooms})
+}
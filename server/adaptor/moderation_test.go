@@ -0,0 +1,109 @@
+package adaptor_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/ponyo877/chatsh/server/adaptor"
+	"github.com/ponyo877/chatsh/server/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestModerationWithoutToken checks that leaving out the owner token and picking an unused name
+// does not get a banned user back into a room
+func TestModerationWithoutToken(t *testing.T) {
+	ad, uc := newAdaptor(t, adaptor.RateLimits{}, adaptor.Identities{})
+	for name, token := range map[string]string{"alice": "tokA", "bob": "tokB", "carol": "tokC"} {
+		if err := uc.SetConfig(domain.NewConfig(name, token)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, room := range []string{"/tmp/banned", "/tmp/muted", "/tmp/open"} {
+		if err := uc.CreateRoom(domain.NewPath(room), "tokA"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := serveTCP(t, ad)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	open := func(request *pb.ClientMessage) (pb.ChatshService_StreamMessageClient, *pb.ServerMessage, error) {
+		t.Helper()
+		stream, err := client.StreamMessage(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := stream.Send(request); err != nil {
+			t.Fatal(err)
+		}
+		msg, err := stream.Recv()
+		return stream, msg, err
+	}
+	join := func(room, name, token string) *pb.ClientMessage {
+		return &pb.ClientMessage{Payload: &pb.ClientMessage_Join{Join: &pb.Join{Name: name, Room: room, OwnerToken: token}}}
+	}
+	tail := func(room, token string) *pb.ClientMessage {
+		return &pb.ClientMessage{Payload: &pb.ClientMessage_Tail{Tail: &pb.Tail{RoomPath: room, OwnerToken: token}}}
+	}
+
+	// Someone without a token was in the room before anyone was muted
+	early, _, err := open(join("/tmp/muted", "stranger", ""))
+	if err != nil {
+		t.Fatalf("join without a token before any restriction: %v", err)
+	}
+	if err := uc.ModerateRoom(domain.NewPath("/tmp/banned"), "tokA", domain.ModerationBan, "bob", 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := uc.ModerateRoom(domain.NewPath("/tmp/muted"), "tokA", domain.ModerationMute, "bob", 0, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		request *pb.ClientMessage
+		allowed bool
+	}{
+		{"banned user", join("/tmp/banned", "bob", "tokB"), false},
+		{"banned user without a token", join("/tmp/banned", "not-bob", ""), false},
+		{"tail without a token", tail("/tmp/banned", ""), false},
+		{"join of a restricted room without a token", join("/tmp/muted", "not-bob", ""), false},
+		{"other user", join("/tmp/banned", "carol", "tokC"), true},
+		{"unrestricted room without a token", join("/tmp/open", "stranger", ""), true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, msg, err := open(tt.request)
+			if tt.allowed {
+				if err != nil {
+					t.Errorf("Recv = %v, %v, want to be let in", msg, err)
+				}
+				return
+			}
+			if status.Code(err) != codes.PermissionDenied {
+				t.Errorf("Recv = %v, %v, want PermissionDenied", msg, err)
+			}
+		})
+	}
+
+	// The session that joined without a token cannot post now that the room has restrictions
+	if err := early.Send(chat("still here")); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := early.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.GetName() != domain.SystemSenderName || msg.GetText() == "still here" {
+		t.Errorf("Recv = %v, want a notice refusing the message", msg)
+	}
+	messages, err := uc.ListMessages(domain.NewPath("/tmp/muted"), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, message := range messages {
+		if message.Content == "still here" {
+			t.Errorf("message of a session without a token was stored in a restricted room")
+		}
+	}
+}
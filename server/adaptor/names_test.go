@@ -0,0 +1,73 @@
+package adaptor_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/ponyo877/chatsh/server/adaptor"
+	"github.com/ponyo877/chatsh/server/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TestNames checks that display names are cleaned up and that room and directory names that a
+// terminal would interpret are refused
+func TestNames(t *testing.T) {
+	ad, uc := newAdaptor(t, adaptor.RateLimits{}, adaptor.Identities{})
+	if err := uc.CreateRoom(domain.NewPath("/tmp/room"), "tokA"); err != nil {
+		t.Fatal(err)
+	}
+	client := serveTCP(t, ad)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := client.SetConfig(ctx, &pb.SetConfigRequest{OwnerToken: "tokA", DisplayName: "\x1b[31mmallory\x1b[0m\n"}); err != nil {
+		t.Fatalf("SetConfig: %v", err)
+	}
+	if res, err := client.GetConfig(ctx, &pb.GetConfigRequest{OwnerToken: "tokA"}); err != nil || res.GetDisplayName() != "mallory" {
+		t.Errorf("GetConfig = %v, %v, want mallory", res, err)
+	}
+	if _, err := client.SetConfig(ctx, &pb.SetConfigRequest{OwnerToken: "tokA", DisplayName: "\x1b[2J"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("SetConfig with an empty name = %v, want InvalidArgument", err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		call func() error
+	}{
+		{"create room", func() error {
+			_, err := client.CreateRoom(ctx, &pb.CreateRoomRequest{Path: "/tmp/\x1b]0;pwned\a", OwnerToken: "tokA"})
+			return err
+		}},
+		{"create directory", func() error {
+			_, err := client.CreateDirectory(ctx, &pb.CreateDirectoryRequest{Path: "/tmp/ dir", OwnerToken: "tokA"})
+			return err
+		}},
+		{"move", func() error {
+			_, err := client.MovePath(ctx, &pb.MovePathRequest{SourcePath: "/tmp/room", DestinationPath: "/tmp/a\nb", OwnerToken: "tokA"})
+			return err
+		}},
+		{"copy", func() error {
+			_, err := client.CopyPath(ctx, &pb.CopyPathRequest{SourcePath: "/tmp/room", DestinationPath: "/tmp/a\tb", OwnerToken: "tokA"})
+			return err
+		}},
+	} {
+		if err := tt.call(); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s = %v, want InvalidArgument", tt.name, err)
+		}
+	}
+
+	stream, err := client.StreamMessage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	join := &pb.ClientMessage{Payload: &pb.ClientMessage_Join{Join: &pb.Join{Name: "\x1b]0;pwned\aeve  \x1b[1m", Room: "/tmp/room", OwnerToken: "tokA"}}}
+	if err := stream.Send(join); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := stream.Recv(); err != nil || msg.GetName() != "eve" || msg.GetText() != "joined #/tmp/room as eve" {
+		t.Errorf("Recv = %v, %v, want eve's join message", msg, err)
+	}
+}
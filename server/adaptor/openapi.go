@@ -0,0 +1,170 @@
+package adaptor
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openAPIVersion is the version of the HTTP API described by the document
+const openAPIVersion = "1.0.0"
+
+// OpenAPI returns the OpenAPI 3 document of the HTTP/JSON API, generated from the route table and
+// the Go types of the request and response bodies so that it cannot drift from the handlers
+func OpenAPI() ([]byte, error) {
+	schemas := map[string]any{}
+	paths := map[string]map[string]any{}
+	for _, route := range restRoutes {
+		path := route.prefix + "{path}" + route.suffix
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(route.method)] = openAPIOperation(route, schemas)
+	}
+	errorRef := schemaRef(reflect.TypeOf(restError{}), schemas)
+
+	document := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "chatsh HTTP API",
+			"version":     openAPIVersion,
+			"description": "Resource-style access to the chatsh file system and rooms. Paths are slash-separated node paths without the leading slash, e.g. /v1/rooms/tmp/general/messages.",
+		},
+		"paths": paths,
+		"security": []any{
+			map[string]any{"bearerAuth": []any{}},
+		},
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "The owner token of the chatsh user, as in owner_token of ~/.chatsh.yaml",
+				},
+			},
+			"responses": map[string]any{
+				"Error": map[string]any{
+					"description": "The request failed; code names the reason, as in the ErrorInfo of the gRPC API",
+					"content":     map[string]any{"application/json": map[string]any{"schema": errorRef}},
+				},
+			},
+		},
+	}
+	return json.MarshalIndent(document, "", "  ")
+}
+
+func openAPIOperation(route restRoute, schemas map[string]any) map[string]any {
+	parameters := []any{map[string]any{
+		"name":        "path",
+		"in":          "path",
+		"required":    true,
+		"description": "Node path; may contain slashes",
+		"schema":      map[string]any{"type": "string"},
+	}}
+	for _, param := range route.query {
+		parameters = append(parameters, map[string]any{
+			"name":        param.name,
+			"in":          "query",
+			"description": param.description,
+			"schema":      map[string]any{"type": param.kind},
+		})
+	}
+
+	success := map[string]any{"description": http.StatusText(route.status)}
+	if route.response != nil {
+		success["content"] = map[string]any{
+			"application/json": map[string]any{"schema": schemaRef(reflect.TypeOf(route.response), schemas)},
+		}
+	}
+	errorResponse := map[string]any{"$ref": "#/components/responses/Error"}
+	responses := map[string]any{
+		strconv.Itoa(route.status): success,
+		"401":                      errorResponse,
+		"404":                      errorResponse,
+	}
+	if route.write {
+		responses["403"] = errorResponse
+		responses["429"] = errorResponse
+	}
+	if route.method == http.MethodDelete {
+		responses["409"] = errorResponse
+	}
+	if route.request != nil {
+		responses["400"] = errorResponse
+	}
+
+	operation := map[string]any{
+		"operationId": route.id,
+		"summary":     route.summary,
+		"parameters":  parameters,
+		"responses":   responses,
+	}
+	if route.request != nil {
+		operation["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": schemaRef(reflect.TypeOf(route.request), schemas)},
+				"text/plain":       map[string]any{"schema": map[string]any{"type": "string"}},
+			},
+		}
+	}
+	return operation
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaRef returns the JSON schema of t, registering named structs in schemas and referring to them
+func schemaRef(t reflect.Type, schemas map[string]any) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaRef(t.Elem(), schemas)}
+	case t.Kind() == reflect.Struct:
+		name := strings.TrimPrefix(t.Name(), "rest")
+		name = strings.ToUpper(name[:1]) + name[1:]
+		if _, exists := schemas[name]; !exists {
+			schemas[name] = map[string]any{} // guards against recursive types
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		schema := schemaRef(field.Type, schemas)
+		if enum := field.Tag.Get("enum"); enum != "" {
+			schema["enum"] = strings.Split(enum, ",")
+		}
+		properties[name] = schema
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,70 @@
+package adaptor
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// PeerCredInfo carries the credentials of the process on the other end of a Unix socket
+type PeerCredInfo struct {
+	credentials.CommonAuthInfo
+	UID uint32
+	GID uint32
+	PID int32
+}
+
+func (PeerCredInfo) AuthType() string {
+	return "peercred"
+}
+
+// unknownPeerInfo stands for a Unix socket client whose credentials could not be read
+type unknownPeerInfo struct {
+	credentials.CommonAuthInfo
+}
+
+func (unknownPeerInfo) AuthType() string {
+	return "peercred"
+}
+
+// peerCredentials is a plaintext transport that records the peer credentials of Unix socket clients.
+// Access to the socket itself is controlled by its file permissions.
+type peerCredentials struct{}
+
+// NewPeerCredentials returns the transport credentials for Unix socket listeners
+func NewPeerCredentials() credentials.TransportCredentials {
+	return peerCredentials{}
+}
+
+func (peerCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("peer credentials are only supported on the server")
+}
+
+func (peerCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	common := credentials.CommonAuthInfo{SecurityLevel: credentials.NoSecurity}
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return conn, unknownPeerInfo{common}, nil
+	}
+	info, err := readPeerCred(uc)
+	if err != nil {
+		// Without credentials the connection still works, only without a local user identity
+		return conn, unknownPeerInfo{common}, nil
+	}
+	info.CommonAuthInfo = common
+	return conn, info, nil
+}
+
+func (peerCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "peercred"}
+}
+
+func (c peerCredentials) Clone() credentials.TransportCredentials {
+	return c
+}
+
+func (peerCredentials) OverrideServerName(string) error {
+	return nil
+}
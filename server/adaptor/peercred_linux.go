@@ -0,0 +1,28 @@
+//go:build linux
+
+package adaptor
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// readPeerCred reads SO_PEERCRED of a connected Unix socket
+func readPeerCred(conn *net.UnixConn) (PeerCredInfo, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return PeerCredInfo{}, err
+	}
+	var ucred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		ucred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return PeerCredInfo{}, err
+	}
+	if credErr != nil {
+		return PeerCredInfo{}, credErr
+	}
+	return PeerCredInfo{UID: ucred.Uid, GID: ucred.Gid, PID: ucred.Pid}, nil
+}
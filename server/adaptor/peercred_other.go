@@ -0,0 +1,12 @@
+//go:build !linux
+
+package adaptor
+
+import (
+	"errors"
+	"net"
+)
+
+func readPeerCred(conn *net.UnixConn) (PeerCredInfo, error) {
+	return PeerCredInfo{}, errors.New("peer credentials are not supported on this platform")
+}
@@ -0,0 +1,215 @@
+package adaptor
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// RetryAfterKey is the trailer carrying the seconds to wait after a ResourceExhausted error
+	RetryAfterKey = "retry-after"
+
+	limiterIdleTimeout = 10 * time.Minute
+)
+
+// RateLimits configures the token buckets. A zero rate disables the limit.
+type RateLimits struct {
+	// WritePerSecond and WriteBurst limit the mutating RPCs of each client, known by its client
+	// certificate, its Unix user, its owner token if a user has it, or else its IP address
+	WritePerSecond float64
+	WriteBurst     int
+	// StreamPerSecond and StreamBurst limit the chat messages of each stream session; messages
+	// beyond them are dropped with a Rejection
+	StreamPerSecond float64
+	StreamBurst     int
+}
+
+var rateLimitedMethods = map[string]bool{
+	pb.ChatshService_SetConfig_FullMethodName:             true,
+	pb.ChatshService_CreateRoom_FullMethodName:            true,
+	pb.ChatshService_CreateDirectory_FullMethodName:       true,
+	pb.ChatshService_DeletePath_FullMethodName:            true,
+	pb.ChatshService_CopyPath_FullMethodName:              true,
+	pb.ChatshService_MovePath_FullMethodName:              true,
+	pb.ChatshService_WriteMessage_FullMethodName:          true,
+	pb.ChatshService_ModerateRoom_FullMethodName:          true,
+	pb.ChatshService_SetRoomLimits_FullMethodName:         true,
+	pb.ChatshService_SetRetention_FullMethodName:          true,
+	pb.ChatshService_RestoreTrash_FullMethodName:          true,
+	pb.ChatshService_EmptyTrash_FullMethodName:            true,
+	pb.ChatshService_CreateWebhook_FullMethodName:         true,
+	pb.ChatshService_TestWebhook_FullMethodName:           true,
+	pb.ChatshService_DeleteWebhook_FullMethodName:         true,
+	pb.ChatshService_CreateIncomingWebhook_FullMethodName: true,
+	pb.ChatshService_RotateIncomingWebhook_FullMethodName: true,
+	pb.ChatshService_RevokeIncomingWebhook_FullMethodName: true,
+}
+
+// keyedLimiter holds one token bucket per key and forgets buckets that have been idle for a while
+type keyedLimiter struct {
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	limiters map[string]*limiterEntry
+	lastGC   time.Time
+}
+
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func newKeyedLimiter(perSecond float64, burst int) *keyedLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &keyedLimiter{
+		limit:    rate.Limit(perSecond),
+		burst:    burst,
+		limiters: make(map[string]*limiterEntry),
+		lastGC:   time.Now(),
+	}
+}
+
+// allow takes a token for key, or returns how long to wait until one is available
+func (l *keyedLimiter) allow(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastGC) > limiterIdleTimeout {
+		for k, entry := range l.limiters {
+			if now.Sub(entry.lastSeen) > limiterIdleTimeout {
+				delete(l.limiters, k)
+			}
+		}
+		l.lastGC = now
+	}
+
+	entry, exists := l.limiters[key]
+	if !exists {
+		entry = &limiterEntry{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[key] = entry
+	}
+	entry.lastSeen = now
+
+	reservation := entry.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+func (l *keyedLimiter) forget(key string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.limiters, key)
+}
+
+// rateLimitError builds a ResourceExhausted status carrying the retry delay
+func rateLimitError(retryAfter time.Duration) error {
+	st := status.New(codes.ResourceExhausted, "rate limit exceeded, retry after "+retryAfter.Round(time.Millisecond).String())
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+func retryAfterTrailer(retryAfter time.Duration) metadata.MD {
+	seconds := int(retryAfter.Seconds())
+	if time.Duration(seconds)*time.Second < retryAfter {
+		seconds++
+	}
+	return metadata.Pairs(RetryAfterKey, strconv.Itoa(seconds))
+}
+
+// rateLimitKey identifies the caller by the identity its transport proves, else by the owner
+// token it sends when that belongs to a user, else by its address. Behind a reverse proxy, such
+// as on Cloud Run, the address is the proxy's and every caller without a user shares it. Only
+// known tokens count, so that a client cannot get a fresh bucket for every request by making one up.
+func (a *Adaptor) rateLimitKey(ctx context.Context, ownerToken string) string {
+	if token, _, ok := a.identities.resolve(ctx); ok {
+		return "identity:" + token
+	}
+	if key, ok := a.userKey(ownerToken); ok {
+		return key
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return peerKey(p.Addr.String())
+	}
+	return "unknown"
+}
+
+// userKey keys the caller by its owner token if a user has it
+func (a *Adaptor) userKey(ownerToken string) (string, bool) {
+	if ownerToken == "" || reservedToken(ownerToken) {
+		return "", false
+	}
+	if _, err := a.uc.GetConfig(ownerToken); err != nil {
+		return "", false
+	}
+	return "user:" + ownerToken, true
+}
+
+// peerKey keys a network address by its host, so that all connections of a client share a bucket
+func peerKey(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return "peer:" + host
+	}
+	return "peer:" + addr
+}
+
+// streamRejection tells a stream client that its chat message was dropped for exceeding the
+// session's rate; the stream stays open
+func streamRejection(retryAfter time.Duration) *pb.ServerMessage {
+	// Rounded up, like the retry-after trailer, so that clients waiting that long are let through
+	retryAfter = retryAfter.Truncate(time.Millisecond) + time.Millisecond
+	return &pb.ServerMessage{
+		Text: "message not sent: rate limit exceeded, retry after " + retryAfter.String(),
+		Rejection: &pb.Rejection{
+			Code:         int32(codes.ResourceExhausted),
+			RetryAfterMs: retryAfter.Milliseconds(),
+		},
+	}
+}
+
+// UnaryRateLimitInterceptor enforces the per-identity write limit on mutating RPCs
+func (a *Adaptor) UnaryRateLimitInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !rateLimitedMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		var ownerToken string
+		if r, ok := req.(interface{ GetOwnerToken() string }); ok {
+			ownerToken = r.GetOwnerToken()
+		}
+		if ok, retryAfter := a.writeLimiter.allow(a.rateLimitKey(ctx, ownerToken)); !ok {
+			grpc.SetTrailer(ctx, retryAfterTrailer(retryAfter))
+			return nil, rateLimitError(retryAfter)
+		}
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,160 @@
+package adaptor_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/ponyo877/chatsh/server/adaptor"
+	"github.com/ponyo877/chatsh/server/domain"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func chat(text string) *pb.ClientMessage {
+	return &pb.ClientMessage{Payload: &pb.ClientMessage_Chat{Chat: &pb.Chat{Text: text}}}
+}
+
+// TestStreamRateLimit checks that a chat message over the session's rate is dropped with a
+// rejection, and that the session carries on
+func TestStreamRateLimit(t *testing.T) {
+	ad, uc := newAdaptor(t, adaptor.RateLimits{StreamPerSecond: 10, StreamBurst: 1}, adaptor.Identities{})
+	if err := uc.SetConfig(domain.NewConfig("alice", "tokA")); err != nil {
+		t.Fatal(err)
+	}
+	if err := uc.CreateRoom(domain.NewPath("/tmp/room"), "tokA"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := serveTCP(t, ad).StreamMessage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	join := &pb.ClientMessage{Payload: &pb.ClientMessage_Join{Join: &pb.Join{Name: "alice", Room: "/tmp/room", OwnerToken: "tokA"}}}
+	if err := stream.Send(join); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := stream.Recv(); err != nil || !strings.HasPrefix(msg.GetText(), "joined") {
+		t.Fatalf("Recv = %v, %v, want the join message", msg, err)
+	}
+
+	// The burst takes the first message; the second comes too soon
+	for _, text := range []string{"one", "two"} {
+		if err := stream.Send(chat(text)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var posted []string
+	var rejection *pb.Rejection
+	for range 2 {
+		msg, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		if msg.GetRejection() != nil {
+			rejection = msg.GetRejection()
+			continue
+		}
+		posted = append(posted, msg.GetText())
+	}
+	if len(posted) != 1 || posted[0] != "one" {
+		t.Errorf("posted %q, want only one", posted)
+	}
+	if rejection == nil {
+		t.Fatal("got no rejection")
+	}
+	retryAfter := time.Duration(rejection.GetRetryAfterMs()) * time.Millisecond
+	if codes.Code(rejection.GetCode()) != codes.ResourceExhausted || retryAfter <= 0 || retryAfter > 100*time.Millisecond {
+		t.Errorf("rejection = %v, want ResourceExhausted with a retry delay of at most 100ms", rejection)
+	}
+
+	// Once the delay has passed the same stream posts again
+	time.Sleep(retryAfter)
+	if err := stream.Send(chat("three")); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := stream.Recv(); err != nil || msg.GetText() != "three" || msg.GetRejection() != nil {
+		t.Errorf("Recv = %v, %v, want three", msg, err)
+	}
+}
+
+// TestWriteRateLimitKey checks that each user gets a write bucket of their own, so that callers
+// sharing an address, as behind a proxy, do not throttle each other, and that clients cannot
+// escape the limit by making up owner tokens
+func TestWriteRateLimitKey(t *testing.T) {
+	limits := adaptor.RateLimits{WritePerSecond: 0.001, WriteBurst: 1}
+	setup := func(t *testing.T) (*adaptor.Adaptor, adaptor.Usecase) {
+		ad, uc := newAdaptor(t, limits, adaptor.Identities{})
+		for _, token := range []string{"tokA", "tokB"} {
+			if err := uc.SetConfig(domain.NewConfig(token, token)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := uc.CreateRoom(domain.NewPath("/tmp/room"), "tokA"); err != nil {
+			t.Fatal(err)
+		}
+		return ad, uc
+	}
+
+	t.Run("grpc", func(t *testing.T) {
+		ad, _ := setup(t)
+		client := serveTCP(t, ad)
+		ctx := context.Background()
+		for i, tt := range []struct {
+			token   string
+			limited bool
+		}{
+			{"tokA", false},
+			{"tokA", true},
+			{"tokB", false},
+			// Unknown tokens share the bucket of the address
+			{"made-up-1", false},
+			{"made-up-2", true},
+		} {
+			var trailer metadata.MD
+			_, err := client.WriteMessage(ctx, &pb.WriteMessageRequest{DestinationPath: "/tmp/room", TextContent: "hi", OwnerToken: tt.token}, grpc.Trailer(&trailer))
+			if limited := status.Code(err) == codes.ResourceExhausted; limited != tt.limited {
+				t.Errorf("write %d with %s = %v, want rate limited: %t", i, tt.token, err, tt.limited)
+			}
+			if tt.limited && len(trailer.Get(adaptor.RetryAfterKey)) != 1 {
+				t.Errorf("trailer = %v, want %s", trailer, adaptor.RetryAfterKey)
+			}
+		}
+	})
+
+	t.Run("rest", func(t *testing.T) {
+		ad, _ := setup(t)
+		server := httptest.NewServer(ad.RESTHandler())
+		defer server.Close()
+		for _, tt := range []struct {
+			token string
+			want  int
+		}{
+			{"tokA", http.StatusNoContent},
+			{"tokA", http.StatusTooManyRequests},
+			{"tokB", http.StatusNoContent},
+		} {
+			req, err := http.NewRequest(http.MethodPost, server.URL+adaptor.RESTPrefix+"rooms/tmp/room/messages", strings.NewReader(`{"text": "hi"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			req.Header.Set("Content-Type", "application/json")
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+			if res.StatusCode != tt.want {
+				t.Errorf("POST with bearer %s = %s, want %d", tt.token, res.Status, tt.want)
+			}
+		}
+	})
+}
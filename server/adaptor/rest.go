@@ -0,0 +1,378 @@
+package adaptor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+const (
+	// RESTPrefix is the path under which the HTTP/JSON API is served
+	RESTPrefix = "/v1/"
+
+	defaultRESTMessageLimit = 50
+	maxRESTMessageLimit     = 1000
+	maxRESTBody             = 1 << 20
+)
+
+// restNode is a directory entry of the HTTP API
+type restNode struct {
+	Name        string    `json:"name"`
+	Type        string    `json:"type" enum:"directory,room"`
+	Owner       string    `json:"owner"`
+	Modified    time.Time `json:"modified"`
+	UnreadCount int       `json:"unread_count,omitempty"`
+}
+
+// restListing is the response of GET /v1/fs/{path}. A room lists itself as its only entry.
+type restListing struct {
+	Path    string     `json:"path"`
+	Type    string     `json:"type" enum:"directory,room"`
+	Entries []restNode `json:"entries"`
+}
+
+type restMessage struct {
+	ID      int       `json:"id"`
+	Author  string    `json:"author"`
+	Text    string    `json:"text"`
+	Created time.Time `json:"created"`
+}
+
+type restMessages struct {
+	Room     string        `json:"room"`
+	Messages []restMessage `json:"messages"`
+}
+
+// restNewMessage is the body of POST /v1/rooms/{path}/messages; a text/plain body is taken as the text
+type restNewMessage struct {
+	Text string `json:"text"`
+}
+
+// restError is the body of every error response
+type restError struct {
+	Error restErrorDetail `json:"error"`
+}
+
+type restErrorDetail struct {
+	// Code is the ErrorInfo reason of the gRPC API, e.g. NOT_FOUND, or UNAUTHENTICATED, RATE_LIMITED and INTERNAL
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Path    string `json:"path,omitempty"`
+	Field   string `json:"field,omitempty"`
+}
+
+// httpError is an error response decided by the HTTP layer itself
+type httpError struct {
+	status int
+	detail restErrorDetail
+}
+
+func (e *httpError) Error() string {
+	return e.detail.Message
+}
+
+func newHTTPError(status int, code, format string, args ...any) *httpError {
+	return &httpError{status: status, detail: restErrorDetail{Code: code, Message: fmt.Sprintf(format, args...)}}
+}
+
+// restCall carries what a route handler needs about the request
+type restCall struct {
+	w     http.ResponseWriter
+	r     *http.Request
+	path  domain.Path
+	token string
+}
+
+// restParam is a query parameter of a route
+type restParam struct {
+	name        string
+	kind        string
+	description string
+}
+
+// restRoute is one operation of the HTTP API; the OpenAPI document is generated from the same table
+type restRoute struct {
+	method string
+	// prefix and suffix surround the node path, e.g. /v1/rooms/{path}/messages
+	prefix  string
+	suffix  string
+	id      string
+	summary string
+	write   bool
+	query   []restParam
+	request any
+	// response is the body of status, or nil when it has none
+	response any
+	status   int
+	handle   func(a *Adaptor, call restCall) (any, error)
+}
+
+var restRoutes = []restRoute{
+	{
+		method: http.MethodGet, prefix: "/v1/fs/", id: "getPath",
+		summary:  "Lists a directory, or describes a room as its only entry",
+		query:    []restParam{{"unread", "boolean", "Include the number of unread messages of each room"}},
+		response: restListing{}, status: http.StatusOK,
+		handle: (*Adaptor).restGetPath,
+	},
+	{
+		method: http.MethodDelete, prefix: "/v1/fs/", id: "deletePath", write: true,
+		summary: "Moves a room or an empty directory owned by the caller to its trash",
+		status:  http.StatusNoContent,
+		handle:  (*Adaptor).restDeletePath,
+	},
+	{
+		method: http.MethodGet, prefix: "/v1/rooms/", suffix: "/messages", id: "listMessages",
+		summary:  "Lists the latest messages of a room",
+		query:    []restParam{{"limit", "integer", "Maximum number of messages, 50 by default and at most 1000"}},
+		response: restMessages{}, status: http.StatusOK,
+		handle: (*Adaptor).restListMessages,
+	},
+	{
+		method: http.MethodPost, prefix: "/v1/rooms/", suffix: "/messages", id: "postMessage", write: true,
+		summary: "Posts a message to a room",
+		request: restNewMessage{}, status: http.StatusNoContent,
+		handle: (*Adaptor).restPostMessage,
+	},
+}
+
+// RESTHandler serves the HTTP/JSON API under RESTPrefix. Callers authenticate with their owner
+// token as a bearer token, or with a client certificate on mutual TLS listeners.
+func (a *Adaptor) RESTHandler() http.Handler {
+	mux := http.NewServeMux()
+	patterns := map[string][]restRoute{}
+	for _, route := range restRoutes {
+		pattern := route.method + " " + route.prefix + "{path...}"
+		patterns[pattern] = append(patterns[pattern], route)
+	}
+	for pattern, routes := range patterns {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			for _, route := range routes {
+				if path, ok := strings.CutSuffix(r.PathValue("path"), route.suffix); ok {
+					a.serveREST(w, r, route, strings.TrimSuffix(path, "/"))
+					return
+				}
+			}
+			writeRESTError(w, newHTTPError(http.StatusNotFound, "NOT_FOUND", "no route for %s %s", r.Method, r.URL.Path))
+		})
+	}
+	mux.HandleFunc("GET "+RESTPrefix+"openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		document, err := OpenAPI()
+		if err != nil {
+			writeRESTError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(document)
+	})
+	mux.HandleFunc(RESTPrefix, func(w http.ResponseWriter, r *http.Request) {
+		writeRESTError(w, newHTTPError(http.StatusNotFound, "NOT_FOUND", "no route for %s %s", r.Method, r.URL.Path))
+	})
+	return mux
+}
+
+func (a *Adaptor) serveREST(w http.ResponseWriter, r *http.Request, route restRoute, path string) {
+	token, err := a.restCaller(r)
+	if err != nil {
+		writeRESTError(w, err)
+		return
+	}
+	if route.write {
+		if ok, retryAfter := a.writeLimiter.allow(a.restRateLimitKey(r, token)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			writeRESTError(w, newHTTPError(http.StatusTooManyRequests, "RATE_LIMITED", "rate limit exceeded, retry after %s", retryAfter.Round(time.Millisecond)))
+			return
+		}
+	}
+	body, err := route.handle(a, restCall{w: w, r: r, path: domain.NewPath("/" + path), token: token})
+	if err != nil {
+		writeRESTError(w, err)
+		return
+	}
+	if body == nil {
+		w.WriteHeader(route.status)
+		return
+	}
+	writeJSON(w, route.status, body)
+}
+
+// restRateLimitKey does for HTTP callers what rateLimitKey does for gRPC ones; token is the
+// caller's, which restCaller has checked belongs to a user
+func (a *Adaptor) restRateLimitKey(r *http.Request, token string) string {
+	if token, _, ok := a.identities.resolveTLS(r.TLS); ok {
+		return "identity:" + token
+	}
+	if key, ok := a.userKey(token); ok {
+		return key
+	}
+	return peerKey(r.RemoteAddr)
+}
+
+// restCaller returns the owner token of the caller: the subject of its verified client
+// certificate if any, else its bearer token, which must belong to a known user and may not be
+// one only a client certificate or a Unix socket gives
+func (a *Adaptor) restCaller(r *http.Request) (string, error) {
+	if token, displayName, ok := a.identities.resolveTLS(r.TLS); ok {
+		a.ensureUser(token, displayName)
+		return token, nil
+	}
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", newHTTPError(http.StatusUnauthorized, "UNAUTHENTICATED", "an Authorization: Bearer <owner token> header is required")
+	}
+	token = strings.TrimSpace(token)
+	if reservedToken(token) {
+		return "", newHTTPError(http.StatusUnauthorized, "UNAUTHENTICATED", "owner tokens starting with %s or %s are only given by a client certificate or a Unix socket", certTokenPrefix, peerTokenPrefix)
+	}
+	if _, err := a.uc.GetConfig(token); err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			return "", newHTTPError(http.StatusUnauthorized, "UNAUTHENTICATED", "unknown owner token")
+		}
+		return "", err
+	}
+	return token, nil
+}
+
+func (a *Adaptor) restGetPath(call restCall) (any, error) {
+	unread, _ := strconv.ParseBool(call.r.URL.Query().Get("unread"))
+	nodes, err := a.uc.ListNodes(call.path, call.token, unread, false)
+	if err != nil {
+		return nil, err
+	}
+	isDirectory, err := a.uc.CheckDirectoryExists(call.path)
+	if err != nil {
+		return nil, err
+	}
+	listing := restListing{Path: call.path.String(), Type: "room", Entries: make([]restNode, len(nodes))}
+	if isDirectory {
+		listing.Type = "directory"
+	}
+	for i, node := range nodes {
+		listing.Entries[i] = restNode{
+			Name:        node.Name,
+			Type:        restNodeType(node.Type),
+			Owner:       node.OwnerName,
+			Modified:    node.CreatedAt,
+			UnreadCount: node.UnreadCount,
+		}
+	}
+	return listing, nil
+}
+
+func restNodeType(nodeType domain.NodeType) string {
+	if nodeType == domain.NodeTypeRoom {
+		return "room"
+	}
+	return "directory"
+}
+
+func (a *Adaptor) restDeletePath(call restCall) (any, error) {
+	return nil, a.uc.DeletePath(call.path, call.token)
+}
+
+func (a *Adaptor) restListMessages(call restCall) (any, error) {
+	limit := defaultRESTMessageLimit
+	if value := call.r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxRESTMessageLimit {
+			return nil, &httpError{status: http.StatusBadRequest, detail: restErrorDetail{
+				Code:    "INVALID_ARGUMENT",
+				Message: fmt.Sprintf("limit must be between 1 and %d", maxRESTMessageLimit),
+				Field:   "limit",
+			}}
+		}
+		limit = parsed
+	}
+	messages, err := a.uc.ListMessages(call.path, int32(limit))
+	if err != nil {
+		return nil, err
+	}
+	response := restMessages{Room: call.path.String(), Messages: make([]restMessage, len(messages))}
+	for i, message := range messages {
+		response.Messages[i] = restMessage{
+			ID:      message.ID,
+			Author:  message.DisplayName,
+			Text:    message.Content,
+			Created: message.CreatedAt,
+		}
+	}
+	return response, nil
+}
+
+// restPostMessage posts as the caller's display name; the bearer token is a secret and never
+// becomes the author
+func (a *Adaptor) restPostMessage(call restCall) (any, error) {
+	text, err := readMessageBody(call.w, call.r)
+	if err != nil {
+		return nil, err
+	}
+	return nil, a.uc.WriteMessage(call.path, text, call.token)
+}
+
+// readMessageBody returns the text of a restNewMessage JSON body, or the whole body of any other type
+func readMessageBody(w http.ResponseWriter, r *http.Request) (string, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRESTBody))
+	if err != nil {
+		return "", newHTTPError(http.StatusRequestEntityTooLarge, "INVALID_ARGUMENT", "request body too large")
+	}
+	if contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); contentType != "application/json" {
+		return string(data), nil
+	}
+	var body restNewMessage
+	if err := json.Unmarshal(data, &body); err != nil {
+		return "", newHTTPError(http.StatusBadRequest, "INVALID_ARGUMENT", "invalid JSON body: %v", err)
+	}
+	return body.Text, nil
+}
+
+// writeRESTError renders err as a restError with the status of its domain error type
+func writeRESTError(w http.ResponseWriter, err error) {
+	var httpErr *httpError
+	if errors.As(err, &httpErr) {
+		if httpErr.status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="chatsh"`)
+		}
+		writeJSON(w, httpErr.status, restError{Error: httpErr.detail})
+		return
+	}
+	var invalid *domain.InvalidMessageError
+	if errors.As(err, &invalid) {
+		field := invalid.Field
+		if field == "" {
+			field = "text"
+		}
+		writeJSON(w, http.StatusBadRequest, restError{Error: restErrorDetail{Code: "INVALID_ARGUMENT", Message: invalid.Error(), Field: field}})
+		return
+	}
+	for _, de := range domainErrors {
+		if !errors.Is(err, de.err) {
+			continue
+		}
+		detail := restErrorDetail{Code: de.reason, Message: err.Error()}
+		var pathErr *domain.PathError
+		if errors.As(err, &pathErr) {
+			detail.Path = pathErr.Path
+		}
+		writeJSON(w, de.httpStatus, restError{Error: detail})
+		return
+	}
+	log.Printf("REST request failed: %v", err)
+	writeJSON(w, http.StatusInternalServerError, restError{Error: restErrorDetail{Code: "INTERNAL", Message: "internal error"}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package adaptor
+
+import (
+	"context"
+	"log"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/ponyo877/chatsh/server/domain"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toPbTrashEntry(entry domain.TrashEntry) *pb.TrashEntry {
+	pbEntry := &pb.TrashEntry{
+		Id:           int64(entry.ID),
+		Type:         toPbNodeType(entry.NodeType),
+		Path:         entry.Path,
+		MessageCount: int32(entry.MessageCount),
+		Deleted:      timestamppb.New(entry.DeletedAt),
+	}
+	if !entry.PurgeAt.IsZero() {
+		pbEntry.Purge = timestamppb.New(entry.PurgeAt)
+	}
+	return pbEntry
+}
+
+func (a *Adaptor) ListTrash(ctx context.Context, in *pb.ListTrashRequest) (*pb.ListTrashResponse, error) {
+	entries, err := a.uc.ListTrash(in.GetOwnerToken())
+	if err != nil {
+		log.Printf("Error listing trash: %v", err)
+		return nil, grpcError(err)
+	}
+	pbEntries := make([]*pb.TrashEntry, len(entries))
+	for i, entry := range entries {
+		pbEntries[i] = toPbTrashEntry(entry)
+	}
+	return &pb.ListTrashResponse{Entries: pbEntries}, nil
+}
+
+func (a *Adaptor) RestoreTrash(ctx context.Context, in *pb.RestoreTrashRequest) (*pb.RestoreTrashResponse, error) {
+	path, err := a.uc.RestoreTrash(int(in.GetId()), in.GetOwnerToken(), in.GetDestinationPath())
+	if err != nil {
+		log.Printf("Error restoring trash entry %d: %v", in.GetId(), err)
+		if statusErr, ok := statusError(err, "id"); ok {
+			return nil, statusErr
+		}
+		return &pb.RestoreTrashResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
+	}
+	return &pb.RestoreTrashResponse{Status: &pb.Status{Ok: true}, Path: path.String()}, nil
+}
+
+func (a *Adaptor) EmptyTrash(ctx context.Context, in *pb.EmptyTrashRequest) (*pb.EmptyTrashResponse, error) {
+	purged, err := a.uc.EmptyTrash(in.GetOwnerToken())
+	if err != nil {
+		log.Printf("Error emptying trash: %v", err)
+		if statusErr, ok := statusError(err, ""); ok {
+			return nil, statusErr
+		}
+		return &pb.EmptyTrashResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
+	}
+	return &pb.EmptyTrashResponse{Status: &pb.Status{Ok: true}, Purged: int32(purged)}, nil
+}
@@ -0,0 +1,105 @@
+package adaptor
+
+import (
+	"context"
+	"log"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/ponyo877/chatsh/server/domain"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var webhookEvents = map[pb.WebhookEvent]domain.WebhookEvent{
+	pb.WebhookEvent_WEBHOOK_MESSAGE: domain.WebhookEventMessage,
+	pb.WebhookEvent_WEBHOOK_CREATE:  domain.WebhookEventCreate,
+	pb.WebhookEvent_WEBHOOK_DELETE:  domain.WebhookEventDelete,
+	pb.WebhookEvent_WEBHOOK_MOVE:    domain.WebhookEventMove,
+}
+
+func toPbWebhookEvent(event domain.WebhookEvent) pb.WebhookEvent {
+	for pbEvent, domainEvent := range webhookEvents {
+		if domainEvent == event {
+			return pbEvent
+		}
+	}
+	return pb.WebhookEvent_WEBHOOK_EVENT_UNKNOWN
+}
+
+// toPbWebhook leaves out the secret, which is only returned on creation
+func toPbWebhook(webhook domain.Webhook) *pb.Webhook {
+	events := make([]pb.WebhookEvent, len(webhook.Events))
+	for i, event := range webhook.Events {
+		events[i] = toPbWebhookEvent(event)
+	}
+	return &pb.Webhook{
+		Id:                int64(webhook.ID),
+		Path:              webhook.Path,
+		Url:               webhook.URL,
+		Events:            events,
+		PathGlob:          webhook.PathGlob,
+		Created:           timestamppb.New(webhook.CreatedAt),
+		PendingDeliveries: int32(webhook.Pending),
+		FailedDeliveries:  int32(webhook.Failed),
+	}
+}
+
+func (a *Adaptor) CreateWebhook(ctx context.Context, in *pb.CreateWebhookRequest) (*pb.CreateWebhookResponse, error) {
+	events := make([]domain.WebhookEvent, len(in.GetEvents()))
+	for i, event := range in.GetEvents() {
+		events[i] = webhookEvents[event]
+	}
+	webhook, err := a.uc.CreateWebhook(domain.NewPath(in.GetPath()), in.GetOwnerToken(), in.GetUrl(), in.GetSecret(), events, in.GetPathGlob())
+	if err != nil {
+		log.Printf("Error creating webhook on %s: %v", in.GetPath(), err)
+		if statusErr, ok := statusError(err, "path"); ok {
+			return nil, statusErr
+		}
+		return &pb.CreateWebhookResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
+	}
+	return &pb.CreateWebhookResponse{
+		Status:  &pb.Status{Ok: true},
+		Webhook: toPbWebhook(webhook),
+		Secret:  webhook.Secret,
+	}, nil
+}
+
+func (a *Adaptor) ListWebhooks(ctx context.Context, in *pb.ListWebhooksRequest) (*pb.ListWebhooksResponse, error) {
+	webhooks, err := a.uc.ListWebhooks(in.GetOwnerToken())
+	if err != nil {
+		log.Printf("Error listing webhooks: %v", err)
+		return nil, grpcError(err)
+	}
+	pbWebhooks := make([]*pb.Webhook, len(webhooks))
+	for i, webhook := range webhooks {
+		pbWebhooks[i] = toPbWebhook(webhook)
+	}
+	return &pb.ListWebhooksResponse{Webhooks: pbWebhooks}, nil
+}
+
+func (a *Adaptor) TestWebhook(ctx context.Context, in *pb.TestWebhookRequest) (*pb.TestWebhookResponse, error) {
+	result, err := a.uc.TestWebhook(int(in.GetId()), in.GetOwnerToken())
+	res := &pb.TestWebhookResponse{
+		Status:     &pb.Status{Ok: true},
+		StatusCode: int32(result.StatusCode),
+		DurationMs: result.Duration.Milliseconds(),
+	}
+	if err != nil {
+		log.Printf("Error testing webhook %d: %v", in.GetId(), err)
+		if statusErr, ok := statusError(err, "id"); ok {
+			return nil, statusErr
+		}
+		res.Status = &pb.Status{Ok: false, Message: err.Error()}
+	}
+	return res, nil
+}
+
+func (a *Adaptor) DeleteWebhook(ctx context.Context, in *pb.DeleteWebhookRequest) (*pb.DeleteWebhookResponse, error) {
+	if err := a.uc.DeleteWebhook(int(in.GetId()), in.GetOwnerToken()); err != nil {
+		log.Printf("Error deleting webhook %d: %v", in.GetId(), err)
+		if statusErr, ok := statusError(err, "id"); ok {
+			return nil, statusErr
+		}
+		return &pb.DeleteWebhookResponse{Status: &pb.Status{Ok: false, Message: err.Error()}}, nil
+	}
+	return &pb.DeleteWebhookResponse{Status: &pb.Status{Ok: true}}, nil
+}
@@ -0,0 +1,386 @@
+// Package archive reads and writes chatsh archives, which carry a subtree of directories and
+// rooms with their messages from one server to another.
+//
+// An archive is a tar file of JSON documents, in this order:
+//
+//	manifest.json                 the Manifest
+//	nodes.jsonl                   a Node per line, every directory before what it contains
+//	messages/000003-0000.jsonl    a Message per line for the room on line 3 of nodes.jsonl
+//	messages/000003-0001.jsonl    and so on, at most MessagesPerPart per file
+//
+// Node paths are relative: the first node is the exported root, named by its base name, and
+// the others are below it, so that importing into a directory recreates the root there.
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	pathpkg "path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+const (
+	// Format names chatsh archives in their manifest
+	Format = "chatsh-archive"
+	// Version is the version of the format this package writes and the newest it reads
+	Version = 1
+	// MessagesPerPart caps the messages of one file, which both sides hold in memory at once
+	MessagesPerPart = 1000
+
+	manifestName = "manifest.json"
+	nodesName    = "nodes.jsonl"
+	messagesDir  = "messages/"
+	// maxEntrySize bounds the files read from an archive, which a part never comes near
+	maxEntrySize = 64 << 20
+)
+
+// messagesFileName matches the files of message parts and captures the index of their room
+var messagesFileName = regexp.MustCompile(`^messages/(\d+)-\d+\.jsonl$`)
+
+const (
+	TypeDirectory = "directory"
+	TypeRoom      = "room"
+)
+
+type Manifest struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	// Root is the absolute path the archive was exported from
+	Root       string    `json:"root"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+func NewManifest(root string, exportedAt time.Time) Manifest {
+	return Manifest{
+		Format:     Format,
+		Version:    Version,
+		Root:       root,
+		ExportedAt: exportedAt,
+	}
+}
+
+// Node is a directory or room; the owner is only informative, as tokens never leave a server
+type Node struct {
+	Path      string     `json:"path"`
+	Type      string     `json:"type"`
+	Owner     string     `json:"owner,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	Limits    *Limits    `json:"limits,omitempty"`
+	Retention *Retention `json:"retention,omitempty"`
+}
+
+// Limits are the settings of a room that differ from the server defaults
+type Limits struct {
+	SlowModeSeconds  int `json:"slow_mode_seconds,omitempty"`
+	MaxMessageLength int `json:"max_message_length,omitempty"`
+	MaxMessageLines  int `json:"max_message_lines,omitempty"`
+}
+
+// Retention is the retention policy set on a node itself
+type Retention struct {
+	MaxAgeSeconds int64 `json:"max_age_seconds,omitempty"`
+	MaxMessages   int   `json:"max_messages,omitempty"`
+	IdleSeconds   int64 `json:"idle_seconds,omitempty"`
+}
+
+type Message struct {
+	Author    string    `json:"author"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Error reports an archive that cannot be read
+type Error struct {
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return "invalid archive: " + e.Reason
+}
+
+func invalid(format string, args ...any) error {
+	return &Error{Reason: fmt.Sprintf(format, args...)}
+}
+
+// Writer writes an archive. WriteHeader comes first, then the messages of every room, in any
+// order and as many parts as needed, then Close.
+type Writer struct {
+	tw      *tar.Writer
+	modTime time.Time
+	rooms   map[int]bool
+	parts   map[int]int
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		tw:    tar.NewWriter(w),
+		parts: map[int]int{},
+	}
+}
+
+func (w *Writer) writeFile(name string, content []byte) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(content)),
+		ModTime: w.modTime,
+		Format:  tar.FormatPAX,
+	}
+	if err := w.tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := w.tw.Write(content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// WriteHeader writes the manifest and the nodes, the root first
+func (w *Writer) WriteHeader(manifest Manifest, nodes []Node) error {
+	w.modTime = manifest.ExportedAt
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := w.writeFile(manifestName, append(content, '\n')); err != nil {
+		return err
+	}
+	w.rooms = map[int]bool{}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i, node := range nodes {
+		if err := encoder.Encode(node); err != nil {
+			return fmt.Errorf("failed to encode node %s: %w", node.Path, err)
+		}
+		w.rooms[i] = node.Type == TypeRoom
+	}
+	return w.writeFile(nodesName, buf.Bytes())
+}
+
+// WriteMessages writes a part of the messages of the room at index room of the nodes
+func (w *Writer) WriteMessages(room int, messages []Message) error {
+	if !w.rooms[room] {
+		return fmt.Errorf("node %d is not a room", room)
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, message := range messages {
+		if err := encoder.Encode(message); err != nil {
+			return fmt.Errorf("failed to encode message: %w", err)
+		}
+	}
+	name := fmt.Sprintf("%s%06d-%04d.jsonl", messagesDir, room, w.parts[room])
+	w.parts[room]++
+	return w.writeFile(name, buf.Bytes())
+}
+
+// Close finishes the tar file; it does not close the underlying writer
+func (w *Writer) Close() error {
+	return w.tw.Close()
+}
+
+// Reader reads an archive written by Writer, checking it as it goes
+type Reader struct {
+	tr    *tar.Reader
+	nodes []Node
+}
+
+// NewReader reads the manifest and the nodes at the start of the archive
+func NewReader(r io.Reader) (*Reader, Manifest, []Node, error) {
+	reader := &Reader{tr: tar.NewReader(r)}
+	var manifest Manifest
+	if err := reader.readJSON(manifestName, &manifest); err != nil {
+		return nil, Manifest{}, nil, err
+	}
+	if manifest.Format != Format {
+		return nil, Manifest{}, nil, invalid("not a chatsh archive")
+	}
+	if manifest.Version < 1 || manifest.Version > Version {
+		return nil, Manifest{}, nil, invalid("format version %d is newer than %d", manifest.Version, Version)
+	}
+	content, err := reader.next(nodesName)
+	if err != nil {
+		return nil, Manifest{}, nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	for {
+		var node Node
+		if err := decoder.Decode(&node); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, Manifest{}, nil, invalid("%s: %v", nodesName, err)
+		}
+		reader.nodes = append(reader.nodes, node)
+	}
+	if err := checkNodes(reader.nodes); err != nil {
+		return nil, Manifest{}, nil, err
+	}
+	return reader, manifest, reader.nodes, nil
+}
+
+// next reads the next file of the archive, which must be called name
+func (r *Reader) next(name string) ([]byte, error) {
+	header, err := r.tr.Next()
+	if errors.Is(err, io.EOF) {
+		return nil, invalid("%s is missing", name)
+	}
+	if err != nil {
+		return nil, invalid("%v", err)
+	}
+	if header.Name != name {
+		return nil, invalid("expected %s, found %s", name, header.Name)
+	}
+	return r.readEntry(header)
+}
+
+func (r *Reader) readEntry(header *tar.Header) ([]byte, error) {
+	if header.Size > maxEntrySize {
+		return nil, invalid("%s is larger than %d bytes", header.Name, maxEntrySize)
+	}
+	content, err := io.ReadAll(r.tr)
+	if err != nil {
+		return nil, invalid("%s: %v", header.Name, err)
+	}
+	return content, nil
+}
+
+func (r *Reader) readJSON(name string, v any) error {
+	content, err := r.next(name)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return invalid("%s: %v", name, err)
+	}
+	return nil
+}
+
+// NextMessages returns the next part of messages and the index of their room among the nodes,
+// or io.EOF at the end of the archive. Files it does not know are skipped.
+func (r *Reader) NextMessages() (int, []Message, error) {
+	for {
+		header, err := r.tr.Next()
+		if errors.Is(err, io.EOF) {
+			return 0, nil, io.EOF
+		}
+		if err != nil {
+			return 0, nil, invalid("%v", err)
+		}
+		match := messagesFileName.FindStringSubmatch(header.Name)
+		if header.Typeflag != tar.TypeReg || match == nil {
+			continue
+		}
+		room, err := strconv.Atoi(match[1])
+		if err != nil || room >= len(r.nodes) || r.nodes[room].Type != TypeRoom {
+			return 0, nil, invalid("%s belongs to no room", header.Name)
+		}
+		content, err := r.readEntry(header)
+		if err != nil {
+			return 0, nil, err
+		}
+		var messages []Message
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		for {
+			var message Message
+			if err := decoder.Decode(&message); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return 0, nil, invalid("%s: %v", header.Name, err)
+			}
+			if err := checkMessage(message); err != nil {
+				return 0, nil, invalid("%s: %v", header.Name, err)
+			}
+			messages = append(messages, message)
+		}
+		return room, messages, nil
+	}
+}
+
+// checkNodes makes sure the nodes form a tree below the first one, whatever wrote them
+func checkNodes(nodes []Node) error {
+	if len(nodes) == 0 {
+		return invalid("%s lists no nodes", nodesName)
+	}
+	directories := map[string]bool{}
+	seen := map[string]bool{}
+	for i, node := range nodes {
+		if node.Path == "" || node.Path != pathpkg.Clean(node.Path) || pathpkg.IsAbs(node.Path) {
+			return invalid("bad node path %q", node.Path)
+		}
+		for _, name := range strings.Split(node.Path, "/") {
+			if name == "." || name == ".." {
+				return invalid("bad node path %q", node.Path)
+			}
+		}
+		if seen[node.Path] {
+			return invalid("node %s is listed twice", node.Path)
+		}
+		seen[node.Path] = true
+		parent := pathpkg.Dir(node.Path)
+		switch {
+		case i == 0 && parent != ".":
+			return invalid("root %s is not a single name", node.Path)
+		case i > 0 && !directories[parent]:
+			return invalid("node %s comes before its directory", node.Path)
+		}
+		switch node.Type {
+		case TypeDirectory:
+			directories[node.Path] = true
+			if node.Limits != nil {
+				return invalid("directory %s has room limits", node.Path)
+			}
+		case TypeRoom:
+			if err := checkLimits(node.Limits); err != nil {
+				return invalid("room %s: %v", node.Path, err)
+			}
+		default:
+			return invalid("node %s has unknown type %q", node.Path, node.Type)
+		}
+		if node.Retention != nil && (node.Retention.MaxAgeSeconds < 0 || node.Retention.IdleSeconds < 0 ||
+			node.Retention.MaxMessages < 0 || node.Retention.MaxMessages > domain.MaxRetentionMessages) {
+			return invalid("node %s has a bad retention policy", node.Path)
+		}
+	}
+	return nil
+}
+
+func checkLimits(limits *Limits) error {
+	switch {
+	case limits == nil:
+		return nil
+	case limits.SlowModeSeconds < 0:
+		return fmt.Errorf("negative slow mode")
+	case limits.MaxMessageLength < 0 || limits.MaxMessageLength > domain.MaxMessageLengthLimit:
+		return fmt.Errorf("max_message_length must be between 0 and %d", domain.MaxMessageLengthLimit)
+	case limits.MaxMessageLines < 0 || limits.MaxMessageLines > domain.MaxMessageLinesLimit:
+		return fmt.Errorf("max_message_lines must be between 0 and %d", domain.MaxMessageLinesLimit)
+	}
+	return nil
+}
+
+func checkMessage(message Message) error {
+	switch {
+	case message.Author == "":
+		return fmt.Errorf("message without author")
+	case !utf8.ValidString(message.Text) || !utf8.ValidString(message.Author):
+		return fmt.Errorf("message is not valid UTF-8")
+	case utf8.RuneCountInString(message.Text) > domain.MaxMessageLengthLimit:
+		return fmt.Errorf("message is longer than %d characters", domain.MaxMessageLengthLimit)
+	case message.CreatedAt.IsZero():
+		return fmt.Errorf("message without time")
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/config"
+)
+
+// listen opens l. Unix sockets left behind by a server that did not shut down cleanly are
+// replaced, and new sockets get mode as their permissions.
+func listen(l config.Listener, mode os.FileMode) (net.Listener, error) {
+	if l.Transport == config.TransportUnix {
+		if err := removeStaleSocket(l.Address); err != nil {
+			return nil, err
+		}
+	}
+	lis, err := net.Listen(l.Network(), l.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", l, err)
+	}
+	if l.Transport == config.TransportUnix {
+		if err := os.Chmod(l.Address, mode); err != nil {
+			lis.Close()
+			return nil, fmt.Errorf("failed to set permissions of %s: %w", l.Address, err)
+		}
+	}
+	return lis, nil
+}
+
+// removeStaleSocket removes the socket at path unless a server still answers on it
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to inspect %s: %w", path, err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another server", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove stale socket %s: %w", path, err)
+	}
+	return nil
+}
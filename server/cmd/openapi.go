@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/ponyo877/chatsh/server/adaptor"
+	"github.com/spf13/cobra"
+)
+
+var openapiCmd = &cobra.Command{
+	Use:   "openapi",
+	Short: "Prints the OpenAPI document of the HTTP/JSON API.",
+	Long: `Prints the OpenAPI document of the HTTP/JSON API.
+
+A running server serves the same document at /v1/openapi.json.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		document, err := adaptor.OpenAPI()
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(append(document, '\n'))
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(openapiCmd)
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/ponyo877/chatsh/server/config"
+	"github.com/ponyo877/chatsh/server/repository"
+	"github.com/spf13/cobra"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Replaces the database with a backup.",
+	Long: `Replaces the database with a backup taken by chatsh admin backup.
+
+Stop the server first. The backup is checked before it replaces anything: it
+must pass SQLite's integrity check and must not come from a newer release.
+The database it replaces is kept next to it as <db>.before-restore. The
+restored database is migrated to this release and checked again.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if cfg.Storage != config.StorageSQLite {
+			return fmt.Errorf("storage %s has no database", cfg.Storage)
+		}
+		dbFile := databaseFile(cfg.Database.DSN)
+		if dbFile == "" {
+			return fmt.Errorf("database %s is not a file", cfg.Database.DSN)
+		}
+		previous := dbFile + ".before-restore"
+		if _, err := os.Stat(previous); err == nil {
+			return fmt.Errorf("%s is left from an earlier restore; remove it first", previous)
+		}
+
+		staged := dbFile + ".restore"
+		if err := copyFile(args[0], staged); err != nil {
+			return err
+		}
+		version, err := checkBackup(staged)
+		if err != nil {
+			removeDatabaseFiles(staged)
+			return fmt.Errorf("%s: %w", args[0], err)
+		}
+		if err := renameDatabaseFiles(dbFile, previous); err != nil {
+			removeDatabaseFiles(staged)
+			return err
+		}
+		if err := os.Rename(staged, dbFile); err != nil {
+			return fmt.Errorf("failed to move the backup into place: %w", err)
+		}
+		fmt.Printf("Restored %s (schema %04d) to %s\n", args[0], version, dbFile)
+		if _, err := os.Stat(previous); err == nil {
+			fmt.Printf("The previous database is kept as %s\n", previous)
+		}
+
+		conn, err := openDatabase(cfg)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		migrator, err := newMigrator(conn)
+		if err != nil {
+			return err
+		}
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			fmt.Printf("Applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if err := repository.CheckIntegrity(conn); err != nil {
+			return err
+		}
+		var rooms, messages int
+		if err := conn.QueryRow("SELECT (SELECT COUNT(*) FROM rooms), (SELECT COUNT(*) FROM messages)").Scan(&rooms, &messages); err != nil {
+			return fmt.Errorf("failed to count rooms: %w", err)
+		}
+		fmt.Printf("Integrity check passed: %d rooms, %d messages.\n", rooms, messages)
+		return nil
+	},
+}
+
+// databaseFile returns the file of a SQLite DSN such as "file:chatsh.db?cache=shared",
+// or "" for in-memory databases
+func databaseFile(dsn string) string {
+	if strings.Contains(dsn, "mode=memory") {
+		return ""
+	}
+	file, _, _ := strings.Cut(strings.TrimPrefix(dsn, "file:"), "?")
+	if file == ":memory:" {
+		return ""
+	}
+	return file
+}
+
+// checkBackup verifies the database in file and returns its schema version
+func checkBackup(file string) (int, error) {
+	conn, err := repository.OpenSQLite(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer conn.Close()
+	if err := repository.CheckIntegrity(conn); err != nil {
+		return 0, err
+	}
+	migrator, err := newMigrator(conn)
+	if err != nil {
+		return 0, err
+	}
+	version, err := migrator.Version()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case version == 0:
+		return 0, fmt.Errorf("not a chatsh database")
+	case version > migrator.Latest():
+		return 0, fmt.Errorf("schema %04d is newer than this release, which knows up to %04d", version, migrator.Latest())
+	}
+	return version, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	return out.Close()
+}
+
+// databaseSuffixes are the files SQLite keeps next to a database: its write-ahead log, the
+// shared memory of the log and the rollback journal
+var databaseSuffixes = []string{"", "-wal", "-shm", "-journal"}
+
+// renameDatabaseFiles moves a database along with the files SQLite keeps next to it, so that
+// commits still in the write-ahead log move too; a missing database is not an error
+func renameDatabaseFiles(src, dst string) error {
+	for _, suffix := range databaseSuffixes {
+		if err := os.Rename(src+suffix, dst+suffix); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to move %s aside: %w", src+suffix, err)
+		}
+	}
+	return nil
+}
+
+func removeDatabaseFiles(file string) {
+	for _, suffix := range databaseSuffixes {
+		os.Remove(file + suffix)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+}
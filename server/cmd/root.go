@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/ponyo877/chatsh/schema"
+	"github.com/ponyo877/chatsh/server/adaptor"
+	"github.com/ponyo877/chatsh/server/config"
+	"github.com/ponyo877/chatsh/server/repository"
+	"github.com/ponyo877/chatsh/server/repository/cache"
+	"github.com/ponyo877/chatsh/server/repository/memory"
+	"github.com/ponyo877/chatsh/server/usecase"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
+)
+
+var cfgFile string
+
+var rootCmd = &cobra.Command{
+	Use:   "chatsh-server",
+	Short: "Runs the chatsh gRPC server.",
+	Long: `Runs the chatsh gRPC server.
+
+Every option can be given as a flag, as an environment variable (CHATSH_*)
+or in the YAML file passed with --config, in that order of precedence.
+PORT sets the default listen address for platforms such as Cloud Run.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if printConfig, _ := cmd.Flags().GetBool("print-config"); printConfig {
+			out, err := cfg.YAML()
+			if err != nil {
+				return err
+			}
+			fmt.Print(out)
+			return nil
+		}
+		return serve(cfg)
+	},
+}
+
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", os.Getenv("CHATSH_CONFIG"), "YAML config file (env CHATSH_CONFIG)")
+	config.RegisterFlags(rootCmd.PersistentFlags())
+	rootCmd.Flags().Bool("print-config", false, "Print the effective configuration as YAML and exit")
+}
+
+// loadConfig resolves the configuration and applies its log level
+func loadConfig(cmd *cobra.Command) (config.Config, error) {
+	cfg, err := config.Load(cfgFile, cmd.Flags())
+	if err != nil {
+		return config.Config{}, err
+	}
+	level, err := cfg.SlogLevel()
+	if err != nil {
+		return config.Config{}, err
+	}
+	// Route the log package through slog so that the level also filters it
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+	return cfg, nil
+}
+
+// openDatabase opens the database without touching its schema
+func openDatabase(cfg config.Config) (*sql.DB, error) {
+	if cfg.Storage != config.StorageSQLite {
+		return nil, fmt.Errorf("storage %s has no database", cfg.Storage)
+	}
+	conn, err := repository.OpenSQLite(repository.WithPragmas(cfg.Database.DSN, sqlitePragmas(cfg.Database)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open db: %w", err)
+	}
+	return conn, nil
+}
+
+func sqlitePragmas(cfg config.DatabaseConfig) []repository.Pragma {
+	pragmas := []repository.Pragma{{Name: "busy_timeout", Value: strconv.FormatInt(cfg.BusyTimeout.Milliseconds(), 10)}}
+	if cfg.WAL {
+		// A WAL database stays consistent on power loss with NORMAL, it only may lose the last commits
+		pragmas = append(pragmas, repository.Pragma{Name: "journal_mode", Value: "WAL"}, repository.Pragma{Name: "synchronous", Value: "NORMAL"})
+	}
+	return pragmas
+}
+
+func batchOptions(cfg config.DatabaseConfig) repository.BatchOptions {
+	return repository.NewBatchOptions(cfg.WriteBatchSize, cfg.WriteBatchDelay)
+}
+
+// newMigrator returns a migrator for the migrations embedded in the binary
+func newMigrator(conn *sql.DB) (*repository.Migrator, error) {
+	migrations, err := repository.LoadMigrations(schema.Migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewMigrator(conn, migrations), nil
+}
+
+// migrateUp brings the schema up to date before the server starts
+func migrateUp(conn *sql.DB) error {
+	migrator, err := newMigrator(conn)
+	if err != nil {
+		return err
+	}
+	applied, err := migrator.Up()
+	for _, migration := range applied {
+		log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
+	}
+	return err
+}
+
+// openRepository returns the repository selected by cfg, with its schema up to date, and
+// a function that releases it
+func openRepository(cfg config.Config) (usecase.Repository, func() error, error) {
+	if cfg.Storage == config.StorageMemory {
+		log.Printf("Using in-memory storage; all data is lost when the server stops")
+		return memory.NewRepository(), func() error { return nil }, nil
+	}
+	conn, err := openDatabase(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("Using SQLite through %s", repository.SQLiteDriver)
+	if err := migrateUp(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	rp := repository.NewRepository(conn, batchOptions(cfg.Database))
+	closeRepository := func() error {
+		return errors.Join(rp.Close(), conn.Close())
+	}
+	return rp, closeRepository, nil
+}
+
+// newGRPCServer builds a gRPC server with the stream limits of cfg and the transport security creds
+func newGRPCServer(cfg config.Config, ad *adaptor.Adaptor, creds credentials.TransportCredentials) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.MaxConcurrentStreams(cfg.Stream.MaxConcurrentStreams),
+		grpc.NumStreamWorkers(cfg.Stream.Workers),
+		// The identity runs first so that rate limits apply to the transport's user
+		grpc.ChainUnaryInterceptor(ad.UnaryIdentityInterceptor(), ad.UnaryRateLimitInterceptor()),
+		grpc.StreamInterceptor(ad.StreamIdentityInterceptor()),
+	}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+	s := grpc.NewServer(opts...)
+	pb.RegisterChatshServiceServer(s, ad)
+	reflection.Register(s)
+	return s
+}
+
+// transportCredentials returns the security of each kind of listener; plaintext TCP has none
+func transportCredentials(cfg config.Config) (map[config.Transport]credentials.TransportCredentials, error) {
+	creds := map[config.Transport]credentials.TransportCredentials{
+		config.TransportTCP:  nil,
+		config.TransportUnix: adaptor.NewPeerCredentials(),
+	}
+	if cfg.TLSEnabled() {
+		tlsConfig, err := serverTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		creds[config.TransportTLS] = credentials.NewTLS(tlsConfig)
+	}
+	return creds, nil
+}
+
+// serverTLSConfig loads the server key pair and, when client authentication is on, the client CAs
+func serverTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.ClientAuth == config.ClientAuthNone {
+		return tlsConfig, nil
+	}
+	pem, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in client CA file %s", cfg.ClientCAFile)
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	if cfg.ClientAuth == config.ClientAuthRequire {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+func serve(cfg config.Config) error {
+	rp, closeRepository, err := openRepository(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeRepository()
+	if cfg.PathCacheSize > 0 {
+		cached := cache.NewRepository(rp, cfg.PathCacheSize)
+		expvar.Publish("path_cache", expvar.Func(func() any { return cached.Stats() }))
+		rp = cached
+	}
+
+	peerTokens, err := cfg.PeerIdentityTokens()
+	if err != nil {
+		return err
+	}
+	messageHooks, err := cfg.MessageHookPipeline()
+	if err != nil {
+		return err
+	}
+	webhookOptions, err := cfg.WebhookOptions()
+	if err != nil {
+		return err
+	}
+
+	uc := usecase.NewUsecase(rp, cfg.MessageLimits(), cfg.Stream.SessionTimeout, webhookOptions, cfg.RetentionOptions(), messageHooks, cfg.AdminTokens)
+	ad := adaptor.NewAdaptor(uc, adaptor.RateLimits{
+		WritePerSecond:  cfg.RateLimit.WritePerSecond,
+		WriteBurst:      cfg.RateLimit.WriteBurst,
+		StreamPerSecond: cfg.RateLimit.StreamPerSecond,
+		StreamBurst:     cfg.RateLimit.StreamBurst,
+	}, adaptor.Identities{
+		CertTokens: cfg.ClientIdentityTokens(),
+		PeerTokens: peerTokens,
+		PeerUsers:  cfg.Unix.PeerUsers,
+	})
+	creds, err := transportCredentials(cfg)
+	if err != nil {
+		return err
+	}
+	listenerConfigs, err := cfg.Listeners()
+	if err != nil {
+		return err
+	}
+	socketMode, err := cfg.SocketMode()
+	if err != nil {
+		return err
+	}
+
+	listeners := make([]net.Listener, 0, len(listenerConfigs))
+	for _, l := range listenerConfigs {
+		lis, err := listen(l, os.FileMode(socketMode))
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, lis)
+	}
+
+	servers, err := newServers(cfg, ad, creds)
+	if err != nil {
+		return err
+	}
+	serveErr := make(chan error, len(listeners))
+	for i, lis := range listeners {
+		transport := listenerConfigs[i].Transport
+		s := servers[transport]
+		log.Printf("Server is listening on %s://%s (%s)", transport, lis.Addr(), s.protocols)
+		go func() {
+			serveErr <- s.serve(lis)
+		}()
+	}
+	// One failing listener stops the whole server
+	err = <-serveErr
+	for _, s := range servers {
+		s.stop()
+	}
+	return fmt.Errorf("failed to serve: %w", err)
+}
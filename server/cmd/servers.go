@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"expvar"
+	"net"
+	"net/http"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"github.com/ponyo877/chatsh/server/adaptor"
+	"github.com/ponyo877/chatsh/server/config"
+	"github.com/ponyo877/chatsh/server/web"
+	"google.golang.org/grpc/credentials"
+)
+
+const webProtocols = "gRPC, gRPC-Web, Connect, HTTP/JSON"
+
+// server serves the listeners of one transport
+type server struct {
+	serve     func(net.Listener) error
+	stop      func()
+	protocols string
+}
+
+// newServers builds one server per transport. With the web protocols enabled, TCP and TLS
+// listeners are served by net/http, which hands native gRPC calls to the gRPC server,
+// translates gRPC-Web and Connect calls for it and serves the HTTP/JSON API under /v1/. Unix sockets always speak native gRPC so that
+// the peer credentials of the connection reach the Adaptor.
+func newServers(cfg config.Config, ad *adaptor.Adaptor, creds map[config.Transport]credentials.TransportCredentials) (map[config.Transport]server, error) {
+	servers := map[config.Transport]server{}
+	for transport, cred := range creds {
+		if transport == config.TransportUnix || !cfg.Web.Enabled {
+			s := newGRPCServer(cfg, ad, cred)
+			servers[transport] = server{serve: s.Serve, stop: s.Stop, protocols: "gRPC"}
+		}
+	}
+	if !cfg.Web.Enabled {
+		return servers, nil
+	}
+
+	rpc, err := web.NewHandler(newGRPCServer(cfg, ad, nil), pb.ChatshService_ServiceDesc.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(adaptor.RESTPrefix, ad.RESTHandler())
+	mux.Handle(adaptor.IncomingWebhookPrefix, ad.IncomingWebhookHandler())
+	if cfg.Web.Metrics {
+		mux.Handle("GET /debug/vars", expvar.Handler())
+	}
+	mux.Handle("/", rpc)
+	handler := web.WithCORS(web.CORS{
+		AllowedOrigins: cfg.Web.CORS.AllowedOrigins,
+		AllowedHeaders: cfg.Web.CORS.AllowedHeaders,
+		MaxAge:         cfg.Web.CORS.MaxAge,
+	}, mux)
+	plain := newHTTPServer(cfg, handler)
+	plain.Protocols.SetUnencryptedHTTP2(true)
+	servers[config.TransportTCP] = server{serve: plain.Serve, stop: func() { plain.Close() }, protocols: webProtocols}
+	if cfg.TLSEnabled() {
+		tlsConfig, err := serverTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		secure := newHTTPServer(cfg, handler)
+		secure.Protocols.SetHTTP2(true)
+		secure.TLSConfig = tlsConfig
+		servers[config.TransportTLS] = server{
+			serve:     func(lis net.Listener) error { return secure.ServeTLS(lis, "", "") },
+			stop:      func() { secure.Close() },
+			protocols: webProtocols,
+		}
+	}
+	return servers, nil
+}
+
+func newHTTPServer(cfg config.Config, handler http.Handler) *http.Server {
+	s := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		Protocols:         new(http.Protocols),
+		HTTP2: &http.HTTP2Config{
+			MaxConcurrentStreams: int(cfg.Stream.MaxConcurrentStreams),
+		},
+	}
+	s.Protocols.SetHTTP1(true)
+	return s
+}
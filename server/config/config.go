@@ -0,0 +1,511 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultPort = "50051"
+
+type Config struct {
+	// Storage selects the repository: sqlite, or memory for tests and ephemeral servers that lose their data on exit
+	Storage  string         `mapstructure:"storage" yaml:"storage"`
+	Database DatabaseConfig `mapstructure:"database" yaml:"database"`
+	// PathCacheSize is how many resolved paths are kept in front of the storage; 0 disables the cache
+	PathCacheSize int             `mapstructure:"path_cache_size" yaml:"path_cache_size"`
+	Listen        []string        `mapstructure:"listen" yaml:"listen"`
+	TLS           TLSConfig       `mapstructure:"tls" yaml:"tls"`
+	Unix          UnixConfig      `mapstructure:"unix" yaml:"unix"`
+	Web           WebConfig       `mapstructure:"web" yaml:"web"`
+	Stream        StreamConfig    `mapstructure:"stream" yaml:"stream"`
+	Message       MessageConfig   `mapstructure:"message" yaml:"message"`
+	RateLimit     RateLimitConfig `mapstructure:"rate_limit" yaml:"rate_limit"`
+	Webhook       WebhookConfig   `mapstructure:"webhook" yaml:"webhook"`
+	Retention     RetentionConfig `mapstructure:"retention" yaml:"retention"`
+	Trash         TrashConfig     `mapstructure:"trash" yaml:"trash"`
+	// MessageHooks filter and rewrite the messages of directory subtrees, in the order given
+	MessageHooks []MessageHookConfig `mapstructure:"message_hooks" yaml:"message_hooks"`
+	// AdminTokens are the owner tokens allowed to call admin RPCs such as Backup; none when empty
+	AdminTokens []string `mapstructure:"admin_tokens" yaml:"admin_tokens"`
+	LogLevel    string   `mapstructure:"log_level" yaml:"log_level"`
+}
+
+type DatabaseConfig struct {
+	DSN string `mapstructure:"dsn" yaml:"dsn"`
+	// WAL lets reads go on while messages are written; litestream replication needs it too
+	WAL bool `mapstructure:"wal" yaml:"wal"`
+	// BusyTimeout is how long a connection waits for another one's write lock before failing
+	BusyTimeout time.Duration `mapstructure:"busy_timeout" yaml:"busy_timeout"`
+	// WriteBatchSize caps the messages committed in one transaction; 1 commits each on its own
+	WriteBatchSize int `mapstructure:"write_batch_size" yaml:"write_batch_size"`
+	// WriteBatchDelay is how long a message may wait for others to share its commit
+	WriteBatchDelay time.Duration `mapstructure:"write_batch_delay" yaml:"write_batch_delay"`
+}
+
+type TLSConfig struct {
+	CertFile string `mapstructure:"cert_file" yaml:"cert_file"`
+	KeyFile  string `mapstructure:"key_file" yaml:"key_file"`
+	// ClientCAFile holds the CAs that sign client certificates
+	ClientCAFile string `mapstructure:"client_ca_file" yaml:"client_ca_file"`
+	// ClientAuth is none, request (verify certificates when given) or require
+	ClientAuth string `mapstructure:"client_auth" yaml:"client_auth"`
+	// ClientIdentities maps client certificates to existing users; other certificates act as
+	// the user "cert:<common name>"
+	ClientIdentities []ClientIdentity `mapstructure:"client_identities" yaml:"client_identities"`
+}
+
+// ClientIdentity maps the subject of a client certificate, e.g. "CN=alice,O=Example", to an owner token
+type ClientIdentity struct {
+	Subject    string `mapstructure:"subject" yaml:"subject"`
+	OwnerToken string `mapstructure:"owner_token" yaml:"owner_token"`
+}
+
+const (
+	StorageSQLite = "sqlite"
+	StorageMemory = "memory"
+)
+
+const (
+	ClientAuthNone    = "none"
+	ClientAuthRequest = "request"
+	ClientAuthRequire = "require"
+)
+
+type UnixConfig struct {
+	// SocketMode holds the octal permissions of Unix sockets, which act as their access control
+	SocketMode string `mapstructure:"socket_mode" yaml:"socket_mode"`
+	// PeerUsers lets local users connecting over a Unix socket act as the user "unix:<login name>"
+	PeerUsers bool `mapstructure:"peer_users" yaml:"peer_users"`
+	// PeerIdentities maps local users to existing chatsh users
+	PeerIdentities []PeerIdentity `mapstructure:"peer_identities" yaml:"peer_identities"`
+}
+
+// PeerIdentity maps a local user, by login name or uid, to an owner token
+type PeerIdentity struct {
+	User       string `mapstructure:"user" yaml:"user"`
+	OwnerToken string `mapstructure:"owner_token" yaml:"owner_token"`
+}
+
+type WebConfig struct {
+	// Enabled serves gRPC-Web, Connect and the HTTP/JSON API next to native gRPC on the TCP and TLS listeners
+	Enabled bool       `mapstructure:"enabled" yaml:"enabled"`
+	CORS    CORSConfig `mapstructure:"cors" yaml:"cors"`
+	// Metrics serves expvar counters, such as the hit rate of the path cache, at /debug/vars
+	Metrics bool `mapstructure:"metrics" yaml:"metrics"`
+}
+
+type CORSConfig struct {
+	// AllowedOrigins lists the browser origins allowed to call the server; "*" allows any
+	AllowedOrigins []string      `mapstructure:"allowed_origins" yaml:"allowed_origins"`
+	AllowedHeaders []string      `mapstructure:"allowed_headers" yaml:"allowed_headers"`
+	MaxAge         time.Duration `mapstructure:"max_age" yaml:"max_age"`
+}
+
+type StreamConfig struct {
+	MaxConcurrentStreams uint32 `mapstructure:"max_concurrent_streams" yaml:"max_concurrent_streams"`
+	Workers              uint32 `mapstructure:"workers" yaml:"workers"`
+	// SessionTimeout ends chat sessions that stay silent this long; zero keeps them open
+	SessionTimeout time.Duration `mapstructure:"session_timeout" yaml:"session_timeout"`
+}
+
+type MessageConfig struct {
+	MaxLength int `mapstructure:"max_length" yaml:"max_length"`
+	MaxLines  int `mapstructure:"max_lines" yaml:"max_lines"`
+}
+
+type RateLimitConfig struct {
+	WritePerSecond  float64 `mapstructure:"write_per_second" yaml:"write_per_second"`
+	WriteBurst      int     `mapstructure:"write_burst" yaml:"write_burst"`
+	StreamPerSecond float64 `mapstructure:"stream_per_second" yaml:"stream_per_second"`
+	StreamBurst     int     `mapstructure:"stream_burst" yaml:"stream_burst"`
+}
+
+type WebhookConfig struct {
+	// MaxAttempts is the number of tries before a delivery is abandoned
+	MaxAttempts int           `mapstructure:"max_attempts" yaml:"max_attempts"`
+	Timeout     time.Duration `mapstructure:"timeout" yaml:"timeout"`
+	Workers     int           `mapstructure:"workers" yaml:"workers"`
+	// AllowedNetworks are the private, loopback or link-local networks, as CIDRs or single
+	// addresses, that webhooks may be delivered to; public addresses are always allowed
+	AllowedNetworks []string `mapstructure:"allowed_networks" yaml:"allowed_networks"`
+}
+
+type RetentionConfig struct {
+	// Interval is the time between two sweeps of the retention janitor; 0 turns it off
+	Interval time.Duration `mapstructure:"interval" yaml:"interval"`
+	// BatchSize caps the messages expired in one transaction, so that writers never wait long
+	BatchSize int `mapstructure:"batch_size" yaml:"batch_size"`
+}
+
+type TrashConfig struct {
+	// PurgeAfter is how long removed rooms and directories can be restored; 0 deletes them right away
+	PurgeAfter time.Duration `mapstructure:"purge_after" yaml:"purge_after"`
+}
+
+// MessageHookConfig applies hooks to the rooms at and below Path
+type MessageHookConfig struct {
+	Path  string       `mapstructure:"path" yaml:"path"`
+	Hooks []HookConfig `mapstructure:"hooks" yaml:"hooks"`
+}
+
+// HookConfig selects a built-in hook by Type; the other fields are the options of that type
+type HookConfig struct {
+	Type string `mapstructure:"type" yaml:"type"`
+	// Patterns are the regular expressions of word_filter, or extra ones for redact_secrets
+	Patterns []string `mapstructure:"patterns" yaml:"patterns,omitempty"`
+	// Action of word_filter: reject (the default) or mask
+	Action      string `mapstructure:"action" yaml:"action,omitempty"`
+	Replacement string `mapstructure:"replacement" yaml:"replacement,omitempty"`
+	MaxLinks    int    `mapstructure:"max_links" yaml:"max_links,omitempty"`
+	MinLength   int    `mapstructure:"min_length" yaml:"min_length,omitempty"`
+	MaxLength   int    `mapstructure:"max_length" yaml:"max_length,omitempty"`
+}
+
+const (
+	HookWordFilter    = "word_filter"
+	HookRedactSecrets = "redact_secrets"
+	HookLinkSpam      = "link_spam"
+	HookLength        = "length"
+
+	HookActionReject = "reject"
+	HookActionMask   = "mask"
+)
+
+// setting ties a config key to its flag and environment variable
+type setting struct {
+	key   string
+	flag  string
+	env   string
+	value any
+	usage string
+}
+
+// settings lists every option with its default. PORT is honoured for the default listen address
+// so that platforms such as Cloud Run keep working without a config file.
+func settings() []setting {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = defaultPort
+	}
+	return []setting{
+		{"storage", "storage", "CHATSH_STORAGE", StorageSQLite, "Storage backend: sqlite or memory (data is lost on exit)"},
+		{"database.dsn", "db", "CHATSH_DB_DSN", "./chatsh.db", "SQLite data source name"},
+		{"database.wal", "db-wal", "CHATSH_DB_WAL", true, "Use SQLite's write-ahead log so that reads do not wait for writes"},
+		{"database.busy_timeout", "db-busy-timeout", "CHATSH_DB_BUSY_TIMEOUT", 5 * time.Second, "How long SQLite waits for a locked database before failing"},
+		{"database.write_batch_size", "db-write-batch-size", "CHATSH_DB_WRITE_BATCH_SIZE", 256, "Most messages committed in one transaction (1 disables batching)"},
+		{"database.write_batch_delay", "db-write-batch-delay", "CHATSH_DB_WRITE_BATCH_DELAY", time.Millisecond, "How long a message may wait for others to share its commit"},
+		{"path_cache_size", "path-cache-size", "CHATSH_PATH_CACHE_SIZE", 10000, "Resolved paths kept in memory (0 disables the cache)"},
+		{"listen", "listen", "CHATSH_LISTEN", []string{":" + port}, "Addresses to listen on: host:port, tcp://host:port, tls://host:port or unix:///path"},
+		{"tls.cert_file", "tls-cert", "CHATSH_TLS_CERT", "", "TLS certificate file; plaintext when empty"},
+		{"tls.key_file", "tls-key", "CHATSH_TLS_KEY", "", "TLS private key file"},
+		{"tls.client_ca_file", "tls-client-ca", "CHATSH_TLS_CLIENT_CA", "", "CA file used to verify client certificates"},
+		{"tls.client_auth", "tls-client-auth", "CHATSH_TLS_CLIENT_AUTH", ClientAuthNone, "Client certificates: none, request or require"},
+		{"unix.socket_mode", "unix-socket-mode", "CHATSH_UNIX_SOCKET_MODE", "0660", "Octal permissions of Unix sockets"},
+		{"unix.peer_users", "unix-peer-users", "CHATSH_UNIX_PEER_USERS", false, "Identify Unix socket clients by their local user"},
+		{"web.enabled", "web", "CHATSH_WEB", true, "Serve gRPC-Web, Connect and the HTTP/JSON API on the TCP and TLS listeners"},
+		{"web.cors.allowed_origins", "cors-origins", "CHATSH_CORS_ORIGINS", []string{}, "Browser origins allowed to call the server (* for any)"},
+		{"web.cors.max_age", "cors-max-age", "CHATSH_CORS_MAX_AGE", 2 * time.Hour, "How long browsers may cache CORS preflight responses"},
+		{"web.metrics", "metrics", "CHATSH_METRICS", false, "Serve expvar metrics, such as the path cache hit rate, at /debug/vars"},
+		{"stream.max_concurrent_streams", "max-concurrent-streams", "CHATSH_MAX_CONCURRENT_STREAMS", uint32(1000), "Maximum concurrent streams per connection"},
+		{"stream.workers", "stream-workers", "CHATSH_STREAM_WORKERS", uint32(10), "Number of stream worker goroutines"},
+		{"stream.session_timeout", "session-timeout", "CHATSH_SESSION_TIMEOUT", time.Duration(0), "Idle time after which chat sessions end (0 disables)"},
+		{"message.max_length", "max-message-length", "CHATSH_MAX_MESSAGE_LENGTH", domain.DefaultMaxMessageLength, "Default maximum characters per message"},
+		{"message.max_lines", "max-message-lines", "CHATSH_MAX_MESSAGE_LINES", domain.DefaultMaxMessageLines, "Default maximum lines per message"},
+		{"rate_limit.write_per_second", "write-rate", "CHATSH_WRITE_RATE", 5.0, "Sustained writes per second per client certificate, Unix user, known owner token or IP address"},
+		{"rate_limit.write_burst", "write-burst", "CHATSH_WRITE_BURST", 20, "Write burst per client certificate, Unix user, known owner token or IP address"},
+		{"rate_limit.stream_per_second", "stream-rate", "CHATSH_STREAM_RATE", 5.0, "Sustained chat messages per second per stream; faster ones are dropped"},
+		{"rate_limit.stream_burst", "stream-burst", "CHATSH_STREAM_BURST", 20, "Chat message burst per stream"},
+		{"webhook.max_attempts", "webhook-max-attempts", "CHATSH_WEBHOOK_MAX_ATTEMPTS", 8, "Webhook delivery attempts before giving up"},
+		{"webhook.timeout", "webhook-timeout", "CHATSH_WEBHOOK_TIMEOUT", 10 * time.Second, "Timeout of each webhook delivery"},
+		{"webhook.workers", "webhook-workers", "CHATSH_WEBHOOK_WORKERS", 4, "Webhook deliveries sent concurrently"},
+		{"webhook.allowed_networks", "webhook-allowed-networks", "CHATSH_WEBHOOK_ALLOWED_NETWORKS", []string{}, "Private, loopback or link-local networks webhooks may reach, e.g. 127.0.0.1/32"},
+		{"retention.interval", "retention-interval", "CHATSH_RETENTION_INTERVAL", time.Minute, "Time between sweeps enforcing retention policies and purging the trash (0 disables both)"},
+		{"retention.batch_size", "retention-batch-size", "CHATSH_RETENTION_BATCH_SIZE", 500, "Messages expired per transaction"},
+		{"trash.purge_after", "trash-purge-after", "CHATSH_TRASH_PURGE_AFTER", 30 * 24 * time.Hour, "How long removed rooms and directories stay in the trash (0 deletes them right away)"},
+		{"admin_tokens", "admin-tokens", "CHATSH_ADMIN_TOKENS", []string{}, "Owner tokens allowed to call admin RPCs such as Backup"},
+		{"log_level", "log-level", "CHATSH_LOG_LEVEL", "info", "Log level: debug, info, warn or error"},
+	}
+}
+
+// RegisterFlags adds a flag for every option to flags
+func RegisterFlags(flags *pflag.FlagSet) {
+	for _, s := range settings() {
+		switch value := s.value.(type) {
+		case string:
+			flags.String(s.flag, value, s.usage)
+		case bool:
+			flags.Bool(s.flag, value, s.usage)
+		case []string:
+			flags.StringSlice(s.flag, value, s.usage)
+		case int:
+			flags.Int(s.flag, value, s.usage)
+		case uint32:
+			flags.Uint32(s.flag, value, s.usage)
+		case float64:
+			flags.Float64(s.flag, value, s.usage)
+		case time.Duration:
+			flags.Duration(s.flag, value, s.usage)
+		}
+	}
+}
+
+// Load resolves the configuration from flags, environment variables and the YAML file at path,
+// in that order of precedence, falling back to the defaults
+func Load(path string, flags *pflag.FlagSet) (Config, error) {
+	v := viper.New()
+	for _, s := range settings() {
+		v.SetDefault(s.key, s.value)
+		if err := v.BindEnv(s.key, s.env); err != nil {
+			return Config{}, fmt.Errorf("error binding %s: %w", s.env, err)
+		}
+		if flag := flags.Lookup(s.flag); flag != nil {
+			if err := v.BindPFlag(s.key, flag); err != nil {
+				return Config{}, fmt.Errorf("error binding --%s: %w", s.flag, err)
+			}
+		}
+	}
+	if path != "" {
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return Config{}, fmt.Errorf("error reading config file %s: %w", path, err)
+		}
+	}
+
+	var config Config
+	if err := v.Unmarshal(&config); err != nil {
+		return Config{}, fmt.Errorf("error decoding config: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+func (c Config) Validate() error {
+	switch c.Storage {
+	case StorageSQLite, StorageMemory:
+	default:
+		return fmt.Errorf("unknown storage '%s'", c.Storage)
+	}
+	if c.Storage == StorageSQLite && c.Database.DSN == "" {
+		return fmt.Errorf("database dsn is required")
+	}
+	if c.Database.BusyTimeout < 0 {
+		return fmt.Errorf("database busy_timeout must not be negative")
+	}
+	if c.Database.WriteBatchSize < 1 {
+		return fmt.Errorf("database write_batch_size must be at least 1")
+	}
+	if c.Database.WriteBatchDelay < 0 {
+		return fmt.Errorf("database write_batch_delay must not be negative")
+	}
+	if c.PathCacheSize < 0 {
+		return fmt.Errorf("path_cache_size must not be negative")
+	}
+	if len(c.Listen) == 0 {
+		return fmt.Errorf("at least one listen address is required")
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		return fmt.Errorf("tls cert_file and key_file must be set together")
+	}
+	switch c.TLS.ClientAuth {
+	case ClientAuthNone:
+	case ClientAuthRequest, ClientAuthRequire:
+		if !c.TLSEnabled() {
+			return fmt.Errorf("tls client_auth %s requires cert_file and key_file", c.TLS.ClientAuth)
+		}
+		if c.TLS.ClientCAFile == "" {
+			return fmt.Errorf("tls client_auth %s requires client_ca_file", c.TLS.ClientAuth)
+		}
+	default:
+		return fmt.Errorf("unknown tls client_auth '%s'", c.TLS.ClientAuth)
+	}
+	for _, identity := range c.TLS.ClientIdentities {
+		if identity.Subject == "" || identity.OwnerToken == "" {
+			return fmt.Errorf("tls client_identities need both subject and owner_token")
+		}
+	}
+	if _, err := c.Listeners(); err != nil {
+		return err
+	}
+	if _, err := c.SocketMode(); err != nil {
+		return err
+	}
+	for _, identity := range c.Unix.PeerIdentities {
+		if identity.User == "" || identity.OwnerToken == "" {
+			return fmt.Errorf("unix peer_identities need both user and owner_token")
+		}
+	}
+	if c.Stream.SessionTimeout < 0 {
+		return fmt.Errorf("stream session_timeout must not be negative")
+	}
+	if c.Message.MaxLength <= 0 || c.Message.MaxLength > domain.MaxMessageLengthLimit {
+		return fmt.Errorf("message max_length must be between 1 and %d", domain.MaxMessageLengthLimit)
+	}
+	if c.Message.MaxLines <= 0 || c.Message.MaxLines > domain.MaxMessageLinesLimit {
+		return fmt.Errorf("message max_lines must be between 1 and %d", domain.MaxMessageLinesLimit)
+	}
+	if c.Webhook.MaxAttempts < 1 {
+		return fmt.Errorf("webhook max_attempts must be at least 1")
+	}
+	if c.Webhook.Timeout <= 0 {
+		return fmt.Errorf("webhook timeout must be positive")
+	}
+	if c.Webhook.Workers < 1 {
+		return fmt.Errorf("webhook workers must be at least 1")
+	}
+	if _, err := c.WebhookOptions(); err != nil {
+		return err
+	}
+	if c.Retention.Interval < 0 {
+		return fmt.Errorf("retention interval must not be negative")
+	}
+	if c.Retention.BatchSize < 1 {
+		return fmt.Errorf("retention batch_size must be at least 1")
+	}
+	if c.Trash.PurgeAfter < 0 {
+		return fmt.Errorf("trash purge_after must not be negative")
+	}
+	for _, token := range c.AdminTokens {
+		if token == "" {
+			return fmt.Errorf("admin_tokens must not be empty")
+		}
+	}
+	if _, err := c.MessageHookPipeline(); err != nil {
+		return err
+	}
+	if _, err := c.SlogLevel(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// TLSEnabled reports whether the server should serve TLS instead of plaintext
+func (c Config) TLSEnabled() bool {
+	return c.TLS.CertFile != ""
+}
+
+// ClientIdentityTokens returns the owner token of every mapped client certificate subject
+func (c Config) ClientIdentityTokens() map[string]string {
+	tokens := make(map[string]string, len(c.TLS.ClientIdentities))
+	for _, identity := range c.TLS.ClientIdentities {
+		tokens[identity.Subject] = identity.OwnerToken
+	}
+	return tokens
+}
+
+func (c Config) MessageLimits() domain.MessageLimits {
+	return domain.NewMessageLimits(c.Message.MaxLength, c.Message.MaxLines)
+}
+
+func (c Config) WebhookOptions() (domain.WebhookOptions, error) {
+	networks := make([]netip.Prefix, len(c.Webhook.AllowedNetworks))
+	for i, network := range c.Webhook.AllowedNetworks {
+		var err error
+		if strings.Contains(network, "/") {
+			networks[i], err = netip.ParsePrefix(network)
+		} else {
+			var addr netip.Addr
+			addr, err = netip.ParseAddr(network)
+			networks[i] = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		if err != nil {
+			return domain.WebhookOptions{}, fmt.Errorf("webhook allowed_networks: %w", err)
+		}
+		networks[i] = networks[i].Masked()
+	}
+	return domain.NewWebhookOptions(c.Webhook.MaxAttempts, c.Webhook.Timeout, c.Webhook.Workers, networks), nil
+}
+
+func (c Config) RetentionOptions() domain.RetentionOptions {
+	return domain.NewRetentionOptions(c.Retention.Interval, c.Retention.BatchSize, c.Trash.PurgeAfter)
+}
+
+// MessageHookPipeline builds the hooks of the message_hooks section
+func (c Config) MessageHookPipeline() (domain.MessageHooks, error) {
+	rules := make([]domain.MessageHookRule, len(c.MessageHooks))
+	for i, rule := range c.MessageHooks {
+		if !strings.HasPrefix(rule.Path, "/") {
+			return domain.MessageHooks{}, fmt.Errorf("message_hooks path '%s' must be absolute", rule.Path)
+		}
+		rules[i].Path = domain.NewPath(rule.Path).String()
+		for _, hook := range rule.Hooks {
+			preWrite, err := hook.build()
+			if err != nil {
+				return domain.MessageHooks{}, fmt.Errorf("message_hooks %s: %s: %w", rule.Path, hook.Type, err)
+			}
+			rules[i].Pre = append(rules[i].Pre, preWrite)
+		}
+	}
+	return domain.NewMessageHooks(rules...), nil
+}
+
+func (h HookConfig) build() (domain.PreWriteHook, error) {
+	switch h.Type {
+	case HookWordFilter:
+		if h.Action != "" && h.Action != HookActionReject && h.Action != HookActionMask {
+			return nil, fmt.Errorf("unknown action '%s' (reject or mask)", h.Action)
+		}
+		return domain.NewWordFilter(h.Patterns, h.Action == HookActionMask, h.Replacement)
+	case HookRedactSecrets:
+		return domain.NewSecretRedactor(h.Patterns, h.Replacement)
+	case HookLinkSpam:
+		if h.MaxLinks < 0 {
+			return nil, fmt.Errorf("max_links must not be negative")
+		}
+		return domain.NewLinkSpamFilter(h.MaxLinks), nil
+	case HookLength:
+		return domain.NewLengthLimit(h.MinLength, h.MaxLength)
+	default:
+		return nil, fmt.Errorf("unknown hook type (%s, %s, %s or %s)", HookWordFilter, HookRedactSecrets, HookLinkSpam, HookLength)
+	}
+}
+
+func (c Config) SlogLevel() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.ToUpper(c.LogLevel))); err != nil {
+		return 0, fmt.Errorf("unknown log level '%s'", c.LogLevel)
+	}
+	return level, nil
+}
+
+// redacted stands in for secrets in the printed configuration
+const redacted = "***"
+
+// Redacted returns a copy of the configuration with its owner tokens, which grant whatever
+// their users and the admins may do, replaced by "***"
+func (c Config) Redacted() Config {
+	c.AdminTokens = slices.Clone(c.AdminTokens)
+	for i := range c.AdminTokens {
+		c.AdminTokens[i] = redacted
+	}
+	c.TLS.ClientIdentities = slices.Clone(c.TLS.ClientIdentities)
+	for i := range c.TLS.ClientIdentities {
+		c.TLS.ClientIdentities[i].OwnerToken = redacted
+	}
+	c.Unix.PeerIdentities = slices.Clone(c.Unix.PeerIdentities)
+	for i := range c.Unix.PeerIdentities {
+		c.Unix.PeerIdentities[i].OwnerToken = redacted
+	}
+	return c
+}
+
+// YAML renders the effective configuration in the format accepted by Load, with its secrets
+// redacted so that it can be pasted into logs and bug reports
+func (c Config) YAML() (string, error) {
+	out, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return "", fmt.Errorf("error encoding config: %w", err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,56 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ponyo877/chatsh/server/config"
+	"github.com/spf13/pflag"
+)
+
+// TestYAMLRedactsTokens checks that --print-config, whose output ends up in shell history and
+// bug reports, shows where owner tokens are set but never the tokens themselves
+func TestYAMLRedactsTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chatsh.yaml")
+	if err := os.WriteFile(path, []byte(`
+admin_tokens: [secret-root]
+tls:
+  client_identities:
+    - subject: CN=alice
+      owner_token: secret-alice
+unix:
+  peer_identities:
+    - user: bob
+      owner_token: secret-bob
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	flags := pflag.NewFlagSet("chatsh-server", pflag.ContinueOnError)
+	config.RegisterFlags(flags)
+	cfg, err := config.Load(path, flags)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	out, err := cfg.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-root", "secret-alice", "secret-bob"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("YAML shows the owner token %s:\n%s", secret, out)
+		}
+	}
+	for _, want := range []string{"- '***'", "subject: CN=alice", "user: bob", "owner_token: '***'"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("YAML lacks %q:\n%s", want, out)
+		}
+	}
+
+	// The server keeps using the real tokens
+	if cfg.AdminTokens[0] != "secret-root" || cfg.TLS.ClientIdentities[0].OwnerToken != "secret-alice" || cfg.Unix.PeerIdentities[0].OwnerToken != "secret-bob" {
+		t.Errorf("YAML changed the loaded config: %+v", cfg)
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// Transport is the security a listener serves
+type Transport string
+
+const (
+	TransportTCP  Transport = "tcp"
+	TransportTLS  Transport = "tls"
+	TransportUnix Transport = "unix"
+)
+
+// Listener is one parsed listen address
+type Listener struct {
+	Transport Transport
+	// Address is host:port for TCP and TLS, and the socket path for Unix
+	Address string
+}
+
+// Network returns the network name accepted by net.Listen
+func (l Listener) Network() string {
+	if l.Transport == TransportUnix {
+		return "unix"
+	}
+	return "tcp"
+}
+
+func (l Listener) String() string {
+	return string(l.Transport) + "://" + l.Address
+}
+
+// parseListener reads tcp://host:port, tls://host:port, unix:///path or unix:path.
+// A bare host:port serves TLS when a certificate is configured and plaintext otherwise.
+func (c Config) parseListener(addr string) (Listener, error) {
+	switch {
+	case strings.HasPrefix(addr, "unix://"):
+		return Listener{Transport: TransportUnix, Address: strings.TrimPrefix(addr, "unix://")}, nil
+	case strings.HasPrefix(addr, "unix:"):
+		return Listener{Transport: TransportUnix, Address: strings.TrimPrefix(addr, "unix:")}, nil
+	case strings.HasPrefix(addr, "tcp://"):
+		return Listener{Transport: TransportTCP, Address: strings.TrimPrefix(addr, "tcp://")}, nil
+	case strings.HasPrefix(addr, "tls://"):
+		return Listener{Transport: TransportTLS, Address: strings.TrimPrefix(addr, "tls://")}, nil
+	case strings.Contains(addr, "://"):
+		return Listener{}, fmt.Errorf("unknown scheme in listen address '%s'", addr)
+	}
+	if c.TLSEnabled() {
+		return Listener{Transport: TransportTLS, Address: addr}, nil
+	}
+	return Listener{Transport: TransportTCP, Address: addr}, nil
+}
+
+// Listeners parses every listen address
+func (c Config) Listeners() ([]Listener, error) {
+	listeners := make([]Listener, 0, len(c.Listen))
+	for _, addr := range c.Listen {
+		listener, err := c.parseListener(addr)
+		if err != nil {
+			return nil, err
+		}
+		switch listener.Transport {
+		case TransportUnix:
+			if listener.Address == "" {
+				return nil, fmt.Errorf("listen address '%s' has no socket path", addr)
+			}
+		case TransportTLS:
+			if !c.TLSEnabled() {
+				return nil, fmt.Errorf("listen address '%s' requires tls cert_file and key_file", addr)
+			}
+			fallthrough
+		default:
+			if _, _, err := net.SplitHostPort(listener.Address); err != nil {
+				return nil, fmt.Errorf("invalid listen address '%s': %w", addr, err)
+			}
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// SocketMode returns the permission bits applied to Unix sockets
+func (c Config) SocketMode() (uint32, error) {
+	mode, err := strconv.ParseUint(c.Unix.SocketMode, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid unix socket_mode '%s'", c.Unix.SocketMode)
+	}
+	return uint32(mode), nil
+}
+
+// PeerIdentityTokens returns the owner token of every mapped local user, keyed by uid
+func (c Config) PeerIdentityTokens() (map[uint32]string, error) {
+	tokens := make(map[uint32]string, len(c.Unix.PeerIdentities))
+	for _, identity := range c.Unix.PeerIdentities {
+		uid, err := lookupUID(identity.User)
+		if err != nil {
+			return nil, err
+		}
+		tokens[uid] = identity.OwnerToken
+	}
+	return tokens, nil
+}
+
+// lookupUID accepts a login name or a numeric uid
+func lookupUID(name string) (uint32, error) {
+	if uid, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(uid), nil
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, fmt.Errorf("unknown unix user '%s': %w", name, err)
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("unix user '%s' has no numeric uid", name)
+	}
+	return uint32(uid), nil
+}
@@ -0,0 +1,27 @@
+package domain
+
+// ConflictPolicy tells an import what to do with a room, or a node of the wrong type, whose path
+// is taken. Directories that exist as directories are always merged into.
+type ConflictPolicy int
+
+const (
+	// ConflictFail refuses the whole import before creating anything
+	ConflictFail ConflictPolicy = iota
+	// ConflictSkip leaves what exists alone and imports the rest
+	ConflictSkip
+	// ConflictRename imports the node as "name.1", "name.2" and so on
+	ConflictRename
+)
+
+// ImportResult sums up an import
+type ImportResult struct {
+	// Path is where the root of the archive went
+	Path        string
+	Directories int
+	Rooms       int
+	Messages    int
+	// Skipped lists the paths left alone, with everything the archive had below them
+	Skipped []string
+	// Renamed maps the paths that were taken to the paths imported instead
+	Renamed map[string]string
+}
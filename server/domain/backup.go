@@ -0,0 +1,17 @@
+package domain
+
+import "io"
+
+// Snapshot is a consistent copy of the whole database, to be read once and closed
+type Snapshot struct {
+	io.ReadCloser
+	// Size is the length of the copy in bytes
+	Size int64
+}
+
+func NewSnapshot(reader io.ReadCloser, size int64) Snapshot {
+	return Snapshot{
+		ReadCloser: reader,
+		Size:       size,
+	}
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Typed errors shared by every layer; the adaptor maps each of them to its own gRPC code
+var (
+	ErrNotFound         = errors.New("not found")
+	ErrAlreadyExists    = errors.New("already exists")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrNotARoom         = errors.New("not a room")
+	ErrNotADirectory    = errors.New("not a directory")
+	ErrNotEmpty         = errors.New("directory not empty")
+	ErrUnsupported      = errors.New("not supported by this server")
+)
+
+// PathError records the path an operation failed on along with the typed error
+type PathError struct {
+	Path string
+	Err  error
+}
+
+func NewPathError(path Path, err error) *PathError {
+	return &PathError{
+		Path: path.String(),
+		Err:  err,
+	}
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Err)
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
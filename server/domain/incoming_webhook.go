@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+	"unicode"
+)
+
+const (
+	DefaultBotName = "webhook"
+	maxBotNameLen  = 32
+)
+
+// IncomingWebhook lets anyone holding its secret URL post to a room as a bot
+type IncomingWebhook struct {
+	ID     int
+	RoomID int
+	// RoomPath is the current path of the room, empty once the room is deleted
+	RoomPath   string
+	OwnerToken string
+	BotName    string
+	// Secret is only known right after it is generated; the database keeps SecretHash
+	Secret     string
+	SecretHash string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+func NewIncomingWebhook(id, roomID int, roomPath, ownerToken, botName, secretHash string, createdAt, lastUsedAt time.Time) IncomingWebhook {
+	return IncomingWebhook{
+		ID:         id,
+		RoomID:     roomID,
+		RoomPath:   roomPath,
+		OwnerToken: ownerToken,
+		BotName:    botName,
+		SecretHash: secretHash,
+		CreatedAt:  createdAt,
+		LastUsedAt: lastUsedAt,
+	}
+}
+
+// ValidateBotName accepts short names of letters, digits, '-', '_' and '.' so that bots
+// read like users in the chat and cannot impersonate the system sender
+func ValidateBotName(name string) error {
+	if name == "" || len(name) > maxBotNameLen {
+		return &InvalidMessageError{Field: "bot_name", Reason: fmt.Sprintf("bot name must be 1 to %d characters", maxBotNameLen)}
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' && r != '.' {
+			return &InvalidMessageError{Field: "bot_name", Reason: fmt.Sprintf("bot name may not contain '%c'", r)}
+		}
+	}
+	if name == SystemSenderName {
+		return &InvalidMessageError{Field: "bot_name", Reason: fmt.Sprintf("'%s' is reserved", name)}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+type Mention struct {
+	ID         int
+	UserToken  string
+	RoomID     int
+	RoomPath   string
+	SenderName string
+	Content    string
+	IsRead     bool
+	CreatedAt  time.Time
+}
+
+func NewMention(id int, userToken string, roomID int, roomPath, senderName, content string, isRead bool, createdAt time.Time) Mention {
+	return Mention{
+		ID:         id,
+		UserToken:  userToken,
+		RoomID:     roomID,
+		RoomPath:   roomPath,
+		SenderName: senderName,
+		Content:    content,
+		IsRead:     isRead,
+		CreatedAt:  createdAt,
+	}
+}
+
+// ParseMentions returns the distinct display names referenced as "@name" in message.
+// Trailing punctuation is not part of the name, so "@alice," mentions "alice".
+func ParseMentions(message string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Fields(message) {
+		at := strings.IndexByte(field, '@')
+		if at < 0 {
+			continue
+		}
+		// Skip e-mail like words such as "bob@example.com"
+		if at > 0 && !strings.ContainsRune("([{<\"'", rune(field[at-1])) {
+			continue
+		}
+		name := strings.TrimRight(field[at+1:], ".,:;!?)]}>\"'")
+		if name == "" || strings.Contains(name, "@") || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
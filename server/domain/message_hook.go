@@ -0,0 +1,228 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const DefaultRedaction = "[REDACTED]"
+
+// HookMessage is what message hooks see of a message about to be, or just, posted
+type HookMessage struct {
+	RoomPath string
+	Sender   string
+	Text     string
+}
+
+func NewHookMessage(roomPath, sender, text string) HookMessage {
+	return HookMessage{
+		RoomPath: roomPath,
+		Sender:   sender,
+		Text:     text,
+	}
+}
+
+// PreWriteHook runs before a message is stored and broadcast. It returns the text to post,
+// unchanged or rewritten, or an *InvalidMessageError to reject the message.
+type PreWriteHook interface {
+	BeforeWrite(message HookMessage) (string, error)
+}
+
+// PostWriteHook observes a message once it is stored and broadcast. It runs on the
+// posting path, so slow work belongs in a goroutine of its own.
+type PostWriteHook interface {
+	AfterWrite(message HookMessage)
+}
+
+// MessageHookRule attaches hooks to the rooms at and below Path
+type MessageHookRule struct {
+	Path string
+	Pre  []PreWriteHook
+	Post []PostWriteHook
+}
+
+// MessageHooks runs the hooks of every rule covering a room, in the order the rules were given
+type MessageHooks struct {
+	rules []MessageHookRule
+}
+
+func NewMessageHooks(rules ...MessageHookRule) MessageHooks {
+	return MessageHooks{rules: rules}
+}
+
+func (h MessageHooks) covering(roomPath string) []MessageHookRule {
+	var rules []MessageHookRule
+	for _, rule := range h.rules {
+		if _, ok := relativePath(rule.Path, roomPath); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// BeforeWrite passes the text through the pre-write hooks, each seeing the rewrite of the one before.
+// An empty result means nothing is left to post.
+func (h MessageHooks) BeforeWrite(message HookMessage) (string, error) {
+	for _, rule := range h.covering(message.RoomPath) {
+		for _, hook := range rule.Pre {
+			text, err := hook.BeforeWrite(message)
+			if err != nil {
+				return "", err
+			}
+			message.Text = text
+		}
+	}
+	return message.Text, nil
+}
+
+func (h MessageHooks) AfterWrite(message HookMessage) {
+	for _, rule := range h.covering(message.RoomPath) {
+		for _, hook := range rule.Post {
+			hook.AfterWrite(message)
+		}
+	}
+}
+
+// WordFilter rejects messages matching any of its patterns, or masks the matches
+// with Replacement when Mask is set
+type WordFilter struct {
+	Patterns    []*regexp.Regexp
+	Mask        bool
+	Replacement string
+}
+
+func NewWordFilter(patterns []string, mask bool, replacement string) (*WordFilter, error) {
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("word filter needs at least one pattern")
+	}
+	compiled, err := compilePatterns(patterns)
+	if err != nil {
+		return nil, err
+	}
+	if replacement == "" {
+		replacement = "***"
+	}
+	return &WordFilter{Patterns: compiled, Mask: mask, Replacement: replacement}, nil
+}
+
+func (f *WordFilter) BeforeWrite(message HookMessage) (string, error) {
+	text := message.Text
+	for _, pattern := range f.Patterns {
+		if !pattern.MatchString(text) {
+			continue
+		}
+		if !f.Mask {
+			return "", &InvalidMessageError{Reason: "message contains a blocked word"}
+		}
+		text = pattern.ReplaceAllLiteralString(text, f.Replacement)
+	}
+	return text, nil
+}
+
+// secretPatterns find credentials in messages. Submatch 1, when present, is the part kept
+// in front of the secret, such as the "aws_secret_access_key = " of a pasted config file.
+var secretPatterns = []*regexp.Regexp{
+	// AWS access key ids
+	regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA|AGPA|AIDA|AIPA|ANPA|ANVA|AROA|APKA)[0-9A-Z]{16}\b`),
+	// AWS secret access keys, recognised by the name in front of them
+	regexp.MustCompile(`(?i)(aws.{0,20}?(?:secret|key).{0,20}?[\s:="']+)[A-Za-z0-9/+]{40}\b`),
+	// ULIDs, the format of chatsh owner tokens
+	regexp.MustCompile(`\b[0-7][0-9A-HJKMNP-TV-Z]{25}\b`),
+}
+
+// SecretRedactor replaces credentials, AWS keys and chatsh owner tokens by default, with Replacement
+type SecretRedactor struct {
+	Patterns    []*regexp.Regexp
+	Replacement string
+}
+
+// NewSecretRedactor redacts the built-in secret formats and any of extraPatterns
+func NewSecretRedactor(extraPatterns []string, replacement string) (*SecretRedactor, error) {
+	extra, err := compilePatterns(extraPatterns)
+	if err != nil {
+		return nil, err
+	}
+	if replacement == "" {
+		replacement = DefaultRedaction
+	}
+	return &SecretRedactor{Patterns: append(append([]*regexp.Regexp{}, secretPatterns...), extra...), Replacement: replacement}, nil
+}
+
+func (r *SecretRedactor) BeforeWrite(message HookMessage) (string, error) {
+	text := message.Text
+	for _, pattern := range r.Patterns {
+		text = pattern.ReplaceAllStringFunc(text, func(match string) string {
+			// Keep the prefix captured by the pattern, if any
+			if groups := pattern.FindStringSubmatch(match); len(groups) > 1 {
+				return groups[1] + r.Replacement
+			}
+			return r.Replacement
+		})
+	}
+	return text, nil
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// LinkSpamFilter rejects messages made of nothing but links, and messages with more than
+// MaxLinks links when MaxLinks is positive
+type LinkSpamFilter struct {
+	MaxLinks int
+}
+
+func NewLinkSpamFilter(maxLinks int) *LinkSpamFilter {
+	return &LinkSpamFilter{MaxLinks: maxLinks}
+}
+
+func (f *LinkSpamFilter) BeforeWrite(message HookMessage) (string, error) {
+	links := linkPattern.FindAllStringIndex(message.Text, -1)
+	if len(links) == 0 {
+		return message.Text, nil
+	}
+	if f.MaxLinks > 0 && len(links) > f.MaxLinks {
+		return "", &InvalidMessageError{Reason: fmt.Sprintf("message has %d links, the limit is %d", len(links), f.MaxLinks)}
+	}
+	if strings.TrimSpace(linkPattern.ReplaceAllString(message.Text, "")) == "" {
+		return "", &InvalidMessageError{Reason: "message only contains links"}
+	}
+	return message.Text, nil
+}
+
+// LengthLimit rejects messages shorter than MinLength or longer than MaxLength characters;
+// a zero bound is not checked
+type LengthLimit struct {
+	MinLength int
+	MaxLength int
+}
+
+func NewLengthLimit(minLength, maxLength int) (*LengthLimit, error) {
+	if minLength < 0 || maxLength < 0 || (maxLength > 0 && minLength > maxLength) {
+		return nil, fmt.Errorf("length limit needs 0 <= min_length <= max_length")
+	}
+	return &LengthLimit{MinLength: minLength, MaxLength: maxLength}, nil
+}
+
+func (l *LengthLimit) BeforeWrite(message HookMessage) (string, error) {
+	length := utf8.RuneCountInString(message.Text)
+	if l.MinLength > 0 && length < l.MinLength {
+		return "", &InvalidMessageError{Reason: fmt.Sprintf("message is %d characters long, the minimum here is %d", length, l.MinLength)}
+	}
+	if l.MaxLength > 0 && length > l.MaxLength {
+		return "", &InvalidMessageError{Reason: fmt.Sprintf("message is %d characters long, the limit here is %d", length, l.MaxLength)}
+	}
+	return message.Text, nil
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		compiled[i] = re
+	}
+	return compiled, nil
+}
@@ -0,0 +1,60 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxImportKeyLength bounds the keys of imported messages
+const MaxImportKeyLength = 512
+
+// ImportedMessage is a message brought in from another chat system. Key identifies it there: a
+// room takes a key once, so that importing the same history again adds nothing.
+type ImportedMessage struct {
+	Key         string
+	DisplayName string
+	Content     string
+	CreatedAt   time.Time
+}
+
+func NewImportedMessage(key, displayName, content string, createdAt time.Time) ImportedMessage {
+	return ImportedMessage{
+		Key:         key,
+		DisplayName: displayName,
+		Content:     content,
+		CreatedAt:   createdAt,
+	}
+}
+
+// ValidateImportedMessage accepts messages with a key, an author, a time and text that a room
+// could hold, whatever limits the room sets for the messages written in it
+func ValidateImportedMessage(message ImportedMessage) error {
+	switch {
+	case message.Key == "" || len(message.Key) > MaxImportKeyLength:
+		return &InvalidMessageError{Field: "key", Reason: fmt.Sprintf("key must be 1 to %d bytes", MaxImportKeyLength)}
+	case message.DisplayName == "":
+		return &InvalidMessageError{Field: "owner_name", Reason: fmt.Sprintf("message %s has no author", message.Key)}
+	case message.Content == "":
+		return &InvalidMessageError{Field: "text_content", Reason: fmt.Sprintf("message %s is empty", message.Key)}
+	case !utf8.ValidString(message.Content) || !utf8.ValidString(message.DisplayName):
+		return &InvalidMessageError{Field: "text_content", Reason: fmt.Sprintf("message %s is not valid UTF-8", message.Key)}
+	case utf8.RuneCountInString(message.Content) > MaxMessageLengthLimit:
+		return &InvalidMessageError{Field: "text_content", Reason: fmt.Sprintf("message %s is longer than %d characters", message.Key, MaxMessageLengthLimit)}
+	case message.CreatedAt.IsZero():
+		return &InvalidMessageError{Field: "created", Reason: fmt.Sprintf("message %s has no time", message.Key)}
+	}
+	return nil
+}
+
+// MessageImport sums up the messages imported into a room
+type MessageImport struct {
+	Path string
+	// Created tells whether the import created the room
+	Created  bool
+	Messages int
+	// Duplicates counts the messages skipped because the room had their keys
+	Duplicates int
+	// Dropped counts the messages left empty once their control sequences were removed
+	Dropped int
+}
@@ -0,0 +1,201 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DefaultMaxMessageLength = 2000
+	DefaultMaxMessageLines  = 20
+	// MaxMessageLengthLimit caps the limit a room owner can configure
+	MaxMessageLengthLimit = 65536
+	MaxMessageLinesLimit  = 1000
+
+	// MaxDisplayNameLength bounds the names users and chat sessions appear under, in characters
+	MaxDisplayNameLength = 80
+	// MaxNodeNameLength bounds the name of a room or directory, in bytes
+	MaxNodeNameLength = 255
+)
+
+// MessageLimits bounds the size of a message. Length is counted in characters (runes).
+type MessageLimits struct {
+	MaxLength int
+	MaxLines  int
+}
+
+func NewMessageLimits(maxLength, maxLines int) MessageLimits {
+	return MessageLimits{
+		MaxLength: maxLength,
+		MaxLines:  maxLines,
+	}
+}
+
+// Override returns the limits with the non-zero values of other applied
+func (l MessageLimits) Override(other MessageLimits) MessageLimits {
+	if other.MaxLength > 0 {
+		l.MaxLength = other.MaxLength
+	}
+	if other.MaxLines > 0 {
+		l.MaxLines = other.MaxLines
+	}
+	return l
+}
+
+// InvalidMessageError reports a message, limit or other request field rejected by validation
+type InvalidMessageError struct {
+	// Field names the offending request field when it is not the message itself
+	Field  string
+	Reason string
+}
+
+func (e *InvalidMessageError) Error() string {
+	if e.Field != "" {
+		return "invalid " + e.Field + ": " + e.Reason
+	}
+	return "invalid message: " + e.Reason
+}
+
+// NormalizeMessage validates message against limits and returns it in canonical form:
+// line breaks become "\n", terminal escape sequences and other control characters are removed,
+// trailing spaces of each line and surrounding blank lines are trimmed.
+// An empty result means there is nothing to post.
+func NormalizeMessage(message string, limits MessageLimits) (string, error) {
+	if !utf8.ValidString(message) {
+		return "", &InvalidMessageError{Reason: "message is not valid UTF-8"}
+	}
+
+	message = strings.ReplaceAll(message, "\r\n", "\n")
+	message = strings.ReplaceAll(message, "\r", "\n")
+	message = StripControlSequences(message)
+
+	lines := strings.Split(message, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	message = strings.Trim(strings.Join(lines, "\n"), "\n")
+	if strings.TrimSpace(message) == "" {
+		return "", nil
+	}
+
+	if length := utf8.RuneCountInString(message); limits.MaxLength > 0 && length > limits.MaxLength {
+		return "", &InvalidMessageError{Reason: fmt.Sprintf("message is %d characters long, the limit is %d", length, limits.MaxLength)}
+	}
+	if lineCount := strings.Count(message, "\n") + 1; limits.MaxLines > 0 && lineCount > limits.MaxLines {
+		return "", &InvalidMessageError{Reason: fmt.Sprintf("message has %d lines, the limit is %d", lineCount, limits.MaxLines)}
+	}
+	return message, nil
+}
+
+// NormalizeDisplayName returns name as chat lines show it: escape sequences and other control
+// characters are removed and runs of whitespace, line breaks included, become one space.
+// field names the request field the name came from.
+func NormalizeDisplayName(field, name string) (string, error) {
+	if !utf8.ValidString(name) {
+		return "", &InvalidMessageError{Field: field, Reason: "name is not valid UTF-8"}
+	}
+	name = strings.Join(strings.Fields(StripControlSequences(name)), " ")
+	if name == "" {
+		return "", &InvalidMessageError{Field: field, Reason: "name is empty"}
+	}
+	if length := utf8.RuneCountInString(name); length > MaxDisplayNameLength {
+		return "", &InvalidMessageError{Field: field, Reason: fmt.Sprintf("name is %d characters long, the limit is %d", length, MaxDisplayNameLength)}
+	}
+	return name, nil
+}
+
+// ValidateNodeName checks the name a room or directory is created under. Unlike display names,
+// node names are refused rather than rewritten, as the client could not name the node again.
+func ValidateNodeName(field, name string) error {
+	reason := ""
+	switch {
+	case !utf8.ValidString(name):
+		reason = "name is not valid UTF-8"
+	case name == "" || name == "/":
+		reason = "name is empty"
+	case len(name) > MaxNodeNameLength:
+		reason = fmt.Sprintf("name is %d bytes long, the limit is %d", len(name), MaxNodeNameLength)
+	case strings.ContainsAny(name, "\n\t") || StripControlSequences(name) != name:
+		reason = "name may not contain line breaks, tabs, control characters or escape sequences"
+	case strings.TrimSpace(name) != name:
+		reason = "name may not start or end with spaces"
+	default:
+		return nil
+	}
+	return &InvalidMessageError{Field: field, Reason: reason}
+}
+
+// StripControlSequences removes ANSI/VT escape sequences and control characters other than
+// newline and tab, so that messages cannot move the cursor, recolor or retitle a terminal
+func StripControlSequences(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\x1b':
+			i = skipEscapeSequence(runes, i)
+		case r == '\u009b': // 8-bit CSI
+			i = skipUntilFinalByte(runes, i+1)
+		case r == '\u009d' || r == '\u0090' || r == '\u0098' || r == '\u009e' || r == '\u009f': // 8-bit OSC, DCS, SOS, PM, APC
+			i = skipUntilStringTerminator(runes, i+1)
+		case r == '\n' || r == '\t':
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f || (r >= 0x80 && r <= 0x9f):
+			// Drop remaining C0/C1 control characters and DEL
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// skipEscapeSequence returns the index of the last rune of the sequence introduced by ESC at i
+func skipEscapeSequence(runes []rune, i int) int {
+	if i+1 >= len(runes) {
+		return i
+	}
+	switch runes[i+1] {
+	case '[':
+		return skipUntilFinalByte(runes, i+2)
+	case ']', 'P', 'X', '^', '_':
+		return skipUntilStringTerminator(runes, i+2)
+	default:
+		// Two-character sequences such as ESC c, possibly with intermediate bytes
+		j := i + 1
+		for j < len(runes) && runes[j] >= 0x20 && runes[j] <= 0x2f {
+			j++
+		}
+		if j >= len(runes) {
+			return len(runes) - 1
+		}
+		return j
+	}
+}
+
+// skipUntilFinalByte skips the parameters of a CSI sequence up to its final byte
+func skipUntilFinalByte(runes []rune, i int) int {
+	for ; i < len(runes); i++ {
+		if runes[i] >= 0x40 && runes[i] <= 0x7e {
+			return i
+		}
+	}
+	return len(runes) - 1
+}
+
+// skipUntilStringTerminator skips a control string ended by BEL, ST or ESC \
+func skipUntilStringTerminator(runes []rune, i int) int {
+	for ; i < len(runes); i++ {
+		switch runes[i] {
+		case '\a', '\u009c':
+			return i
+		case '\x1b':
+			if i+1 < len(runes) && runes[i+1] == '\\' {
+				return i + 1
+			}
+		}
+	}
+	return len(runes) - 1
+}
@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeDisplayName(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "alice", "alice"},
+		{"spaces kept between words", "Alice Liddell", "Alice Liddell"},
+		{"surrounding spaces", "  alice\t", "alice"},
+		{"line breaks", "alice\nbob\r\ncarol", "alice bob carol"},
+		{"color", "\x1b[31malice\x1b[0m", "alice"},
+		{"window title", "\x1b]0;pwned\aalice", "alice"},
+		{"8-bit csi", "\u009b2Jalice", "alice"},
+		{"backspaces", "root\b\b\b\balice", "rootalice"},
+		{"non-ascii", "アリス", "アリス"},
+		{"longest", strings.Repeat("あ", MaxDisplayNameLength), strings.Repeat("あ", MaxDisplayNameLength)},
+		{"empty", "", ""},
+		{"only spaces", " \t\n", ""},
+		{"only escapes", "\x1b[2J\x1b[H", ""},
+		{"too long", strings.Repeat("a", MaxDisplayNameLength+1), ""},
+		{"invalid utf-8", "alice\xff", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeDisplayName("display_name", tt.in)
+			if tt.want == "" {
+				var invalid *InvalidMessageError
+				if !errors.As(err, &invalid) || invalid.Field != "display_name" {
+					t.Errorf("NormalizeDisplayName(%q) = %q, %v, want an InvalidMessageError for display_name", tt.in, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("NormalizeDisplayName(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateNodeName(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		in   string
+		ok   bool
+	}{
+		{"plain", "general", true},
+		{"inner spaces", "release notes", true},
+		{"dots", "v1.2", true},
+		{"non-ascii", "雑談", true},
+		{"longest", strings.Repeat("a", MaxNodeNameLength), true},
+		{"empty", "", false},
+		{"root", "/", false},
+		{"too long", strings.Repeat("a", MaxNodeNameLength+1), false},
+		{"escape sequence", "room\x1b[2J", false},
+		{"bare escape", "room\x1b", false},
+		{"8-bit csi", "room\u009b2J", false},
+		{"bell", "room\a", false},
+		{"line break", "room\nother", false},
+		{"tab", "room\tother", false},
+		{"delete", "room\x7f", false},
+		{"leading space", " room", false},
+		{"trailing space", "room ", false},
+		{"invalid utf-8", "room\xff", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateNodeName("path", tt.in)
+			if tt.ok {
+				if err != nil {
+					t.Errorf("ValidateNodeName(%q) = %v", tt.in, err)
+				}
+				return
+			}
+			var invalid *InvalidMessageError
+			if !errors.As(err, &invalid) || invalid.Field != "path" {
+				t.Errorf("ValidateNodeName(%q) = %v, want an InvalidMessageError for path", tt.in, err)
+			}
+		})
+	}
+}
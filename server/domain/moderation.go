@@ -0,0 +1,113 @@
+package domain
+
+import "time"
+
+type ModerationAction int
+
+const (
+	ModerationUnknown ModerationAction = iota
+	ModerationKick
+	ModerationBan
+	ModerationUnban
+	ModerationMute
+	ModerationUnmute
+	ModerationSlowMode
+	ModerationLimits
+)
+
+func (a ModerationAction) String() string {
+	switch a {
+	case ModerationKick:
+		return "kick"
+	case ModerationBan:
+		return "ban"
+	case ModerationUnban:
+		return "unban"
+	case ModerationMute:
+		return "mute"
+	case ModerationUnmute:
+		return "unmute"
+	case ModerationSlowMode:
+		return "slowmode"
+	case ModerationLimits:
+		return "limits"
+	default:
+		return "unknown"
+	}
+}
+
+type RestrictionType int
+
+const (
+	RestrictionBan RestrictionType = iota + 1
+	RestrictionMute
+)
+
+type RoomRestriction struct {
+	RoomID      int
+	UserToken   string
+	DisplayName string
+	Type        RestrictionType
+	CreatedAt   time.Time
+}
+
+func NewRoomRestriction(roomID int, userToken, displayName string, restrictionType RestrictionType, createdAt time.Time) RoomRestriction {
+	return RoomRestriction{
+		RoomID:      roomID,
+		UserToken:   userToken,
+		DisplayName: displayName,
+		Type:        restrictionType,
+		CreatedAt:   createdAt,
+	}
+}
+
+type RoomSettings struct {
+	RoomID          int
+	SlowModeSeconds int
+	// MaxMessageLength and MaxMessageLines override the server defaults when non-zero
+	MaxMessageLength int
+	MaxMessageLines  int
+}
+
+func NewRoomSettings(roomID, slowModeSeconds, maxMessageLength, maxMessageLines int) RoomSettings {
+	return RoomSettings{
+		RoomID:           roomID,
+		SlowModeSeconds:  slowModeSeconds,
+		MaxMessageLength: maxMessageLength,
+		MaxMessageLines:  maxMessageLines,
+	}
+}
+
+func (s RoomSettings) MessageLimits() MessageLimits {
+	return NewMessageLimits(s.MaxMessageLength, s.MaxMessageLines)
+}
+
+type ModerationLog struct {
+	ID         int
+	RoomID     int
+	ActorName  string
+	Action     ModerationAction
+	TargetName string
+	Detail     string
+	CreatedAt  time.Time
+}
+
+func NewModerationLog(id, roomID int, actorName string, action ModerationAction, targetName, detail string, createdAt time.Time) ModerationLog {
+	return ModerationLog{
+		ID:         id,
+		RoomID:     roomID,
+		ActorName:  actorName,
+		Action:     action,
+		TargetName: targetName,
+		Detail:     detail,
+		CreatedAt:  createdAt,
+	}
+}
+
+// RoomModeration is the current moderation state of a room
+type RoomModeration struct {
+	Restrictions []RoomRestriction
+	Settings     RoomSettings
+	Limits       MessageLimits
+	Logs         []ModerationLog
+}
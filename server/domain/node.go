@@ -9,6 +9,10 @@ type Node struct {
 	OwnerToken string
 	OwnerName  string
 	CreatedAt  time.Time
+	// UnreadCount is only filled in when listing with read markers
+	UnreadCount int
+	// Retention is the policy in effect, nil when none is; only filled in on request
+	Retention *RetentionPolicy
 }
 
 func NewNode(id int, name string, nodeType NodeType, ownerToken, ownerName string, createdAt time.Time) Node {
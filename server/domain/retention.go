@@ -0,0 +1,86 @@
+package domain
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxRetentionMessages caps the message count a retention policy can keep
+const MaxRetentionMessages = 1000000
+
+// RetentionPolicy bounds how long the rooms it applies to keep their messages, and
+// themselves. A policy set on a directory applies to every room below it that has no
+// policy of its own or on a closer directory. Zero fields are unbounded.
+type RetentionPolicy struct {
+	NodeType NodeType
+	NodeID   int
+	// Path is where the policy is set, which may be an ancestor of the room it applies to
+	Path string
+	// MaxAge expires messages older than this
+	MaxAge time.Duration
+	// MaxMessages expires the oldest messages beyond this many
+	MaxMessages int
+	// IdleTTL removes a room nothing has been written to for this long, into the trash of its
+	// owner when the trash is on
+	IdleTTL   time.Duration
+	UpdatedAt time.Time
+}
+
+func NewRetentionPolicy(nodeType NodeType, nodeID int, path string, maxAge time.Duration, maxMessages int, idleTTL time.Duration, updatedAt time.Time) RetentionPolicy {
+	return RetentionPolicy{
+		NodeType:    nodeType,
+		NodeID:      nodeID,
+		Path:        path,
+		MaxAge:      maxAge,
+		MaxMessages: maxMessages,
+		IdleTTL:     idleTTL,
+		UpdatedAt:   updatedAt,
+	}
+}
+
+// IsUnbounded reports whether the policy keeps everything, as rooms without a policy do
+func (p RetentionPolicy) IsUnbounded() bool {
+	return p.MaxAge <= 0 && p.MaxMessages <= 0 && p.IdleTTL <= 0
+}
+
+// IsIdle reports whether a room last active at lastActive has outlived IdleTTL by now
+func (p RetentionPolicy) IsIdle(lastActive, now time.Time) bool {
+	return p.IdleTTL > 0 && lastActive.Before(now.Add(-p.IdleTTL))
+}
+
+// String lists the bounds of the policy, like "max-age=24h0m0s idle=168h0m0s", or "keep"
+func (p RetentionPolicy) String() string {
+	var bounds []string
+	if p.MaxAge > 0 {
+		bounds = append(bounds, "max-age="+p.MaxAge.String())
+	}
+	if p.MaxMessages > 0 {
+		bounds = append(bounds, "max-messages="+strconv.Itoa(p.MaxMessages))
+	}
+	if p.IdleTTL > 0 {
+		bounds = append(bounds, "idle="+p.IdleTTL.String())
+	}
+	if len(bounds) == 0 {
+		return "keep"
+	}
+	return strings.Join(bounds, " ")
+}
+
+// RetentionOptions tunes the janitor enforcing retention policies and purging the trash
+type RetentionOptions struct {
+	// Interval is the time between two sweeps; zero turns the janitor off
+	Interval time.Duration
+	// BatchSize bounds the messages deleted in one transaction
+	BatchSize int
+	// TrashPurgeAfter is how long removed nodes stay in the trash; zero deletes them right away
+	TrashPurgeAfter time.Duration
+}
+
+func NewRetentionOptions(interval time.Duration, batchSize int, trashPurgeAfter time.Duration) RetentionOptions {
+	return RetentionOptions{
+		Interval:        interval,
+		BatchSize:       batchSize,
+		TrashPurgeAfter: trashPurgeAfter,
+	}
+}
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionPolicyIsIdle(t *testing.T) {
+	now := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name       string
+		idleTTL    time.Duration
+		lastActive time.Time
+		want       bool
+	}{
+		{"no idle bound", 0, now.AddDate(-1, 0, 0), false},
+		{"negative idle bound", -time.Hour, now.AddDate(-1, 0, 0), false},
+		{"recently active", 24 * time.Hour, now.Add(-time.Hour), false},
+		{"exactly at the bound", 24 * time.Hour, now.Add(-24 * time.Hour), false},
+		{"past the bound", 24 * time.Hour, now.Add(-24*time.Hour - time.Second), true},
+		{"long idle", 24 * time.Hour, now.AddDate(0, -1, 0), true},
+		{"active in the future", 24 * time.Hour, now.Add(time.Hour), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := RetentionPolicy{IdleTTL: tt.idleTTL}
+			if got := policy.IsIdle(tt.lastActive, now); got != tt.want {
+				t.Errorf("IsIdle(%s) with idle=%s = %t, want %t", now.Sub(tt.lastActive), tt.idleTTL, got, tt.want)
+			}
+		})
+	}
+}
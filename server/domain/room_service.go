@@ -8,6 +8,8 @@ type RoomService interface {
 
 	GetActiveClients(roomPath string) []StreamSession
 	GetSession(sessionID string) (StreamSession, bool)
+	// TouchSession records activity so that the session does not time out
+	TouchSession(sessionID string)
 
 	IsRoomActive(roomPath string) bool
 	GetActiveRooms() []string
@@ -39,6 +41,10 @@ type StreamManager interface {
 	HandleLeaveRequest(sessionID string) error
 	HandleChatRequest(sessionID string, message string) error
 
+	// KickSession signals the session to end; Kicked is closed once it has been kicked
+	KickSession(sessionID string) error
+	Kicked(sessionID string) <-chan struct{}
+
 	Cleanup() error
 	GetStats() StreamStats
 }
@@ -1,6 +1,16 @@
 package domain
 
-import "time"
+import (
+	"strconv"
+	"time"
+)
+
+// SystemSenderName is the sender of notices generated by the server itself
+const SystemSenderName = "chatsh"
+
+// UnknownSenderName stands in for the display name of an owner token without a config, as the
+// token itself is a secret
+const UnknownSenderName = "anonymous"
 
 type StreamEventType int
 
@@ -9,6 +19,8 @@ const (
 	EventLeave
 	EventMessage
 	EventError
+	// EventExpire tells the sessions in a room which messages the retention policy removed
+	EventExpire
 )
 
 func (t StreamEventType) String() string {
@@ -21,6 +33,8 @@ func (t StreamEventType) String() string {
 		return "message"
 	case EventError:
 		return "error"
+	case EventExpire:
+		return "expire"
 	default:
 		return "unknown"
 	}
@@ -34,6 +48,8 @@ type StreamEvent struct {
 	Message   string
 	Timestamp time.Time
 	Error     error
+	// ExpiredIDs are the ids of the messages an expire event removed
+	ExpiredIDs []int
 }
 
 func NewJoinEvent(sessionID, roomPath, sender string) StreamEvent {
@@ -69,6 +85,18 @@ func NewMessageEvent(sessionID, roomPath, sender, message string) StreamEvent {
 	}
 }
 
+// NewExpireEvent is sent by the server, with a notice that clients unaware of expiry show as a message
+func NewExpireEvent(roomPath string, messageIDs []int) StreamEvent {
+	return StreamEvent{
+		Type:       EventExpire,
+		RoomPath:   roomPath,
+		Sender:     SystemSenderName,
+		Message:    strconv.Itoa(len(messageIDs)) + " messages expired",
+		ExpiredIDs: messageIDs,
+		Timestamp:  time.Now(),
+	}
+}
+
 func NewErrorEvent(sessionID, roomPath string, err error) StreamEvent {
 	return StreamEvent{
 		Type:      EventError,
@@ -85,6 +113,8 @@ func (e StreamEvent) IsValid() bool {
 		return e.SessionID != "" && e.RoomPath != "" && e.Sender != ""
 	case EventError:
 		return e.Error != nil
+	case EventExpire:
+		return e.RoomPath != "" && len(e.ExpiredIDs) > 0
 	default:
 		return false
 	}
@@ -7,8 +7,7 @@ import (
 )
 
 const (
-	ringSize       = 256
-	sessionTimeout = 30 * time.Minute
+	ringSize = 256
 )
 
 type streamManagerImpl struct {
@@ -16,8 +15,11 @@ type streamManagerImpl struct {
 	rooms         map[string]*roomImpl
 	sessions      map[string]StreamSession
 	responseChans map[string]chan<- StreamResponse
+	kickChans     map[string]chan struct{}
 	stats         StreamStats
 	startTime     time.Time
+	// sessionTimeout is how long a chat session may stay silent; zero disables the timeout
+	sessionTimeout time.Duration
 }
 
 type roomImpl struct {
@@ -28,12 +30,14 @@ type roomImpl struct {
 	manager   *streamManagerImpl
 }
 
-func NewStreamManager() StreamManager {
+func NewStreamManager(sessionTimeout time.Duration) StreamManager {
 	sm := &streamManagerImpl{
-		rooms:         make(map[string]*roomImpl),
-		sessions:      make(map[string]StreamSession),
-		responseChans: make(map[string]chan<- StreamResponse),
-		startTime:     time.Now(),
+		rooms:          make(map[string]*roomImpl),
+		sessions:       make(map[string]StreamSession),
+		responseChans:  make(map[string]chan<- StreamResponse),
+		kickChans:      make(map[string]chan struct{}),
+		startTime:      time.Now(),
+		sessionTimeout: sessionTimeout,
 	}
 	return sm
 }
@@ -55,8 +59,9 @@ func (r *roomImpl) fanout() {
 		for sessionID := range r.clients {
 			if responseChan, exists := r.manager.responseChans[sessionID]; exists {
 				response := StreamResponse{
-					Name:    event.Sender,
-					Message: event.Message,
+					Name:       event.Sender,
+					Message:    event.Message,
+					ExpiredIDs: event.ExpiredIDs,
 				}
 				select {
 				case responseChan <- response:
@@ -167,6 +172,16 @@ func (sm *streamManagerImpl) GetSession(sessionID string) (StreamSession, bool)
 	return session, exists
 }
 
+func (sm *streamManagerImpl) TouchSession(sessionID string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if session, exists := sm.sessions[sessionID]; exists {
+		session.LastActiveAt = time.Now()
+		sm.sessions[sessionID] = session
+	}
+}
+
 func (sm *streamManagerImpl) IsRoomActive(roomPath string) bool {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
@@ -209,7 +224,7 @@ func (sm *streamManagerImpl) ValidateSession(sessionID string) bool {
 		return false
 	}
 
-	return session.IsActive(sessionTimeout)
+	return session.IsTail || session.IsActive(sm.sessionTimeout)
 }
 
 func (sm *streamManagerImpl) Broadcast(event StreamEvent) error {
@@ -226,8 +241,9 @@ func (sm *streamManagerImpl) SendToSession(sessionID string, event StreamEvent)
 	}
 
 	response := StreamResponse{
-		Name:    event.Sender,
-		Message: event.Message,
+		Name:       event.Sender,
+		Message:    event.Message,
+		ExpiredIDs: event.ExpiredIDs,
 	}
 
 	select {
@@ -260,6 +276,7 @@ func (sm *streamManagerImpl) RegisterSession(sessionID string, responseChan chan
 	defer sm.mu.Unlock()
 
 	sm.responseChans[sessionID] = responseChan
+	sm.kickChans[sessionID] = make(chan struct{})
 	return nil
 }
 
@@ -268,6 +285,7 @@ func (sm *streamManagerImpl) UnregisterSession(sessionID string) error {
 	defer sm.mu.Unlock()
 
 	delete(sm.responseChans, sessionID)
+	delete(sm.kickChans, sessionID)
 	return nil
 }
 
@@ -291,7 +309,7 @@ func (sm *streamManagerImpl) HandleJoinRequest(request StreamRequest, sessionID,
 		return StreamSession{}, fmt.Errorf("invalid join request")
 	}
 
-	session := NewStreamSession(sessionID, request.Name, request.RoomPath, remote, false)
+	session := NewStreamSession(sessionID, request.Name, request.OwnerToken, request.RoomPath, remote, false)
 	if err := sm.JoinRoom(session); err != nil {
 		return StreamSession{}, fmt.Errorf("failed to join room: %w", err)
 	}
@@ -312,6 +330,29 @@ func (sm *streamManagerImpl) HandleChatRequest(sessionID string, message string)
 	return sm.BroadcastMessage(session.RoomPath, session.Name, message)
 }
 
+func (sm *streamManagerImpl) KickSession(sessionID string) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	kickChan, exists := sm.kickChans[sessionID]
+	if !exists {
+		return fmt.Errorf("session not registered: %s", sessionID)
+	}
+	select {
+	case <-kickChan:
+	default:
+		close(kickChan)
+	}
+	return nil
+}
+
+func (sm *streamManagerImpl) Kicked(sessionID string) <-chan struct{} {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	return sm.kickChans[sessionID]
+}
+
 func (sm *streamManagerImpl) Cleanup() error {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
@@ -323,6 +364,7 @@ func (sm *streamManagerImpl) Cleanup() error {
 	sm.rooms = make(map[string]*roomImpl)
 	sm.sessions = make(map[string]StreamSession)
 	sm.responseChans = make(map[string]chan<- StreamResponse)
+	sm.kickChans = make(map[string]chan struct{})
 	sm.stats = StreamStats{}
 
 	return nil
@@ -22,24 +22,27 @@ func (t StreamRequestType) String() string {
 }
 
 type StreamRequest struct {
-	Type     StreamRequestType
-	Name     string
-	RoomPath string
-	Message  string
+	Type       StreamRequestType
+	Name       string
+	OwnerToken string
+	RoomPath   string
+	Message    string
 }
 
-func NewJoinRequest(name, roomPath string) StreamRequest {
+func NewJoinRequest(name, ownerToken, roomPath string) StreamRequest {
 	return StreamRequest{
-		Type:     RequestJoin,
-		Name:     name,
-		RoomPath: roomPath,
+		Type:       RequestJoin,
+		Name:       name,
+		OwnerToken: ownerToken,
+		RoomPath:   roomPath,
 	}
 }
 
-func NewTailRequest(roomPath string) StreamRequest {
+func NewTailRequest(ownerToken, roomPath string) StreamRequest {
 	return StreamRequest{
-		Type:     RequestTail,
-		RoomPath: roomPath,
+		Type:       RequestTail,
+		OwnerToken: ownerToken,
+		RoomPath:   roomPath,
 	}
 }
 
@@ -4,6 +4,8 @@ type StreamResponse struct {
 	Name    string
 	Message string
 	Error   error
+	// ExpiredIDs are the messages removed by retention, which clients showing them should drop
+	ExpiredIDs []int
 }
 
 func NewStreamResponse(name, message string) StreamResponse {
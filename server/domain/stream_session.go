@@ -5,22 +5,27 @@ import (
 )
 
 type StreamSession struct {
-	ID       string
-	Name     string
-	RoomPath string
-	IsTail   bool
-	JoinedAt time.Time
-	Remote   string
+	ID         string
+	Name       string
+	OwnerToken string
+	RoomPath   string
+	IsTail     bool
+	JoinedAt   time.Time
+	// LastActiveAt is when the session last sent a chat message
+	LastActiveAt time.Time
+	Remote       string
 }
 
-func NewStreamSession(id, name, roomPath, remote string, isTail bool) StreamSession {
+func NewStreamSession(id, name, ownerToken, roomPath, remote string, isTail bool) StreamSession {
 	return StreamSession{
-		ID:       id,
-		Name:     name,
-		RoomPath: roomPath,
-		IsTail:   isTail,
-		JoinedAt: time.Now(),
-		Remote:   remote,
+		ID:           id,
+		Name:         name,
+		OwnerToken:   ownerToken,
+		RoomPath:     roomPath,
+		IsTail:       isTail,
+		JoinedAt:     time.Now(),
+		LastActiveAt: time.Now(),
+		Remote:       remote,
 	}
 }
 
@@ -28,8 +33,9 @@ func (s StreamSession) IsValid() bool {
 	return s.ID != "" && s.RoomPath != ""
 }
 
+// IsActive reports whether the session has been active within timeout; a zero timeout never expires
 func (s StreamSession) IsActive(timeout time.Duration) bool {
-	return time.Since(s.JoinedAt) < timeout
+	return timeout <= 0 || time.Since(s.LastActiveAt) < timeout
 }
 
 func (s StreamSession) String() string {
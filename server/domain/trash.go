@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+// LostAndFoundPath is where restored nodes go when the directory they were removed from is gone
+const LostAndFoundPath = "/lost+found"
+
+// TrashEntry is a room or directory removed with rm, which its owner can restore until the
+// trash is emptied or purges it
+type TrashEntry struct {
+	ID       int
+	NodeType NodeType
+	// NodeID is the id the node had, and gets back when it is restored
+	NodeID int
+	Name   string
+	// Path is where the node was removed from
+	Path       string
+	OwnerToken string
+	// MessageCount is the number of messages of a room, kept along with it
+	MessageCount int
+	CreatedAt    time.Time
+	DeletedAt    time.Time
+	// PurgeAt is when the entry goes for good, zero when nothing purges the trash
+	PurgeAt time.Time
+}
+
+func NewTrashEntry(id int, nodeType NodeType, nodeID int, name, path, ownerToken string, messageCount int, createdAt, deletedAt time.Time) TrashEntry {
+	return TrashEntry{
+		ID:           id,
+		NodeType:     nodeType,
+		NodeID:       nodeID,
+		Name:         name,
+		Path:         path,
+		OwnerToken:   ownerToken,
+		MessageCount: messageCount,
+		CreatedAt:    createdAt,
+		DeletedAt:    deletedAt,
+	}
+}
@@ -0,0 +1,208 @@
+package domain
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+type WebhookEvent int
+
+const (
+	WebhookEventUnknown WebhookEvent = iota
+	WebhookEventMessage
+	WebhookEventCreate
+	WebhookEventDelete
+	WebhookEventMove
+	// WebhookEventPing is only sent by "webhook test" and cannot be subscribed to
+	WebhookEventPing
+)
+
+// WebhookEvents lists the events a webhook can subscribe to
+var WebhookEvents = []WebhookEvent{WebhookEventMessage, WebhookEventCreate, WebhookEventDelete, WebhookEventMove}
+
+func (e WebhookEvent) String() string {
+	switch e {
+	case WebhookEventMessage:
+		return "message"
+	case WebhookEventCreate:
+		return "create"
+	case WebhookEventDelete:
+		return "delete"
+	case WebhookEventMove:
+		return "move"
+	case WebhookEventPing:
+		return "ping"
+	default:
+		return "unknown"
+	}
+}
+
+func ParseWebhookEvent(name string) (WebhookEvent, error) {
+	for _, event := range WebhookEvents {
+		if event.String() == name {
+			return event, nil
+		}
+	}
+	return WebhookEventUnknown, fmt.Errorf("unknown webhook event '%s'", name)
+}
+
+// Webhook posts the events of a room, or of every node below a directory, to URL
+type Webhook struct {
+	ID         int
+	OwnerToken string
+	Path       string
+	URL        string
+	// Secret is the HMAC-SHA256 key of the X-Chatsh-Signature header
+	Secret string
+	// Events filters the events sent; empty sends all of them
+	Events []WebhookEvent
+	// PathGlob further filters the node paths; a relative pattern is matched against the path below Path
+	PathGlob  string
+	CreatedAt time.Time
+	// Pending and Failed count the queued and abandoned deliveries
+	Pending int
+	Failed  int
+}
+
+func NewWebhook(id int, ownerToken, path, url, secret string, events []WebhookEvent, pathGlob string, createdAt time.Time) Webhook {
+	return Webhook{
+		ID:         id,
+		OwnerToken: ownerToken,
+		Path:       path,
+		URL:        url,
+		Secret:     secret,
+		Events:     events,
+		PathGlob:   pathGlob,
+		CreatedAt:  createdAt,
+	}
+}
+
+// Matches reports whether the webhook wants event on the node at nodePath
+func (w Webhook) Matches(event WebhookEvent, nodePath string) bool {
+	if len(w.Events) > 0 && !slices.Contains(w.Events, event) {
+		return false
+	}
+	relative, ok := relativePath(w.Path, nodePath)
+	if !ok {
+		return false
+	}
+	if w.PathGlob == "" {
+		return true
+	}
+	target := relative
+	if strings.HasPrefix(w.PathGlob, "/") {
+		target = nodePath
+	}
+	matched, err := path.Match(w.PathGlob, target)
+	return err == nil && matched
+}
+
+// relativePath returns nodePath relative to base if it is base itself or lies below it
+func relativePath(base, nodePath string) (string, bool) {
+	if nodePath == base {
+		return ".", true
+	}
+	prefix := strings.TrimSuffix(base, "/") + "/"
+	if !strings.HasPrefix(nodePath, prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(nodePath, prefix), true
+}
+
+// EventNames renders the event filter for display, "all" when it is empty
+func (w Webhook) EventNames() string {
+	if len(w.Events) == 0 {
+		return "all"
+	}
+	names := make([]string, len(w.Events))
+	for i, event := range w.Events {
+		names[i] = event.String()
+	}
+	return strings.Join(names, ",")
+}
+
+type WebhookDeliveryState int
+
+const (
+	WebhookDeliveryPending WebhookDeliveryState = iota
+	// WebhookDeliveryFailed is a delivery abandoned after the last retry
+	WebhookDeliveryFailed
+)
+
+// WebhookDelivery is a queued POST of one event to one webhook
+type WebhookDelivery struct {
+	ID            int
+	WebhookID     int
+	Event         WebhookEvent
+	Payload       []byte
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	State         WebhookDeliveryState
+	CreatedAt     time.Time
+}
+
+func NewWebhookDelivery(id, webhookID int, event WebhookEvent, payload []byte, attempts int, nextAttemptAt time.Time, lastError string, state WebhookDeliveryState, createdAt time.Time) WebhookDelivery {
+	return WebhookDelivery{
+		ID:            id,
+		WebhookID:     webhookID,
+		Event:         event,
+		Payload:       payload,
+		Attempts:      attempts,
+		NextAttemptAt: nextAttemptAt,
+		LastError:     lastError,
+		State:         state,
+		CreatedAt:     createdAt,
+	}
+}
+
+// WebhookOptions tunes the delivery of webhooks
+type WebhookOptions struct {
+	// MaxAttempts is the number of tries before a delivery is abandoned
+	MaxAttempts int
+	// Timeout bounds each POST
+	Timeout time.Duration
+	// Workers is the number of deliveries sent concurrently
+	Workers int
+	// AllowedNetworks are the non-public networks webhooks may still be delivered to
+	AllowedNetworks []netip.Prefix
+}
+
+func NewWebhookOptions(maxAttempts int, timeout time.Duration, workers int, allowedNetworks []netip.Prefix) WebhookOptions {
+	return WebhookOptions{
+		MaxAttempts:     maxAttempts,
+		Timeout:         timeout,
+		Workers:         workers,
+		AllowedNetworks: allowedNetworks,
+	}
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, which some clouds serve metadata from
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// AllowsAddress reports whether webhooks may be delivered to addr. Any room owner registers
+// webhooks, so private, loopback, link-local and unspecified addresses, such as those of the
+// host itself or of a cloud metadata service, are only reached within AllowedNetworks.
+func (o WebhookOptions) AllowsAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	public := addr.IsGlobalUnicast() && !addr.IsPrivate() && !sharedAddressSpace.Contains(addr)
+	if public {
+		return true
+	}
+	for _, network := range o.AllowedNetworks {
+		if network.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookTestResult is the outcome of a test delivery
+type WebhookTestResult struct {
+	StatusCode int
+	Duration   time.Duration
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+// snapshotFile removes the temporary directory of a snapshot once it has been read
+type snapshotFile struct {
+	*os.File
+	dir string
+}
+
+func (f *snapshotFile) Close() error {
+	err := f.File.Close()
+	if removeErr := os.RemoveAll(f.dir); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// Snapshot copies the database with VACUUM INTO, which reads it in a single transaction while
+// writers go on, into a temporary file next to it; closing the snapshot removes the file
+func (r *Repository) Snapshot() (domain.Snapshot, error) {
+	var seq int
+	var name, file string
+	if err := r.db.QueryRow("PRAGMA database_list").Scan(&seq, &name, &file); err != nil {
+		return domain.Snapshot{}, fmt.Errorf("error locating database file: %w", err)
+	}
+	parent := ""
+	if file != "" {
+		parent = filepath.Dir(file)
+	}
+	dir, err := os.MkdirTemp(parent, ".chatsh-backup-")
+	if err != nil {
+		return domain.Snapshot{}, fmt.Errorf("error creating snapshot directory: %w", err)
+	}
+	path := filepath.Join(dir, "chatsh.db")
+	if _, err := r.db.Exec("VACUUM INTO ?", path); err != nil {
+		os.RemoveAll(dir)
+		return domain.Snapshot{}, fmt.Errorf("error copying database: %w", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		return domain.Snapshot{}, fmt.Errorf("error opening snapshot: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		os.RemoveAll(dir)
+		return domain.Snapshot{}, fmt.Errorf("error reading snapshot size: %w", err)
+	}
+	return domain.NewSnapshot(&snapshotFile{File: f, dir: dir}, info.Size()), nil
+}
+
+// CheckIntegrity runs SQLite's integrity check over the whole database and returns the
+// problems it reports as an error
+func CheckIntegrity(db *sql.DB) error {
+	rows, err := db.Query("PRAGMA integrity_check")
+	if err != nil {
+		return fmt.Errorf("failed to check integrity: %w", err)
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var problem string
+		if err := rows.Scan(&problem); err != nil {
+			return fmt.Errorf("failed to scan integrity check: %w", err)
+		}
+		if problem != "ok" {
+			problems = append(problems, problem)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to check integrity: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("database is corrupt: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+// Package cache keeps the nodes of recently resolved paths in front of a usecase.Repository,
+// so that chat messages and RPCs stop querying the database for the same few paths.
+package cache
+
+import (
+	"container/list"
+	"maps"
+	"strings"
+	"sync"
+
+	"github.com/ponyo877/chatsh/server/domain"
+	"github.com/ponyo877/chatsh/server/usecase"
+)
+
+// Stats counts the lookups of the cache since it was created
+type Stats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	// Invalidations counts the paths dropped because their node changed
+	Invalidations int64   `json:"invalidations"`
+	Size          int     `json:"size"`
+	Capacity      int     `json:"capacity"`
+	HitRate       float64 `json:"hit_rate"`
+}
+
+type entry struct {
+	path string
+	node domain.Node
+}
+
+type nodeKey struct {
+	nodeType domain.NodeType
+	id       int
+}
+
+// Repository caches GetNodeByPath in a least recently used list of at most capacity paths.
+// Every method changing a path or an owner name drops the entries it affects, so lookups
+// never see a node that the repository underneath no longer has. Misses are not cached.
+type Repository struct {
+	usecase.Repository
+	capacity int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// paths finds the cached paths of a node, for changes that only know its id. A node has
+	// one path, but lookups racing a move may cache both its old and its new one.
+	paths map[nodeKey]map[string]bool
+	// order has the most recently used entry in front
+	order *list.List
+	// generation changes with every invalidation, so that a lookup racing a change
+	// does not put back what the change just dropped
+	generation uint64
+	stats      Stats
+}
+
+func NewRepository(repo usecase.Repository, capacity int) *Repository {
+	return &Repository{
+		Repository: repo,
+		capacity:   capacity,
+		entries:    map[string]*list.Element{},
+		paths:      map[nodeKey]map[string]bool{},
+		order:      list.New(),
+	}
+}
+
+func (r *Repository) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := r.stats
+	stats.Size = r.order.Len()
+	stats.Capacity = r.capacity
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+func (r *Repository) GetNodeByPath(path domain.Path) (domain.Node, error) {
+	key := path.String()
+	r.mu.Lock()
+	if element, ok := r.entries[key]; ok {
+		r.order.MoveToFront(element)
+		r.stats.Hits++
+		node := element.Value.(*entry).node
+		r.mu.Unlock()
+		return node, nil
+	}
+	r.stats.Misses++
+	generation := r.generation
+	r.mu.Unlock()
+
+	node, err := r.Repository.GetNodeByPath(path)
+	if err != nil {
+		return node, err
+	}
+	r.mu.Lock()
+	if r.generation == generation {
+		r.add(key, node)
+	}
+	r.mu.Unlock()
+	return node, nil
+}
+
+func (r *Repository) add(path string, node domain.Node) {
+	if element, ok := r.entries[path]; ok {
+		r.remove(element)
+	}
+	r.entries[path] = r.order.PushFront(&entry{path: path, node: node})
+	key := nodeKey{node.Type, node.ID}
+	if r.paths[key] == nil {
+		r.paths[key] = map[string]bool{}
+	}
+	r.paths[key][path] = true
+	for r.order.Len() > r.capacity {
+		r.remove(r.order.Back())
+		r.stats.Evictions++
+	}
+}
+
+func (r *Repository) remove(element *list.Element) {
+	e := r.order.Remove(element).(*entry)
+	delete(r.entries, e.path)
+	key := nodeKey{e.node.Type, e.node.ID}
+	delete(r.paths[key], e.path)
+	if len(r.paths[key]) == 0 {
+		delete(r.paths, key)
+	}
+}
+
+// invalidate drops the entries for which drop is true
+func (r *Repository) invalidate(drop func(*entry) bool) {
+	r.generation++
+	for element := r.order.Front(); element != nil; {
+		next := element.Next()
+		if drop(element.Value.(*entry)) {
+			r.remove(element)
+			r.stats.Invalidations++
+		}
+		element = next
+	}
+}
+
+// invalidateNode drops the node, and everything below it when it is a directory. A directory
+// that is not cached may still have cached descendants, whose paths are unknown, so the
+// whole cache goes then.
+func (r *Repository) invalidateNode(nodeType domain.NodeType, id int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Dropping entries shrinks the set, so match against a copy
+	paths := maps.Clone(r.paths[nodeKey{nodeType, id}])
+	ok := len(paths) > 0
+	switch {
+	case ok && nodeType == domain.NodeTypeDirectory:
+		r.invalidate(func(e *entry) bool {
+			for path := range paths {
+				if e.path == path || strings.HasPrefix(e.path, path+"/") {
+					return true
+				}
+			}
+			return false
+		})
+	case ok:
+		r.invalidate(func(e *entry) bool { return paths[e.path] })
+	case nodeType == domain.NodeTypeDirectory:
+		r.invalidate(func(*entry) bool { return true })
+	default:
+		// An uncached room has nothing to drop, but lookups in flight may still hold it
+		r.generation++
+	}
+}
+
+func (r *Repository) CreateConfig(config domain.Config) error {
+	if err := r.Repository.CreateConfig(config); err != nil {
+		return err
+	}
+	// The nodes carry the display name of their owner
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.invalidate(func(e *entry) bool { return e.node.OwnerToken == config.OwnerToken })
+	return nil
+}
+
+func (r *Repository) DeleteDirectory(dirID int) error {
+	if err := r.Repository.DeleteDirectory(dirID); err != nil {
+		return err
+	}
+	r.invalidateNode(domain.NodeTypeDirectory, dirID)
+	return nil
+}
+
+func (r *Repository) UpdateDirectory(srcDirID, dstDirID int, dstDirPath, name string) error {
+	if err := r.Repository.UpdateDirectory(srcDirID, dstDirID, dstDirPath, name); err != nil {
+		return err
+	}
+	r.invalidateNode(domain.NodeTypeDirectory, srcDirID)
+	return nil
+}
+
+func (r *Repository) DeleteRoom(roomID int) error {
+	if err := r.Repository.DeleteRoom(roomID); err != nil {
+		return err
+	}
+	r.invalidateNode(domain.NodeTypeRoom, roomID)
+	return nil
+}
+
+func (r *Repository) UpdateRoom(srcRoomID, dstDirID int, dstDirPath, name string) error {
+	if err := r.Repository.UpdateRoom(srcRoomID, dstDirID, dstDirPath, name); err != nil {
+		return err
+	}
+	r.invalidateNode(domain.NodeTypeRoom, srcRoomID)
+	return nil
+}
+
+func (r *Repository) TrashNode(nodeType domain.NodeType, nodeID int) (int, error) {
+	id, err := r.Repository.TrashNode(nodeType, nodeID)
+	if err != nil {
+		return 0, err
+	}
+	r.invalidateNode(nodeType, nodeID)
+	return id, nil
+}
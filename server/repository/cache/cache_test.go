@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ponyo877/chatsh/server/domain"
+	"github.com/ponyo877/chatsh/server/repository/memory"
+	"github.com/ponyo877/chatsh/server/usecase"
+)
+
+func lookup(t *testing.T, repo usecase.Repository, path string) domain.Node {
+	t.Helper()
+	node, err := repo.GetNodeByPath(domain.NewPath(path))
+	if err != nil {
+		t.Fatalf("GetNodeByPath(%s): %v", path, err)
+	}
+	return node
+}
+
+// TestMovedDirectory moves a directory whose descendants are cached and checks that their old
+// paths miss, and that their new paths resolve to the same nodes
+func TestMovedDirectory(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		// cached are the paths looked up before the move
+		cached []string
+		// dst is the directory the move puts /tmp/a in, and name its new name
+		dst, newName string
+	}{
+		{"rename", []string{"/tmp/a", "/tmp/a/room", "/tmp/a/sub", "/tmp/a/sub/room"}, "/tmp", "b"},
+		{"move", []string{"/tmp/a", "/tmp/a/room", "/tmp/a/sub/room"}, "/tmp/dst", "a"},
+		{"move and rename", []string{"/tmp/a/room", "/tmp/a/sub", "/tmp/a/sub/room"}, "/tmp/dst", "b"},
+		// Without the directory itself cached, its descendants are still found by their paths
+		{"uncached directory", []string{"/tmp/a/room", "/tmp/a/sub/room"}, "/tmp", "b"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := NewRepository(memory.NewRepository(), 64)
+			if err := repo.CreateConfig(domain.NewConfig("alice", "tokA")); err != nil {
+				t.Fatal(err)
+			}
+			for _, dir := range []struct{ parent, name string }{{"/tmp", "a"}, {"/tmp", "dst"}, {"/tmp/a", "sub"}} {
+				parent := lookup(t, repo, dir.parent)
+				if err := repo.CreateDirectory(parent.ID, dir.parent, dir.name, "tokA"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, dir := range []string{"/tmp/a", "/tmp/a/sub"} {
+				if err := repo.CreateRoom(lookup(t, repo, dir).ID, dir, "room", "tokA"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Only what the case caches stays in the cache
+			repo.invalidate(func(*entry) bool { return true })
+			nodes := map[string]domain.Node{}
+			for _, path := range tt.cached {
+				nodes[path] = lookup(t, repo, path)
+			}
+
+			dst := lookup(t, repo, tt.dst)
+			moved := lookup(t, repo, "/tmp/a")
+			if err := repo.UpdateDirectory(moved.ID, dst.ID, tt.dst, tt.newName); err != nil {
+				t.Fatalf("UpdateDirectory: %v", err)
+			}
+
+			newPrefix := tt.dst + "/" + tt.newName
+			for path, node := range nodes {
+				if _, err := repo.GetNodeByPath(domain.NewPath(path)); !errors.Is(err, usecase.ErrNotFound) {
+					t.Errorf("GetNodeByPath(%s) after the move = %v, want ErrNotFound", path, err)
+				}
+				newPath := newPrefix + path[len("/tmp/a"):]
+				if got := lookup(t, repo, newPath); got.ID != node.ID || got.Type != node.Type {
+					t.Errorf("GetNodeByPath(%s) = %v %d, want %v %d", newPath, got.Type, got.ID, node.Type, node.ID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package memory
+
+import (
+	"fmt"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+// Snapshot is unsupported: there is no database file to copy, and nothing that could restore one
+func (r *Repository) Snapshot() (domain.Snapshot, error) {
+	return domain.Snapshot{}, fmt.Errorf("backup of in-memory storage: %w", domain.ErrUnsupported)
+}
@@ -0,0 +1,632 @@
+// Package memory implements usecase.Repository in memory, for tests and for servers whose
+// data may vanish on restart. It follows the semantics of the SQLite repository, which the
+// suite in server/repository/repotest checks for both.
+package memory
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+	"github.com/ponyo877/chatsh/server/usecase"
+)
+
+type user struct {
+	displayName string
+	createdAt   time.Time
+}
+
+// node is a row of the directories or rooms table; parentID is the parent directory
+type node struct {
+	id         int
+	name       string
+	parentID   int
+	ownerToken string
+	path       string
+	createdAt  time.Time
+}
+
+type readMarkerKey struct {
+	ownerToken string
+	roomID     int
+}
+
+type retentionKey struct {
+	nodeType domain.NodeType
+	nodeID   int
+}
+
+type restriction struct {
+	roomID          int
+	userToken       string
+	restrictionType domain.RestrictionType
+	createdAt       time.Time
+}
+
+type Repository struct {
+	mu          sync.Mutex
+	users       map[string]user
+	directories map[int]*node
+	rooms       map[int]*node
+	// messages holds the messages of each room in insertion order
+	messages         map[int][]domain.Message
+	mentions         []domain.Mention
+	readMarkers      map[readMarkerKey]int
+	restrictions     []restriction
+	roomSettings     map[int]domain.RoomSettings
+	moderationLogs   []domain.ModerationLog
+	retention        map[retentionKey]domain.RetentionPolicy
+	trash            map[int]trashed
+	webhooks         map[int]domain.Webhook
+	deliveries       map[int]domain.WebhookDelivery
+	incomingWebhooks map[int]domain.IncomingWebhook
+	// importKeys holds the keys of the messages imported into each room
+	importKeys map[int]map[string]bool
+	// lastIDs plays the part of AUTOINCREMENT: ids are never reused within a table
+	lastIDs map[string]int
+}
+
+// seedDirectories mirrors the tree created by schema/migrations/0001_initial.up.sql
+var seedDirectories = []struct {
+	name     string
+	parentID int
+}{
+	{"", 0},
+	{"mnt", 1}, {"srv", 1}, {"opt", 1}, {"media", 1}, {"usr", 1}, {"lost+found", 1}, {"snap", 1}, {"var", 1},
+	{"home", 1}, {"root", 1}, {"proc", 1}, {"dev", 1}, {"etc", 1}, {"boot", 1}, {"tmp", 1}, {"run", 1}, {"sys", 1},
+	{"chatsh", 10},
+}
+
+// NewRepository returns an empty store holding the initial tree and its "admin" owner
+func NewRepository() usecase.Repository {
+	r := &Repository{
+		users:            map[string]user{},
+		directories:      map[int]*node{},
+		rooms:            map[int]*node{},
+		messages:         map[int][]domain.Message{},
+		readMarkers:      map[readMarkerKey]int{},
+		roomSettings:     map[int]domain.RoomSettings{},
+		retention:        map[retentionKey]domain.RetentionPolicy{},
+		trash:            map[int]trashed{},
+		webhooks:         map[int]domain.Webhook{},
+		deliveries:       map[int]domain.WebhookDelivery{},
+		incomingWebhooks: map[int]domain.IncomingWebhook{},
+		importKeys:       map[int]map[string]bool{},
+		lastIDs:          map[string]int{},
+	}
+	seededAt := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	r.users["admin"] = user{displayName: "Administrator", createdAt: seededAt}
+	for _, seed := range seedDirectories {
+		path := "/"
+		if parent, ok := r.directories[seed.parentID]; ok {
+			path = filepath.Join(parent.path, seed.name)
+		}
+		id := r.nextID("directories")
+		r.directories[id] = &node{id: id, name: seed.name, parentID: seed.parentID, ownerToken: "admin", path: path, createdAt: seededAt}
+	}
+	// As in schema/migrations/0007_retention.up.sql
+	tmp := findByPath(r.directories, "/tmp")
+	r.retention[retentionKey{domain.NodeTypeDirectory, tmp.id}] = domain.NewRetentionPolicy(domain.NodeTypeDirectory, tmp.id, "", 24*time.Hour, 0, 24*time.Hour, seededAt)
+	return r
+}
+
+func (r *Repository) nextID(table string) int {
+	r.lastIDs[table]++
+	return r.lastIDs[table]
+}
+
+func findByPath(nodes map[int]*node, path string) *node {
+	for _, n := range nodes {
+		if n.path == path {
+			return n
+		}
+	}
+	return nil
+}
+
+// conflicts reports whether a node named name below parentID, or at path, already exists in nodes;
+// like the UNIQUE constraints of the tables, a directory and a room do not conflict with each other
+func conflicts(nodes map[int]*node, exceptID, parentID int, name, path string) bool {
+	for _, n := range nodes {
+		if n.id != exceptID && ((n.parentID == parentID && n.name == name) || n.path == path) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedNodes(nodes map[int]*node) []*node {
+	sorted := make([]*node, 0, len(nodes))
+	for _, n := range nodes {
+		sorted = append(sorted, n)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].id < sorted[j].id })
+	return sorted
+}
+
+func (r *Repository) CheckDirectoryExists(path domain.Path) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return findByPath(r.directories, path.String()) != nil, nil
+}
+
+func (r *Repository) GetConfig(ownerToken string) (domain.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[ownerToken]
+	if !ok {
+		return domain.Config{}, usecase.ErrNotFound
+	}
+	return domain.NewConfig(u.displayName, ownerToken), nil
+}
+
+func (r *Repository) CreateConfig(config domain.Config) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[config.OwnerToken] = user{displayName: config.DisplayName, createdAt: time.Now()}
+	return nil
+}
+
+// toNode returns the node as seen through a join with its owner, which hides nodes of unknown owners
+func (r *Repository) toNode(n *node, nodeType domain.NodeType) (domain.Node, bool) {
+	owner, ok := r.users[n.ownerToken]
+	if !ok {
+		return domain.Node{}, false
+	}
+	return domain.NewNode(n.id, n.name, nodeType, n.ownerToken, owner.displayName, n.createdAt), true
+}
+
+func (r *Repository) GetNodeByPath(path domain.Path) (domain.Node, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n := findByPath(r.directories, path.String()); n != nil {
+		if result, ok := r.toNode(n, domain.NodeTypeDirectory); ok {
+			result.Name = path.NodeName()
+			return result, nil
+		}
+	}
+	if n := findByPath(r.rooms, path.String()); n != nil {
+		if result, ok := r.toNode(n, domain.NodeTypeRoom); ok {
+			result.Name = path.NodeName()
+			return result, nil
+		}
+	}
+	return domain.Node{}, usecase.ErrNotFound
+}
+
+// ListNodes returns the directories, then the rooms, below parentDirID, each by name
+func (r *Repository) ListNodes(parentDirID int) ([]domain.Node, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var results []domain.Node
+	for _, table := range []struct {
+		nodes    map[int]*node
+		nodeType domain.NodeType
+	}{{r.directories, domain.NodeTypeDirectory}, {r.rooms, domain.NodeTypeRoom}} {
+		for _, n := range sortedNodes(table.nodes) {
+			if n.parentID != parentDirID {
+				continue
+			}
+			if result, ok := r.toNode(n, table.nodeType); ok {
+				results = append(results, result)
+			}
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Type != results[j].Type {
+			return results[i].Type < results[j].Type
+		}
+		return results[i].Name < results[j].Name
+	})
+	return results, nil
+}
+
+func (r *Repository) CreateDirectory(parentDirID int, parentDirPath, name, ownerToken string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	newPath := filepath.Join(parentDirPath, name)
+	if conflicts(r.directories, 0, parentDirID, name, newPath) {
+		return fmt.Errorf("failed to insert directory '%s': %w", name, usecase.ErrAlreadyExists)
+	}
+	id := r.nextID("directories")
+	r.directories[id] = &node{id: id, name: name, parentID: parentDirID, ownerToken: ownerToken, path: newPath, createdAt: time.Now()}
+	return nil
+}
+
+func (r *Repository) DeleteDirectory(dirID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.retention, retentionKey{domain.NodeTypeDirectory, dirID})
+	delete(r.directories, dirID)
+	return nil
+}
+
+// UpdateDirectory moves the directory along with everything below it
+func (r *Repository) UpdateDirectory(srcDirID, dstDirID int, dstDirPath, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	newPath := filepath.Join(dstDirPath, name)
+	if conflicts(r.directories, srcDirID, dstDirID, name, newPath) {
+		return fmt.Errorf("failed to update directory path: %w", usecase.ErrAlreadyExists)
+	}
+	n, ok := r.directories[srcDirID]
+	if !ok {
+		return nil
+	}
+	oldPath := n.path
+	n.parentID, n.name, n.path = dstDirID, name, newPath
+	// Everything below the directory moves with it
+	for _, nodes := range []map[int]*node{r.directories, r.rooms} {
+		for _, descendant := range nodes {
+			if strings.HasPrefix(descendant.path, oldPath+"/") {
+				descendant.path = newPath + strings.TrimPrefix(descendant.path, oldPath)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Repository) CreateRoom(parentDirID int, parentDirPath, name, ownerToken string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err := r.createRoom(parentDirID, parentDirPath, name, ownerToken)
+	return err
+}
+
+func (r *Repository) createRoom(parentDirID int, parentDirPath, name, ownerToken string) (int, error) {
+	newPath := filepath.Join(parentDirPath, name)
+	if conflicts(r.rooms, 0, parentDirID, name, newPath) {
+		return 0, fmt.Errorf("failed to insert room '%s': %w", name, usecase.ErrAlreadyExists)
+	}
+	id := r.nextID("rooms")
+	r.rooms[id] = &node{id: id, name: name, parentID: parentDirID, ownerToken: ownerToken, path: newPath, createdAt: time.Now()}
+	return id, nil
+}
+
+// CreateExistRoom copies the room roomID, messages included, to dstDirPath/name
+func (r *Repository) CreateExistRoom(roomID, dstDirID int, dstDirPath, name, ownerToken string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	newRoomID, err := r.createRoom(dstDirID, dstDirPath, name, ownerToken)
+	if err != nil {
+		return err
+	}
+	for _, message := range r.messages[roomID] {
+		r.messages[newRoomID] = append(r.messages[newRoomID], domain.NewMessage(r.nextID("messages"), newRoomID, message.DisplayName, message.Content, message.CreatedAt))
+	}
+	return nil
+}
+
+// DeleteRoom removes the room with its messages, mentions, read markers, moderation data, import keys and retention policy
+func (r *Repository) DeleteRoom(roomID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deleteRoomData(roomID)
+	delete(r.rooms, roomID)
+	return nil
+}
+
+// deleteRoomData deletes what refers to the room, leaving the room itself
+func (r *Repository) deleteRoomData(roomID int) {
+	delete(r.messages, roomID)
+	r.mentions = slices.DeleteFunc(r.mentions, func(m domain.Mention) bool { return m.RoomID == roomID })
+	for key := range r.readMarkers {
+		if key.roomID == roomID {
+			delete(r.readMarkers, key)
+		}
+	}
+	delete(r.roomSettings, roomID)
+	r.restrictions = slices.DeleteFunc(r.restrictions, func(rr restriction) bool { return rr.roomID == roomID })
+	r.moderationLogs = slices.DeleteFunc(r.moderationLogs, func(l domain.ModerationLog) bool { return l.RoomID == roomID })
+	delete(r.retention, retentionKey{domain.NodeTypeRoom, roomID})
+	delete(r.importKeys, roomID)
+}
+
+func (r *Repository) UpdateRoom(srcRoomID, dstDirID int, dstDirPath, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	newPath := filepath.Join(dstDirPath, name)
+	if conflicts(r.rooms, srcRoomID, dstDirID, name, newPath) {
+		return fmt.Errorf("failed to update room path for %d: %w", srcRoomID, usecase.ErrAlreadyExists)
+	}
+	if n, ok := r.rooms[srcRoomID]; ok {
+		n.parentID, n.name, n.path = dstDirID, name, newPath
+	}
+	return nil
+}
+
+func (r *Repository) CreateMessage(roomID int, displayName, message string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := r.nextID("messages")
+	r.messages[roomID] = append(r.messages[roomID], domain.NewMessage(id, roomID, displayName, message, time.Now()))
+	return id, nil
+}
+
+// messagesByTime returns the messages of the room oldest first; copies keep the time of their original
+func (r *Repository) messagesByTime(roomID int) []domain.Message {
+	messages := slices.Clone(r.messages[roomID])
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].CreatedAt.Before(messages[j].CreatedAt) })
+	return messages
+}
+
+// ListMessages returns the latest messages first
+func (r *Repository) ListMessages(roomID, limit, offset int) ([]domain.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages := r.messagesByTime(roomID)
+	slices.Reverse(messages)
+	if offset >= len(messages) {
+		return []domain.Message{}, nil
+	}
+	messages = messages[offset:]
+	if limit >= 0 && limit < len(messages) {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+
+// ListMessagesAfter returns the messages written after afterID, in the order they were written
+func (r *Repository) ListMessagesAfter(roomID, afterID, limit int) ([]domain.Message, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages := []domain.Message{}
+	for _, message := range r.messages[roomID] {
+		if len(messages) == limit {
+			break
+		}
+		if message.ID > afterID {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+// CreateMessages stores messages keeping their authors and times
+func (r *Repository) CreateMessages(roomID int, messages []domain.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, message := range messages {
+		r.messages[roomID] = append(r.messages[roomID], domain.NewMessage(r.nextID("messages"), roomID, message.DisplayName, message.Content, message.CreatedAt))
+	}
+	return nil
+}
+
+// ImportMessages stores the messages whose keys the room has not taken yet
+func (r *Repository) ImportMessages(roomID int, messages []domain.ImportedMessage) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := r.importKeys[roomID]
+	if keys == nil {
+		keys = map[string]bool{}
+		r.importKeys[roomID] = keys
+	}
+	latest := 0
+	for _, message := range r.messages[roomID] {
+		latest = max(latest, message.ID)
+	}
+	imported, last := 0, 0
+	for _, message := range messages {
+		if keys[message.Key] {
+			continue
+		}
+		keys[message.Key] = true
+		last = r.nextID("messages")
+		r.messages[roomID] = append(r.messages[roomID], domain.NewMessage(last, roomID, message.DisplayName, message.Content, message.CreatedAt))
+		imported++
+	}
+	if imported == 0 {
+		return 0, nil
+	}
+	// Readers who had read the whole room still have, imported history being older
+	for key, lastRead := range r.readMarkers {
+		if key.roomID == roomID && lastRead >= latest {
+			r.readMarkers[key] = last
+		}
+	}
+	return imported, nil
+}
+
+// ListMessagesByQuery returns the messages matching the regular expression pattern, oldest first
+func (r *Repository) ListMessagesByQuery(roomID int, pattern string) ([]domain.Message, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute search in room %d for query '%s': %w", roomID, pattern, err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var messages []domain.Message
+	for _, message := range r.messagesByTime(roomID) {
+		if re.MatchString(message.Content) {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+// CreateMentions stores a mention for every user whose display name is in userNames
+func (r *Repository) CreateMentions(roomID int, userNames []string, senderName, message string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	tokens := make([]string, 0, len(r.users))
+	for token := range r.users {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	for _, token := range tokens {
+		if !slices.Contains(userNames, r.users[token].displayName) {
+			continue
+		}
+		r.mentions = append(r.mentions, domain.NewMention(r.nextID("mentions"), token, roomID, "", senderName, message, false, now))
+	}
+	return nil
+}
+
+func (r *Repository) ListMentions(ownerToken string, unreadOnly bool) ([]domain.Mention, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mentions := []domain.Mention{}
+	for _, mention := range r.mentions {
+		room, ok := r.rooms[mention.RoomID]
+		if !ok || mention.UserToken != ownerToken || (unreadOnly && mention.IsRead) {
+			continue
+		}
+		mention.RoomPath = room.path
+		mentions = append(mentions, mention)
+	}
+	sort.SliceStable(mentions, func(i, j int) bool { return mentions[i].CreatedAt.Before(mentions[j].CreatedAt) })
+	return mentions, nil
+}
+
+// Marks all mentions of the user when mentionIDs is empty
+func (r *Repository) UpdateMentionsRead(ownerToken string, mentionIDs []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, mention := range r.mentions {
+		if mention.UserToken == ownerToken && (len(mentionIDs) == 0 || slices.Contains(mentionIDs, mention.ID)) {
+			r.mentions[i].IsRead = true
+		}
+	}
+	return nil
+}
+
+func (r *Repository) GetReadMarker(ownerToken string, roomID int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.readMarkers[readMarkerKey{ownerToken, roomID}], nil
+}
+
+// Marks up to the latest message of the room when messageID is 0; the marker only moves forward
+func (r *Repository) UpsertReadMarker(ownerToken string, roomID, messageID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if messageID <= 0 {
+		messageID = 0
+		for _, message := range r.messages[roomID] {
+			messageID = max(messageID, message.ID)
+		}
+	}
+	key := readMarkerKey{ownerToken, roomID}
+	r.readMarkers[key] = max(r.readMarkers[key], messageID)
+	return nil
+}
+
+// CountUnreadMessages leaves out the rooms without unread messages
+func (r *Repository) CountUnreadMessages(ownerToken string, roomIDs []int) (map[int]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[int]int, len(roomIDs))
+	for _, roomID := range roomIDs {
+		lastRead := r.readMarkers[readMarkerKey{ownerToken, roomID}]
+		for _, message := range r.messages[roomID] {
+			if message.ID > lastRead {
+				counts[roomID]++
+			}
+		}
+	}
+	return counts, nil
+}
+
+func (r *Repository) GetTokensByDisplayName(displayName string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var tokens []string
+	for token, u := range r.users {
+		if u.displayName == displayName {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Strings(tokens)
+	return tokens, nil
+}
+
+func (r *Repository) CreateRoomRestriction(roomID int, userToken string, restrictionType domain.RestrictionType, createdBy string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rr := range r.restrictions {
+		if rr.roomID == roomID && rr.userToken == userToken && rr.restrictionType == restrictionType {
+			return nil
+		}
+	}
+	r.restrictions = append(r.restrictions, restriction{roomID: roomID, userToken: userToken, restrictionType: restrictionType, createdAt: time.Now()})
+	return nil
+}
+
+func (r *Repository) DeleteRoomRestriction(roomID int, userToken string, restrictionType domain.RestrictionType) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.restrictions = slices.DeleteFunc(r.restrictions, func(rr restriction) bool {
+		return rr.roomID == roomID && rr.userToken == userToken && rr.restrictionType == restrictionType
+	})
+	return nil
+}
+
+func (r *Repository) HasRoomRestriction(roomID int, userToken string, restrictionType domain.RestrictionType) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rr := range r.restrictions {
+		if rr.roomID == roomID && rr.userToken == userToken && rr.restrictionType == restrictionType {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *Repository) ListRoomRestrictions(roomID int) ([]domain.RoomRestriction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	restrictions := []domain.RoomRestriction{}
+	for _, rr := range r.restrictions {
+		u, ok := r.users[rr.userToken]
+		if rr.roomID != roomID || !ok {
+			continue
+		}
+		restrictions = append(restrictions, domain.NewRoomRestriction(roomID, rr.userToken, u.displayName, rr.restrictionType, rr.createdAt))
+	}
+	return restrictions, nil
+}
+
+func (r *Repository) GetRoomSettings(roomID int) (domain.RoomSettings, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if settings, ok := r.roomSettings[roomID]; ok {
+		return settings, nil
+	}
+	return domain.NewRoomSettings(roomID, 0, 0, 0), nil
+}
+
+func (r *Repository) UpsertRoomSettings(settings domain.RoomSettings) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.roomSettings[settings.RoomID] = settings
+	return nil
+}
+
+func (r *Repository) CreateModerationLog(log domain.ModerationLog) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	log.ID = r.nextID("moderation_logs")
+	log.CreatedAt = time.Now()
+	r.moderationLogs = append(r.moderationLogs, log)
+	return nil
+}
+
+// ListModerationLogs returns the latest entries first
+func (r *Repository) ListModerationLogs(roomID, limit int) ([]domain.ModerationLog, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	logs := []domain.ModerationLog{}
+	for i := len(r.moderationLogs) - 1; i >= 0 && (limit < 0 || len(logs) < limit); i-- {
+		if r.moderationLogs[i].RoomID == roomID {
+			logs = append(logs, r.moderationLogs[i])
+		}
+	}
+	return logs, nil
+}
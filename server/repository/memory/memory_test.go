@@ -0,0 +1,17 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/ponyo877/chatsh/server/repository/cache"
+	"github.com/ponyo877/chatsh/server/repository/memory"
+	"github.com/ponyo877/chatsh/server/repository/repotest"
+)
+
+func TestRepository(t *testing.T) {
+	repotest.TestRepository(t, memory.NewRepository())
+}
+
+func TestRepositoryPathCache(t *testing.T) {
+	repotest.TestRepository(t, cache.NewRepository(memory.NewRepository(), 1024))
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+	"github.com/ponyo877/chatsh/server/usecase"
+)
+
+// withPath fills in the current path of the policy's node; ok is false once the node is gone
+func (r *Repository) withPath(policy domain.RetentionPolicy) (domain.RetentionPolicy, bool) {
+	nodes := r.rooms
+	if policy.NodeType == domain.NodeTypeDirectory {
+		nodes = r.directories
+	}
+	n, ok := nodes[policy.NodeID]
+	if !ok {
+		return domain.RetentionPolicy{}, false
+	}
+	policy.Path = n.path
+	return policy, true
+}
+
+func (r *Repository) GetRetentionPolicy(nodeType domain.NodeType, nodeID int) (domain.RetentionPolicy, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if policy, ok := r.retention[retentionKey{nodeType, nodeID}]; ok {
+		if policy, ok := r.withPath(policy); ok {
+			return policy, nil
+		}
+	}
+	return domain.RetentionPolicy{}, usecase.ErrNotFound
+}
+
+func (r *Repository) ListRetentionPolicies() ([]domain.RetentionPolicy, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	policies := []domain.RetentionPolicy{}
+	for _, policy := range r.retention {
+		if policy, ok := r.withPath(policy); ok {
+			policies = append(policies, policy)
+		}
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Path < policies[j].Path })
+	return policies, nil
+}
+
+func (r *Repository) UpsertRetentionPolicy(policy domain.RetentionPolicy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	policy.Path = ""
+	policy.UpdatedAt = time.Now()
+	r.retention[retentionKey{policy.NodeType, policy.NodeID}] = policy
+	return nil
+}
+
+func (r *Repository) DeleteRetentionPolicy(nodeType domain.NodeType, nodeID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.retention, retentionKey{nodeType, nodeID})
+	return nil
+}
+
+// deleteMessages removes the given messages of the room and returns their ids
+func (r *Repository) deleteMessages(roomID int, expired []domain.Message) []int {
+	ids := make([]int, len(expired))
+	for i, message := range expired {
+		ids[i] = message.ID
+	}
+	r.messages[roomID] = slices.DeleteFunc(r.messages[roomID], func(m domain.Message) bool {
+		return slices.Contains(ids, m.ID)
+	})
+	return ids
+}
+
+func (r *Repository) DeleteMessagesBefore(roomID int, before time.Time, limit int) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var expired []domain.Message
+	for _, message := range r.messagesByTime(roomID) {
+		if len(expired) == limit || !message.CreatedAt.Before(before) {
+			break
+		}
+		expired = append(expired, message)
+	}
+	return r.deleteMessages(roomID, expired), nil
+}
+
+func (r *Repository) DeleteMessagesBeyond(roomID, keep, limit int) ([]int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	messages := r.messagesByTime(roomID)
+	slices.Reverse(messages)
+	if keep >= len(messages) {
+		return []int{}, nil
+	}
+	expired := messages[keep:]
+	if limit < len(expired) {
+		expired = expired[:limit]
+	}
+	return r.deleteMessages(roomID, expired), nil
+}
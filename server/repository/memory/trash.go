@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+	"github.com/ponyo877/chatsh/server/usecase"
+)
+
+// trashed is a row of the trash table: the node as it was when removed
+type trashed struct {
+	id        int
+	nodeType  domain.NodeType
+	node      node
+	deletedAt time.Time
+}
+
+func (r *Repository) nodeTable(nodeType domain.NodeType) (map[int]*node, bool) {
+	switch nodeType {
+	case domain.NodeTypeDirectory:
+		return r.directories, true
+	case domain.NodeTypeRoom:
+		return r.rooms, true
+	}
+	return nil, false
+}
+
+func (r *Repository) toTrashEntry(t trashed) domain.TrashEntry {
+	messageCount := 0
+	if t.nodeType == domain.NodeTypeRoom {
+		messageCount = len(r.messages[t.node.id])
+	}
+	return domain.NewTrashEntry(t.id, t.nodeType, t.node.id, t.node.name, t.node.path, t.node.ownerToken, messageCount, t.node.createdAt, t.deletedAt)
+}
+
+func (r *Repository) TrashNode(nodeType domain.NodeType, nodeID int) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	nodes, ok := r.nodeTable(nodeType)
+	if !ok {
+		return 0, fmt.Errorf("cannot trash node of type %d", nodeType)
+	}
+	n, ok := nodes[nodeID]
+	if !ok {
+		return 0, fmt.Errorf("failed to move node %d to the trash: %w", nodeID, usecase.ErrNotFound)
+	}
+	id := r.nextID("trash")
+	r.trash[id] = trashed{id: id, nodeType: nodeType, node: *n, deletedAt: time.Now()}
+	delete(nodes, nodeID)
+	return id, nil
+}
+
+func (r *Repository) GetTrashEntry(id int) (domain.TrashEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.trash[id]
+	if !ok {
+		return domain.TrashEntry{}, usecase.ErrNotFound
+	}
+	return r.toTrashEntry(t), nil
+}
+
+func (r *Repository) ListTrashEntries(ownerToken string) ([]domain.TrashEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := []domain.TrashEntry{}
+	for _, t := range r.trash {
+		if t.node.ownerToken == ownerToken {
+			entries = append(entries, r.toTrashEntry(t))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].DeletedAt.Equal(entries[j].DeletedAt) {
+			return entries[i].DeletedAt.After(entries[j].DeletedAt)
+		}
+		return entries[i].ID > entries[j].ID
+	})
+	return entries, nil
+}
+
+func (r *Repository) RestoreTrashEntry(id, dstDirID int, dstDirPath, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.trash[id]
+	if !ok {
+		return usecase.ErrNotFound
+	}
+	nodes, ok := r.nodeTable(t.nodeType)
+	if !ok {
+		return fmt.Errorf("cannot restore node of type %d", t.nodeType)
+	}
+	newPath := filepath.Join(dstDirPath, name)
+	if conflicts(nodes, 0, dstDirID, name, newPath) {
+		return fmt.Errorf("failed to restore '%s': %w", newPath, usecase.ErrAlreadyExists)
+	}
+	n := t.node
+	n.parentID, n.name, n.path = dstDirID, name, newPath
+	nodes[n.id] = &n
+	delete(r.trash, id)
+	return nil
+}
+
+func (r *Repository) PurgeTrashEntries(ownerToken string, before time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	purged := 0
+	for id, t := range r.trash {
+		if !t.deletedAt.Before(before) || (ownerToken != "" && t.node.ownerToken != ownerToken) {
+			continue
+		}
+		if t.nodeType == domain.NodeTypeRoom {
+			r.deleteRoomData(t.node.id)
+		} else {
+			delete(r.retention, retentionKey{t.nodeType, t.node.id})
+		}
+		delete(r.trash, id)
+		purged++
+	}
+	return purged, nil
+}
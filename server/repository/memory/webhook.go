@@ -0,0 +1,227 @@
+package memory
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+	"github.com/ponyo877/chatsh/server/usecase"
+)
+
+// withDeliveryCounts returns a copy of the webhook with its pending and failed delivery counts
+func (r *Repository) withDeliveryCounts(webhook domain.Webhook) domain.Webhook {
+	webhook.Events = slices.Clone(webhook.Events)
+	webhook.Pending, webhook.Failed = 0, 0
+	for _, delivery := range r.deliveries {
+		if delivery.WebhookID != webhook.ID {
+			continue
+		}
+		switch delivery.State {
+		case domain.WebhookDeliveryPending:
+			webhook.Pending++
+		case domain.WebhookDeliveryFailed:
+			webhook.Failed++
+		}
+	}
+	return webhook
+}
+
+// listWebhooks returns the webhooks accepted by keep, by id
+func (r *Repository) listWebhooks(keep func(domain.Webhook) bool) []domain.Webhook {
+	webhooks := []domain.Webhook{}
+	for _, webhook := range r.webhooks {
+		if keep(webhook) {
+			webhooks = append(webhooks, r.withDeliveryCounts(webhook))
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks
+}
+
+func (r *Repository) CreateWebhook(webhook domain.Webhook) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	webhook.ID = r.nextID("webhooks")
+	webhook.Events = slices.Clone(webhook.Events)
+	webhook.CreatedAt = time.Now()
+	r.webhooks[webhook.ID] = webhook
+	return webhook.ID, nil
+}
+
+func (r *Repository) GetWebhook(id int) (domain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	webhook, ok := r.webhooks[id]
+	if !ok {
+		return domain.Webhook{}, usecase.ErrNotFound
+	}
+	return r.withDeliveryCounts(webhook), nil
+}
+
+func (r *Repository) ListWebhooks(ownerToken string) ([]domain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.listWebhooks(func(webhook domain.Webhook) bool { return webhook.OwnerToken == ownerToken }), nil
+}
+
+func (r *Repository) ListWebhooksByPaths(paths []string) ([]domain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.listWebhooks(func(webhook domain.Webhook) bool { return slices.Contains(paths, webhook.Path) }), nil
+}
+
+// DeleteWebhook removes the webhook along with its queued deliveries
+func (r *Repository) DeleteWebhook(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for deliveryID, delivery := range r.deliveries {
+		if delivery.WebhookID == id {
+			delete(r.deliveries, deliveryID)
+		}
+	}
+	delete(r.webhooks, id)
+	return nil
+}
+
+func (r *Repository) CreateWebhookDeliveries(deliveries []domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, delivery := range deliveries {
+		id := r.nextID("webhook_deliveries")
+		r.deliveries[id] = domain.NewWebhookDelivery(id, delivery.WebhookID, delivery.Event, slices.Clone(delivery.Payload), 0, delivery.NextAttemptAt, "", domain.WebhookDeliveryPending, now)
+	}
+	return nil
+}
+
+// ListDueWebhookDeliveries returns the pending deliveries whose next attempt is due at now, oldest first
+func (r *Repository) ListDueWebhookDeliveries(now time.Time, limit int) ([]domain.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	deliveries := []domain.WebhookDelivery{}
+	for _, delivery := range r.deliveries {
+		if delivery.State == domain.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) {
+			delivery.Payload = slices.Clone(delivery.Payload)
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].NextAttemptAt.Equal(deliveries[j].NextAttemptAt) {
+			return deliveries[i].NextAttemptAt.Before(deliveries[j].NextAttemptAt)
+		}
+		return deliveries[i].ID < deliveries[j].ID
+	})
+	if limit >= 0 && limit < len(deliveries) {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (r *Repository) UpdateWebhookDelivery(delivery domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.deliveries[delivery.ID]
+	if !ok {
+		return nil
+	}
+	stored.Attempts = delivery.Attempts
+	stored.NextAttemptAt = delivery.NextAttemptAt
+	stored.LastError = delivery.LastError
+	stored.State = delivery.State
+	r.deliveries[delivery.ID] = stored
+	return nil
+}
+
+func (r *Repository) DeleteWebhookDelivery(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.deliveries, id)
+	return nil
+}
+
+// withRoomPath returns the webhook with the current path of its room, empty once the room is gone
+func (r *Repository) withRoomPath(webhook domain.IncomingWebhook) domain.IncomingWebhook {
+	webhook.RoomPath = ""
+	if room, ok := r.rooms[webhook.RoomID]; ok {
+		webhook.RoomPath = room.path
+	}
+	return webhook
+}
+
+func (r *Repository) CreateIncomingWebhook(webhook domain.IncomingWebhook) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.incomingWebhooks {
+		if existing.SecretHash == webhook.SecretHash {
+			return 0, usecase.ErrAlreadyExists
+		}
+	}
+	webhook.ID = r.nextID("incoming_webhooks")
+	webhook.Secret = ""
+	webhook.CreatedAt = time.Now()
+	webhook.LastUsedAt = time.Time{}
+	r.incomingWebhooks[webhook.ID] = webhook
+	return webhook.ID, nil
+}
+
+func (r *Repository) GetIncomingWebhook(id int) (domain.IncomingWebhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	webhook, ok := r.incomingWebhooks[id]
+	if !ok {
+		return domain.IncomingWebhook{}, usecase.ErrNotFound
+	}
+	return r.withRoomPath(webhook), nil
+}
+
+func (r *Repository) GetIncomingWebhookBySecretHash(secretHash string) (domain.IncomingWebhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, webhook := range r.incomingWebhooks {
+		if webhook.SecretHash == secretHash {
+			return r.withRoomPath(webhook), nil
+		}
+	}
+	return domain.IncomingWebhook{}, usecase.ErrNotFound
+}
+
+func (r *Repository) ListIncomingWebhooks(ownerToken string) ([]domain.IncomingWebhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	webhooks := []domain.IncomingWebhook{}
+	for _, webhook := range r.incomingWebhooks {
+		if webhook.OwnerToken == ownerToken {
+			webhooks = append(webhooks, r.withRoomPath(webhook))
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks, nil
+}
+
+func (r *Repository) UpdateIncomingWebhookSecret(id int, secretHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if webhook, ok := r.incomingWebhooks[id]; ok {
+		webhook.SecretHash = secretHash
+		r.incomingWebhooks[id] = webhook
+	}
+	return nil
+}
+
+func (r *Repository) UpdateIncomingWebhookUsed(id int, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if webhook, ok := r.incomingWebhooks[id]; ok {
+		webhook.LastUsedAt = usedAt
+		r.incomingWebhooks[id] = webhook
+	}
+	return nil
+}
+
+func (r *Repository) DeleteIncomingWebhook(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.incomingWebhooks, id)
+	return nil
+}
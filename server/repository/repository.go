@@ -3,6 +3,7 @@ package repository
 import (
 	"database/sql"
 	"path/filepath"
+	"strings"
 
 	"errors"
 	"fmt"
@@ -13,17 +14,42 @@ import (
 )
 
 type Repository struct {
-	db *sql.DB
+	db    *sql.DB
+	stmts *statements
+	// writer batches message inserts; nil when every message commits on its own
+	writer *messageWriter
 }
 
-func NewRepository(db *sql.DB) usecase.Repository {
-	return &Repository{db: db}
+func NewRepository(db *sql.DB, batch BatchOptions) *Repository {
+	stmts := newStatements(db)
+	r := &Repository{db: db, stmts: stmts}
+	if batch.Size > 1 {
+		r.writer = newMessageWriter(db, stmts, batch)
+	}
+	return r
+}
+
+// Close commits the messages still queued and releases the prepared statements.
+// The database itself stays open.
+func (r *Repository) Close() error {
+	if r.writer != nil {
+		r.writer.close()
+	}
+	return r.stmts.close()
+}
+
+// conflictError reports violations of the unique path constraints as usecase.ErrAlreadyExists
+func conflictError(err error) error {
+	if isUniqueViolation(err) {
+		return usecase.ErrAlreadyExists
+	}
+	return err
 }
 
 func (r *Repository) CheckDirectoryExists(path domain.Path) (bool, error) {
 	query := "SELECT EXISTS(SELECT 1 FROM directories WHERE path = ?)"
 	var exists bool
-	err := r.db.QueryRow(query, path.String()).Scan(&exists)
+	err := r.queryRow(query, path.String()).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("error checking directory existence: %w", err)
 	}
@@ -34,7 +60,7 @@ func (r *Repository) GetConfig(ownerToken string) (domain.Config, error) {
 	query := "SELECT display_name FROM users WHERE token = ?"
 	var config domain.Config
 	config.OwnerToken = ownerToken
-	if err := r.db.QueryRow(query, config.OwnerToken).Scan(&config.DisplayName); err != nil {
+	if err := r.queryRow(query, config.OwnerToken).Scan(&config.DisplayName); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return domain.Config{}, usecase.ErrNotFound
 		}
@@ -52,7 +78,7 @@ func (r *Repository) CreateConfig(config domain.Config) error {
 			display_name = EXCLUDED.display_name,
 			created_at = EXCLUDED.created_at
 	`
-	if _, err := r.db.Exec(query, config.OwnerToken, config.DisplayName, time.Now()); err != nil {
+	if _, err := r.exec(query, config.OwnerToken, config.DisplayName, time.Now()); err != nil {
 		return fmt.Errorf("error inserting config: %w", err)
 	}
 	return nil
@@ -85,7 +111,7 @@ func (r *Repository) GetNodeByPath(path domain.Path) (domain.Node, error) {
 	var nodeID int
 	var ownerToken, displayName string
 	var createdAt time.Time
-	if err := r.db.QueryRow(query, path.String()).Scan(&nodeID, &nodeType, &ownerToken, &displayName, &createdAt); err != nil {
+	if err := r.queryRow(query, path.String()).Scan(&nodeID, &nodeType, &ownerToken, &displayName, &createdAt); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return domain.Node{}, usecase.ErrNotFound
 		}
@@ -125,8 +151,9 @@ func (r *Repository) ListNodes(parentDirID int) ([]domain.Node, error) {
 		FROM rooms r
 		JOIN users u ON r.owner_token = u.token
 		WHERE directory_id = $1
+		ORDER BY type, name
 	`
-	rows, err := r.db.Query(query, parentDirID)
+	rows, err := r.query(query, parentDirID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query subdirectories for %d: %w", parentDirID, err)
 	}
@@ -160,27 +187,61 @@ func (r *Repository) ListNodes(parentDirID int) ([]domain.Node, error) {
 func (r *Repository) CreateDirectory(parentDirID int, parentPath string, name string, ownerToken string) error {
 	newPath := filepath.Join(parentPath, name)
 	query := "INSERT INTO directories (name, parent_id, owner_token, path, created_at) VALUES (?, ?, ?, ?, ?)"
-	if _, err := r.db.Exec(query, name, parentDirID, ownerToken, newPath, time.Now()); err != nil {
-		return fmt.Errorf("failed to insert directory '%s': %w", name, err)
+	if _, err := r.exec(query, name, parentDirID, ownerToken, newPath, time.Now()); err != nil {
+		return fmt.Errorf("failed to insert directory '%s': %w", name, conflictError(err))
 	}
 	return nil
 }
 
 // For Empty Directory
 func (r *Repository) DeleteDirectory(dirID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM retention_policies WHERE node_type = ? AND node_id = ?", domain.NodeTypeDirectory, dirID); err != nil {
+		return fmt.Errorf("failed to delete retention policy for directory %d: %w", dirID, err)
+	}
 	query := "DELETE FROM directories WHERE id = ?"
-	if _, err := r.db.Exec(query, dirID); err != nil {
+	if _, err := tx.Exec(query, dirID); err != nil {
 		return fmt.Errorf("failed to delete directory %d: %w", dirID, err)
 	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
 	return nil
 }
 
 // For Empty Directory
+// UpdateDirectory moves the directory along with everything below it
 func (r *Repository) UpdateDirectory(srcDirID, dstDirID int, dstDirPath string, name string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	var oldPath string
+	if err := tx.QueryRow("SELECT path FROM directories WHERE id = ?", srcDirID).Scan(&oldPath); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return fmt.Errorf("failed to get directory path: %w", err)
+	}
 	query := "UPDATE directories SET parent_id = ?, name = ?, path = ? WHERE id = ?"
 	newPath := filepath.Join(dstDirPath, name)
-	if _, err := r.db.Exec(query, dstDirID, name, newPath, srcDirID); err != nil {
-		return fmt.Errorf("failed to update directory path: %w", err)
+	if _, err := tx.Exec(query, dstDirID, name, newPath, srcDirID); err != nil {
+		return fmt.Errorf("failed to update directory path: %w", conflictError(err))
+	}
+	// Descendants are the paths between oldPath + "/" and oldPath + "0", the next character
+	for _, table := range []string{"directories", "rooms"} {
+		query := "UPDATE " + table + " SET path = ? || substr(path, ?) WHERE path > ? AND path < ?"
+		if _, err := tx.Exec(query, newPath, len(oldPath)+1, oldPath+"/", oldPath+"0"); err != nil {
+			return fmt.Errorf("failed to update %s below %s: %w", table, oldPath, conflictError(err))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 	return nil
 }
@@ -188,8 +249,8 @@ func (r *Repository) UpdateDirectory(srcDirID, dstDirID int, dstDirPath string,
 func (r *Repository) CreateRoom(parentDirID int, parentDirPath, name, ownerToken string) error {
 	newPath := filepath.Join(parentDirPath, name)
 	query := "INSERT INTO rooms (name, directory_id, path, owner_token, created_at) VALUES (?, ?, ?, ?, ?)"
-	if _, err := r.db.Exec(query, name, parentDirID, newPath, ownerToken, time.Now()); err != nil {
-		return fmt.Errorf("failed to insert room '%s': %w", name, err)
+	if _, err := r.exec(query, name, parentDirID, newPath, ownerToken, time.Now()); err != nil {
+		return fmt.Errorf("failed to insert room '%s': %w", name, conflictError(err))
 	}
 	return nil
 }
@@ -204,7 +265,7 @@ func (r *Repository) CreateExistRoom(roomID, dstDirID int, dstDirPath, name, own
 	query := "INSERT INTO rooms (name, directory_id, path, owner_token, created_at) VALUES (?, ?, ?, ?, ?)"
 	result, err := tx.Exec(query, name, dstDirID, newPath, ownerToken, time.Now())
 	if err != nil {
-		return fmt.Errorf("failed to insert room '%s': %w", name, err)
+		return fmt.Errorf("failed to insert room '%s': %w", name, conflictError(err))
 	}
 	newRoomID, err := result.LastInsertId()
 	if err != nil {
@@ -226,12 +287,10 @@ func (r *Repository) DeleteRoom(roomID int) error {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
-	query := "DELETE FROM messages WHERE room_id = ?"
-	_, err = tx.Exec(query, roomID)
-	if err != nil {
-		return fmt.Errorf("failed to delete messages for room %d: %w", roomID, err)
+	if err := deleteRoomData(tx, roomID); err != nil {
+		return err
 	}
-	query = "DELETE FROM rooms WHERE id = ?"
+	query := "DELETE FROM rooms WHERE id = ?"
 	_, err = tx.Exec(query, roomID)
 	if err != nil {
 		return fmt.Errorf("failed to delete room %d: %w", roomID, err)
@@ -242,26 +301,65 @@ func (r *Repository) DeleteRoom(roomID int) error {
 	return nil
 }
 
+// deleteRoomData deletes what refers to the room: its messages, mentions, read markers,
+// moderation data, import keys and retention policy
+func deleteRoomData(tx *sql.Tx, roomID int) error {
+	query := "DELETE FROM messages WHERE room_id = ?"
+	_, err := tx.Exec(query, roomID)
+	if err != nil {
+		return fmt.Errorf("failed to delete messages for room %d: %w", roomID, err)
+	}
+	query = "DELETE FROM mentions WHERE room_id = ?"
+	_, err = tx.Exec(query, roomID)
+	if err != nil {
+		return fmt.Errorf("failed to delete mentions for room %d: %w", roomID, err)
+	}
+	query = "DELETE FROM read_markers WHERE room_id = ?"
+	_, err = tx.Exec(query, roomID)
+	if err != nil {
+		return fmt.Errorf("failed to delete read markers for room %d: %w", roomID, err)
+	}
+	for _, table := range []string{"room_settings", "room_restrictions", "moderation_logs", "message_imports"} {
+		if _, err := tx.Exec("DELETE FROM "+table+" WHERE room_id = ?", roomID); err != nil {
+			return fmt.Errorf("failed to delete %s for room %d: %w", table, roomID, err)
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM retention_policies WHERE node_type = ? AND node_id = ?", domain.NodeTypeRoom, roomID); err != nil {
+		return fmt.Errorf("failed to delete retention policy for room %d: %w", roomID, err)
+	}
+	return nil
+}
+
 func (r *Repository) UpdateRoom(srcRoomID, dstDirID int, dstDirPath, name string) error {
 	newPath := filepath.Join(dstDirPath, name)
 	query := "UPDATE rooms SET directory_id = ?, name = ?, path = ? WHERE id = ?"
-	if _, err := r.db.Exec(query, dstDirID, name, newPath, srcRoomID); err != nil {
-		return fmt.Errorf("failed to update room path for %d: %w", srcRoomID, err)
+	if _, err := r.exec(query, dstDirID, name, newPath, srcRoomID); err != nil {
+		return fmt.Errorf("failed to update room path for %d: %w", srcRoomID, conflictError(err))
 	}
 	return nil
 }
 
-func (r *Repository) CreateMessage(roomID int, displayName, message string) error {
-	query := "INSERT INTO messages (room_id, display_name, content, created_at) VALUES (?, ?, ?, ?)"
-	if _, err := r.db.Exec(query, roomID, displayName, message, time.Now()); err != nil {
-		return fmt.Errorf("failed to insert message for room %d: %w", roomID, err)
+// CreateMessage returns the id of the new message once it is committed
+func (r *Repository) CreateMessage(roomID int, displayName, message string) (int, error) {
+	// The time is taken before queueing, as a batched message commits a moment later
+	createdAt := time.Now()
+	if r.writer != nil {
+		return r.writer.write(roomID, displayName, message, createdAt)
 	}
-	return nil
+	result, err := r.exec(insertMessageQuery, roomID, displayName, message, createdAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert message for room %d: %w", roomID, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get message id: %w", err)
+	}
+	return int(id), nil
 }
 
 func (r *Repository) ListMessages(roomID, limit, offset int) ([]domain.Message, error) {
 	query := "SELECT id, display_name, content, created_at FROM messages WHERE room_id = ? ORDER BY created_at DESC LIMIT ? OFFSET ?"
-	rows, err := r.db.Query(query, roomID, limit, offset)
+	rows, err := r.query(query, roomID, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query messages for room %d: %w", roomID, err)
 	}
@@ -284,9 +382,110 @@ func (r *Repository) ListMessages(roomID, limit, offset int) ([]domain.Message,
 	return messages, nil
 }
 
+// ListMessagesAfter returns at most limit messages of the room written after the message
+// afterID, in the order they were written
+func (r *Repository) ListMessagesAfter(roomID, afterID, limit int) ([]domain.Message, error) {
+	query := "SELECT id, display_name, content, created_at FROM messages WHERE room_id = ? AND id > ? ORDER BY id LIMIT ?"
+	rows, err := r.query(query, roomID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages for room %d: %w", roomID, err)
+	}
+	defer rows.Close()
+
+	var id int
+	var content, displayName string
+	var createdAt time.Time
+	messages := []domain.Message{}
+	for rows.Next() {
+		if err := rows.Scan(&id, &displayName, &content, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan message content: %w", err)
+		}
+		messages = append(messages, domain.NewMessage(id, roomID, displayName, content, createdAt))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over messages for room %d: %w", roomID, err)
+	}
+	return messages, nil
+}
+
+// CreateMessages stores messages written elsewhere in one transaction, keeping their authors
+// and times; the room gets new ids for them
+func (r *Repository) CreateMessages(roomID int, messages []domain.Message) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(insertMessageQuery)
+	if err != nil {
+		return fmt.Errorf("failed to prepare message insert: %w", err)
+	}
+	defer stmt.Close()
+	for _, message := range messages {
+		if _, err := stmt.Exec(roomID, message.DisplayName, message.Content, message.CreatedAt); err != nil {
+			return fmt.Errorf("failed to insert message for room %d: %w", roomID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) ImportMessages(roomID int, messages []domain.ImportedMessage) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	var latest int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(id), 0) FROM messages WHERE room_id = ?", roomID).Scan(&latest); err != nil {
+		return 0, fmt.Errorf("failed to get latest message of room %d: %w", roomID, err)
+	}
+	imported := 0
+	for _, message := range messages {
+		var seen int
+		err := tx.QueryRow("SELECT 1 FROM message_imports WHERE room_id = ? AND import_key = ?", roomID, message.Key).Scan(&seen)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("failed to look up import key for room %d: %w", roomID, err)
+		}
+		result, err := tx.Exec(insertMessageQuery, roomID, message.DisplayName, message.Content, message.CreatedAt)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert message for room %d: %w", roomID, err)
+		}
+		messageID, err := result.LastInsertId()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get id of message for room %d: %w", roomID, err)
+		}
+		if _, err := tx.Exec("INSERT INTO message_imports (room_id, import_key, message_id) VALUES (?, ?, ?)", roomID, message.Key, messageID); err != nil {
+			return 0, fmt.Errorf("failed to record import key for room %d: %w", roomID, err)
+		}
+		imported++
+	}
+	// Imported history is older than what the readers have seen, though its ids are newer: those
+	// who had read the whole room still have
+	if imported > 0 {
+		query := `
+			UPDATE read_markers
+			SET last_message_id = (SELECT MAX(id) FROM messages WHERE room_id = ?), updated_at = ?
+			WHERE room_id = ? AND last_message_id >= ?
+		`
+		if _, err := tx.Exec(query, roomID, time.Now(), roomID, latest); err != nil {
+			return 0, fmt.Errorf("failed to move read markers of room %d: %w", roomID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return imported, nil
+}
+
 func (r *Repository) ListMessagesByQuery(roomID int, pattern string) ([]domain.Message, error) {
-	query := "SELECT * FROM messages WHERE room_id = ? AND content REGEXP ? ORDER BY created_at"
-	rows, err := r.db.Query(query, roomID, pattern)
+	query := "SELECT id, display_name, content, created_at FROM messages WHERE room_id = ? AND content REGEXP ? ORDER BY created_at"
+	rows, err := r.query(query, roomID, pattern)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute search in room %d for query '%s': %w", roomID, pattern, err)
 	}
@@ -297,7 +496,7 @@ func (r *Repository) ListMessagesByQuery(roomID int, pattern string) ([]domain.M
 	var content, displayName string
 	var createdAt time.Time
 	for rows.Next() {
-		if err := rows.Scan(&content, &id, &displayName, &content, &createdAt); err != nil {
+		if err := rows.Scan(&id, &displayName, &content, &createdAt); err != nil {
 			return nil, fmt.Errorf("failed to scan message content: %w", err)
 		}
 		messages = append(messages, domain.NewMessage(id, roomID, displayName, content, createdAt))
@@ -307,3 +506,278 @@ func (r *Repository) ListMessagesByQuery(roomID int, pattern string) ([]domain.M
 	}
 	return messages, nil
 }
+
+func (r *Repository) CreateMentions(roomID int, userNames []string, senderName, message string) error {
+	if len(userNames) == 0 {
+		return nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(userNames)), ", ")
+	query := `
+		INSERT INTO mentions (user_token, room_id, sender_name, content, created_at)
+		SELECT token, ?, ?, ?, ? FROM users WHERE display_name IN (` + placeholders + `)
+	`
+	args := []any{roomID, senderName, message, time.Now()}
+	for _, name := range userNames {
+		args = append(args, name)
+	}
+	if _, err := r.db.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to insert mentions for room %d: %w", roomID, err)
+	}
+	return nil
+}
+
+func (r *Repository) ListMentions(ownerToken string, unreadOnly bool) ([]domain.Mention, error) {
+	query := `
+		SELECT m.id, m.room_id, r.path, m.sender_name, m.content, m.is_read, m.created_at
+		FROM mentions m
+		JOIN rooms r ON m.room_id = r.id
+		WHERE m.user_token = ? AND (? = FALSE OR m.is_read = FALSE)
+		ORDER BY m.created_at
+	`
+	rows, err := r.query(query, ownerToken, unreadOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query mentions: %w", err)
+	}
+	defer rows.Close()
+
+	var id, roomID int
+	var roomPath, senderName, content string
+	var isRead bool
+	var createdAt time.Time
+	mentions := []domain.Mention{}
+	for rows.Next() {
+		if err := rows.Scan(&id, &roomID, &roomPath, &senderName, &content, &isRead, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan mention: %w", err)
+		}
+		mentions = append(mentions, domain.NewMention(id, ownerToken, roomID, roomPath, senderName, content, isRead, createdAt))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over mentions: %w", err)
+	}
+	return mentions, nil
+}
+
+// Marks all mentions of the user when mentionIDs is empty
+func (r *Repository) UpdateMentionsRead(ownerToken string, mentionIDs []int) error {
+	query := "UPDATE mentions SET is_read = TRUE WHERE user_token = ?"
+	args := []any{ownerToken}
+	if len(mentionIDs) > 0 {
+		query += " AND id IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(mentionIDs)), ", ") + ")"
+		for _, id := range mentionIDs {
+			args = append(args, id)
+		}
+	}
+	if _, err := r.db.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to mark mentions as read: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) GetReadMarker(ownerToken string, roomID int) (int, error) {
+	query := "SELECT last_message_id FROM read_markers WHERE user_token = ? AND room_id = ?"
+	var lastMessageID int
+	if err := r.queryRow(query, ownerToken, roomID).Scan(&lastMessageID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("error querying read marker for room %d: %w", roomID, err)
+	}
+	return lastMessageID, nil
+}
+
+// Marks up to the latest message of the room when messageID is 0. The marker only moves
+// forward, so that a client marking an older message does not unread the newer ones.
+func (r *Repository) UpsertReadMarker(ownerToken string, roomID, messageID int) error {
+	query := `
+		INSERT INTO read_markers (user_token, room_id, last_message_id, updated_at)
+		SELECT ?, ?, CASE WHEN ? > 0 THEN ? ELSE COALESCE(MAX(id), 0) END, ?
+		FROM messages WHERE room_id = ?
+		ON CONFLICT (user_token, room_id)
+		DO UPDATE SET
+			last_message_id = MAX(read_markers.last_message_id, EXCLUDED.last_message_id),
+			updated_at = EXCLUDED.updated_at
+	`
+	if _, err := r.exec(query, ownerToken, roomID, messageID, messageID, time.Now(), roomID); err != nil {
+		return fmt.Errorf("failed to update read marker for room %d: %w", roomID, err)
+	}
+	return nil
+}
+
+func (r *Repository) CountUnreadMessages(ownerToken string, roomIDs []int) (map[int]int, error) {
+	counts := make(map[int]int, len(roomIDs))
+	if len(roomIDs) == 0 {
+		return counts, nil
+	}
+	query := `
+		SELECT m.room_id, COUNT(*)
+		FROM messages m
+		LEFT JOIN read_markers rm ON rm.room_id = m.room_id AND rm.user_token = ?
+		WHERE m.room_id IN (` + strings.TrimSuffix(strings.Repeat("?, ", len(roomIDs)), ", ") + `)
+		AND m.id > COALESCE(rm.last_message_id, 0)
+		GROUP BY m.room_id
+	`
+	args := []any{ownerToken}
+	for _, id := range roomIDs {
+		args = append(args, id)
+	}
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count unread messages: %w", err)
+	}
+	defer rows.Close()
+
+	var roomID, count int
+	for rows.Next() {
+		if err := rows.Scan(&roomID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan unread count: %w", err)
+		}
+		counts[roomID] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over unread counts: %w", err)
+	}
+	return counts, nil
+}
+
+func (r *Repository) GetTokensByDisplayName(displayName string) ([]string, error) {
+	query := "SELECT token FROM users WHERE display_name = ?"
+	rows, err := r.query(query, displayName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users named '%s': %w", displayName, err)
+	}
+	defer rows.Close()
+
+	var tokens []string
+	for rows.Next() {
+		var token string
+		if err := rows.Scan(&token); err != nil {
+			return nil, fmt.Errorf("failed to scan user token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over users named '%s': %w", displayName, err)
+	}
+	return tokens, nil
+}
+
+func (r *Repository) CreateRoomRestriction(roomID int, userToken string, restrictionType domain.RestrictionType, createdBy string) error {
+	query := `
+		INSERT INTO room_restrictions (room_id, user_token, kind, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (room_id, user_token, kind) DO NOTHING
+	`
+	if _, err := r.exec(query, roomID, userToken, restrictionType, createdBy, time.Now()); err != nil {
+		return fmt.Errorf("failed to insert restriction for room %d: %w", roomID, err)
+	}
+	return nil
+}
+
+func (r *Repository) DeleteRoomRestriction(roomID int, userToken string, restrictionType domain.RestrictionType) error {
+	query := "DELETE FROM room_restrictions WHERE room_id = ? AND user_token = ? AND kind = ?"
+	if _, err := r.exec(query, roomID, userToken, restrictionType); err != nil {
+		return fmt.Errorf("failed to delete restriction for room %d: %w", roomID, err)
+	}
+	return nil
+}
+
+func (r *Repository) HasRoomRestriction(roomID int, userToken string, restrictionType domain.RestrictionType) (bool, error) {
+	query := "SELECT EXISTS(SELECT 1 FROM room_restrictions WHERE room_id = ? AND user_token = ? AND kind = ?)"
+	var exists bool
+	if err := r.queryRow(query, roomID, userToken, restrictionType).Scan(&exists); err != nil {
+		return false, fmt.Errorf("error checking restriction for room %d: %w", roomID, err)
+	}
+	return exists, nil
+}
+
+func (r *Repository) ListRoomRestrictions(roomID int) ([]domain.RoomRestriction, error) {
+	query := `
+		SELECT rr.user_token, u.display_name, rr.kind, rr.created_at
+		FROM room_restrictions rr
+		JOIN users u ON rr.user_token = u.token
+		WHERE rr.room_id = ?
+		ORDER BY rr.created_at
+	`
+	rows, err := r.query(query, roomID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query restrictions for room %d: %w", roomID, err)
+	}
+	defer rows.Close()
+
+	var userToken, displayName string
+	var restrictionType domain.RestrictionType
+	var createdAt time.Time
+	restrictions := []domain.RoomRestriction{}
+	for rows.Next() {
+		if err := rows.Scan(&userToken, &displayName, &restrictionType, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan restriction: %w", err)
+		}
+		restrictions = append(restrictions, domain.NewRoomRestriction(roomID, userToken, displayName, restrictionType, createdAt))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over restrictions for room %d: %w", roomID, err)
+	}
+	return restrictions, nil
+}
+
+func (r *Repository) GetRoomSettings(roomID int) (domain.RoomSettings, error) {
+	query := "SELECT slow_mode_seconds, max_message_length, max_message_lines FROM room_settings WHERE room_id = ?"
+	settings := domain.NewRoomSettings(roomID, 0, 0, 0)
+	if err := r.queryRow(query, roomID).Scan(&settings.SlowModeSeconds, &settings.MaxMessageLength, &settings.MaxMessageLines); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return settings, nil
+		}
+		return domain.RoomSettings{}, fmt.Errorf("error querying settings for room %d: %w", roomID, err)
+	}
+	return settings, nil
+}
+
+func (r *Repository) UpsertRoomSettings(settings domain.RoomSettings) error {
+	query := `
+		INSERT INTO room_settings (room_id, slow_mode_seconds, max_message_length, max_message_lines, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (room_id)
+		DO UPDATE SET
+			slow_mode_seconds = EXCLUDED.slow_mode_seconds,
+			max_message_length = EXCLUDED.max_message_length,
+			max_message_lines = EXCLUDED.max_message_lines,
+			updated_at = EXCLUDED.updated_at
+	`
+	if _, err := r.exec(query, settings.RoomID, settings.SlowModeSeconds, settings.MaxMessageLength, settings.MaxMessageLines, time.Now()); err != nil {
+		return fmt.Errorf("failed to update settings for room %d: %w", settings.RoomID, err)
+	}
+	return nil
+}
+
+func (r *Repository) CreateModerationLog(log domain.ModerationLog) error {
+	query := "INSERT INTO moderation_logs (room_id, actor_name, action, target_name, detail, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+	if _, err := r.exec(query, log.RoomID, log.ActorName, log.Action, log.TargetName, log.Detail, time.Now()); err != nil {
+		return fmt.Errorf("failed to insert moderation log for room %d: %w", log.RoomID, err)
+	}
+	return nil
+}
+
+func (r *Repository) ListModerationLogs(roomID, limit int) ([]domain.ModerationLog, error) {
+	query := "SELECT id, actor_name, action, target_name, detail, created_at FROM moderation_logs WHERE room_id = ? ORDER BY created_at DESC LIMIT ?"
+	rows, err := r.query(query, roomID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query moderation logs for room %d: %w", roomID, err)
+	}
+	defer rows.Close()
+
+	var id int
+	var actorName, targetName, detail string
+	var action domain.ModerationAction
+	var createdAt time.Time
+	logs := []domain.ModerationLog{}
+	for rows.Next() {
+		if err := rows.Scan(&id, &actorName, &action, &targetName, &detail, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan moderation log: %w", err)
+		}
+		logs = append(logs, domain.NewModerationLog(id, roomID, actorName, action, targetName, detail, createdAt))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over moderation logs for room %d: %w", roomID, err)
+	}
+	return logs, nil
+}
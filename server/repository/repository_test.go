@@ -0,0 +1,96 @@
+package repository_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ponyo877/chatsh/schema"
+	"github.com/ponyo877/chatsh/server/repository"
+	"github.com/ponyo877/chatsh/server/repository/cache"
+	"github.com/ponyo877/chatsh/server/repository/repotest"
+)
+
+// newRepository returns an empty repository in a database of its own, set up like the server's
+func newRepository(t testing.TB, wal bool, batch repository.BatchOptions) *repository.Repository {
+	t.Helper()
+	pragmas := []repository.Pragma{{Name: "busy_timeout", Value: "5000"}}
+	if wal {
+		pragmas = append(pragmas, repository.Pragma{Name: "journal_mode", Value: "WAL"}, repository.Pragma{Name: "synchronous", Value: "NORMAL"})
+	}
+	dsn := repository.WithPragmas(filepath.Join(t.TempDir(), "chatsh.db"), pragmas)
+	conn, err := repository.OpenSQLite(dsn)
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	migrations, err := repository.LoadMigrations(schema.Migrations, "migrations")
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	if _, err := repository.NewMigrator(conn, migrations).Up(); err != nil {
+		t.Fatalf("migrating: %v", err)
+	}
+	rp := repository.NewRepository(conn, batch)
+	// Cleanups run last-in first-out, so the writer stops before the database closes
+	t.Cleanup(func() { rp.Close() })
+	return rp
+}
+
+func TestRepository(t *testing.T) {
+	repotest.TestRepository(t, newRepository(t, true, repository.NewBatchOptions(64, time.Millisecond)))
+}
+
+func TestRepositoryAutocommit(t *testing.T) {
+	repotest.TestRepository(t, newRepository(t, true, repository.NewBatchOptions(1, 0)))
+}
+
+func TestRepositoryPathCache(t *testing.T) {
+	repotest.TestRepository(t, cache.NewRepository(newRepository(t, true, repository.NewBatchOptions(64, time.Millisecond)), 1024))
+}
+
+// TestImmediateTransactions reads in a transaction while another connection writes, and then
+// writes itself. A deferred transaction fails there with SQLITE_BUSY, as its snapshot is stale;
+// WithPragmas makes it take the write lock when it begins, so that the other waits instead.
+func TestImmediateTransactions(t *testing.T) {
+	dsn := repository.WithPragmas(filepath.Join(t.TempDir(), "chatsh.db"), []repository.Pragma{
+		{Name: "busy_timeout", Value: "5000"},
+		{Name: "journal_mode", Value: "WAL"},
+	})
+	conn, err := repository.OpenSQLite(dsn)
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec("CREATE TABLE counters (n INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := conn.Begin()
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	defer tx.Rollback()
+	var n int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM counters").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	other := make(chan error, 1)
+	go func() {
+		_, err := conn.Exec("INSERT INTO counters VALUES (1)")
+		other <- err
+	}()
+	select {
+	case err := <-other:
+		t.Fatalf("another connection wrote during the transaction: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, err := tx.Exec("INSERT INTO counters VALUES (?)", n+1); err != nil {
+		t.Fatalf("writing after reading: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if err := <-other; err != nil {
+		t.Errorf("the other connection failed instead of waiting: %v", err)
+	}
+}
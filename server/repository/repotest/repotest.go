@@ -0,0 +1,1208 @@
+// Package repotest checks that a usecase.Repository behaves like the SQLite one. Every
+// implementation runs the same suite from its tests, so the usecase layer can rely on
+// identical semantics.
+package repotest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+	"github.com/ponyo877/chatsh/server/usecase"
+)
+
+// TestRepository runs the conformance suite against repo, which must be freshly created:
+// migrated and holding nothing but the initial directory tree. Every check is a subtest of t;
+// they share repo and run in order.
+func TestRepository(t *testing.T, repo usecase.Repository) {
+	s := &suite{repo: repo}
+	for _, c := range []struct {
+		name string
+		run  func(*suite)
+	}{
+		{"initial tree", (*suite).testInitialTree},
+		{"users", (*suite).testUsers},
+		{"paths", (*suite).testPaths},
+		{"subtree moves", (*suite).testSubtreeMoves},
+		{"owner renames", (*suite).testOwnerRenames},
+		{"concurrent moves", (*suite).testConcurrentMoves},
+		{"messages", (*suite).testMessages},
+		{"message pages", (*suite).testMessagePages},
+		{"message imports", (*suite).testMessageImports},
+		{"concurrent writes", (*suite).testConcurrentWrites},
+		{"copies", (*suite).testCopies},
+		{"mentions", (*suite).testMentions},
+		{"read markers", (*suite).testReadMarkers},
+		{"moderation", (*suite).testModeration},
+		{"room deletion", (*suite).testRoomDeletion},
+		{"retention", (*suite).testRetention},
+		{"trash", (*suite).testTrash},
+		{"webhooks", (*suite).testWebhooks},
+		{"incoming webhooks", (*suite).testIncomingWebhooks},
+		{"backup", (*suite).testBackup},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s.t = t
+			c.run(s)
+		})
+	}
+}
+
+type suite struct {
+	repo usecase.Repository
+	// t is the subtest of the running check; concurrent checks report through it from
+	// their goroutines, which end before the check returns
+	t *testing.T
+}
+
+func (s *suite) errorf(format string, args ...any) {
+	s.t.Helper()
+	s.t.Errorf(format, args...)
+}
+
+// ok records err and reports whether the step succeeded
+func (s *suite) ok(step string, err error) bool {
+	s.t.Helper()
+	if err != nil {
+		s.errorf("%s: %v", step, err)
+		return false
+	}
+	return true
+}
+
+func (s *suite) node(path string) (domain.Node, bool) {
+	node, err := s.repo.GetNodeByPath(domain.NewPath(path))
+	return node, s.ok("GetNodeByPath("+path+")", err)
+}
+
+func (s *suite) user(token, name string) {
+	s.ok("CreateConfig("+token+")", s.repo.CreateConfig(domain.NewConfig(name, token)))
+}
+
+// write stores a message and returns its id
+func (s *suite) write(roomID int, displayName, text string) int {
+	id, err := s.repo.CreateMessage(roomID, displayName, text)
+	s.ok("CreateMessage", err)
+	return id
+}
+
+// room creates a room below the existing directory dir and returns it
+func (s *suite) room(dir, name, ownerToken string) (domain.Node, bool) {
+	parent, ok := s.node(dir)
+	if !ok {
+		return domain.Node{}, false
+	}
+	if !s.ok("CreateRoom("+name+")", s.repo.CreateRoom(parent.ID, dir, name, ownerToken)) {
+		return domain.Node{}, false
+	}
+	return s.node(dir + "/" + name)
+}
+
+func (s *suite) directory(dir, name, ownerToken string) (domain.Node, bool) {
+	parent, ok := s.node(dir)
+	if !ok {
+		return domain.Node{}, false
+	}
+	if !s.ok("CreateDirectory("+name+")", s.repo.CreateDirectory(parent.ID, dir, name, ownerToken)) {
+		return domain.Node{}, false
+	}
+	return s.node(dir + "/" + name)
+}
+
+func (s *suite) messageTexts(roomID, limit, offset int) []string {
+	messages, err := s.repo.ListMessages(roomID, limit, offset)
+	if !s.ok("ListMessages", err) {
+		return nil
+	}
+	texts := make([]string, len(messages))
+	for i, message := range messages {
+		texts[i] = message.Content
+	}
+	return texts
+}
+
+func nodeNames(nodes []domain.Node) []string {
+	names := make([]string, len(nodes))
+	for i, node := range nodes {
+		names[i] = node.Name
+	}
+	return names
+}
+
+func (s *suite) testInitialTree() {
+	root, ok := s.node("/")
+	if !ok {
+		return
+	}
+	if root.Type != domain.NodeTypeDirectory || root.OwnerToken != "admin" || root.OwnerName != "Administrator" {
+		s.errorf("root is %+v, want a directory owned by admin (Administrator)", root)
+	}
+	children, err := s.repo.ListNodes(root.ID)
+	if s.ok("ListNodes(/)", err) {
+		want := []string{"boot", "dev", "etc", "home", "lost+found", "media", "mnt", "opt", "proc", "root", "run", "snap", "srv", "sys", "tmp", "usr", "var"}
+		if got := nodeNames(children); !slices.Equal(got, want) {
+			s.errorf("ListNodes(/) = %v, want %v", got, want)
+		}
+	}
+	if exists, err := s.repo.CheckDirectoryExists(domain.NewPath("/home/chatsh")); s.ok("CheckDirectoryExists", err) && !exists {
+		s.errorf("/home/chatsh does not exist")
+	}
+	if _, err := s.repo.GetNodeByPath(domain.NewPath("/nowhere")); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("GetNodeByPath(/nowhere) = %v, want ErrNotFound", err)
+	}
+}
+
+func (s *suite) testUsers() {
+	if _, err := s.repo.GetConfig("tokA"); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("GetConfig of an unknown token = %v, want ErrNotFound", err)
+	}
+	s.user("tokA", "alice")
+	s.user("tokB", "bobby")
+	s.user("tokB", "bob")
+	s.user("tokC", "carol")
+	s.user("tokC2", "carol")
+	if config, err := s.repo.GetConfig("tokB"); s.ok("GetConfig", err) && config.DisplayName != "bob" {
+		s.errorf("CreateConfig did not rename tokB: %+v", config)
+	}
+	tokens, err := s.repo.GetTokensByDisplayName("carol")
+	sort.Strings(tokens)
+	if s.ok("GetTokensByDisplayName", err) && !slices.Equal(tokens, []string{"tokC", "tokC2"}) {
+		s.errorf("GetTokensByDisplayName(carol) = %v", tokens)
+	}
+	if tokens, _ := s.repo.GetTokensByDisplayName("nobody"); len(tokens) != 0 {
+		s.errorf("GetTokensByDisplayName(nobody) = %v", tokens)
+	}
+}
+
+func (s *suite) testPaths() {
+	dir, ok := s.directory("/tmp", "paths", "tokA")
+	if !ok {
+		return
+	}
+	if dir.Name != "paths" || dir.Type != domain.NodeTypeDirectory || dir.OwnerName != "alice" {
+		s.errorf("created directory is %+v", dir)
+	}
+	tmp, _ := s.node("/tmp")
+	if err := s.repo.CreateDirectory(tmp.ID, "/tmp", "paths", "tokB"); !errors.Is(err, usecase.ErrAlreadyExists) {
+		s.errorf("duplicate directory: %v, want ErrAlreadyExists", err)
+	}
+	room, ok := s.room("/tmp/paths", "r1", "tokB")
+	if !ok {
+		return
+	}
+	if room.Type != domain.NodeTypeRoom || room.OwnerToken != "tokB" || room.OwnerName != "bob" {
+		s.errorf("created room is %+v", room)
+	}
+	if err := s.repo.CreateRoom(dir.ID, "/tmp/paths", "r1", "tokA"); !errors.Is(err, usecase.ErrAlreadyExists) {
+		s.errorf("duplicate room: %v, want ErrAlreadyExists", err)
+	}
+	if exists, _ := s.repo.CheckDirectoryExists(domain.NewPath("/tmp/paths/r1")); exists {
+		s.errorf("CheckDirectoryExists is true for a room")
+	}
+	s.directory("/tmp/paths", "sub", "tokA")
+	s.room("/tmp/paths", "r2", "tokA")
+	s.room("/tmp/paths", "a0", "tokA")
+	nodes, err := s.repo.ListNodes(dir.ID)
+	if s.ok("ListNodes", err) {
+		if got := nodeNames(nodes); !slices.Equal(got, []string{"sub", "a0", "r1", "r2"}) {
+			s.errorf("ListNodes = %v, want directories first, then rooms, each by name", got)
+		}
+	}
+	if nodes, err := s.repo.ListNodes(-1); s.ok("ListNodes(-1)", err) && len(nodes) != 0 {
+		s.errorf("ListNodes of a missing directory = %v", nodeNames(nodes))
+	}
+
+	// Moves keep the id and fail on taken names
+	sub, _ := s.node("/tmp/paths/sub")
+	if err := s.repo.UpdateRoom(room.ID, sub.ID, "/tmp/paths/sub", "moved"); s.ok("UpdateRoom", err) {
+		if moved, ok := s.node("/tmp/paths/sub/moved"); ok && moved.ID != room.ID {
+			s.errorf("moved room has id %d, want %d", moved.ID, room.ID)
+		}
+		if _, err := s.repo.GetNodeByPath(domain.NewPath("/tmp/paths/r1")); !errors.Is(err, usecase.ErrNotFound) {
+			s.errorf("old room path still resolves: %v", err)
+		}
+	}
+	r2, _ := s.node("/tmp/paths/r2")
+	if err := s.repo.UpdateRoom(r2.ID, sub.ID, "/tmp/paths/sub", "moved"); !errors.Is(err, usecase.ErrAlreadyExists) {
+		s.errorf("move onto a taken room name: %v, want ErrAlreadyExists", err)
+	}
+	if err := s.repo.UpdateDirectory(sub.ID, tmp.ID, "/tmp", "paths-sub"); s.ok("UpdateDirectory", err) {
+		if renamed, ok := s.node("/tmp/paths-sub"); ok && renamed.ID != sub.ID {
+			s.errorf("moved directory has id %d, want %d", renamed.ID, sub.ID)
+		}
+	}
+	if err := s.repo.UpdateDirectory(sub.ID, tmp.ID, "/tmp", "paths"); !errors.Is(err, usecase.ErrAlreadyExists) {
+		s.errorf("move onto a taken directory name: %v, want ErrAlreadyExists", err)
+	}
+
+	empty, _ := s.directory("/tmp", "empty", "tokA")
+	if s.ok("DeleteDirectory", s.repo.DeleteDirectory(empty.ID)) {
+		if _, err := s.repo.GetNodeByPath(domain.NewPath("/tmp/empty")); !errors.Is(err, usecase.ErrNotFound) {
+			s.errorf("deleted directory still resolves: %v", err)
+		}
+	}
+	// Paths of deleted nodes can be taken again, with fresh ids
+	if again, ok := s.directory("/tmp", "empty", "tokA"); ok && again.ID == empty.ID {
+		s.errorf("directory id %d was reused", empty.ID)
+	}
+}
+
+// testSubtreeMoves checks that a directory takes everything below it along, whether or not
+// the directory itself was looked up before
+func (s *suite) testSubtreeMoves() {
+	tmp, ok := s.node("/tmp")
+	if !ok {
+		return
+	}
+	for _, resolveDirectory := range []bool{true, false} {
+		name := fmt.Sprintf("tree-%t", resolveDirectory)
+		dir, ok := s.directory("/tmp", name, "tokA")
+		if !ok {
+			return
+		}
+		sub, _ := s.directory("/tmp/"+name, "sub", "tokA")
+		room, _ := s.room("/tmp/"+name+"/sub", "room", "tokA")
+		// Resolve the paths about to change, as callers do before a move
+		s.node("/tmp/" + name + "/sub/room")
+		s.node("/tmp/" + name + "/sub")
+		if resolveDirectory {
+			s.node("/tmp/" + name)
+		}
+		if !s.ok("UpdateDirectory", s.repo.UpdateDirectory(dir.ID, tmp.ID, "/tmp", name+"-moved")) {
+			return
+		}
+		for _, old := range []string{"", "/sub", "/sub/room"} {
+			if _, err := s.repo.GetNodeByPath(domain.NewPath("/tmp/" + name + old)); !errors.Is(err, usecase.ErrNotFound) {
+				s.errorf("/tmp/%s%s still resolves after the move: %v", name, old, err)
+			}
+		}
+		for path, want := range map[string]domain.Node{"": dir, "/sub": sub, "/sub/room": room} {
+			if moved, ok := s.node("/tmp/" + name + "-moved" + path); ok && (moved.ID != want.ID || moved.Type != want.Type) {
+				s.errorf("/tmp/%s-moved%s is %+v, want %+v", name, path, moved, want)
+			}
+		}
+		if exists, _ := s.repo.CheckDirectoryExists(domain.NewPath("/tmp/" + name + "-moved/sub")); !exists {
+			s.errorf("CheckDirectoryExists does not see the moved subdirectory")
+		}
+		if sub, ok := s.node("/tmp/" + name + "-moved/sub"); ok {
+			if nodes, _ := s.repo.ListNodes(sub.ID); len(nodes) != 1 || nodes[0].ID != room.ID {
+				s.errorf("ListNodes of the moved subdirectory = %+v", nodes)
+			}
+		}
+	}
+	// A directory whose name merely starts like the moved one stays put
+	s.directory("/tmp", "prefix", "tokA")
+	s.directory("/tmp", "prefix2", "tokA")
+	s.room("/tmp/prefix2", "room", "tokA")
+	prefix, _ := s.node("/tmp/prefix")
+	s.ok("UpdateDirectory", s.repo.UpdateDirectory(prefix.ID, tmp.ID, "/tmp", "prefix-moved"))
+	s.node("/tmp/prefix2/room")
+}
+
+func (s *suite) testOwnerRenames() {
+	s.user("tokD", "dave")
+	if _, ok := s.room("/tmp", "daves", "tokD"); !ok {
+		return
+	}
+	s.user("tokD", "david")
+	if room, ok := s.node("/tmp/daves"); ok && room.OwnerName != "david" {
+		s.errorf("room owner is still %q after the rename", room.OwnerName)
+	}
+}
+
+// testConcurrentMoves renames a room back and forth while others look it up, then checks
+// that lookups agree with where the room ended up
+func (s *suite) testConcurrentMoves() {
+	dir, ok := s.directory("/tmp", "racing", "tokA")
+	if !ok {
+		return
+	}
+	room, ok := s.room("/tmp/racing", "a", "tokA")
+	if !ok {
+		return
+	}
+	const moves = 100
+	names := []string{"a", "b"}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for _, name := range names {
+					node, err := s.repo.GetNodeByPath(domain.NewPath("/tmp/racing/" + name))
+					if err == nil && node.ID != room.ID {
+						s.errorf("/tmp/racing/%s resolved to node %d", name, node.ID)
+					}
+				}
+			}
+		}()
+	}
+	var moveErr error
+	for i := 1; i <= moves && moveErr == nil; i++ {
+		moveErr = s.repo.UpdateRoom(room.ID, dir.ID, "/tmp/racing", names[i%2])
+	}
+	close(stop)
+	wg.Wait()
+	if !s.ok("UpdateRoom", moveErr) {
+		return
+	}
+	final, gone := names[moves%2], names[(moves+1)%2]
+	if node, ok := s.node("/tmp/racing/" + final); ok && node.ID != room.ID {
+		s.errorf("/tmp/racing/%s is node %d, want %d", final, node.ID, room.ID)
+	}
+	if _, err := s.repo.GetNodeByPath(domain.NewPath("/tmp/racing/" + gone)); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("/tmp/racing/%s still resolves after the last move: %v", gone, err)
+	}
+	// Deleting while others look it up leaves nothing behind either
+	stop = make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					s.repo.GetNodeByPath(domain.NewPath("/tmp/racing/" + final))
+				}
+			}
+		}()
+	}
+	s.ok("DeleteRoom", s.repo.DeleteRoom(room.ID))
+	close(stop)
+	wg.Wait()
+	if _, err := s.repo.GetNodeByPath(domain.NewPath("/tmp/racing/" + final)); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("deleted room still resolves: %v", err)
+	}
+}
+
+func (s *suite) testMessages() {
+	room, ok := s.room("/tmp", "messages", "tokA")
+	if !ok {
+		return
+	}
+	if texts := s.messageTexts(room.ID, 10, 0); texts == nil || len(texts) != 0 {
+		s.errorf("ListMessages of an empty room = %#v, want an empty slice", texts)
+	}
+	var ids []int
+	for _, text := range []string{"first", "second hello", "third", "fourth hello"} {
+		ids = append(ids, s.write(room.ID, "alice", text))
+	}
+	if got, want := s.messageTexts(room.ID, 10, 0), []string{"fourth hello", "third", "second hello", "first"}; !slices.Equal(got, want) {
+		s.errorf("ListMessages = %v, want newest first %v", got, want)
+	}
+	if got, want := s.messageTexts(room.ID, 2, 1), []string{"third", "second hello"}; !slices.Equal(got, want) {
+		s.errorf("ListMessages(limit 2, offset 1) = %v, want %v", got, want)
+	}
+	if got := s.messageTexts(room.ID, 10, 4); len(got) != 0 {
+		s.errorf("ListMessages past the end = %v", got)
+	}
+
+	messages, err := s.repo.ListMessages(room.ID, 10, 0)
+	if !s.ok("ListMessages", err) || len(messages) != 4 {
+		return
+	}
+	for i, message := range messages {
+		if message.RoomID != room.ID || message.DisplayName != "alice" || message.CreatedAt.IsZero() {
+			s.errorf("message %d is %+v", i, message)
+		}
+		if want := ids[len(ids)-1-i]; message.ID != want {
+			s.errorf("message %q has id %d, CreateMessage returned %d", message.Content, message.ID, want)
+		}
+		if i > 0 && message.ID >= messages[i-1].ID {
+			s.errorf("message ids are not increasing with time: %d after %d", messages[i-1].ID, message.ID)
+		}
+	}
+
+	found, err := s.repo.ListMessagesByQuery(room.ID, "h.llo$")
+	if s.ok("ListMessagesByQuery", err) {
+		if len(found) != 2 || found[0].Content != "second hello" || found[1].Content != "fourth hello" {
+			s.errorf("ListMessagesByQuery = %+v, want the two hellos oldest first", found)
+		} else if found[0].ID != messages[2].ID || found[0].DisplayName != "alice" {
+			s.errorf("ListMessagesByQuery returned %+v, want id %d by alice", found[0], messages[2].ID)
+		}
+	}
+	if found, err := s.repo.ListMessagesByQuery(room.ID, "^nothing"); s.ok("ListMessagesByQuery", err) && len(found) != 0 {
+		s.errorf("ListMessagesByQuery without matches = %+v", found)
+	}
+	if _, err := s.repo.ListMessagesByQuery(room.ID, "("); err == nil {
+		s.errorf("ListMessagesByQuery accepted an invalid pattern")
+	}
+}
+
+func (s *suite) testMessagePages() {
+	room, ok := s.room("/tmp", "pages", "tokA")
+	if !ok {
+		return
+	}
+	written := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.write(room.ID, "alice", "live")
+	imported := []domain.Message{
+		domain.NewMessage(0, room.ID, "carol", "old one", written),
+		domain.NewMessage(0, room.ID, "dave", "old two", written.Add(time.Minute)),
+	}
+	s.ok("CreateMessages", s.repo.CreateMessages(room.ID, imported))
+	s.write(room.ID, "alice", "live again")
+
+	first, err := s.repo.ListMessagesAfter(room.ID, 0, 3)
+	if !s.ok("ListMessagesAfter", err) {
+		return
+	}
+	if got, want := messageContents(first), []string{"live", "old one", "old two"}; !slices.Equal(got, want) {
+		s.errorf("ListMessagesAfter(0, limit 3) = %v, want %v in the order written", got, want)
+		return
+	}
+	if first[1].DisplayName != "carol" || !first[1].CreatedAt.Equal(written) || first[1].ID <= first[0].ID {
+		s.errorf("imported message is %+v, want carol's at %s with a new id", first[1], written)
+	}
+	rest, err := s.repo.ListMessagesAfter(room.ID, first[2].ID, 3)
+	if s.ok("ListMessagesAfter", err) && !slices.Equal(messageContents(rest), []string{"live again"}) {
+		s.errorf("ListMessagesAfter the third message = %v, want the last one", messageContents(rest))
+	}
+	if got := s.messageTexts(room.ID, 10, 0); !slices.Equal(got, []string{"live again", "live", "old two", "old one"}) {
+		s.errorf("ListMessages = %v, want imported messages ordered by their time", got)
+	}
+}
+
+func (s *suite) testMessageImports() {
+	room, ok := s.room("/tmp", "imports", "tokA")
+	if !ok {
+		return
+	}
+	other, ok := s.room("/tmp", "imports2", "tokA")
+	if !ok {
+		return
+	}
+	written := time.Date(2019, 3, 4, 9, 30, 0, 0, time.UTC)
+	batch := []domain.ImportedMessage{
+		domain.NewImportedMessage("slack:1", "carol", "morning", written),
+		domain.NewImportedMessage("slack:2", "dave", "hi carol", written.Add(time.Minute)),
+		domain.NewImportedMessage("slack:1", "carol", "morning", written),
+	}
+	if imported, err := s.repo.ImportMessages(room.ID, batch); s.ok("ImportMessages", err) && imported != 2 {
+		s.errorf("ImportMessages imported %d messages, want 2 as the batch repeats a key", imported)
+	}
+	again := append(batch[:2:2], domain.NewImportedMessage("slack:3", "carol", "bye", written.Add(time.Hour)))
+	if imported, err := s.repo.ImportMessages(room.ID, again); s.ok("ImportMessages", err) && imported != 1 {
+		s.errorf("importing again imported %d messages, want only the new one", imported)
+	}
+	if imported, err := s.repo.ImportMessages(other.ID, batch[:1]); s.ok("ImportMessages", err) && imported != 1 {
+		s.errorf("another room imported %d messages, want keys to be per room", imported)
+	}
+
+	messages, err := s.repo.ListMessagesAfter(room.ID, 0, 10)
+	if !s.ok("ListMessagesAfter", err) {
+		return
+	}
+	if got, want := messageContents(messages), []string{"morning", "hi carol", "bye"}; !slices.Equal(got, want) {
+		s.errorf("imported messages = %v, want %v", got, want)
+		return
+	}
+	if messages[1].DisplayName != "dave" || !messages[1].CreatedAt.Equal(written.Add(time.Minute)) {
+		s.errorf("imported message is %+v, want dave's at %s", messages[1], written.Add(time.Minute))
+	}
+
+	// Keys go with the room, so that a room of the same name starts afresh
+	if !s.ok("DeleteRoom", s.repo.DeleteRoom(room.ID)) {
+		return
+	}
+	if room, ok = s.room("/tmp", "imports", "tokA"); !ok {
+		return
+	}
+	if imported, err := s.repo.ImportMessages(room.ID, batch); s.ok("ImportMessages", err) && imported != 2 {
+		s.errorf("new room imported %d messages, want 2", imported)
+	}
+
+	// History imported later is not news to those who had read the room; those who had not
+	// keep their place
+	imported, err := s.repo.ListMessagesAfter(room.ID, 0, 10)
+	if !s.ok("ListMessagesAfter", err) || len(imported) != 2 {
+		return
+	}
+	s.ok("UpsertReadMarker", s.repo.UpsertReadMarker("tokB", room.ID, 0))
+	s.ok("UpsertReadMarker", s.repo.UpsertReadMarker("tokC", room.ID, imported[0].ID))
+	if n, err := s.repo.ImportMessages(room.ID, []domain.ImportedMessage{domain.NewImportedMessage("slack:0", "carol", "earlier", written.Add(-time.Hour))}); !s.ok("ImportMessages", err) || n != 1 {
+		return
+	}
+	counts, err := s.repo.CountUnreadMessages("tokB", []int{room.ID})
+	if s.ok("CountUnreadMessages", err) && counts[room.ID] != 0 {
+		s.errorf("CountUnreadMessages after an import = %v, want nothing unread for a reader who was caught up", counts)
+	}
+	if id, _ := s.repo.GetReadMarker("tokC", room.ID); id != imported[0].ID {
+		s.errorf("read marker behind the latest message = %d after an import, want it kept at %d", id, imported[0].ID)
+	}
+}
+
+func messageContents(messages []domain.Message) []string {
+	contents := make([]string, len(messages))
+	for i, message := range messages {
+		contents[i] = message.Content
+	}
+	return contents
+}
+
+func (s *suite) testConcurrentWrites() {
+	room, ok := s.room("/tmp", "concurrent", "tokA")
+	if !ok {
+		return
+	}
+	const writers, perWriter = 8, 25
+	var wg sync.WaitGroup
+	results := make(chan error, writers*perWriter)
+	ids := make(chan int, writers*perWriter)
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWriter {
+				id, err := s.repo.CreateMessage(room.ID, "alice", fmt.Sprintf("%d-%d", w, i))
+				results <- err
+				ids <- id
+			}
+		}()
+	}
+	wg.Wait()
+	close(results)
+	close(ids)
+	for err := range results {
+		s.ok("CreateMessage", err)
+	}
+	seen := map[int]bool{}
+	for id := range ids {
+		if seen[id] {
+			s.errorf("CreateMessage returned id %d twice", id)
+		}
+		seen[id] = true
+	}
+	messages, err := s.repo.ListMessages(room.ID, writers*perWriter+1, 0)
+	if !s.ok("ListMessages", err) {
+		return
+	}
+	if len(messages) != writers*perWriter {
+		s.errorf("%d of %d concurrent messages were stored", len(messages), writers*perWriter)
+	}
+	for _, message := range messages {
+		if !seen[message.ID] {
+			s.errorf("stored message %d was not returned by CreateMessage", message.ID)
+		}
+	}
+}
+
+func (s *suite) testCopies() {
+	src, ok := s.room("/tmp", "original", "tokA")
+	if !ok {
+		return
+	}
+	s.write(src.ID, "alice", "one")
+	s.write(src.ID, "bob", "two")
+	home, _ := s.node("/home")
+	if !s.ok("CreateExistRoom", s.repo.CreateExistRoom(src.ID, home.ID, "/home", "copy", "tokB")) {
+		return
+	}
+	copied, ok := s.node("/home/copy")
+	if !ok {
+		return
+	}
+	if copied.ID == src.ID || copied.OwnerToken != "tokB" || copied.Type != domain.NodeTypeRoom {
+		s.errorf("copy is %+v", copied)
+	}
+	original, _ := s.repo.ListMessages(src.ID, 10, 0)
+	copies, _ := s.repo.ListMessages(copied.ID, 10, 0)
+	if len(copies) != len(original) {
+		s.errorf("copy has %d messages, want %d", len(copies), len(original))
+		return
+	}
+	for i := range copies {
+		if copies[i].Content != original[i].Content || copies[i].DisplayName != original[i].DisplayName || copies[i].ID == original[i].ID {
+			s.errorf("copied message %+v does not match %+v with a new id", copies[i], original[i])
+		}
+		if !copies[i].CreatedAt.Equal(original[i].CreatedAt) {
+			s.errorf("copied message keeps time %v, want %v", copies[i].CreatedAt, original[i].CreatedAt)
+		}
+	}
+	// The copy is independent of its original
+	s.write(copied.ID, "bob", "three")
+	if got := s.messageTexts(src.ID, 10, 0); len(got) != 2 {
+		s.errorf("writing to the copy changed the original: %v", got)
+	}
+	if err := s.repo.CreateExistRoom(src.ID, home.ID, "/home", "copy", "tokB"); !errors.Is(err, usecase.ErrAlreadyExists) {
+		s.errorf("copy onto a taken name: %v, want ErrAlreadyExists", err)
+	}
+}
+
+func (s *suite) testMentions() {
+	room, ok := s.room("/tmp", "mentions", "tokA")
+	if !ok {
+		return
+	}
+	s.ok("CreateMentions", s.repo.CreateMentions(room.ID, nil, "bob", "nobody"))
+	s.ok("CreateMentions", s.repo.CreateMentions(room.ID, []string{"alice", "ghost"}, "bob", "hi @alice"))
+	s.ok("CreateMentions", s.repo.CreateMentions(room.ID, []string{"alice", "carol"}, "bob", "hi all"))
+
+	mentions, err := s.repo.ListMentions("tokA", false)
+	if !s.ok("ListMentions", err) {
+		return
+	}
+	if len(mentions) != 2 || mentions[0].Content != "hi @alice" || mentions[1].Content != "hi all" {
+		s.errorf("ListMentions(alice) = %+v, want two mentions oldest first", mentions)
+		return
+	}
+	if m := mentions[0]; m.RoomID != room.ID || m.RoomPath != "/tmp/mentions" || m.SenderName != "bob" || m.UserToken != "tokA" || m.IsRead {
+		s.errorf("mention is %+v", m)
+	}
+	if carols, _ := s.repo.ListMentions("tokC2", false); len(carols) != 1 {
+		s.errorf("every user with a mentioned name gets a mention, tokC2 got %d", len(carols))
+	}
+
+	s.ok("UpdateMentionsRead", s.repo.UpdateMentionsRead("tokA", []int{mentions[0].ID}))
+	if unread, _ := s.repo.ListMentions("tokA", true); len(unread) != 1 || unread[0].ID != mentions[1].ID {
+		s.errorf("unread mentions after marking one = %+v", unread)
+	}
+	// Marking someone else's mention has no effect
+	carols, _ := s.repo.ListMentions("tokC", false)
+	if len(carols) == 1 {
+		s.ok("UpdateMentionsRead", s.repo.UpdateMentionsRead("tokA", []int{carols[0].ID}))
+		if unread, _ := s.repo.ListMentions("tokC", true); len(unread) != 1 {
+			s.errorf("marking another user's mention changed it")
+		}
+	}
+	s.ok("UpdateMentionsRead", s.repo.UpdateMentionsRead("tokA", nil))
+	if unread, _ := s.repo.ListMentions("tokA", true); len(unread) != 0 {
+		s.errorf("unread mentions after marking all = %+v", unread)
+	}
+
+	// Mentions follow their room when it moves
+	home, _ := s.node("/home")
+	if s.ok("UpdateRoom", s.repo.UpdateRoom(room.ID, home.ID, "/home", "mentions")) {
+		if moved, _ := s.repo.ListMentions("tokA", false); len(moved) != 2 || moved[0].RoomPath != "/home/mentions" {
+			s.errorf("mentions after a move = %+v", moved)
+		}
+	}
+}
+
+func (s *suite) testReadMarkers() {
+	room, ok := s.room("/tmp", "markers", "tokA")
+	if !ok {
+		return
+	}
+	other, _ := s.room("/tmp", "markers-other", "tokA")
+	if id, err := s.repo.GetReadMarker("tokB", room.ID); s.ok("GetReadMarker", err) && id != 0 {
+		s.errorf("GetReadMarker without a marker = %d, want 0", id)
+	}
+	s.ok("UpsertReadMarker", s.repo.UpsertReadMarker("tokB", room.ID, 0))
+	if id, _ := s.repo.GetReadMarker("tokB", room.ID); id != 0 {
+		s.errorf("marking an empty room read = %d, want 0", id)
+	}
+	for _, text := range []string{"a", "b", "c"} {
+		s.write(room.ID, "alice", text)
+	}
+	s.write(other.ID, "alice", "x")
+	messages, _ := s.repo.ListMessages(room.ID, 10, 0)
+	if len(messages) != 3 {
+		return
+	}
+
+	counts, err := s.repo.CountUnreadMessages("tokB", []int{room.ID, other.ID})
+	if s.ok("CountUnreadMessages", err) && (counts[room.ID] != 3 || counts[other.ID] != 1) {
+		s.errorf("CountUnreadMessages = %v, want 3 and 1", counts)
+	}
+	s.ok("UpsertReadMarker", s.repo.UpsertReadMarker("tokB", room.ID, messages[1].ID))
+	if id, _ := s.repo.GetReadMarker("tokB", room.ID); id != messages[1].ID {
+		s.errorf("GetReadMarker = %d, want %d", id, messages[1].ID)
+	}
+	if counts, _ := s.repo.CountUnreadMessages("tokB", []int{room.ID}); counts[room.ID] != 1 {
+		s.errorf("CountUnreadMessages after reading up to the second = %v, want 1", counts)
+	}
+	// A client catching up late does not unread what another already marked
+	s.ok("UpsertReadMarker", s.repo.UpsertReadMarker("tokB", room.ID, messages[2].ID))
+	if id, _ := s.repo.GetReadMarker("tokB", room.ID); id != messages[1].ID {
+		s.errorf("marking an older message read moved the marker back to %d, want %d", id, messages[1].ID)
+	}
+	s.ok("UpsertReadMarker", s.repo.UpsertReadMarker("tokB", room.ID, 0))
+	if id, _ := s.repo.GetReadMarker("tokB", room.ID); id != messages[0].ID {
+		s.errorf("marking all read = %d, want the latest id %d", id, messages[0].ID)
+	}
+	counts, _ = s.repo.CountUnreadMessages("tokB", []int{room.ID, other.ID})
+	if _, ok := counts[room.ID]; ok || len(counts) != 1 {
+		s.errorf("CountUnreadMessages = %v, want only rooms with unread messages", counts)
+	}
+	if counts, err := s.repo.CountUnreadMessages("tokB", nil); s.ok("CountUnreadMessages", err) && (counts == nil || len(counts) != 0) {
+		s.errorf("CountUnreadMessages without rooms = %#v, want an empty map", counts)
+	}
+	if id, _ := s.repo.GetReadMarker("tokA", room.ID); id != 0 {
+		s.errorf("read markers leak between users: %d", id)
+	}
+}
+
+func (s *suite) testModeration() {
+	room, ok := s.room("/tmp", "moderated", "tokA")
+	if !ok {
+		return
+	}
+	settings, err := s.repo.GetRoomSettings(room.ID)
+	if s.ok("GetRoomSettings", err) && settings != domain.NewRoomSettings(room.ID, 0, 0, 0) {
+		s.errorf("default settings = %+v", settings)
+	}
+	want := domain.NewRoomSettings(room.ID, 5, 100, 3)
+	s.ok("UpsertRoomSettings", s.repo.UpsertRoomSettings(want))
+	s.ok("UpsertRoomSettings", s.repo.UpsertRoomSettings(domain.NewRoomSettings(room.ID, 10, 100, 3)))
+	want.SlowModeSeconds = 10
+	if settings, _ := s.repo.GetRoomSettings(room.ID); settings != want {
+		s.errorf("GetRoomSettings = %+v, want %+v", settings, want)
+	}
+
+	s.ok("CreateRoomRestriction", s.repo.CreateRoomRestriction(room.ID, "tokB", domain.RestrictionBan, "tokA"))
+	s.ok("CreateRoomRestriction", s.repo.CreateRoomRestriction(room.ID, "tokB", domain.RestrictionBan, "tokA"))
+	s.ok("CreateRoomRestriction", s.repo.CreateRoomRestriction(room.ID, "tokB", domain.RestrictionMute, "tokA"))
+	if has, err := s.repo.HasRoomRestriction(room.ID, "tokB", domain.RestrictionBan); s.ok("HasRoomRestriction", err) && !has {
+		s.errorf("ban was not stored")
+	}
+	if has, _ := s.repo.HasRoomRestriction(room.ID, "tokC", domain.RestrictionBan); has {
+		s.errorf("HasRoomRestriction is true for another user")
+	}
+	restrictions, err := s.repo.ListRoomRestrictions(room.ID)
+	if s.ok("ListRoomRestrictions", err) {
+		if len(restrictions) != 2 || restrictions[0].DisplayName != "bob" || restrictions[0].Type != domain.RestrictionBan || restrictions[1].Type != domain.RestrictionMute {
+			s.errorf("ListRoomRestrictions = %+v, want one ban and one mute of bob", restrictions)
+		}
+	}
+	s.ok("DeleteRoomRestriction", s.repo.DeleteRoomRestriction(room.ID, "tokB", domain.RestrictionBan))
+	if has, _ := s.repo.HasRoomRestriction(room.ID, "tokB", domain.RestrictionBan); has {
+		s.errorf("ban was not deleted")
+	}
+	if has, _ := s.repo.HasRoomRestriction(room.ID, "tokB", domain.RestrictionMute); !has {
+		s.errorf("deleting the ban also deleted the mute")
+	}
+
+	for _, target := range []string{"bob", "carol", "dave"} {
+		s.ok("CreateModerationLog", s.repo.CreateModerationLog(domain.NewModerationLog(0, room.ID, "alice", domain.ModerationBan, target, "", time.Time{})))
+	}
+	logs, err := s.repo.ListModerationLogs(room.ID, 2)
+	if s.ok("ListModerationLogs", err) {
+		if len(logs) != 2 || logs[0].TargetName != "dave" || logs[1].TargetName != "carol" {
+			s.errorf("ListModerationLogs(2) = %+v, want dave then carol", logs)
+		} else if logs[0].ID <= logs[1].ID || logs[0].RoomID != room.ID || logs[0].ActorName != "alice" || logs[0].CreatedAt.IsZero() {
+			s.errorf("moderation log is %+v", logs[0])
+		}
+	}
+}
+
+// testRoomDeletion checks that deleting a room also deletes everything attached to it
+func (s *suite) testRoomDeletion() {
+	room, ok := s.room("/tmp", "doomed", "tokA")
+	if !ok {
+		return
+	}
+	s.write(room.ID, "bob", "hi @carol")
+	s.ok("CreateMentions", s.repo.CreateMentions(room.ID, []string{"carol"}, "bob", "hi @carol"))
+	s.ok("UpsertReadMarker", s.repo.UpsertReadMarker("tokB", room.ID, 0))
+	s.ok("UpsertRoomSettings", s.repo.UpsertRoomSettings(domain.NewRoomSettings(room.ID, 1, 0, 0)))
+	s.ok("CreateRoomRestriction", s.repo.CreateRoomRestriction(room.ID, "tokC", domain.RestrictionMute, "tokA"))
+	s.ok("CreateModerationLog", s.repo.CreateModerationLog(domain.NewModerationLog(0, room.ID, "alice", domain.ModerationMute, "carol", "", time.Time{})))
+	before, _ := s.repo.ListMentions("tokC", false)
+
+	if !s.ok("DeleteRoom", s.repo.DeleteRoom(room.ID)) {
+		return
+	}
+	if _, err := s.repo.GetNodeByPath(domain.NewPath("/tmp/doomed")); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("deleted room still resolves: %v", err)
+	}
+	if texts := s.messageTexts(room.ID, 10, 0); len(texts) != 0 {
+		s.errorf("messages survived: %v", texts)
+	}
+	if after, _ := s.repo.ListMentions("tokC", false); len(after) != len(before)-1 {
+		s.errorf("mentions survived: %d before, %d after", len(before), len(after))
+	}
+	if id, _ := s.repo.GetReadMarker("tokB", room.ID); id != 0 {
+		s.errorf("read marker survived: %d", id)
+	}
+	if settings, _ := s.repo.GetRoomSettings(room.ID); settings.SlowModeSeconds != 0 {
+		s.errorf("settings survived: %+v", settings)
+	}
+	if restrictions, _ := s.repo.ListRoomRestrictions(room.ID); len(restrictions) != 0 {
+		s.errorf("restrictions survived: %+v", restrictions)
+	}
+	if logs, _ := s.repo.ListModerationLogs(room.ID, 10); len(logs) != 0 {
+		s.errorf("moderation logs survived: %+v", logs)
+	}
+	if again, ok := s.room("/tmp", "doomed", "tokA"); ok && again.ID == room.ID {
+		s.errorf("room id %d was reused", room.ID)
+	}
+}
+
+func (s *suite) testTrash() {
+	dir, ok := s.directory("/srv", "trashed", "tokA")
+	if !ok {
+		return
+	}
+	room, ok := s.room("/srv/trashed", "room", "tokA")
+	if !ok {
+		return
+	}
+	s.write(room.ID, "alice", "first")
+	s.write(room.ID, "bob", "hi @carol")
+	s.ok("CreateMentions", s.repo.CreateMentions(room.ID, []string{"carol"}, "bob", "hi @carol"))
+	s.ok("UpsertRoomSettings", s.repo.UpsertRoomSettings(domain.NewRoomSettings(room.ID, 7, 0, 0)))
+	s.ok("UpsertRetentionPolicy", s.repo.UpsertRetentionPolicy(domain.NewRetentionPolicy(domain.NodeTypeRoom, room.ID, "", time.Hour, 0, 0, time.Time{})))
+	mentions, _ := s.repo.ListMentions("tokC", false)
+
+	id, err := s.repo.TrashNode(domain.NodeTypeRoom, room.ID)
+	if !s.ok("TrashNode", err) {
+		return
+	}
+	if _, err := s.repo.GetNodeByPath(domain.NewPath("/srv/trashed/room")); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("trashed room still resolves: %v", err)
+	}
+	if nodes, _ := s.repo.ListNodes(dir.ID); len(nodes) != 0 {
+		s.errorf("trashed room still listed: %+v", nodes)
+	}
+	if after, _ := s.repo.ListMentions("tokC", false); len(after) != len(mentions)-1 {
+		s.errorf("mentions of the trashed room listed: %d before, %d after", len(mentions), len(after))
+	}
+	if _, err := s.repo.GetRetentionPolicy(domain.NodeTypeRoom, room.ID); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("policy of the trashed room found: %v", err)
+	}
+	if entry, err := s.repo.GetTrashEntry(id); s.ok("GetTrashEntry", err) &&
+		(entry.NodeType != domain.NodeTypeRoom || entry.NodeID != room.ID || entry.Name != "room" || entry.Path != "/srv/trashed/room" ||
+			entry.OwnerToken != "tokA" || entry.MessageCount != 2 || entry.DeletedAt.IsZero()) {
+		s.errorf("trash entry = %+v", entry)
+	}
+	if entries, err := s.repo.ListTrashEntries("tokA"); s.ok("ListTrashEntries", err) && (len(entries) == 0 || entries[0].ID != id) {
+		s.errorf("trash of tokA = %+v, want entry %d first", entries, id)
+	}
+	if entries, _ := s.repo.ListTrashEntries("tokB"); slices.ContainsFunc(entries, func(e domain.TrashEntry) bool { return e.ID == id }) {
+		s.errorf("entry %d of tokA in the trash of tokB", id)
+	}
+
+	// The path is free again, so restoring there conflicts
+	if _, ok := s.room("/srv/trashed", "room", "tokB"); !ok {
+		return
+	}
+	if err := s.repo.RestoreTrashEntry(id, dir.ID, "/srv/trashed", "room"); !errors.Is(err, usecase.ErrAlreadyExists) {
+		s.errorf("restore over an existing room: %v", err)
+	}
+	if !s.ok("RestoreTrashEntry", s.repo.RestoreTrashEntry(id, dir.ID, "/srv/trashed", "restored")) {
+		return
+	}
+	if restored, ok := s.node("/srv/trashed/restored"); ok && (restored.ID != room.ID || restored.OwnerToken != "tokA" || !restored.CreatedAt.Equal(room.CreatedAt)) {
+		s.errorf("restored room = %+v, was %+v", restored, room)
+	}
+	if texts := s.messageTexts(room.ID, 10, 0); !slices.Equal(texts, []string{"hi @carol", "first"}) {
+		s.errorf("messages of the restored room = %q", texts)
+	}
+	if settings, _ := s.repo.GetRoomSettings(room.ID); settings.SlowModeSeconds != 7 {
+		s.errorf("settings of the restored room = %+v", settings)
+	}
+	if policy, err := s.repo.GetRetentionPolicy(domain.NodeTypeRoom, room.ID); err != nil || policy.Path != "/srv/trashed/restored" {
+		s.errorf("policy of the restored room = %+v, %v", policy, err)
+	}
+	if after, _ := s.repo.ListMentions("tokC", false); len(after) != len(mentions) {
+		s.errorf("mentions after restore: %d, want %d", len(after), len(mentions))
+	}
+	if _, err := s.repo.GetTrashEntry(id); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("restored entry still in the trash: %v", err)
+	}
+
+	empty, ok := s.directory("/srv/trashed", "empty", "tokA")
+	if !ok {
+		return
+	}
+	dirEntry, err := s.repo.TrashNode(domain.NodeTypeDirectory, empty.ID)
+	if s.ok("TrashNode(directory)", err) {
+		if exists, _ := s.repo.CheckDirectoryExists(domain.NewPath("/srv/trashed/empty")); exists {
+			s.errorf("trashed directory still exists")
+		}
+		if s.ok("RestoreTrashEntry(directory)", s.repo.RestoreTrashEntry(dirEntry, dir.ID, "/srv/trashed", "empty")) {
+			if restored, ok := s.node("/srv/trashed/empty"); ok && (restored.ID != empty.ID || restored.Type != domain.NodeTypeDirectory) {
+				s.errorf("restored directory = %+v", restored)
+			}
+		}
+	}
+
+	id, err = s.repo.TrashNode(domain.NodeTypeRoom, room.ID)
+	if !s.ok("TrashNode", err) {
+		return
+	}
+	entry, _ := s.repo.GetTrashEntry(id)
+	if n, err := s.repo.PurgeTrashEntries("tokA", entry.DeletedAt.Add(-time.Second)); s.ok("PurgeTrashEntries", err) && n != 0 {
+		s.errorf("purged %d entries removed later than the time", n)
+	}
+	if n, err := s.repo.PurgeTrashEntries("tokB", time.Now().Add(time.Hour)); s.ok("PurgeTrashEntries", err) && n != 0 {
+		s.errorf("purged %d entries of another owner", n)
+	}
+	if n, err := s.repo.PurgeTrashEntries("", time.Now().Add(time.Hour)); s.ok("PurgeTrashEntries", err) && n < 1 {
+		s.errorf("purged %d entries, want at least 1", n)
+	}
+	if _, err := s.repo.GetTrashEntry(id); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("purged entry still in the trash: %v", err)
+	}
+	if texts := s.messageTexts(room.ID, 10, 0); len(texts) != 0 {
+		s.errorf("messages survived the purge: %v", texts)
+	}
+	if settings, _ := s.repo.GetRoomSettings(room.ID); settings.SlowModeSeconds != 0 {
+		s.errorf("settings survived the purge: %+v", settings)
+	}
+	if again, ok := s.room("/srv/trashed", "restored", "tokA"); ok && again.ID == room.ID {
+		s.errorf("room id %d was reused", room.ID)
+	}
+}
+
+func (s *suite) testRetention() {
+	policies, err := s.repo.ListRetentionPolicies()
+	if s.ok("ListRetentionPolicies", err) {
+		i := slices.IndexFunc(policies, func(p domain.RetentionPolicy) bool { return p.Path == "/tmp" })
+		if i < 0 || policies[i].NodeType != domain.NodeTypeDirectory || policies[i].MaxAge != 24*time.Hour || policies[i].IdleTTL != 24*time.Hour {
+			s.errorf("seeded /tmp policy missing: %+v", policies)
+		}
+	}
+
+	dir, ok := s.directory("/srv", "retained", "tokA")
+	if !ok {
+		return
+	}
+	room, ok := s.room("/srv/retained", "room", "tokA")
+	if !ok {
+		return
+	}
+	s.ok("UpsertRetentionPolicy", s.repo.UpsertRetentionPolicy(domain.NewRetentionPolicy(domain.NodeTypeDirectory, dir.ID, "", time.Hour, 0, 0, time.Time{})))
+	s.ok("UpsertRetentionPolicy", s.repo.UpsertRetentionPolicy(domain.NewRetentionPolicy(domain.NodeTypeDirectory, dir.ID, "", 0, 3, time.Minute, time.Time{})))
+	if policy, err := s.repo.GetRetentionPolicy(domain.NodeTypeDirectory, dir.ID); s.ok("GetRetentionPolicy", err) &&
+		(policy.Path != "/srv/retained" || policy.MaxAge != 0 || policy.MaxMessages != 3 || policy.IdleTTL != time.Minute) {
+		s.errorf("upserted policy = %+v", policy)
+	}
+	if _, err := s.repo.GetRetentionPolicy(domain.NodeTypeRoom, room.ID); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("room without a policy of its own: %v", err)
+	}
+	// The directory of the same id is a different node
+	if _, err := s.repo.GetRetentionPolicy(domain.NodeTypeRoom, dir.ID); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("policy of directory %d found for room %d: %v", dir.ID, dir.ID, err)
+	}
+
+	var ids []int
+	for i := range 5 {
+		ids = append(ids, s.write(room.ID, "alice", fmt.Sprintf("m%d", i)))
+	}
+	if deleted, err := s.repo.DeleteMessagesBeyond(room.ID, 3, 1); s.ok("DeleteMessagesBeyond", err) && !slices.Equal(deleted, []int{ids[1]}) {
+		s.errorf("first batch beyond 3 deleted %v, want %v", deleted, ids[1:2])
+	}
+	if deleted, err := s.repo.DeleteMessagesBeyond(room.ID, 3, 10); s.ok("DeleteMessagesBeyond", err) && !slices.Equal(deleted, []int{ids[0]}) {
+		s.errorf("second batch beyond 3 deleted %v, want %v", deleted, ids[:1])
+	}
+	if deleted, err := s.repo.DeleteMessagesBeyond(room.ID, 3, 10); s.ok("DeleteMessagesBeyond", err) && len(deleted) != 0 {
+		s.errorf("nothing left beyond 3, yet deleted %v", deleted)
+	}
+	if deleted, err := s.repo.DeleteMessagesBefore(room.ID, time.Now().Add(-time.Hour), 10); s.ok("DeleteMessagesBefore", err) && len(deleted) != 0 {
+		s.errorf("no message is an hour old, yet deleted %v", deleted)
+	}
+	if deleted, err := s.repo.DeleteMessagesBefore(room.ID, time.Now().Add(time.Hour), 2); s.ok("DeleteMessagesBefore", err) {
+		slices.Sort(deleted)
+		if !slices.Equal(deleted, ids[2:4]) {
+			s.errorf("oldest batch deleted %v, want %v", deleted, ids[2:4])
+		}
+	}
+	if got, want := s.messageTexts(room.ID, 10, 0), []string{"m4"}; !slices.Equal(got, want) {
+		s.errorf("messages left = %v, want %v", got, want)
+	}
+
+	// Policies follow their node when it moves, and go when it is deleted
+	srv, ok := s.node("/srv")
+	if !ok {
+		return
+	}
+	s.ok("UpsertRetentionPolicy", s.repo.UpsertRetentionPolicy(domain.NewRetentionPolicy(domain.NodeTypeRoom, room.ID, "", 0, 0, 0, time.Time{})))
+	s.ok("UpdateDirectory", s.repo.UpdateDirectory(dir.ID, srv.ID, "/srv", "kept"))
+	if policies, err := s.repo.ListRetentionPolicies(); s.ok("ListRetentionPolicies", err) {
+		var paths []string
+		for _, policy := range policies {
+			if strings.HasPrefix(policy.Path, "/srv/") {
+				paths = append(paths, policy.Path)
+			}
+		}
+		if want := []string{"/srv/kept", "/srv/kept/room"}; !slices.Equal(paths, want) {
+			s.errorf("policies after the move are at %v, want %v", paths, want)
+		}
+	}
+	s.ok("DeleteRoom", s.repo.DeleteRoom(room.ID))
+	if _, err := s.repo.GetRetentionPolicy(domain.NodeTypeRoom, room.ID); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("policy of a deleted room: %v", err)
+	}
+	s.ok("DeleteRetentionPolicy", s.repo.DeleteRetentionPolicy(domain.NodeTypeDirectory, dir.ID))
+	if _, err := s.repo.GetRetentionPolicy(domain.NodeTypeDirectory, dir.ID); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("deleted policy: %v", err)
+	}
+}
+
+func (s *suite) testWebhooks() {
+	if _, err := s.repo.GetWebhook(999); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("GetWebhook of a missing id = %v, want ErrNotFound", err)
+	}
+	events := []domain.WebhookEvent{domain.WebhookEventMessage, domain.WebhookEventMove}
+	first, err := s.repo.CreateWebhook(domain.NewWebhook(0, "tokA", "/srv", "http://example.com/a", "s1", events, "*.log", time.Time{}))
+	if !s.ok("CreateWebhook", err) {
+		return
+	}
+	second, _ := s.repo.CreateWebhook(domain.NewWebhook(0, "tokA", "/tmp", "http://example.com/b", "s2", nil, "", time.Time{}))
+	third, _ := s.repo.CreateWebhook(domain.NewWebhook(0, "tokB", "/srv", "http://example.com/c", "s3", nil, "", time.Time{}))
+
+	webhook, err := s.repo.GetWebhook(first)
+	if s.ok("GetWebhook", err) {
+		if webhook.ID != first || webhook.OwnerToken != "tokA" || webhook.Path != "/srv" || webhook.URL != "http://example.com/a" ||
+			webhook.Secret != "s1" || !slices.Equal(webhook.Events, events) || webhook.PathGlob != "*.log" || webhook.CreatedAt.IsZero() {
+			s.errorf("GetWebhook = %+v", webhook)
+		}
+	}
+	if webhook, _ := s.repo.GetWebhook(second); len(webhook.Events) != 0 {
+		s.errorf("a webhook for every event came back with %v", webhook.Events)
+	}
+	if list, err := s.repo.ListWebhooks("tokA"); s.ok("ListWebhooks", err) && (len(list) != 2 || list[0].ID != first || list[1].ID != second) {
+		s.errorf("ListWebhooks(tokA) = %+v", list)
+	}
+	if list, err := s.repo.ListWebhooksByPaths([]string{"/srv", "/nowhere"}); s.ok("ListWebhooksByPaths", err) && (len(list) != 2 || list[0].ID != first || list[1].ID != third) {
+		s.errorf("ListWebhooksByPaths(/srv) = %+v", list)
+	}
+	if list, err := s.repo.ListWebhooksByPaths(nil); s.ok("ListWebhooksByPaths", err) && len(list) != 0 {
+		s.errorf("ListWebhooksByPaths without paths = %+v", list)
+	}
+
+	now := time.Now()
+	s.ok("CreateWebhookDeliveries", s.repo.CreateWebhookDeliveries(nil))
+	s.ok("CreateWebhookDeliveries", s.repo.CreateWebhookDeliveries([]domain.WebhookDelivery{
+		{WebhookID: first, Event: domain.WebhookEventMessage, Payload: []byte(`{"n":1}`), NextAttemptAt: now.Add(-time.Minute)},
+		{WebhookID: first, Event: domain.WebhookEventMove, Payload: []byte(`{"n":2}`), NextAttemptAt: now.Add(-2 * time.Minute)},
+		{WebhookID: second, Event: domain.WebhookEventMessage, Payload: []byte(`{"n":3}`), NextAttemptAt: now.Add(time.Hour)},
+	}))
+	due, err := s.repo.ListDueWebhookDeliveries(now, 10)
+	if !s.ok("ListDueWebhookDeliveries", err) {
+		return
+	}
+	if len(due) != 2 || string(due[0].Payload) != `{"n":2}` || string(due[1].Payload) != `{"n":1}` {
+		s.errorf("ListDueWebhookDeliveries = %+v, want the two due deliveries, oldest first", due)
+		return
+	}
+	if d := due[0]; d.WebhookID != first || d.Event != domain.WebhookEventMove || d.Attempts != 0 || d.State != domain.WebhookDeliveryPending || d.LastError != "" || d.CreatedAt.IsZero() {
+		s.errorf("queued delivery is %+v", d)
+	}
+	if limited, _ := s.repo.ListDueWebhookDeliveries(now, 1); len(limited) != 1 || limited[0].ID != due[0].ID {
+		s.errorf("ListDueWebhookDeliveries(limit 1) = %+v", limited)
+	}
+	if webhook, _ := s.repo.GetWebhook(first); webhook.Pending != 2 || webhook.Failed != 0 {
+		s.errorf("delivery counts = %d pending, %d failed, want 2 and 0", webhook.Pending, webhook.Failed)
+	}
+
+	retry := due[0]
+	retry.Attempts, retry.LastError, retry.NextAttemptAt = 1, "HTTP 500", now.Add(time.Minute)
+	s.ok("UpdateWebhookDelivery", s.repo.UpdateWebhookDelivery(retry))
+	failed := due[1]
+	failed.Attempts, failed.LastError, failed.State = 8, "timeout", domain.WebhookDeliveryFailed
+	s.ok("UpdateWebhookDelivery", s.repo.UpdateWebhookDelivery(failed))
+	if due, _ := s.repo.ListDueWebhookDeliveries(now, 10); len(due) != 0 {
+		s.errorf("rescheduled and failed deliveries are still due: %+v", due)
+	}
+	if due, _ := s.repo.ListDueWebhookDeliveries(now.Add(2*time.Minute), 10); len(due) != 1 || due[0].Attempts != 1 || due[0].LastError != "HTTP 500" {
+		s.errorf("rescheduled delivery = %+v", due)
+	}
+	if webhook, _ := s.repo.GetWebhook(first); webhook.Pending != 1 || webhook.Failed != 1 {
+		s.errorf("delivery counts = %d pending, %d failed, want 1 and 1", webhook.Pending, webhook.Failed)
+	}
+	s.ok("DeleteWebhookDelivery", s.repo.DeleteWebhookDelivery(retry.ID))
+	if webhook, _ := s.repo.GetWebhook(first); webhook.Pending != 0 {
+		s.errorf("deleted delivery is still pending")
+	}
+
+	s.ok("DeleteWebhook", s.repo.DeleteWebhook(second))
+	if _, err := s.repo.GetWebhook(second); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("deleted webhook still exists: %v", err)
+	}
+	if due, _ := s.repo.ListDueWebhookDeliveries(now.Add(2*time.Hour), 10); len(due) != 0 {
+		s.errorf("deliveries of a deleted webhook survived: %+v", due)
+	}
+}
+
+func (s *suite) testIncomingWebhooks() {
+	room, ok := s.room("/tmp", "incoming", "tokA")
+	if !ok {
+		return
+	}
+	if _, err := s.repo.GetIncomingWebhookBySecretHash("unknown"); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("GetIncomingWebhookBySecretHash of an unknown hash = %v, want ErrNotFound", err)
+	}
+	id, err := s.repo.CreateIncomingWebhook(domain.NewIncomingWebhook(0, room.ID, "", "tokA", "ci", "hash1", time.Time{}, time.Time{}))
+	if !s.ok("CreateIncomingWebhook", err) {
+		return
+	}
+	if _, err := s.repo.CreateIncomingWebhook(domain.NewIncomingWebhook(0, room.ID, "", "tokA", "ci", "hash1", time.Time{}, time.Time{})); err == nil {
+		s.errorf("CreateIncomingWebhook accepted a duplicate secret hash")
+	}
+	webhook, err := s.repo.GetIncomingWebhookBySecretHash("hash1")
+	if s.ok("GetIncomingWebhookBySecretHash", err) {
+		if webhook.ID != id || webhook.RoomID != room.ID || webhook.RoomPath != "/tmp/incoming" || webhook.OwnerToken != "tokA" ||
+			webhook.BotName != "ci" || webhook.CreatedAt.IsZero() || !webhook.LastUsedAt.IsZero() {
+			s.errorf("GetIncomingWebhookBySecretHash = %+v", webhook)
+		}
+	}
+
+	usedAt := time.Now()
+	s.ok("UpdateIncomingWebhookUsed", s.repo.UpdateIncomingWebhookUsed(id, usedAt))
+	s.ok("UpdateIncomingWebhookSecret", s.repo.UpdateIncomingWebhookSecret(id, "hash2"))
+	if _, err := s.repo.GetIncomingWebhookBySecretHash("hash1"); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("the rotated secret still resolves: %v", err)
+	}
+	if webhook, err := s.repo.GetIncomingWebhook(id); s.ok("GetIncomingWebhook", err) && (webhook.SecretHash != "hash2" || !webhook.LastUsedAt.Equal(usedAt)) {
+		s.errorf("GetIncomingWebhook = %+v, want hash2 last used at %v", webhook, usedAt)
+	}
+
+	home, _ := s.node("/home")
+	s.ok("UpdateRoom", s.repo.UpdateRoom(room.ID, home.ID, "/home", "incoming"))
+	other, _ := s.repo.CreateIncomingWebhook(domain.NewIncomingWebhook(0, room.ID, "", "tokB", "bot", "hash3", time.Time{}, time.Time{}))
+	list, err := s.repo.ListIncomingWebhooks("tokA")
+	if s.ok("ListIncomingWebhooks", err) && (len(list) != 1 || list[0].ID != id || list[0].RoomPath != "/home/incoming") {
+		s.errorf("ListIncomingWebhooks(tokA) = %+v, want the webhook at the room's new path", list)
+	}
+	s.ok("DeleteRoom", s.repo.DeleteRoom(room.ID))
+	if webhook, err := s.repo.GetIncomingWebhook(id); s.ok("GetIncomingWebhook", err) && webhook.RoomPath != "" {
+		s.errorf("webhook of a deleted room has path %q", webhook.RoomPath)
+	}
+	s.ok("DeleteIncomingWebhook", s.repo.DeleteIncomingWebhook(other))
+	if _, err := s.repo.GetIncomingWebhook(other); !errors.Is(err, usecase.ErrNotFound) {
+		s.errorf("deleted incoming webhook still exists: %v", err)
+	}
+}
+
+// sqliteHeader starts every SQLite database file
+const sqliteHeader = "SQLite format 3\x00"
+
+func (s *suite) testBackup() {
+	snapshot, err := s.repo.Snapshot()
+	if errors.Is(err, domain.ErrUnsupported) {
+		return
+	}
+	if !s.ok("Snapshot", err) {
+		return
+	}
+	data, err := io.ReadAll(snapshot)
+	s.ok("Close", snapshot.Close())
+	if !s.ok("ReadAll", err) {
+		return
+	}
+	if int64(len(data)) != snapshot.Size {
+		s.errorf("snapshot has %d bytes, want the %d it announced", len(data), snapshot.Size)
+	}
+	if !strings.HasPrefix(string(data), sqliteHeader) {
+		s.errorf("snapshot is not a SQLite database")
+	}
+}
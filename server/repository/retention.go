@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+	"github.com/ponyo877/chatsh/server/usecase"
+)
+
+// retentionPolicyQuery selects the policies with the current path of their node;
+// policies whose node is gone have no path and are left out
+const retentionPolicyQuery = `
+	SELECT p.node_type, p.node_id, COALESCE(d.path, r.path), p.max_age_seconds, p.max_messages, p.idle_seconds, p.updated_at
+	FROM retention_policies p
+	LEFT JOIN directories d ON p.node_type = 1 AND d.id = p.node_id
+	LEFT JOIN rooms r ON p.node_type = 2 AND r.id = p.node_id
+	WHERE COALESCE(d.path, r.path) IS NOT NULL
+`
+
+func scanRetentionPolicy(row rowScanner) (domain.RetentionPolicy, error) {
+	var nodeType domain.NodeType
+	var nodeID, maxMessages int
+	var path string
+	var maxAgeSeconds, idleSeconds int64
+	var updatedAt time.Time
+	if err := row.Scan(&nodeType, &nodeID, &path, &maxAgeSeconds, &maxMessages, &idleSeconds, &updatedAt); err != nil {
+		return domain.RetentionPolicy{}, err
+	}
+	return domain.NewRetentionPolicy(nodeType, nodeID, path, time.Duration(maxAgeSeconds)*time.Second, maxMessages, time.Duration(idleSeconds)*time.Second, updatedAt), nil
+}
+
+func (r *Repository) GetRetentionPolicy(nodeType domain.NodeType, nodeID int) (domain.RetentionPolicy, error) {
+	query := retentionPolicyQuery + " AND p.node_type = ? AND p.node_id = ?"
+	policy, err := scanRetentionPolicy(r.queryRow(query, nodeType, nodeID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.RetentionPolicy{}, usecase.ErrNotFound
+		}
+		return domain.RetentionPolicy{}, fmt.Errorf("error querying retention policy: %w", err)
+	}
+	return policy, nil
+}
+
+func (r *Repository) ListRetentionPolicies() ([]domain.RetentionPolicy, error) {
+	rows, err := r.query(retentionPolicyQuery + " ORDER BY 3")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retention policies: %w", err)
+	}
+	defer rows.Close()
+	policies := []domain.RetentionPolicy{}
+	for rows.Next() {
+		policy, err := scanRetentionPolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan retention policy: %w", err)
+		}
+		policies = append(policies, policy)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over retention policies: %w", err)
+	}
+	return policies, nil
+}
+
+func (r *Repository) UpsertRetentionPolicy(policy domain.RetentionPolicy) error {
+	query := `
+		INSERT INTO retention_policies (node_type, node_id, max_age_seconds, max_messages, idle_seconds, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (node_type, node_id)
+		DO UPDATE SET
+			max_age_seconds = EXCLUDED.max_age_seconds,
+			max_messages = EXCLUDED.max_messages,
+			idle_seconds = EXCLUDED.idle_seconds,
+			updated_at = EXCLUDED.updated_at
+	`
+	maxAgeSeconds := int64(policy.MaxAge / time.Second)
+	idleSeconds := int64(policy.IdleTTL / time.Second)
+	if _, err := r.exec(query, policy.NodeType, policy.NodeID, maxAgeSeconds, policy.MaxMessages, idleSeconds, time.Now()); err != nil {
+		return fmt.Errorf("failed to update retention policy of %s: %w", policy.Path, err)
+	}
+	return nil
+}
+
+func (r *Repository) DeleteRetentionPolicy(nodeType domain.NodeType, nodeID int) error {
+	query := "DELETE FROM retention_policies WHERE node_type = ? AND node_id = ?"
+	if _, err := r.exec(query, nodeType, nodeID); err != nil {
+		return fmt.Errorf("failed to delete retention policy: %w", err)
+	}
+	return nil
+}
+
+// deleteMessages runs a DELETE ... RETURNING id, which is a transaction of its own
+func (r *Repository) deleteMessages(query string, args ...any) ([]int, error) {
+	rows, err := r.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *Repository) DeleteMessagesBefore(roomID int, before time.Time, limit int) ([]int, error) {
+	query := `
+		DELETE FROM messages WHERE id IN (
+			SELECT id FROM messages WHERE room_id = ? AND created_at < ? ORDER BY created_at LIMIT ?
+		) RETURNING id
+	`
+	ids, err := r.deleteMessages(query, roomID, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete expired messages of room %d: %w", roomID, err)
+	}
+	return ids, nil
+}
+
+func (r *Repository) DeleteMessagesBeyond(roomID, keep, limit int) ([]int, error) {
+	query := `
+		DELETE FROM messages WHERE id IN (
+			SELECT id FROM messages WHERE room_id = ? ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?
+		) RETURNING id
+	`
+	ids, err := r.deleteMessages(query, roomID, limit, keep)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete messages beyond %d of room %d: %w", keep, roomID, err)
+	}
+	return ids, nil
+}
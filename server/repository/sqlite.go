@@ -0,0 +1,38 @@
+package repository
+
+import "strings"
+
+// Pragma is set on every connection the pool opens
+type Pragma struct {
+	Name  string
+	Value string
+}
+
+// immediateTxLock makes every transaction take the write lock when it begins. Transactions
+// here all write, and most read first; a deferred one that reads under WAL and then wants to
+// write fails with SQLITE_BUSY at once if another wrote in between, as the busy timeout only
+// applies to taking the lock. Both drivers spell it the same.
+const immediateTxLock = "_txlock=immediate"
+
+// WithPragmas adds the pragmas to dsn in the form of the driver, leaving those dsn already sets,
+// and makes transactions immediate unless dsn chooses their locking
+func WithPragmas(dsn string, pragmas []Pragma) string {
+	for _, pragma := range pragmas {
+		if strings.Contains(dsn, pragma.Name) {
+			continue
+		}
+		dsn = withParam(dsn, pragmaParam(pragma))
+	}
+	if !strings.Contains(dsn, "_txlock") {
+		dsn = withParam(dsn, immediateTxLock)
+	}
+	return dsn
+}
+
+func withParam(dsn, param string) string {
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + param
+}
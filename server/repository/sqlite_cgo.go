@@ -0,0 +1,45 @@
+//go:build !sqlite_purego
+
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLiteDriver names the SQLite driver the server is built with
+const SQLiteDriver = "mattn/go-sqlite3 (cgo)"
+
+const sqliteDriverName = "sqlite3_with_go_func"
+
+func init() {
+	sql.Register(sqliteDriverName,
+		&sqlite3.SQLiteDriver{
+			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+				return conn.RegisterFunc("regexp", regex, true)
+			},
+		})
+}
+
+func regex(re, s string) (bool, error) {
+	return regexp.MatchString(re, s)
+}
+
+// OpenSQLite opens dsn with the regexp function the repository searches with
+func OpenSQLite(dsn string) (*sql.DB, error) {
+	return sql.Open(sqliteDriverName, dsn)
+}
+
+// pragmaParam spells pragma as a DSN parameter of mattn/go-sqlite3
+func pragmaParam(pragma Pragma) string {
+	return "_" + pragma.Name + "=" + pragma.Value
+}
+
+// isUniqueViolation reports whether err comes from a UNIQUE constraint of mattn/go-sqlite3
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
@@ -0,0 +1,66 @@
+//go:build sqlite_purego
+
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLiteDriver names the SQLite driver the server is built with
+const SQLiteDriver = "modernc.org/sqlite (pure Go)"
+
+// sqliteDriverName is the name modernc.org/sqlite registers itself under
+const sqliteDriverName = "sqlite"
+
+func init() {
+	// Functions of modernc.org/sqlite are registered for every connection it opens
+	sqlite.MustRegisterDeterministicScalarFunction("regexp", 2, regex)
+}
+
+func regex(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	re, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("regexp: pattern must be text")
+	}
+	var s string
+	switch v := args[1].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+	return regexp.MatchString(re, s)
+}
+
+// OpenSQLite opens dsn with the regexp function the repository searches with, storing times
+// in the same format as mattn/go-sqlite3 so that databases move freely between the two builds
+func OpenSQLite(dsn string) (*sql.DB, error) {
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return sql.Open(sqliteDriverName, dsn+separator+"_time_format=sqlite")
+}
+
+// pragmaParam spells pragma as a DSN parameter of modernc.org/sqlite
+func pragmaParam(pragma Pragma) string {
+	return "_pragma=" + pragma.Name + "(" + pragma.Value + ")"
+}
+
+// isUniqueViolation reports whether err comes from a UNIQUE constraint of modernc.org/sqlite
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
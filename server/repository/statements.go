@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// statements prepares each query once and reuses it on every connection of the pool.
+// Only queries with a fixed text belong here, as every distinct text stays prepared.
+type statements struct {
+	db    *sql.DB
+	mu    sync.RWMutex
+	stmts map[string]*sql.Stmt
+}
+
+func newStatements(db *sql.DB) *statements {
+	return &statements{db: db, stmts: map[string]*sql.Stmt{}}
+}
+
+func (s *statements) get(query string) (*sql.Stmt, error) {
+	s.mu.RLock()
+	stmt, ok := s.stmts[query]
+	s.mu.RUnlock()
+	if ok {
+		return stmt, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stmt, ok := s.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := s.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	s.stmts[query] = stmt
+	return stmt, nil
+}
+
+func (s *statements) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	for query, stmt := range s.stmts {
+		errs = append(errs, stmt.Close())
+		delete(s.stmts, query)
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Repository) exec(query string, args ...any) (sql.Result, error) {
+	stmt, err := r.stmts.get(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Exec(args...)
+}
+
+func (r *Repository) query(query string, args ...any) (*sql.Rows, error) {
+	stmt, err := r.stmts.get(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.Query(args...)
+}
+
+func (r *Repository) queryRow(query string, args ...any) *sql.Row {
+	stmt, err := r.stmts.get(query)
+	if err != nil {
+		// Running the query unprepared reports the same error through Scan
+		return r.db.QueryRow(query, args...)
+	}
+	return stmt.QueryRow(args...)
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+	"github.com/ponyo877/chatsh/server/usecase"
+)
+
+// nodeTables maps node types to the table holding their rows
+var nodeTables = map[domain.NodeType]string{
+	domain.NodeTypeDirectory: "directories",
+	domain.NodeTypeRoom:      "rooms",
+}
+
+// nodeParentColumns names the column referring to the parent directory in each node table
+var nodeParentColumns = map[domain.NodeType]string{
+	domain.NodeTypeDirectory: "parent_id",
+	domain.NodeTypeRoom:      "directory_id",
+}
+
+// trashEntryQuery counts the messages of rooms, which stay in the messages table while in the trash
+const trashEntryQuery = `
+	SELECT t.id, t.node_type, t.node_id, t.name, t.path, t.owner_token,
+		CASE WHEN t.node_type = 2 THEN (SELECT COUNT(*) FROM messages m WHERE m.room_id = t.node_id) ELSE 0 END,
+		t.created_at, t.deleted_at
+	FROM trash t
+`
+
+func scanTrashEntry(row rowScanner) (domain.TrashEntry, error) {
+	var id, nodeID, messageCount int
+	var nodeType domain.NodeType
+	var name, path, ownerToken string
+	var createdAt, deletedAt time.Time
+	if err := row.Scan(&id, &nodeType, &nodeID, &name, &path, &ownerToken, &messageCount, &createdAt, &deletedAt); err != nil {
+		return domain.TrashEntry{}, err
+	}
+	return domain.NewTrashEntry(id, nodeType, nodeID, name, path, ownerToken, messageCount, createdAt, deletedAt), nil
+}
+
+func (r *Repository) TrashNode(nodeType domain.NodeType, nodeID int) (int, error) {
+	table, ok := nodeTables[nodeType]
+	if !ok {
+		return 0, fmt.Errorf("cannot trash node of type %d", nodeType)
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	query := `
+		INSERT INTO trash (node_type, node_id, name, path, owner_token, created_at, deleted_at)
+		SELECT ?, id, name, path, owner_token, created_at, ? FROM ` + table + ` WHERE id = ?
+	`
+	result, err := tx.Exec(query, nodeType, time.Now(), nodeID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to move %s %d to the trash: %w", table, nodeID, err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return 0, fmt.Errorf("failed to move %s %d to the trash: %w", table, nodeID, usecase.ErrNotFound)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get trash entry id: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM "+table+" WHERE id = ?", nodeID); err != nil {
+		return 0, fmt.Errorf("failed to delete %s %d: %w", table, nodeID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return int(id), nil
+}
+
+func (r *Repository) GetTrashEntry(id int) (domain.TrashEntry, error) {
+	entry, err := scanTrashEntry(r.queryRow(trashEntryQuery+" WHERE t.id = ?", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.TrashEntry{}, usecase.ErrNotFound
+		}
+		return domain.TrashEntry{}, fmt.Errorf("error querying trash entry: %w", err)
+	}
+	return entry, nil
+}
+
+func (r *Repository) ListTrashEntries(ownerToken string) ([]domain.TrashEntry, error) {
+	rows, err := r.query(trashEntryQuery+" WHERE t.owner_token = ? ORDER BY t.deleted_at DESC, t.id DESC", ownerToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trash: %w", err)
+	}
+	defer rows.Close()
+	entries := []domain.TrashEntry{}
+	for rows.Next() {
+		entry, err := scanTrashEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trash entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over trash: %w", err)
+	}
+	return entries, nil
+}
+
+func (r *Repository) RestoreTrashEntry(id, dstDirID int, dstDirPath, name string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	var nodeType domain.NodeType
+	if err := tx.QueryRow("SELECT node_type FROM trash WHERE id = ?", id).Scan(&nodeType); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return usecase.ErrNotFound
+		}
+		return fmt.Errorf("failed to get trash entry %d: %w", id, err)
+	}
+	table, ok := nodeTables[nodeType]
+	if !ok {
+		return fmt.Errorf("cannot restore node of type %d", nodeType)
+	}
+	// The node keeps its id, which AUTOINCREMENT never hands out again
+	query := `
+		INSERT INTO ` + table + ` (id, name, ` + nodeParentColumns[nodeType] + `, owner_token, path, created_at)
+		SELECT node_id, ?, ?, owner_token, ?, created_at FROM trash WHERE id = ?
+	`
+	newPath := filepath.Join(dstDirPath, name)
+	if _, err := tx.Exec(query, name, dstDirID, newPath, id); err != nil {
+		return fmt.Errorf("failed to restore '%s': %w", newPath, conflictError(err))
+	}
+	if _, err := tx.Exec("DELETE FROM trash WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete trash entry %d: %w", id, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) PurgeTrashEntries(ownerToken string, before time.Time) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	where := "deleted_at < ? AND (? = '' OR owner_token = ?)"
+	rows, err := tx.Query("SELECT id, node_type, node_id FROM trash WHERE "+where, before, ownerToken, ownerToken)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query trash: %w", err)
+	}
+	type purged struct {
+		id       int
+		nodeType domain.NodeType
+		nodeID   int
+	}
+	var entries []purged
+	for rows.Next() {
+		var entry purged
+		if err := rows.Scan(&entry.id, &entry.nodeType, &entry.nodeID); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan trash entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating over trash: %w", err)
+	}
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	ids := make([]any, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.id
+		if entry.nodeType == domain.NodeTypeRoom {
+			if err := deleteRoomData(tx, entry.nodeID); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if _, err := tx.Exec("DELETE FROM retention_policies WHERE node_type = ? AND node_id = ?", entry.nodeType, entry.nodeID); err != nil {
+			return 0, fmt.Errorf("failed to delete retention policy for directory %d: %w", entry.nodeID, err)
+		}
+	}
+	query := "DELETE FROM trash WHERE id IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + ")"
+	if _, err := tx.Exec(query, ids...); err != nil {
+		return 0, fmt.Errorf("failed to delete trash entries: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(entries), nil
+}
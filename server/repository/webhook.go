@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+	"github.com/ponyo877/chatsh/server/usecase"
+)
+
+// webhookColumns are scanned by scanWebhook, followed by the pending and failed delivery counts
+const webhookColumns = `
+	w.id, w.owner_token, w.path, w.url, w.secret, w.events, w.path_glob, w.created_at,
+	(SELECT COUNT(*) FROM webhook_deliveries d WHERE d.webhook_id = w.id AND d.state = 0),
+	(SELECT COUNT(*) FROM webhook_deliveries d WHERE d.webhook_id = w.id AND d.state = 1)
+`
+
+// Events are stored by name, separated by commas, so that the column stays readable
+func encodeWebhookEvents(events []domain.WebhookEvent) string {
+	names := make([]string, len(events))
+	for i, event := range events {
+		names[i] = event.String()
+	}
+	return strings.Join(names, ",")
+}
+
+func decodeWebhookEvents(column string) ([]domain.WebhookEvent, error) {
+	if column == "" {
+		return nil, nil
+	}
+	var events []domain.WebhookEvent
+	for _, name := range strings.Split(column, ",") {
+		event, err := domain.ParseWebhookEvent(name)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWebhook(row rowScanner) (domain.Webhook, error) {
+	var id, pending, failed int
+	var ownerToken, path, url, secret, events, pathGlob string
+	var createdAt time.Time
+	if err := row.Scan(&id, &ownerToken, &path, &url, &secret, &events, &pathGlob, &createdAt, &pending, &failed); err != nil {
+		return domain.Webhook{}, err
+	}
+	decoded, err := decodeWebhookEvents(events)
+	if err != nil {
+		return domain.Webhook{}, fmt.Errorf("webhook %d: %w", id, err)
+	}
+	webhook := domain.NewWebhook(id, ownerToken, path, url, secret, decoded, pathGlob, createdAt)
+	webhook.Pending = pending
+	webhook.Failed = failed
+	return webhook, nil
+}
+
+func (r *Repository) queryWebhooks(query string, args ...any) ([]domain.Webhook, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []domain.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+func (r *Repository) CreateWebhook(webhook domain.Webhook) (int, error) {
+	query := "INSERT INTO webhooks (owner_token, path, url, secret, events, path_glob, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	result, err := r.exec(query, webhook.OwnerToken, webhook.Path, webhook.URL, webhook.Secret, encodeWebhookEvents(webhook.Events), webhook.PathGlob, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert webhook for %s: %w", webhook.Path, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get webhook id: %w", err)
+	}
+	return int(id), nil
+}
+
+func (r *Repository) GetWebhook(id int) (domain.Webhook, error) {
+	query := "SELECT " + webhookColumns + " FROM webhooks w WHERE w.id = ?"
+	webhook, err := scanWebhook(r.queryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Webhook{}, usecase.ErrNotFound
+		}
+		return domain.Webhook{}, fmt.Errorf("error querying webhook %d: %w", id, err)
+	}
+	return webhook, nil
+}
+
+func (r *Repository) ListWebhooks(ownerToken string) ([]domain.Webhook, error) {
+	query := "SELECT " + webhookColumns + " FROM webhooks w WHERE w.owner_token = ? ORDER BY w.id"
+	return r.queryWebhooks(query, ownerToken)
+}
+
+func (r *Repository) ListWebhooksByPaths(paths []string) ([]domain.Webhook, error) {
+	if len(paths) == 0 {
+		return []domain.Webhook{}, nil
+	}
+	query := "SELECT " + webhookColumns + " FROM webhooks w WHERE w.path IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(paths)), ", ") + ") ORDER BY w.id"
+	args := make([]any, len(paths))
+	for i, path := range paths {
+		args[i] = path
+	}
+	return r.queryWebhooks(query, args...)
+}
+
+// DeleteWebhook removes the webhook along with its queued deliveries
+func (r *Repository) DeleteWebhook(id int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete deliveries of webhook %d: %w", id, err)
+	}
+	if _, err := tx.Exec("DELETE FROM webhooks WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete webhook %d: %w", id, err)
+	}
+	return tx.Commit()
+}
+
+func (r *Repository) CreateWebhookDeliveries(deliveries []domain.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO webhook_deliveries (webhook_id, event, payload, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?)"
+	now := time.Now()
+	for _, delivery := range deliveries {
+		if _, err := tx.Exec(query, delivery.WebhookID, delivery.Event, delivery.Payload, delivery.NextAttemptAt, now); err != nil {
+			return fmt.Errorf("failed to queue delivery for webhook %d: %w", delivery.WebhookID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ListDueWebhookDeliveries returns the pending deliveries whose next attempt is due at now, oldest first
+func (r *Repository) ListDueWebhookDeliveries(now time.Time, limit int) ([]domain.WebhookDelivery, error) {
+	query := `
+		SELECT id, webhook_id, event, payload, attempts, next_attempt_at, last_error, state, created_at
+		FROM webhook_deliveries
+		WHERE state = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at, id
+		LIMIT ?
+	`
+	rows, err := r.query(query, domain.WebhookDeliveryPending, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var id, webhookID, attempts int
+	var event domain.WebhookEvent
+	var payload []byte
+	var nextAttemptAt, createdAt time.Time
+	var lastError string
+	var state domain.WebhookDeliveryState
+	deliveries := []domain.WebhookDelivery{}
+	for rows.Next() {
+		if err := rows.Scan(&id, &webhookID, &event, &payload, &attempts, &nextAttemptAt, &lastError, &state, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, domain.NewWebhookDelivery(id, webhookID, event, payload, attempts, nextAttemptAt, lastError, state, createdAt))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (r *Repository) UpdateWebhookDelivery(delivery domain.WebhookDelivery) error {
+	query := "UPDATE webhook_deliveries SET attempts = ?, next_attempt_at = ?, last_error = ?, state = ? WHERE id = ?"
+	if _, err := r.exec(query, delivery.Attempts, delivery.NextAttemptAt, delivery.LastError, delivery.State, delivery.ID); err != nil {
+		return fmt.Errorf("failed to update webhook delivery %d: %w", delivery.ID, err)
+	}
+	return nil
+}
+
+func (r *Repository) DeleteWebhookDelivery(id int) error {
+	if _, err := r.exec("DELETE FROM webhook_deliveries WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete webhook delivery %d: %w", id, err)
+	}
+	return nil
+}
+
+// incomingWebhookColumns are scanned by scanIncomingWebhook; the room path is empty once the room is gone
+const incomingWebhookColumns = `
+	i.id, i.room_id, COALESCE(r.path, ''), i.owner_token, i.bot_name, i.secret_hash, i.created_at, i.last_used_at
+`
+
+func scanIncomingWebhook(row rowScanner) (domain.IncomingWebhook, error) {
+	var id, roomID int
+	var roomPath, ownerToken, botName, secretHash string
+	var createdAt time.Time
+	var lastUsedAt sql.NullTime
+	if err := row.Scan(&id, &roomID, &roomPath, &ownerToken, &botName, &secretHash, &createdAt, &lastUsedAt); err != nil {
+		return domain.IncomingWebhook{}, err
+	}
+	return domain.NewIncomingWebhook(id, roomID, roomPath, ownerToken, botName, secretHash, createdAt, lastUsedAt.Time), nil
+}
+
+func (r *Repository) getIncomingWebhook(where string, arg any) (domain.IncomingWebhook, error) {
+	query := "SELECT " + incomingWebhookColumns + " FROM incoming_webhooks i LEFT JOIN rooms r ON i.room_id = r.id WHERE " + where
+	webhook, err := scanIncomingWebhook(r.queryRow(query, arg))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.IncomingWebhook{}, usecase.ErrNotFound
+		}
+		return domain.IncomingWebhook{}, fmt.Errorf("error querying incoming webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+func (r *Repository) CreateIncomingWebhook(webhook domain.IncomingWebhook) (int, error) {
+	query := "INSERT INTO incoming_webhooks (room_id, owner_token, bot_name, secret_hash, created_at) VALUES (?, ?, ?, ?, ?)"
+	result, err := r.exec(query, webhook.RoomID, webhook.OwnerToken, webhook.BotName, webhook.SecretHash, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert incoming webhook for room %d: %w", webhook.RoomID, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get incoming webhook id: %w", err)
+	}
+	return int(id), nil
+}
+
+func (r *Repository) GetIncomingWebhook(id int) (domain.IncomingWebhook, error) {
+	return r.getIncomingWebhook("i.id = ?", id)
+}
+
+func (r *Repository) GetIncomingWebhookBySecretHash(secretHash string) (domain.IncomingWebhook, error) {
+	return r.getIncomingWebhook("i.secret_hash = ?", secretHash)
+}
+
+func (r *Repository) ListIncomingWebhooks(ownerToken string) ([]domain.IncomingWebhook, error) {
+	query := "SELECT " + incomingWebhookColumns + " FROM incoming_webhooks i LEFT JOIN rooms r ON i.room_id = r.id WHERE i.owner_token = ? ORDER BY i.id"
+	rows, err := r.query(query, ownerToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query incoming webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []domain.IncomingWebhook{}
+	for rows.Next() {
+		webhook, err := scanIncomingWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan incoming webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating over incoming webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+func (r *Repository) UpdateIncomingWebhookSecret(id int, secretHash string) error {
+	if _, err := r.exec("UPDATE incoming_webhooks SET secret_hash = ? WHERE id = ?", secretHash, id); err != nil {
+		return fmt.Errorf("failed to rotate incoming webhook %d: %w", id, err)
+	}
+	return nil
+}
+
+func (r *Repository) UpdateIncomingWebhookUsed(id int, usedAt time.Time) error {
+	if _, err := r.exec("UPDATE incoming_webhooks SET last_used_at = ? WHERE id = ?", usedAt, id); err != nil {
+		return fmt.Errorf("failed to update incoming webhook %d: %w", id, err)
+	}
+	return nil
+}
+
+func (r *Repository) DeleteIncomingWebhook(id int) error {
+	if _, err := r.exec("DELETE FROM incoming_webhooks WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete incoming webhook %d: %w", id, err)
+	}
+	return nil
+}
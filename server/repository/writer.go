@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const insertMessageQuery = "INSERT INTO messages (room_id, display_name, content, created_at) VALUES (?, ?, ?, ?)"
+
+var errWriterClosed = errors.New("message writer is closed")
+
+// BatchOptions bound the group commit of messages: a transaction holds at most Size messages,
+// and the first of them waits at most Delay for others to join, only while other writers are
+// on their way. A Size of 1 commits every message on its own.
+type BatchOptions struct {
+	Size  int
+	Delay time.Duration
+}
+
+func NewBatchOptions(size int, delay time.Duration) BatchOptions {
+	return BatchOptions{
+		Size:  size,
+		Delay: delay,
+	}
+}
+
+type messageWrite struct {
+	roomID      int
+	displayName string
+	content     string
+	createdAt   time.Time
+	done        chan messageWritten
+}
+
+type messageWritten struct {
+	id  int
+	err error
+}
+
+// messageWriter is the single goroutine inserting messages. Writers queue up while a
+// transaction commits and all go into the next one, so a busy room costs one commit, and
+// one wait for SQLite's write lock, per batch instead of per message.
+type messageWriter struct {
+	db      *sql.DB
+	stmts   *statements
+	options BatchOptions
+	// mu guards closed and keeps writes from queueing once the queue is closed
+	mu      sync.RWMutex
+	closed  bool
+	queue   chan messageWrite
+	stopped chan struct{}
+	// writing counts the callers between queueing a message and getting its id
+	writing atomic.Int64
+}
+
+func newMessageWriter(db *sql.DB, stmts *statements, options BatchOptions) *messageWriter {
+	w := &messageWriter{
+		db:      db,
+		stmts:   stmts,
+		options: options,
+		queue:   make(chan messageWrite, options.Size),
+		stopped: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// write queues a message and waits for the commit of its batch, returning the message id
+func (w *messageWriter) write(roomID int, displayName, content string, createdAt time.Time) (int, error) {
+	done := make(chan messageWritten, 1)
+	w.writing.Add(1)
+	defer w.writing.Add(-1)
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return 0, errWriterClosed
+	}
+	w.queue <- messageWrite{roomID: roomID, displayName: displayName, content: content, createdAt: createdAt, done: done}
+	w.mu.RUnlock()
+	result := <-done
+	return result.id, result.err
+}
+
+// close commits the queued messages and stops the writer
+func (w *messageWriter) close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+	<-w.stopped
+}
+
+func (w *messageWriter) run() {
+	defer close(w.stopped)
+	for first := range w.queue {
+		w.commit(w.collect(first))
+	}
+}
+
+// collect gathers the messages joining first in its transaction
+func (w *messageWriter) collect(first messageWrite) []messageWrite {
+	batch := []messageWrite{first}
+	var timeout <-chan time.Time
+	if w.options.Delay > 0 {
+		timer := time.NewTimer(w.options.Delay)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for len(batch) < w.options.Size {
+		// Whatever queued up during the last commit goes in without waiting
+		select {
+		case write, ok := <-w.queue:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, write)
+			continue
+		default:
+		}
+		// Waiting only pays off when someone else is about to write
+		if timeout == nil || int64(len(batch)) >= w.writing.Load() {
+			return batch
+		}
+		select {
+		case write, ok := <-w.queue:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, write)
+		case <-timeout:
+			return batch
+		}
+	}
+	return batch
+}
+
+// commit inserts the batch in one transaction. When that fails, the messages are retried
+// one by one, so that one bad message does not take the others down with it.
+func (w *messageWriter) commit(batch []messageWrite) {
+	ids, err := w.insert(batch)
+	if err != nil && len(batch) > 1 {
+		for _, write := range batch {
+			w.commit([]messageWrite{write})
+		}
+		return
+	}
+	for i, write := range batch {
+		if err != nil {
+			write.done <- messageWritten{err: err}
+			continue
+		}
+		write.done <- messageWritten{id: ids[i]}
+	}
+}
+
+func (w *messageWriter) insert(batch []messageWrite) ([]int, error) {
+	prepared, err := w.stmts.get(insertMessageQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare message insert: %w", err)
+	}
+	tx, err := w.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	stmt := tx.Stmt(prepared)
+	defer stmt.Close()
+	ids := make([]int, len(batch))
+	for i, write := range batch {
+		result, err := stmt.Exec(write.roomID, write.displayName, write.content, write.createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert message for room %d: %w", write.roomID, err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get message id: %w", err)
+		}
+		ids[i] = int(id)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit messages: %w", err)
+	}
+	return ids, nil
+}
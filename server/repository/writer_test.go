@@ -0,0 +1,108 @@
+package repository_test
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+	"github.com/ponyo877/chatsh/server/repository"
+	"github.com/ponyo877/chatsh/server/repository/cache"
+	"github.com/ponyo877/chatsh/server/usecase"
+)
+
+// benchmarkWriters is how many chat sessions write at once
+const benchmarkWriters = 16
+
+// BenchmarkCreateMessage stores messages in one room from concurrent writers, each looking the
+// room up before every message like a chat session does, once per way of storing them. Besides
+// the time per message it reports the latency a writer sees, and how many messages failed:
+// without WAL, readers and the writer can lock each other out beyond the busy timeout.
+func BenchmarkCreateMessage(b *testing.B) {
+	groupCommit := repository.NewBatchOptions(256, time.Millisecond)
+	for _, run := range []struct {
+		name          string
+		wal           bool
+		batch         repository.BatchOptions
+		pathCacheSize int
+	}{
+		{"autocommit", false, repository.NewBatchOptions(1, 0), 0},
+		{"autocommit-wal", true, repository.NewBatchOptions(1, 0), 0},
+		{"group-commit-wal", true, groupCommit, 0},
+		{"group-commit-wal-path-cache", true, groupCommit, 1024},
+	} {
+		b.Run(run.name, func(b *testing.B) {
+			var repo usecase.Repository = newRepository(b, run.wal, run.batch)
+			if run.pathCacheSize > 0 {
+				repo = cache.NewRepository(repo, run.pathCacheSize)
+			}
+			benchmarkWrites(b, repo)
+		})
+	}
+}
+
+func benchmarkWrites(b *testing.B, repo usecase.Repository) {
+	if err := repo.CreateConfig(domain.NewConfig("benchmark", "benchmark")); err != nil {
+		b.Fatal(err)
+	}
+	tmp, err := repo.GetNodeByPath(domain.NewPath("/tmp"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := repo.CreateRoom(tmp.ID, "/tmp", "benchmark", "benchmark"); err != nil {
+		b.Fatal(err)
+	}
+	roomPath := domain.NewPath("/tmp/benchmark")
+
+	var mu sync.Mutex
+	var latencies []time.Duration
+	failed := 0
+	var wg sync.WaitGroup
+	b.ResetTimer()
+	for w := range benchmarkWriters {
+		// The first writers take the remainder
+		count := b.N / benchmarkWriters
+		if w < b.N%benchmarkWriters {
+			count++
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			own := make([]time.Duration, 0, count)
+			ownFailed := 0
+			for i := range count {
+				sent := time.Now()
+				room, err := repo.GetNodeByPath(roomPath)
+				if err != nil {
+					ownFailed++
+					continue
+				}
+				if _, err := repo.CreateMessage(room.ID, fmt.Sprintf("writer%d", w), fmt.Sprintf("message %d", i)); err != nil {
+					ownFailed++
+					continue
+				}
+				own = append(own, time.Since(sent))
+			}
+			mu.Lock()
+			latencies = append(latencies, own...)
+			failed += ownFailed
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	b.StopTimer()
+	b.ReportMetric(float64(failed), "failed")
+	if len(latencies) == 0 {
+		return
+	}
+	slices.Sort(latencies)
+	for _, quantile := range []struct {
+		unit  string
+		value float64
+	}{{"p50-ns", 0.5}, {"p99-ns", 0.99}} {
+		i := min(int(quantile.value*float64(len(latencies))), len(latencies)-1)
+		b.ReportMetric(float64(latencies[i].Nanoseconds()), quantile.unit)
+	}
+}
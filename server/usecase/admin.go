@@ -0,0 +1,80 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+// checkAdmin refuses the admin operations to everyone but the configured admin tokens
+func (u *Usecase) checkAdmin(ownerToken string) error {
+	if ownerToken == "" || !u.admins[ownerToken] {
+		return fmt.Errorf("admin operations: %w", domain.ErrPermissionDenied)
+	}
+	return nil
+}
+
+// Backup returns a consistent copy of the whole database, which the caller must close
+func (u *Usecase) Backup(ownerToken string) (domain.Snapshot, error) {
+	if err := u.checkAdmin(ownerToken); err != nil {
+		return domain.Snapshot{}, err
+	}
+	snapshot, err := u.repo.Snapshot()
+	if err != nil {
+		return domain.Snapshot{}, fmt.Errorf("error taking snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// ImportMessages adds messages from another chat system to the room at roomPath with their own
+// authors and times, skipping those whose keys the room has taken. When createRoom is set, a
+// missing room is created in its existing directory, owned by the admin. Imported history is
+// not broadcast and reaches neither webhooks nor mentions. Text is cleaned up like that of live
+// messages, and messages left empty are dropped.
+func (u *Usecase) ImportMessages(ownerToken string, roomPath domain.Path, createRoom bool, messages []domain.ImportedMessage) (domain.MessageImport, error) {
+	if err := u.checkAdmin(ownerToken); err != nil {
+		return domain.MessageImport{}, err
+	}
+	result := domain.MessageImport{Path: roomPath.String()}
+	kept := messages[:0]
+	for _, message := range messages {
+		if err := domain.ValidateImportedMessage(message); err != nil {
+			return domain.MessageImport{}, err
+		}
+		displayName, err := domain.NormalizeDisplayName("owner_name", message.DisplayName)
+		if err != nil {
+			return domain.MessageImport{}, err
+		}
+		// Logs carry the escapes and colour codes of terminals and IRC clients, which must not
+		// reach those who cat the room
+		content, err := domain.NormalizeMessage(message.Content, domain.MessageLimits{})
+		if err != nil {
+			return domain.MessageImport{}, err
+		}
+		if content == "" {
+			result.Dropped++
+			continue
+		}
+		message.DisplayName, message.Content = displayName, content
+		kept = append(kept, message)
+	}
+	messages = kept
+	room, err := lookupRoom(u.repo, roomPath)
+	if errors.Is(err, domain.ErrNotFound) && createRoom {
+		if err := u.CreateRoom(roomPath, ownerToken); err != nil {
+			return domain.MessageImport{}, fmt.Errorf("error creating room: %w", err)
+		}
+		result.Created = true
+		room, err = lookupRoom(u.repo, roomPath)
+	}
+	if err != nil {
+		return domain.MessageImport{}, fmt.Errorf("error getting room: %w", err)
+	}
+	imported, err := u.repo.ImportMessages(room.ID, messages)
+	if err != nil {
+		return domain.MessageImport{}, fmt.Errorf("error importing messages into %s: %w", roomPath, err)
+	}
+	result.Messages, result.Duplicates = imported, len(messages)-imported
+	return result, nil
+}
@@ -0,0 +1,332 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	pathpkg "path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/archive"
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+// exportNode is a node of the exported subtree with its entry in the archive
+type exportNode struct {
+	node  domain.Node
+	entry archive.Node
+}
+
+// Export writes the room or directory at nodePath, with everything below it, to w as a chatsh
+// archive. Messages written meanwhile may or may not make it in.
+func (u *Usecase) Export(nodePath domain.Path, w io.Writer) error {
+	if nodePath.String() == "/" {
+		return &domain.InvalidMessageError{Field: "path", Reason: "the root directory cannot be exported, only what is below it"}
+	}
+	root, err := lookupNode(u.repo, nodePath)
+	if err != nil {
+		return fmt.Errorf("error getting path: %w", err)
+	}
+	var nodes []exportNode
+	if err := u.collectExportNodes(root, nodePath.NodeName(), &nodes); err != nil {
+		return err
+	}
+	entries := make([]archive.Node, len(nodes))
+	for i, n := range nodes {
+		entries[i] = n.entry
+	}
+
+	writer := archive.NewWriter(w)
+	if err := writer.WriteHeader(archive.NewManifest(nodePath.String(), time.Now()), entries); err != nil {
+		return err
+	}
+	for i, n := range nodes {
+		if n.node.Type != domain.NodeTypeRoom {
+			continue
+		}
+		afterID := 0
+		for {
+			messages, err := u.repo.ListMessagesAfter(n.node.ID, afterID, archive.MessagesPerPart)
+			if err != nil {
+				return fmt.Errorf("error listing messages of %s: %w", n.entry.Path, err)
+			}
+			if len(messages) == 0 {
+				break
+			}
+			part := make([]archive.Message, len(messages))
+			for j, message := range messages {
+				part[j] = archive.Message{Author: message.DisplayName, Text: message.Content, CreatedAt: message.CreatedAt}
+			}
+			if err := writer.WriteMessages(i, part); err != nil {
+				return err
+			}
+			afterID = messages[len(messages)-1].ID
+		}
+	}
+	return writer.Close()
+}
+
+// collectExportNodes appends node and then everything below it, each directory before its contents
+func (u *Usecase) collectExportNodes(node domain.Node, archivePath string, nodes *[]exportNode) error {
+	entry := archive.Node{
+		Path:      archivePath,
+		Type:      archive.TypeDirectory,
+		Owner:     node.OwnerName,
+		CreatedAt: node.CreatedAt,
+	}
+	if node.Type == domain.NodeTypeRoom {
+		entry.Type = archive.TypeRoom
+		settings, err := u.repo.GetRoomSettings(node.ID)
+		if err != nil {
+			return fmt.Errorf("error getting settings of %s: %w", archivePath, err)
+		}
+		if settings.SlowModeSeconds != 0 || settings.MaxMessageLength != 0 || settings.MaxMessageLines != 0 {
+			entry.Limits = &archive.Limits{
+				SlowModeSeconds:  settings.SlowModeSeconds,
+				MaxMessageLength: settings.MaxMessageLength,
+				MaxMessageLines:  settings.MaxMessageLines,
+			}
+		}
+	}
+	policy, err := u.repo.GetRetentionPolicy(node.Type, node.ID)
+	switch {
+	case err == nil:
+		entry.Retention = &archive.Retention{
+			MaxAgeSeconds: int64(policy.MaxAge / time.Second),
+			MaxMessages:   policy.MaxMessages,
+			IdleSeconds:   int64(policy.IdleTTL / time.Second),
+		}
+	case !errors.Is(err, ErrNotFound):
+		return fmt.Errorf("error getting retention policy of %s: %w", archivePath, err)
+	}
+	*nodes = append(*nodes, exportNode{node: node, entry: entry})
+
+	if node.Type != domain.NodeTypeDirectory {
+		return nil
+	}
+	children, err := u.repo.ListNodes(node.ID)
+	if err != nil {
+		return fmt.Errorf("error listing nodes of %s: %w", archivePath, err)
+	}
+	for _, child := range children {
+		if err := u.collectExportNodes(child, pathpkg.Join(archivePath, child.Name), nodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importNode is what an import does with a node of the archive
+type importNode struct {
+	entry  archive.Node
+	target domain.Path
+	// parent is the index of the directory of the node, -1 for the root of the archive
+	parent int
+	create bool
+	skip   bool
+	// id is the node created or merged into, once the import runs
+	id int
+}
+
+// Import recreates the archive read from r in the directory dstPath, the root of the archive
+// under name unless that is empty. Nodes are owned by ownerToken; onConflict decides for the
+// rooms, and the nodes of the wrong type, whose paths are taken. Messages are cleaned up like
+// live ones, those left empty dropped; they keep the authors of the archive only when an admin
+// imports, and are the caller's otherwise, as anyone can write an archive.
+func (u *Usecase) Import(dstPath domain.Path, name, ownerToken string, onConflict domain.ConflictPolicy, r io.Reader) (domain.ImportResult, error) {
+	if name != "" && (strings.Contains(name, "/") || name == "." || name == "..") {
+		return domain.ImportResult{}, &domain.InvalidMessageError{Field: "name", Reason: "must be a single path element"}
+	}
+	if name != "" {
+		if err := domain.ValidateNodeName("name", name); err != nil {
+			return domain.ImportResult{}, err
+		}
+	}
+	dst, err := lookupDirectory(u.repo, dstPath)
+	if err != nil {
+		return domain.ImportResult{}, fmt.Errorf("error getting destination directory: %w", err)
+	}
+	reader, _, entries, err := archive.NewReader(r)
+	if err != nil {
+		return domain.ImportResult{}, archiveError(err)
+	}
+	keepAuthors, caller := u.checkAdmin(ownerToken) == nil, displayName(u.repo, ownerToken)
+	result := domain.ImportResult{Renamed: map[string]string{}}
+	nodes, err := u.planImport(dstPath, name, entries, onConflict, &result)
+	if err != nil {
+		return domain.ImportResult{}, err
+	}
+	result.Path = nodes[0].target.String()
+
+	for i := range nodes {
+		n := &nodes[i]
+		if n.skip || !n.create {
+			continue
+		}
+		parentID := dst.ID
+		if n.parent >= 0 {
+			parentID = nodes[n.parent].id
+		}
+		if n.id, err = u.createImportedNode(*n, parentID, ownerToken); err != nil {
+			return result, err
+		}
+		if n.entry.Type == archive.TypeRoom {
+			result.Rooms++
+		} else {
+			result.Directories++
+		}
+	}
+
+	for {
+		room, messages, err := reader.NextMessages()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return result, archiveError(err)
+		}
+		if !nodes[room].create || nodes[room].skip {
+			continue
+		}
+		imported := make([]domain.Message, 0, len(messages))
+		for _, message := range messages {
+			text, err := domain.NormalizeMessage(message.Text, domain.MessageLimits{})
+			if err != nil || text == "" {
+				continue
+			}
+			author := caller
+			if keepAuthors {
+				if author, err = domain.NormalizeDisplayName("author", message.Author); err != nil {
+					author = domain.UnknownSenderName
+				}
+			}
+			imported = append(imported, domain.NewMessage(0, nodes[room].id, author, text, message.CreatedAt))
+		}
+		if err := u.repo.CreateMessages(nodes[room].id, imported); err != nil {
+			return result, fmt.Errorf("error importing messages of %s: %w", nodes[room].target, err)
+		}
+		result.Messages += len(imported)
+	}
+	return result, nil
+}
+
+// planImport decides where every node of the archive goes before anything is created, so that
+// ConflictFail leaves the tree as it was
+func (u *Usecase) planImport(dstPath domain.Path, name string, entries []archive.Node, onConflict domain.ConflictPolicy, result *domain.ImportResult) ([]importNode, error) {
+	nodes := make([]importNode, len(entries))
+	indexes := make(map[string]int, len(entries))
+	planned := map[string]bool{}
+	for i, entry := range entries {
+		indexes[entry.Path] = i
+		n := importNode{entry: entry, parent: -1}
+		parentPath, base := dstPath, pathpkg.Base(entry.Path)
+		if i == 0 && name != "" {
+			base = name
+		} else if err := domain.ValidateNodeName("archive", base); err != nil {
+			var invalid *domain.InvalidMessageError
+			errors.As(err, &invalid)
+			return nil, &domain.InvalidMessageError{Field: "archive", Reason: fmt.Sprintf("node %q: %s", entry.Path, invalid.Reason)}
+		}
+		if i > 0 {
+			n.parent = indexes[pathpkg.Dir(entry.Path)]
+			parent := nodes[n.parent]
+			if parent.skip {
+				n.skip = true
+				nodes[i] = n
+				continue
+			}
+			parentPath = parent.target
+		}
+		n.target = domain.NewPath(pathpkg.Join(parentPath.String(), base))
+
+		existing, err := u.repo.GetNodeByPath(n.target)
+		exists := err == nil
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("error getting path: %w", err)
+		}
+		switch {
+		case !exists && !planned[n.target.String()]:
+			n.create = true
+		case exists && existing.Type == domain.NodeTypeDirectory && entry.Type == archive.TypeDirectory:
+			n.id = existing.ID
+		case onConflict == domain.ConflictSkip:
+			n.skip = true
+			result.Skipped = append(result.Skipped, n.target.String())
+		case onConflict == domain.ConflictRename:
+			renamed, err := u.vacantName(n.target, planned)
+			if err != nil {
+				return nil, err
+			}
+			result.Renamed[n.target.String()] = renamed.String()
+			n.target, n.create = renamed, true
+		default:
+			return nil, domain.NewPathError(n.target, domain.ErrAlreadyExists)
+		}
+		planned[n.target.String()] = true
+		nodes[i] = n
+	}
+	return nodes, nil
+}
+
+// vacantName returns the first of "name.1", "name.2" and so on that is free
+func (u *Usecase) vacantName(path domain.Path, planned map[string]bool) (domain.Path, error) {
+	for n := 1; ; n++ {
+		candidate := domain.NewPath(path.String() + "." + strconv.Itoa(n))
+		if planned[candidate.String()] {
+			continue
+		}
+		_, err := u.repo.GetNodeByPath(candidate)
+		if errors.Is(err, ErrNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return domain.Path{}, fmt.Errorf("error getting path: %w", err)
+		}
+	}
+}
+
+// createImportedNode creates the node with the settings and retention policy it had, and returns its id
+func (u *Usecase) createImportedNode(n importNode, parentID int, ownerToken string) (int, error) {
+	nodeType := domain.NodeTypeDirectory
+	create := u.repo.CreateDirectory
+	if n.entry.Type == archive.TypeRoom {
+		nodeType, create = domain.NodeTypeRoom, u.repo.CreateRoom
+	}
+	if err := create(parentID, n.target.Parent().String(), n.target.NodeName(), ownerToken); err != nil {
+		if errors.Is(err, ErrAlreadyExists) {
+			return 0, domain.NewPathError(n.target, domain.ErrAlreadyExists)
+		}
+		return 0, fmt.Errorf("error creating %s: %w", n.target, err)
+	}
+	node, err := u.repo.GetNodeByPath(n.target)
+	if err != nil {
+		return 0, fmt.Errorf("error getting path: %w", err)
+	}
+	if limits := n.entry.Limits; limits != nil {
+		settings := domain.NewRoomSettings(node.ID, limits.SlowModeSeconds, limits.MaxMessageLength, limits.MaxMessageLines)
+		if err := u.repo.UpsertRoomSettings(settings); err != nil {
+			return 0, fmt.Errorf("error setting limits of %s: %w", n.target, err)
+		}
+	}
+	if retention := n.entry.Retention; retention != nil {
+		policy := domain.NewRetentionPolicy(nodeType, node.ID, n.target.String(),
+			time.Duration(retention.MaxAgeSeconds)*time.Second, retention.MaxMessages,
+			time.Duration(retention.IdleSeconds)*time.Second, time.Now())
+		if err := u.repo.UpsertRetentionPolicy(policy); err != nil {
+			return 0, fmt.Errorf("error setting retention policy of %s: %w", n.target, err)
+		}
+	}
+	u.emitNodeEvent(domain.WebhookEventCreate, nodeType, n.target.String(), "", ownerToken)
+	return node.ID, nil
+}
+
+// archiveError reports unreadable archives as invalid arguments
+func archiveError(err error) error {
+	var archiveErr *archive.Error
+	if errors.As(err, &archiveErr) {
+		return &domain.InvalidMessageError{Field: "archive", Reason: archiveErr.Reason}
+	}
+	return err
+}
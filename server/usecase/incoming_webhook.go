@@ -0,0 +1,146 @@
+package usecase
+
+import (
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+// newIncomingWebhookSecret returns a URL-safe secret and the hash that is stored in its place
+func newIncomingWebhookSecret() (string, string, error) {
+	secret := make([]byte, webhookSecretBytes)
+	if _, err := cryptorand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("error generating incoming webhook secret: %w", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	return encoded, hashIncomingWebhookSecret(encoded), nil
+}
+
+func hashIncomingWebhookSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateIncomingWebhook gives a room the caller owns a secret URL that posts as botName.
+// The result is the only place the secret is returned.
+func (u *Usecase) CreateIncomingWebhook(roomPath domain.Path, ownerToken, botName string) (domain.IncomingWebhook, error) {
+	room, err := lookupOwnedRoom(u.repo, roomPath, ownerToken)
+	if err != nil {
+		return domain.IncomingWebhook{}, err
+	}
+	if botName == "" {
+		botName = domain.DefaultBotName
+	}
+	if err := domain.ValidateBotName(botName); err != nil {
+		return domain.IncomingWebhook{}, err
+	}
+	secret, secretHash, err := newIncomingWebhookSecret()
+	if err != nil {
+		return domain.IncomingWebhook{}, err
+	}
+	webhook := domain.NewIncomingWebhook(0, room.ID, roomPath.String(), ownerToken, botName, secretHash, time.Now(), time.Time{})
+	if webhook.ID, err = u.repo.CreateIncomingWebhook(webhook); err != nil {
+		return domain.IncomingWebhook{}, fmt.Errorf("error creating incoming webhook: %w", err)
+	}
+	webhook.Secret = secret
+	return webhook, nil
+}
+
+func (u *Usecase) ListIncomingWebhooks(ownerToken string) ([]domain.IncomingWebhook, error) {
+	webhooks, err := u.repo.ListIncomingWebhooks(ownerToken)
+	if err != nil {
+		return nil, fmt.Errorf("error listing incoming webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+// lookupOwnedIncomingWebhook resolves an incoming webhook that only its owner may manage
+func (u *Usecase) lookupOwnedIncomingWebhook(id int, ownerToken string) (domain.IncomingWebhook, error) {
+	webhook, err := u.repo.GetIncomingWebhook(id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return domain.IncomingWebhook{}, fmt.Errorf("incoming webhook %d: %w", id, domain.ErrNotFound)
+		}
+		return domain.IncomingWebhook{}, fmt.Errorf("error getting incoming webhook: %w", err)
+	}
+	if webhook.OwnerToken != ownerToken {
+		return domain.IncomingWebhook{}, fmt.Errorf("incoming webhook %d: %w", id, domain.ErrPermissionDenied)
+	}
+	return webhook, nil
+}
+
+// RotateIncomingWebhook replaces the secret, so the old URL stops working right away
+func (u *Usecase) RotateIncomingWebhook(id int, ownerToken string) (domain.IncomingWebhook, error) {
+	webhook, err := u.lookupOwnedIncomingWebhook(id, ownerToken)
+	if err != nil {
+		return domain.IncomingWebhook{}, err
+	}
+	secret, secretHash, err := newIncomingWebhookSecret()
+	if err != nil {
+		return domain.IncomingWebhook{}, err
+	}
+	if err := u.repo.UpdateIncomingWebhookSecret(id, secretHash); err != nil {
+		return domain.IncomingWebhook{}, fmt.Errorf("error rotating incoming webhook: %w", err)
+	}
+	webhook.Secret = secret
+	webhook.SecretHash = secretHash
+	return webhook, nil
+}
+
+func (u *Usecase) RevokeIncomingWebhook(id int, ownerToken string) error {
+	if _, err := u.lookupOwnedIncomingWebhook(id, ownerToken); err != nil {
+		return err
+	}
+	if err := u.repo.DeleteIncomingWebhook(id); err != nil {
+		return fmt.Errorf("error revoking incoming webhook: %w", err)
+	}
+	return nil
+}
+
+// PostIncomingWebhook writes message to the room of the webhook holding secret, as its bot.
+// Unknown secrets and webhooks whose room is gone are both reported as ErrNotFound.
+func (u *Usecase) PostIncomingWebhook(secret, message string) error {
+	webhook, err := u.repo.GetIncomingWebhookBySecretHash(hashIncomingWebhookSecret(secret))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("incoming webhook: %w", domain.ErrNotFound)
+		}
+		return fmt.Errorf("error getting incoming webhook: %w", err)
+	}
+	if webhook.RoomPath == "" {
+		return fmt.Errorf("incoming webhook %d: %w", webhook.ID, domain.ErrNotFound)
+	}
+	roomPath := domain.NewPath(webhook.RoomPath)
+	room, err := lookupRoom(u.repo, roomPath)
+	if err != nil {
+		return err
+	}
+	// The webhook acts for its owner, so it keeps working where the owner may post
+	if err := u.moderator.checkPost(room, webhook.OwnerToken, webhook.BotName); err != nil {
+		return err
+	}
+	message, err = normalizeMessage(u.repo, u.messageLimits, room, message)
+	if err != nil {
+		return err
+	}
+	message, err = u.messageHooks.BeforeWrite(domain.NewHookMessage(roomPath.String(), webhook.BotName, message))
+	if err != nil {
+		return err
+	}
+	if message == "" {
+		return &domain.InvalidMessageError{Field: "text", Reason: "message is empty"}
+	}
+	if err := u.postMessage(room, roomPath.String(), webhook.BotName, "", message); err != nil {
+		return err
+	}
+	if err := u.repo.UpdateIncomingWebhookUsed(webhook.ID, time.Now()); err != nil {
+		fmt.Printf("Error updating incoming webhook %d: %v\n", webhook.ID, err)
+	}
+	return nil
+}
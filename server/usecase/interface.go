@@ -1,7 +1,7 @@
 package usecase
 
 import (
-	"errors"
+	"time"
 
 	"github.com/ponyo877/chatsh/server/domain"
 )
@@ -28,11 +28,94 @@ type Repository interface {
 	UpdateRoom(srcRoomID, dstDirID int, dstDirPath, name string) error
 
 	// Message
-	CreateMessage(roomID int, displayName, message string) error
+	// CreateMessage returns the id of the stored message
+	CreateMessage(roomID int, displayName, message string) (int, error)
 	ListMessages(roomID, limit, offset int) ([]domain.Message, error)
 	ListMessagesByQuery(roomID int, pattern string) ([]domain.Message, error)
+	// ListMessagesAfter pages through the messages of a room in the order they were written
+	ListMessagesAfter(roomID, afterID, limit int) ([]domain.Message, error)
+	// CreateMessages stores messages written elsewhere, keeping their authors and times
+	CreateMessages(roomID int, messages []domain.Message) error
+	// ImportMessages stores the messages whose keys the room has not taken yet, keeping their
+	// authors and times, and returns how many it stored. Read markers at the room's latest
+	// message move past the imported ones, which are history rather than news.
+	ImportMessages(roomID int, messages []domain.ImportedMessage) (int, error)
+	// DeleteMessagesBefore deletes at most limit of the oldest messages of the room created
+	// before the time, and DeleteMessagesBeyond at most limit of the messages older than its
+	// newest keep; both return the ids of the deleted messages
+	DeleteMessagesBefore(roomID int, before time.Time, limit int) ([]int, error)
+	DeleteMessagesBeyond(roomID, keep, limit int) ([]int, error)
+
+	// Mention
+	CreateMentions(roomID int, userNames []string, senderName, message string) error
+	ListMentions(ownerToken string, unreadOnly bool) ([]domain.Mention, error)
+	UpdateMentionsRead(ownerToken string, mentionIDs []int) error
+
+	// Read Marker
+	GetReadMarker(ownerToken string, roomID int) (int, error)
+	// UpsertReadMarker never moves the marker back
+	UpsertReadMarker(ownerToken string, roomID, messageID int) error
+	CountUnreadMessages(ownerToken string, roomIDs []int) (map[int]int, error)
+
+	// Moderation
+	GetTokensByDisplayName(displayName string) ([]string, error)
+	CreateRoomRestriction(roomID int, userToken string, restrictionType domain.RestrictionType, createdBy string) error
+	DeleteRoomRestriction(roomID int, userToken string, restrictionType domain.RestrictionType) error
+	HasRoomRestriction(roomID int, userToken string, restrictionType domain.RestrictionType) (bool, error)
+	ListRoomRestrictions(roomID int) ([]domain.RoomRestriction, error)
+	GetRoomSettings(roomID int) (domain.RoomSettings, error)
+	UpsertRoomSettings(settings domain.RoomSettings) error
+	CreateModerationLog(log domain.ModerationLog) error
+	ListModerationLogs(roomID, limit int) ([]domain.ModerationLog, error)
+
+	// Retention
+	// GetRetentionPolicy returns ErrNotFound when the node has no policy of its own
+	GetRetentionPolicy(nodeType domain.NodeType, nodeID int) (domain.RetentionPolicy, error)
+	// ListRetentionPolicies returns every policy with the current path of its node, sorted by path
+	ListRetentionPolicies() ([]domain.RetentionPolicy, error)
+	UpsertRetentionPolicy(policy domain.RetentionPolicy) error
+	DeleteRetentionPolicy(nodeType domain.NodeType, nodeID int) error
+
+	// Trash
+	// TrashNode takes the room or the empty directory out of the tree into the trash and returns
+	// the id of its entry; its messages and everything else referring to it stay for a restore
+	TrashNode(nodeType domain.NodeType, nodeID int) (int, error)
+	// GetTrashEntry returns ErrNotFound for unknown ids
+	GetTrashEntry(id int) (domain.TrashEntry, error)
+	// ListTrashEntries returns the entries of the owner, the most recently removed first
+	ListTrashEntries(ownerToken string) ([]domain.TrashEntry, error)
+	// RestoreTrashEntry puts the node of the entry back, with its id, as dstDirPath/name
+	RestoreTrashEntry(id, dstDirID int, dstDirPath, name string) error
+	// PurgeTrashEntries deletes for good the entries removed before the time, those of the
+	// owner or, when ownerToken is empty, of everyone; it returns how many it deleted
+	PurgeTrashEntries(ownerToken string, before time.Time) (int, error)
+
+	// Backup
+	// Snapshot copies the whole database consistently while it stays in use; storage that
+	// cannot be copied returns domain.ErrUnsupported
+	Snapshot() (domain.Snapshot, error)
+
+	// Webhook
+	CreateWebhook(webhook domain.Webhook) (int, error)
+	GetWebhook(id int) (domain.Webhook, error)
+	ListWebhooks(ownerToken string) ([]domain.Webhook, error)
+	ListWebhooksByPaths(paths []string) ([]domain.Webhook, error)
+	DeleteWebhook(id int) error
+	CreateWebhookDeliveries(deliveries []domain.WebhookDelivery) error
+	ListDueWebhookDeliveries(now time.Time, limit int) ([]domain.WebhookDelivery, error)
+	UpdateWebhookDelivery(delivery domain.WebhookDelivery) error
+	DeleteWebhookDelivery(id int) error
+
+	// Incoming Webhook
+	CreateIncomingWebhook(webhook domain.IncomingWebhook) (int, error)
+	GetIncomingWebhook(id int) (domain.IncomingWebhook, error)
+	GetIncomingWebhookBySecretHash(secretHash string) (domain.IncomingWebhook, error)
+	ListIncomingWebhooks(ownerToken string) ([]domain.IncomingWebhook, error)
+	UpdateIncomingWebhookSecret(id int, secretHash string) error
+	UpdateIncomingWebhookUsed(id int, usedAt time.Time) error
+	DeleteIncomingWebhook(id int) error
 }
 
-var ErrNotFound = errors.New("not found")
+var ErrNotFound = domain.ErrNotFound
 
-var ErrAlreadyExists = errors.New("already exists")
+var ErrAlreadyExists = domain.ErrAlreadyExists
@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"errors"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+// lookupNode resolves path, reporting a missing node as a PathError that names it
+func lookupNode(repo Repository, path domain.Path) (domain.Node, error) {
+	node, err := repo.GetNodeByPath(path)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return domain.Node{}, domain.NewPathError(path, domain.ErrNotFound)
+		}
+		return domain.Node{}, err
+	}
+	return node, nil
+}
+
+// lookupRoom resolves a path that must be a room
+func lookupRoom(repo Repository, path domain.Path) (domain.Node, error) {
+	node, err := lookupNode(repo, path)
+	if err != nil {
+		return domain.Node{}, err
+	}
+	if node.Type != domain.NodeTypeRoom {
+		return domain.Node{}, domain.NewPathError(path, domain.ErrNotARoom)
+	}
+	return node, nil
+}
+
+// lookupDirectory resolves a path that must be a directory
+func lookupDirectory(repo Repository, path domain.Path) (domain.Node, error) {
+	node, err := lookupNode(repo, path)
+	if err != nil {
+		return domain.Node{}, err
+	}
+	if node.Type != domain.NodeTypeDirectory {
+		return domain.Node{}, domain.NewPathError(path, domain.ErrNotADirectory)
+	}
+	return node, nil
+}
+
+// lookupOwnedRoom resolves a room that only its owner may manage
+func lookupOwnedRoom(repo Repository, path domain.Path, ownerToken string) (domain.Node, error) {
+	room, err := lookupRoom(repo, path)
+	if err != nil {
+		return domain.Node{}, err
+	}
+	if room.OwnerToken != ownerToken {
+		return domain.Node{}, domain.NewPathError(path, domain.ErrPermissionDenied)
+	}
+	return room, nil
+}
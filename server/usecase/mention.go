@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+// recordMentions stores an inbox entry for every user mentioned in message
+// who is not currently chatting in the room, nor the sender; it is only called
+// once the message is stored
+func recordMentions(repo Repository, streamManager domain.StreamManager, roomID int, roomPath, senderName, senderToken, message string) error {
+	names := domain.ParseMentions(message)
+	if len(names) == 0 {
+		return nil
+	}
+	self := []string{senderName}
+	if senderToken != "" {
+		if config, err := repo.GetConfig(senderToken); err == nil {
+			self = append(self, config.DisplayName)
+		}
+	}
+	names = slices.DeleteFunc(names, func(name string) bool {
+		return slices.Contains(self, name)
+	})
+	for _, client := range streamManager.GetActiveClients(roomPath) {
+		if client.IsTail {
+			continue
+		}
+		names = slices.DeleteFunc(names, func(name string) bool {
+			return name == client.Name
+		})
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	if err := repo.CreateMentions(roomID, names, senderName, message); err != nil {
+		return fmt.Errorf("error recording mentions: %w", err)
+	}
+	return nil
+}
+
+func (u *Usecase) ListMentions(ownerToken string, unreadOnly bool) ([]domain.Mention, error) {
+	mentions, err := u.repo.ListMentions(ownerToken, unreadOnly)
+	if err != nil {
+		return nil, fmt.Errorf("error listing mentions: %w", err)
+	}
+	return mentions, nil
+}
+
+func (u *Usecase) MarkMentionsRead(ownerToken string, mentionIDs []int) error {
+	if err := u.repo.UpdateMentionsRead(ownerToken, mentionIDs); err != nil {
+		return fmt.Errorf("error marking mentions as read: %w", err)
+	}
+	return nil
+}
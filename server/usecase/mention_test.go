@@ -0,0 +1,141 @@
+package usecase
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+// mentionRepository holds one room and the users' configs, and records the mentions stored;
+// messages fail to save when saveErr is set
+type mentionRepository struct {
+	*webhookRepository
+	room     domain.Node
+	configs  map[string]domain.Config
+	saveErr  error
+	mentions [][]string
+}
+
+func newMentionRepository() *mentionRepository {
+	return &mentionRepository{
+		webhookRepository: newWebhookRepository(),
+		room:              domain.Node{ID: 1, Name: "room", Type: domain.NodeTypeRoom, OwnerToken: "tokA"},
+		configs: map[string]domain.Config{
+			"tokA": domain.NewConfig("alice", "tokA"),
+			"tokB": domain.NewConfig("bob", "tokB"),
+		},
+	}
+}
+
+func (r *mentionRepository) GetConfig(ownerToken string) (domain.Config, error) {
+	config, ok := r.configs[ownerToken]
+	if !ok {
+		return domain.Config{}, ErrNotFound
+	}
+	return config, nil
+}
+
+func (r *mentionRepository) GetNodeByPath(path domain.Path) (domain.Node, error) {
+	return r.room, nil
+}
+
+func (r *mentionRepository) GetRoomSettings(roomID int) (domain.RoomSettings, error) {
+	return domain.NewRoomSettings(roomID, 0, 0, 0), nil
+}
+
+func (r *mentionRepository) CreateMessage(roomID int, displayName, message string) (int, error) {
+	return 1, r.saveErr
+}
+
+func (r *mentionRepository) CreateMentions(roomID int, userNames []string, senderName, message string) error {
+	r.mentions = append(r.mentions, userNames)
+	return nil
+}
+
+// chatRoom has the sessions in it chatting, or tailing the room
+type chatRoom struct {
+	domain.StreamManager
+	sessions []domain.StreamSession
+}
+
+func (c chatRoom) GetSession(sessionID string) (domain.StreamSession, bool) {
+	for _, session := range c.sessions {
+		if session.ID == sessionID {
+			return session, true
+		}
+	}
+	return domain.StreamSession{}, false
+}
+
+func (c chatRoom) GetActiveClients(roomPath string) []domain.StreamSession {
+	return c.sessions
+}
+
+func (c chatRoom) BroadcastMessage(roomPath, sender, message string) error {
+	return nil
+}
+
+func TestRecordMentions(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		senderName  string
+		senderToken string
+		message     string
+		sessions    []domain.StreamSession
+		// want is the names stored, nil when nothing is
+		want []string
+	}{
+		{"mention", "alice", "tokA", "@bob look", nil, []string{"bob"}},
+		{"no mention", "alice", "tokA", "hello bob", nil, nil},
+		{"self", "alice", "tokA", "@alice note to self", nil, nil},
+		{"self among others", "alice", "tokA", "@alice @bob @carol", nil, []string{"bob", "carol"}},
+		{"self by token", "tokA", "tokA", "@alice @bob", nil, []string{"bob"}},
+		{"sender without a token", "deploy-bot", "", "@deploy-bot @bob", nil, []string{"bob"}},
+		{"mentioned user in the room", "alice", "tokA", "@bob @carol", []domain.StreamSession{domain.NewStreamSession("s1", "bob", "tokB", "/tmp/room", "", false)}, []string{"carol"}},
+		{"mentioned user tailing the room", "alice", "tokA", "@bob", []domain.StreamSession{domain.NewStreamSession("s1", "bob", "tokB", "/tmp/room", "", true)}, []string{"bob"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMentionRepository()
+			if err := recordMentions(repo, chatRoom{sessions: tt.sessions}, 1, "/tmp/room", tt.senderName, tt.senderToken, tt.message); err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == nil {
+				if len(repo.mentions) != 0 {
+					t.Errorf("stored mentions of %q, want none", repo.mentions)
+				}
+				return
+			}
+			if len(repo.mentions) != 1 || !slices.Equal(repo.mentions[0], tt.want) {
+				t.Errorf("stored mentions of %q, want %q", repo.mentions, tt.want)
+			}
+		})
+	}
+}
+
+// TestChatMessageMentions checks that a chat message that could not be saved leaves no inbox
+// entries pointing at it
+func TestChatMessageMentions(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		saveErr error
+		want    int
+	}{
+		{"saved", nil, 1},
+		{"not saved", errors.New("disk full"), 0},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMentionRepository()
+			repo.saveErr = tt.saveErr
+			streams := chatRoom{sessions: []domain.StreamSession{domain.NewStreamSession("s1", "alice", "tokA", "/tmp/room", "", false)}}
+			u := NewStreamUsecase(repo, streams, newModerator(repo), newWebhookDispatcher(repo, loopbackOptions(1)), domain.NewMessageHooks(), domain.NewMessageLimits(0, 0), 0)
+			if err := u.HandleChatMessage("s1", "@bob look"); err != nil {
+				t.Fatal(err)
+			}
+			if len(repo.mentions) != tt.want {
+				t.Errorf("stored %d mentions, want %d", len(repo.mentions), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package usecase
+
+import (
+	"fmt"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+// normalizeMessage applies the message limits of the room on top of the server defaults
+func normalizeMessage(repo Repository, defaults domain.MessageLimits, room domain.Node, message string) (string, error) {
+	settings, err := repo.GetRoomSettings(room.ID)
+	if err != nil {
+		return "", fmt.Errorf("error getting room settings: %w", err)
+	}
+	return domain.NormalizeMessage(message, defaults.Override(settings.MessageLimits()))
+}
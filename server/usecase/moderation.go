@@ -0,0 +1,279 @@
+package usecase
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+const (
+	moderationLogLimit = 50
+	slowModeMaxSeconds = 24 * 60 * 60
+)
+
+// moderator enforces the bans, mutes and slow mode of rooms
+type moderator struct {
+	repo       Repository
+	mu         sync.Mutex
+	lastPosted map[string]time.Time
+}
+
+func newModerator(repo Repository) *moderator {
+	return &moderator{
+		repo:       repo,
+		lastPosted: make(map[string]time.Time),
+	}
+}
+
+func (m *moderator) isRestricted(room domain.Node, ownerToken string, restrictionType domain.RestrictionType) (bool, error) {
+	return m.repo.HasRoomRestriction(room.ID, ownerToken, restrictionType)
+}
+
+// checkJoin rejects users banned from the room. Bans and mutes hold owner tokens, so clients
+// without one, from the request or from the transport, could be anyone and are refused in
+// rooms with restrictions: a display name is whatever the client claims.
+func (m *moderator) checkJoin(room domain.Node, ownerToken, name string) error {
+	if ownerToken != "" && ownerToken == room.OwnerToken {
+		return nil
+	}
+	if ownerToken == "" {
+		restrictions, err := m.repo.ListRoomRestrictions(room.ID)
+		if err != nil {
+			return fmt.Errorf("error listing restrictions: %w", err)
+		}
+		if len(restrictions) > 0 {
+			return fmt.Errorf("%s has banned or muted users, so an owner token is required: %w", room.Name, domain.ErrPermissionDenied)
+		}
+		return nil
+	}
+	banned, err := m.isRestricted(room, ownerToken, domain.RestrictionBan)
+	if err != nil {
+		return err
+	}
+	if banned {
+		return fmt.Errorf("you are banned from %s: %w", room.Name, domain.ErrPermissionDenied)
+	}
+	return nil
+}
+
+// checkPost rejects messages from banned or muted users and enforces slow mode
+func (m *moderator) checkPost(room domain.Node, ownerToken, name string) error {
+	if ownerToken != "" && ownerToken == room.OwnerToken {
+		return nil
+	}
+	if err := m.checkJoin(room, ownerToken, name); err != nil {
+		return err
+	}
+	muted, err := m.isRestricted(room, ownerToken, domain.RestrictionMute)
+	if err != nil {
+		return err
+	}
+	if muted {
+		return fmt.Errorf("you are muted in %s: %w", room.Name, domain.ErrPermissionDenied)
+	}
+
+	settings, err := m.repo.GetRoomSettings(room.ID)
+	if err != nil {
+		return err
+	}
+	if settings.SlowModeSeconds <= 0 {
+		return nil
+	}
+	interval := time.Duration(settings.SlowModeSeconds) * time.Second
+	identity := ownerToken
+	if identity == "" {
+		identity = "name:" + name
+	}
+	key := fmt.Sprintf("%d/%s", room.ID, identity)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if last, exists := m.lastPosted[key]; exists && now.Sub(last) < interval {
+		wait := interval - now.Sub(last)
+		return fmt.Errorf("slow mode is on in %s: wait %s before sending again", room.Name, wait.Round(time.Second))
+	}
+	m.lastPosted[key] = now
+	if len(m.lastPosted) > 10000 {
+		for k, last := range m.lastPosted {
+			if now.Sub(last) > time.Duration(slowModeMaxSeconds)*time.Second {
+				delete(m.lastPosted, k)
+			}
+		}
+	}
+	return nil
+}
+
+func (u *Usecase) ModerateRoom(path domain.Path, ownerToken string, action domain.ModerationAction, targetName string, slowModeSeconds int, reason string) error {
+	room, err := lookupOwnedRoom(u.repo, path, ownerToken)
+	if err != nil {
+		return fmt.Errorf("error getting room: %w", err)
+	}
+	actorName := displayName(u.repo, ownerToken)
+
+	var tokens []string
+	if action != domain.ModerationSlowMode {
+		if targetName == "" {
+			return fmt.Errorf("target user is required for %s", action)
+		}
+		tokens, err = u.repo.GetTokensByDisplayName(targetName)
+		if err != nil {
+			return fmt.Errorf("error resolving user '%s': %w", targetName, err)
+		}
+		// The owner can never lock themselves out of their own room
+		tokens = slices.DeleteFunc(tokens, func(token string) bool {
+			return token == room.OwnerToken
+		})
+		if len(tokens) == 0 && action != domain.ModerationKick {
+			return fmt.Errorf("user '%s': %w", targetName, domain.ErrNotFound)
+		}
+	}
+
+	detail := reason
+	notice := ""
+	switch action {
+	case domain.ModerationKick:
+		if u.kickSessions(path.String(), tokens, targetName, fmt.Sprintf("you were kicked from #%s by %s", path, actorName)) == 0 {
+			return fmt.Errorf("%s is not in the room", targetName)
+		}
+		notice = fmt.Sprintf("%s was kicked by %s", targetName, actorName)
+	case domain.ModerationBan:
+		for _, token := range tokens {
+			if err := u.repo.CreateRoomRestriction(room.ID, token, domain.RestrictionBan, ownerToken); err != nil {
+				return fmt.Errorf("error banning user: %w", err)
+			}
+		}
+		u.kickSessions(path.String(), tokens, targetName, fmt.Sprintf("you were banned from #%s by %s", path, actorName))
+		notice = fmt.Sprintf("%s was banned by %s", targetName, actorName)
+	case domain.ModerationUnban:
+		for _, token := range tokens {
+			if err := u.repo.DeleteRoomRestriction(room.ID, token, domain.RestrictionBan); err != nil {
+				return fmt.Errorf("error unbanning user: %w", err)
+			}
+		}
+	case domain.ModerationMute:
+		for _, token := range tokens {
+			if err := u.repo.CreateRoomRestriction(room.ID, token, domain.RestrictionMute, ownerToken); err != nil {
+				return fmt.Errorf("error muting user: %w", err)
+			}
+		}
+		notice = fmt.Sprintf("%s was muted by %s", targetName, actorName)
+	case domain.ModerationUnmute:
+		for _, token := range tokens {
+			if err := u.repo.DeleteRoomRestriction(room.ID, token, domain.RestrictionMute); err != nil {
+				return fmt.Errorf("error unmuting user: %w", err)
+			}
+		}
+	case domain.ModerationSlowMode:
+		if slowModeSeconds < 0 || slowModeSeconds > slowModeMaxSeconds {
+			return fmt.Errorf("slow mode must be between 0 and %d seconds", slowModeMaxSeconds)
+		}
+		settings, err := u.repo.GetRoomSettings(room.ID)
+		if err != nil {
+			return fmt.Errorf("error getting room settings: %w", err)
+		}
+		settings.SlowModeSeconds = slowModeSeconds
+		if err := u.repo.UpsertRoomSettings(settings); err != nil {
+			return fmt.Errorf("error updating slow mode: %w", err)
+		}
+		detail = fmt.Sprintf("%ds", slowModeSeconds)
+		notice = fmt.Sprintf("slow mode set to %ds by %s", slowModeSeconds, actorName)
+		if slowModeSeconds == 0 {
+			notice = fmt.Sprintf("slow mode turned off by %s", actorName)
+		}
+	default:
+		return fmt.Errorf("unknown moderation action")
+	}
+
+	if err := u.repo.CreateModerationLog(domain.NewModerationLog(0, room.ID, actorName, action, targetName, detail, time.Now())); err != nil {
+		return fmt.Errorf("error recording moderation log: %w", err)
+	}
+	if notice != "" && u.streamManager.IsRoomActive(path.String()) {
+		if err := u.streamManager.BroadcastMessage(path.String(), domain.SystemSenderName, notice); err != nil {
+			fmt.Printf("Error broadcasting moderation notice: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// kickSessions ends the live sessions of the target user in the room and returns how many were ended
+func (u *Usecase) kickSessions(roomPath string, tokens []string, targetName, notice string) int {
+	kicked := 0
+	for _, session := range u.streamManager.GetActiveClients(roomPath) {
+		target := slices.Contains(tokens, session.OwnerToken)
+		if session.OwnerToken == "" {
+			target = session.Name == targetName
+		}
+		if !target {
+			continue
+		}
+		if err := u.streamManager.SendToSession(session.ID, domain.NewMessageEvent("", roomPath, domain.SystemSenderName, notice)); err != nil {
+			fmt.Printf("Error notifying kicked session %s: %v\n", session.ID, err)
+		}
+		if err := u.streamManager.KickSession(session.ID); err != nil {
+			fmt.Printf("Error kicking session %s: %v\n", session.ID, err)
+			continue
+		}
+		kicked++
+	}
+	return kicked
+}
+
+func (u *Usecase) GetRoomModeration(path domain.Path, ownerToken string) (domain.RoomModeration, error) {
+	room, err := lookupOwnedRoom(u.repo, path, ownerToken)
+	if err != nil {
+		return domain.RoomModeration{}, fmt.Errorf("error getting room: %w", err)
+	}
+	restrictions, err := u.repo.ListRoomRestrictions(room.ID)
+	if err != nil {
+		return domain.RoomModeration{}, fmt.Errorf("error listing restrictions: %w", err)
+	}
+	settings, err := u.repo.GetRoomSettings(room.ID)
+	if err != nil {
+		return domain.RoomModeration{}, fmt.Errorf("error getting room settings: %w", err)
+	}
+	logs, err := u.repo.ListModerationLogs(room.ID, moderationLogLimit)
+	if err != nil {
+		return domain.RoomModeration{}, fmt.Errorf("error listing moderation logs: %w", err)
+	}
+	return domain.RoomModeration{
+		Restrictions: restrictions,
+		Settings:     settings,
+		Limits:       u.messageLimits.Override(settings.MessageLimits()),
+		Logs:         logs,
+	}, nil
+}
+
+// SetRoomLimits overrides the server's message limits for the room; zero restores the default
+func (u *Usecase) SetRoomLimits(path domain.Path, ownerToken string, limits domain.MessageLimits) error {
+	room, err := lookupOwnedRoom(u.repo, path, ownerToken)
+	if err != nil {
+		return fmt.Errorf("error getting room: %w", err)
+	}
+	if limits.MaxLength < 0 || limits.MaxLength > domain.MaxMessageLengthLimit {
+		return &domain.InvalidMessageError{Field: "max_message_length", Reason: fmt.Sprintf("message length limit must be between 0 and %d", domain.MaxMessageLengthLimit)}
+	}
+	if limits.MaxLines < 0 || limits.MaxLines > domain.MaxMessageLinesLimit {
+		return &domain.InvalidMessageError{Field: "max_message_lines", Reason: fmt.Sprintf("message line limit must be between 0 and %d", domain.MaxMessageLinesLimit)}
+	}
+
+	settings, err := u.repo.GetRoomSettings(room.ID)
+	if err != nil {
+		return fmt.Errorf("error getting room settings: %w", err)
+	}
+	settings.MaxMessageLength = limits.MaxLength
+	settings.MaxMessageLines = limits.MaxLines
+	if err := u.repo.UpsertRoomSettings(settings); err != nil {
+		return fmt.Errorf("error updating message limits: %w", err)
+	}
+
+	actorName := displayName(u.repo, ownerToken)
+	detail := fmt.Sprintf("length=%d lines=%d", limits.MaxLength, limits.MaxLines)
+	if err := u.repo.CreateModerationLog(domain.NewModerationLog(0, room.ID, actorName, domain.ModerationLimits, "", detail, time.Now())); err != nil {
+		return fmt.Errorf("error recording moderation log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package usecase
+
+import (
+	"fmt"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+func (u *Usecase) GetReadMarker(path domain.Path, ownerToken string) (int, error) {
+	node, err := lookupRoom(u.repo, path)
+	if err != nil {
+		return 0, fmt.Errorf("error getting room: %w", err)
+	}
+	lastMessageID, err := u.repo.GetReadMarker(ownerToken, node.ID)
+	if err != nil {
+		return 0, fmt.Errorf("error getting read marker: %w", err)
+	}
+	return lastMessageID, nil
+}
+
+// MarkRead moves the read marker of the room to messageID, or to its latest message when messageID is 0
+func (u *Usecase) MarkRead(path domain.Path, ownerToken string, messageID int) error {
+	if ownerToken == "" {
+		return fmt.Errorf("owner token is required")
+	}
+	node, err := lookupRoom(u.repo, path)
+	if err != nil {
+		return fmt.Errorf("error getting room: %w", err)
+	}
+	if err := u.repo.UpsertReadMarker(ownerToken, node.ID, messageID); err != nil {
+		return fmt.Errorf("error marking room as read: %w", err)
+	}
+	return nil
+}
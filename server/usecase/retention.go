@@ -0,0 +1,283 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+// effectiveRetention returns the policy of the node at nodePath, or else of its closest
+// directory with one; ok is false when no policy applies
+func effectiveRetention(repo Repository, node domain.Node, nodePath domain.Path) (domain.RetentionPolicy, bool, error) {
+	for {
+		policy, err := repo.GetRetentionPolicy(node.Type, node.ID)
+		if err == nil {
+			return policy, true, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return domain.RetentionPolicy{}, false, err
+		}
+		if nodePath.String() == "/" {
+			return domain.RetentionPolicy{}, false, nil
+		}
+		nodePath = nodePath.Parent()
+		if node, err = lookupDirectory(repo, nodePath); err != nil {
+			return domain.RetentionPolicy{}, false, err
+		}
+	}
+}
+
+// fillRetention sets the policy in effect for each node in nodes, which are the entries of
+// the directory at dirPath, or the node at dirPath itself
+func fillRetention(repo Repository, dir domain.Node, dirPath domain.Path, nodes []domain.Node) error {
+	inherited, inherits, err := effectiveRetention(repo, dir, dirPath)
+	if err != nil {
+		return err
+	}
+	for i, n := range nodes {
+		if n.Type == dir.Type && n.ID == dir.ID {
+			if inherits {
+				nodes[i].Retention = &inherited
+			}
+			continue
+		}
+		policy, err := repo.GetRetentionPolicy(n.Type, n.ID)
+		switch {
+		case err == nil:
+			nodes[i].Retention = &policy
+		case !errors.Is(err, ErrNotFound):
+			return err
+		case inherits:
+			nodes[i].Retention = &inherited
+		}
+	}
+	return nil
+}
+
+// StatPath returns the node at path along with the retention policy in effect for it
+func (u *Usecase) StatPath(nodePath domain.Path, ownerToken string) (domain.Node, error) {
+	node, err := lookupNode(u.repo, nodePath)
+	if err != nil {
+		return domain.Node{}, fmt.Errorf("error getting path: %w", err)
+	}
+	nodes := []domain.Node{node}
+	if err := fillRetention(u.repo, node, nodePath, nodes); err != nil {
+		return domain.Node{}, fmt.Errorf("error getting retention policy: %w", err)
+	}
+	return nodes[0], nil
+}
+
+// SetRetention sets the retention policy of a room, or of a directory for the rooms below
+// it; inherit removes the node's own policy instead. An unbounded policy keeps everything,
+// overriding that of the directories above.
+func (u *Usecase) SetRetention(nodePath domain.Path, ownerToken string, policy domain.RetentionPolicy, inherit bool) error {
+	node, err := lookupNode(u.repo, nodePath)
+	if err != nil {
+		return fmt.Errorf("error getting path: %w", err)
+	}
+	if node.OwnerToken != ownerToken {
+		return domain.NewPathError(nodePath, domain.ErrPermissionDenied)
+	}
+	if inherit {
+		if err := u.repo.DeleteRetentionPolicy(node.Type, node.ID); err != nil {
+			return fmt.Errorf("error removing retention policy: %w", err)
+		}
+		return nil
+	}
+	if policy.MaxAge < 0 {
+		return &domain.InvalidMessageError{Field: "max_age_seconds", Reason: "maximum age must not be negative"}
+	}
+	if policy.MaxMessages < 0 || policy.MaxMessages > domain.MaxRetentionMessages {
+		return &domain.InvalidMessageError{Field: "max_messages", Reason: fmt.Sprintf("message count must be between 0 and %d", domain.MaxRetentionMessages)}
+	}
+	if policy.IdleTTL < 0 {
+		return &domain.InvalidMessageError{Field: "idle_seconds", Reason: "idle time must not be negative"}
+	}
+	policy.NodeType = node.Type
+	policy.NodeID = node.ID
+	policy.Path = nodePath.String()
+	if err := u.repo.UpsertRetentionPolicy(policy); err != nil {
+		return fmt.Errorf("error setting retention policy: %w", err)
+	}
+	return nil
+}
+
+// retentionJanitor enforces retention policies, and purges the trash, in the background.
+// Messages are deleted a batch at a time, each batch its own transaction, so that writers
+// to the room never wait long; the sessions in the room are told which messages went.
+type retentionJanitor struct {
+	repo          Repository
+	streamManager domain.StreamManager
+	webhooks      *webhookDispatcher
+	options       domain.RetentionOptions
+}
+
+func newRetentionJanitor(repo Repository, streamManager domain.StreamManager, webhooks *webhookDispatcher, options domain.RetentionOptions) *retentionJanitor {
+	return &retentionJanitor{
+		repo:          repo,
+		streamManager: streamManager,
+		webhooks:      webhooks,
+		options:       options,
+	}
+}
+
+// run sweeps every Interval until the process exits
+func (j *retentionJanitor) run() {
+	for {
+		j.sweep(time.Now())
+		j.purgeTrash(time.Now())
+		time.Sleep(j.options.Interval)
+	}
+}
+
+// purgeTrash deletes for good what was removed more than TrashPurgeAfter ago
+func (j *retentionJanitor) purgeTrash(now time.Time) {
+	if j.options.TrashPurgeAfter <= 0 {
+		return
+	}
+	purged, err := j.repo.PurgeTrashEntries("", now.Add(-j.options.TrashPurgeAfter))
+	if err != nil {
+		fmt.Printf("Error purging trash: %v\n", err)
+		return
+	}
+	if purged > 0 {
+		fmt.Printf("Purged %d trash entries older than %s\n", purged, j.options.TrashPurgeAfter)
+	}
+}
+
+// sweep applies every policy to the rooms it is in effect for. A directory's policy covers the
+// rooms below it, except below the nodes with a policy of their own, which get their turn.
+func (j *retentionJanitor) sweep(now time.Time) {
+	policies, err := j.repo.ListRetentionPolicies()
+	if err != nil {
+		fmt.Printf("Error listing retention policies: %v\n", err)
+		return
+	}
+	own := make(map[string]bool, len(policies))
+	for _, policy := range policies {
+		own[policy.Path] = true
+	}
+	for _, policy := range policies {
+		if policy.IsUnbounded() {
+			continue
+		}
+		if policy.NodeType == domain.NodeTypeRoom {
+			room, err := j.repo.GetNodeByPath(domain.NewPath(policy.Path))
+			if err != nil {
+				fmt.Printf("Error getting room %s: %v\n", policy.Path, err)
+				continue
+			}
+			j.enforce(room, policy.Path, policy, now)
+			continue
+		}
+		j.walk(policy.NodeID, policy.Path, policy, own, now)
+	}
+}
+
+func (j *retentionJanitor) walk(dirID int, dirPath string, policy domain.RetentionPolicy, own map[string]bool, now time.Time) {
+	children, err := j.repo.ListNodes(dirID)
+	if err != nil {
+		fmt.Printf("Error listing nodes of %s: %v\n", dirPath, err)
+		return
+	}
+	for _, child := range children {
+		childPath := path.Join(dirPath, child.Name)
+		if own[childPath] {
+			continue
+		}
+		if child.Type == domain.NodeTypeDirectory {
+			j.walk(child.ID, childPath, policy, own, now)
+			continue
+		}
+		j.enforce(child, childPath, policy, now)
+	}
+}
+
+// enforce removes the room when it has been idle too long, or else its expired messages
+func (j *retentionJanitor) enforce(room domain.Node, roomPath string, policy domain.RetentionPolicy, now time.Time) {
+	if policy.IdleTTL > 0 {
+		idle, err := j.idle(room, roomPath, policy, now)
+		if err != nil {
+			fmt.Printf("Error checking activity of %s: %v\n", roomPath, err)
+			return
+		}
+		if idle {
+			j.removeRoom(room, roomPath, policy)
+			return
+		}
+	}
+	if policy.MaxAge > 0 {
+		j.expire(roomPath, func() ([]int, error) {
+			return j.repo.DeleteMessagesBefore(room.ID, now.Add(-policy.MaxAge), j.options.BatchSize)
+		})
+	}
+	if policy.MaxMessages > 0 {
+		j.expire(roomPath, func() ([]int, error) {
+			return j.repo.DeleteMessagesBeyond(room.ID, policy.MaxMessages, j.options.BatchSize)
+		})
+	}
+}
+
+// idle reports whether nothing was written to the room for the policy's IdleTTL. Rooms with
+// someone in them are in use, however quiet.
+func (j *retentionJanitor) idle(room domain.Node, roomPath string, policy domain.RetentionPolicy, now time.Time) (bool, error) {
+	if j.streamManager.IsRoomActive(roomPath) {
+		return false, nil
+	}
+	lastActive := room.CreatedAt
+	latest, err := j.repo.ListMessages(room.ID, 1, 0)
+	if err != nil {
+		return false, err
+	}
+	if len(latest) > 0 && latest[0].CreatedAt.After(lastActive) {
+		lastActive = latest[0].CreatedAt
+	}
+	return policy.IsIdle(lastActive, now), nil
+}
+
+// expire calls deleteBatch until a batch comes back short
+func (j *retentionJanitor) expire(roomPath string, deleteBatch func() ([]int, error)) {
+	for {
+		ids, err := deleteBatch()
+		if err != nil {
+			fmt.Printf("Error expiring messages of %s: %v\n", roomPath, err)
+			return
+		}
+		if len(ids) > 0 && j.streamManager.IsRoomActive(roomPath) {
+			if err := j.streamManager.BroadcastToRoom(roomPath, domain.NewExpireEvent(roomPath, ids)); err != nil {
+				fmt.Printf("Error broadcasting expiry to %s: %v\n", roomPath, err)
+			}
+		}
+		if len(ids) < j.options.BatchSize {
+			return
+		}
+	}
+}
+
+// removeRoom takes an idle room out of the tree the way rm does: into the trash of its owner,
+// who can restore it until the trash is purged, or for good when the trash is off
+func (j *retentionJanitor) removeRoom(room domain.Node, roomPath string, policy domain.RetentionPolicy) {
+	var err error
+	if j.options.TrashPurgeAfter > 0 {
+		_, err = j.repo.TrashNode(domain.NodeTypeRoom, room.ID)
+	} else {
+		err = j.repo.DeleteRoom(room.ID)
+	}
+	if err != nil {
+		fmt.Printf("Error removing idle room %s: %v\n", roomPath, err)
+		return
+	}
+	j.webhooks.emit(domain.WebhookEventDelete, webhookPayload{
+		Path:     roomPath,
+		NodeType: webhookNodeType(domain.NodeTypeRoom),
+		Actor:    domain.SystemSenderName,
+	}, roomPath)
+	if j.options.TrashPurgeAfter > 0 {
+		fmt.Printf("Moved %s to the trash, idle for %s under the retention policy of %s\n", roomPath, policy.IdleTTL, policy.Path)
+		return
+	}
+	fmt.Printf("Deleted %s, idle for %s under the retention policy of %s\n", roomPath, policy.IdleTTL, policy.Path)
+}
@@ -0,0 +1,90 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+// retentionRepository holds the latest message of each room and records what the janitor
+// removes; webhooks come from the embedded webhookRepository
+type retentionRepository struct {
+	*webhookRepository
+	latest  map[int]domain.Message
+	trashed []int
+	deleted []int
+}
+
+func (r *retentionRepository) ListMessages(roomID, limit, offset int) ([]domain.Message, error) {
+	if msg, ok := r.latest[roomID]; ok {
+		return []domain.Message{msg}, nil
+	}
+	return nil, nil
+}
+
+func (r *retentionRepository) TrashNode(nodeType domain.NodeType, nodeID int) (int, error) {
+	r.trashed = append(r.trashed, nodeID)
+	return len(r.trashed), nil
+}
+
+func (r *retentionRepository) DeleteRoom(roomID int) error {
+	r.deleted = append(r.deleted, roomID)
+	return nil
+}
+
+// activeRooms reports the rooms in it as having someone in them
+type activeRooms struct {
+	domain.StreamManager
+	rooms map[string]bool
+}
+
+func (a activeRooms) IsRoomActive(roomPath string) bool {
+	return a.rooms[roomPath]
+}
+
+// TestRetentionIdleRooms checks which rooms the janitor finds idle, and that those go to the
+// trash when it is on
+func TestRetentionIdleRooms(t *testing.T) {
+	now := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	policy := domain.RetentionPolicy{NodeType: domain.NodeTypeDirectory, Path: "/tmp", IdleTTL: 24 * time.Hour}
+	for _, tt := range []struct {
+		name    string
+		created time.Time
+		// latest is when the last message was written, zero for none
+		latest     time.Time
+		active     bool
+		trashAfter time.Duration
+		trashed    bool
+		deleted    bool
+	}{
+		{"new and empty", now.Add(-time.Hour), time.Time{}, false, time.Hour, false, false},
+		{"old and empty", now.AddDate(0, 0, -2), time.Time{}, false, time.Hour, true, false},
+		{"old with a recent message", now.AddDate(0, 0, -2), now.Add(-time.Hour), false, time.Hour, false, false},
+		{"old with an old message", now.AddDate(0, 0, -3), now.AddDate(0, 0, -2), false, time.Hour, true, false},
+		{"quiet with someone in it", now.AddDate(0, 0, -2), now.AddDate(0, 0, -2), true, time.Hour, false, false},
+		{"idle with the trash off", now.AddDate(0, 0, -2), time.Time{}, false, 0, false, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &retentionRepository{webhookRepository: newWebhookRepository(), latest: map[int]domain.Message{}}
+			if !tt.latest.IsZero() {
+				repo.latest[1] = domain.NewMessage(10, 1, "alice", "hi", tt.latest)
+			}
+			j := newRetentionJanitor(
+				repo,
+				activeRooms{rooms: map[string]bool{"/tmp/room": tt.active}},
+				newWebhookDispatcher(repo, loopbackOptions(1)),
+				domain.NewRetentionOptions(time.Minute, 100, tt.trashAfter),
+			)
+			room := domain.Node{ID: 1, Name: "room", Type: domain.NodeTypeRoom, CreatedAt: tt.created}
+			j.enforce(room, "/tmp/room", policy, now)
+
+			if trashed := len(repo.trashed) == 1 && repo.trashed[0] == 1; trashed != tt.trashed || len(repo.trashed) > 1 {
+				t.Errorf("trashed %v, want the room trashed: %t", repo.trashed, tt.trashed)
+			}
+			if deleted := len(repo.deleted) == 1 && repo.deleted[0] == 1; deleted != tt.deleted || len(repo.deleted) > 1 {
+				t.Errorf("deleted %v, want the room deleted: %t", repo.deleted, tt.deleted)
+			}
+		})
+	}
+}
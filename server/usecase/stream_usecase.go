@@ -3,6 +3,7 @@ package usecase
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/ponyo877/chatsh/server/domain"
 )
@@ -11,13 +12,24 @@ import (
 type StreamUsecase struct {
 	repo          Repository
 	streamManager domain.StreamManager
+	moderator     *moderator
+	webhooks      *webhookDispatcher
+	messageHooks  domain.MessageHooks
+	messageLimits domain.MessageLimits
+	// sessionTimeout ends chat sessions that stay silent this long; zero keeps them open
+	sessionTimeout time.Duration
 }
 
 // NewStreamUsecase creates a new stream usecase
-func NewStreamUsecase(repo Repository, streamManager domain.StreamManager) *StreamUsecase {
+func NewStreamUsecase(repo Repository, streamManager domain.StreamManager, moderator *moderator, webhooks *webhookDispatcher, messageHooks domain.MessageHooks, messageLimits domain.MessageLimits, sessionTimeout time.Duration) *StreamUsecase {
 	return &StreamUsecase{
-		repo:          repo,
-		streamManager: streamManager,
+		repo:           repo,
+		streamManager:  streamManager,
+		moderator:      moderator,
+		webhooks:       webhooks,
+		messageHooks:   messageHooks,
+		messageLimits:  messageLimits,
+		sessionTimeout: sessionTimeout,
 	}
 }
 
@@ -34,9 +46,37 @@ func (u *StreamUsecase) HandleStreamSession(
 	defer u.streamManager.UnregisterSession(sessionID)
 
 	var sessionInitialized bool
+	kicked := u.streamManager.Kicked(sessionID)
+
+	// Idle chat sessions are checked a few times per timeout period
+	var idleCheck <-chan time.Time
+	if u.sessionTimeout > 0 {
+		ticker := time.NewTicker(max(u.sessionTimeout/4, time.Second))
+		defer ticker.Stop()
+		idleCheck = ticker.C
+	}
+
+	// Process incoming requests until the client leaves, is kicked by a moderator or times out
+	for {
+		var request domain.StreamRequest
+		select {
+		case req, ok := <-requestChan:
+			if !ok {
+				return u.endSession(sessionID, sessionInitialized, nil)
+			}
+			request = req
+		case <-kicked:
+			return u.endSession(sessionID, sessionInitialized, fmt.Errorf("kicked from room"))
+		case <-idleCheck:
+			if !sessionInitialized || u.streamManager.ValidateSession(sessionID) {
+				continue
+			}
+			if session, exists := u.streamManager.GetSession(sessionID); exists {
+				u.sendNotice(sessionID, session.RoomPath, fmt.Sprintf("session closed after %s of inactivity", u.sessionTimeout))
+			}
+			return u.endSession(sessionID, sessionInitialized, fmt.Errorf("session timed out"))
+		}
 
-	// Process incoming requests
-	for request := range requestChan {
 		if !sessionInitialized {
 			// Handle initial request (join or tail)
 			_, err := u.HandleInitialRequest(request, sessionID, remote)
@@ -50,21 +90,23 @@ func (u *StreamUsecase) HandleStreamSession(
 
 		// Handle subsequent requests (chat messages)
 		if request.Type == domain.RequestChat {
+			u.streamManager.TouchSession(sessionID)
 			if err := u.HandleChatMessage(sessionID, request.Message); err != nil {
 				responseChan <- domain.NewStreamError(err)
 				// Don't return error for chat message failures, continue processing
 			}
 		}
 	}
+}
 
-	// Clean up session when request channel is closed
+// endSession cleans up an initialized session and returns reason as the result of the stream
+func (u *StreamUsecase) endSession(sessionID string, sessionInitialized bool, reason error) error {
 	if sessionInitialized {
 		if err := u.HandleSessionEnd(sessionID); err != nil {
 			fmt.Printf("Error ending session %s: %v\n", sessionID, err)
 		}
 	}
-
-	return nil
+	return reason
 }
 
 // HandleInitialRequest processes the first request from a streaming client
@@ -84,10 +126,16 @@ func (u *StreamUsecase) HandleInitialRequest(
 	}
 
 	// Validate room exists and is actually a room
-	if err := u.validateRoom(roomPath); err != nil {
+	roomNode, err := u.validateRoom(roomPath)
+	if err != nil {
 		return domain.StreamSession{}, fmt.Errorf("room validation failed: %w", err)
 	}
 
+	// Banned users can neither join nor tail the room
+	if err := u.moderator.checkJoin(roomNode, request.OwnerToken, request.Name); err != nil {
+		return domain.StreamSession{}, err
+	}
+
 	// Handle different request types
 	switch request.Type {
 	case domain.RequestJoin:
@@ -108,8 +156,7 @@ func (u *StreamUsecase) HandleChatMessage(sessionID, message string) error {
 	}
 
 	// Validate message
-	trimmedMessage := strings.TrimSpace(message)
-	if trimmedMessage == "" {
+	if strings.TrimSpace(message) == "" {
 		return nil // Ignore empty messages
 	}
 
@@ -119,20 +166,60 @@ func (u *StreamUsecase) HandleChatMessage(sessionID, message string) error {
 		return fmt.Errorf("failed to get room details: %w", err)
 	}
 
+	// Tell the sender why the message was refused instead of dropping it silently
+	if err := u.moderator.checkPost(roomNode, session.OwnerToken, session.Name); err != nil {
+		u.sendNotice(sessionID, session.RoomPath, err.Error())
+		return nil
+	}
+	trimmedMessage, err := normalizeMessage(u.repo, u.messageLimits, roomNode, message)
+	if err != nil {
+		u.sendNotice(sessionID, session.RoomPath, err.Error())
+		return nil
+	}
+	trimmedMessage, err = u.messageHooks.BeforeWrite(domain.NewHookMessage(session.RoomPath, session.Name, trimmedMessage))
+	if err != nil {
+		u.sendNotice(sessionID, session.RoomPath, err.Error())
+		return nil
+	}
+	if trimmedMessage == "" {
+		return nil // Nothing left after removing control sequences
+	}
+
 	// Broadcast message to room
 	if err := u.streamManager.BroadcastMessage(session.RoomPath, session.Name, trimmedMessage); err != nil {
 		return fmt.Errorf("failed to broadcast message: %w", err)
 	}
 
 	// Save message to database
-	if err := u.repo.CreateMessage(roomNode.ID, session.Name, trimmedMessage); err != nil {
+	_, saveErr := u.repo.CreateMessage(roomNode.ID, session.Name, trimmedMessage)
+	if saveErr != nil {
 		// Log error but don't fail the broadcast
-		fmt.Printf("Error saving chat message to DB for roomID %d: %v\n", roomNode.ID, err)
+		fmt.Printf("Error saving chat message to DB for roomID %d: %v\n", roomNode.ID, saveErr)
+	}
+
+	u.messageHooks.AfterWrite(domain.NewHookMessage(session.RoomPath, session.Name, trimmedMessage))
+
+	// Queue the message for webhooks; the deliveries run in the background
+	u.webhooks.emitMessage(session.RoomPath, session.Name, trimmedMessage)
+
+	// Notify mentioned users who are not in the room, of a message they will find there
+	if saveErr == nil {
+		if err := recordMentions(u.repo, u.streamManager, roomNode.ID, session.RoomPath, session.Name, session.OwnerToken, trimmedMessage); err != nil {
+			fmt.Printf("Error saving mentions for roomID %d: %v\n", roomNode.ID, err)
+		}
 	}
 
 	return nil
 }
 
+// sendNotice delivers a server notice to a single session
+func (u *StreamUsecase) sendNotice(sessionID, roomPath, message string) {
+	notice := domain.NewMessageEvent("", roomPath, domain.SystemSenderName, message)
+	if err := u.streamManager.SendToSession(sessionID, notice); err != nil {
+		fmt.Printf("Error sending notice to %s: %v\n", sessionID, err)
+	}
+}
+
 // HandleSessionEnd processes the end of a streaming session
 func (u *StreamUsecase) HandleSessionEnd(sessionID string) error {
 	// Get session before removing it
@@ -155,7 +242,7 @@ func (u *StreamUsecase) HandleSessionEnd(sessionID string) error {
 			}
 
 			// Save leave message to database
-			if err := u.repo.CreateMessage(roomNode.ID, session.Name, leaveMessage); err != nil {
+			if _, err := u.repo.CreateMessage(roomNode.ID, session.Name, leaveMessage); err != nil {
 				fmt.Printf("Error saving leave message to DB for roomID %d: %v\n", roomNode.ID, err)
 			}
 		}
@@ -171,15 +258,12 @@ func (u *StreamUsecase) GetStreamStats() domain.StreamStats {
 }
 
 // validateRoom checks if the given path is a valid room
-func (u *StreamUsecase) validateRoom(roomPath string) error {
-	roomNode, err := u.repo.GetNodeByPath(domain.NewPath(roomPath))
+func (u *StreamUsecase) validateRoom(roomPath string) (domain.Node, error) {
+	roomNode, err := lookupRoom(u.repo, domain.NewPath(roomPath))
 	if err != nil {
-		return fmt.Errorf("failed to get room details from DB for '%s': %w", roomPath, err)
+		return domain.Node{}, fmt.Errorf("failed to get room details from DB: %w", err)
 	}
-	if roomNode.Type != domain.NodeTypeRoom {
-		return fmt.Errorf("path '%s' is not a room", roomPath)
-	}
-	return nil
+	return roomNode, nil
 }
 
 // handleJoinRequest processes a join request
@@ -188,13 +272,17 @@ func (u *StreamUsecase) handleJoinRequest(
 	sessionID, remote, roomPath string,
 ) (domain.StreamSession, error) {
 	// Set default client name if not provided
-	clientName := request.Name
-	if clientName == "" {
-		clientName = remote
+	clientName := remote
+	if request.Name != "" {
+		name, err := domain.NormalizeDisplayName("name", request.Name)
+		if err != nil {
+			return domain.StreamSession{}, err
+		}
+		clientName = name
 	}
 
 	// Create session
-	session := domain.NewStreamSession(sessionID, clientName, roomPath, remote, false)
+	session := domain.NewStreamSession(sessionID, clientName, request.OwnerToken, roomPath, remote, false)
 
 	// Join room
 	if err := u.streamManager.JoinRoom(session); err != nil {
@@ -216,7 +304,7 @@ func (u *StreamUsecase) handleJoinRequest(
 	}
 
 	// Save join message to database
-	if err := u.repo.CreateMessage(roomNode.ID, clientName, joinMessage); err != nil {
+	if _, err := u.repo.CreateMessage(roomNode.ID, clientName, joinMessage); err != nil {
 		fmt.Printf("Error saving join message to DB for roomID %d: %v\n", roomNode.ID, err)
 	}
 
@@ -229,7 +317,7 @@ func (u *StreamUsecase) handleTailRequest(
 	sessionID, remote, roomPath string,
 ) (domain.StreamSession, error) {
 	// Create tail session (no client name needed for tail mode)
-	session := domain.NewStreamSession(sessionID, remote, roomPath, remote, true)
+	session := domain.NewStreamSession(sessionID, remote, request.OwnerToken, roomPath, remote, true)
 
 	// Join room (but don't broadcast join message for tail mode)
 	if err := u.streamManager.JoinRoom(session); err != nil {
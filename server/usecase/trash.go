@@ -0,0 +1,94 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	pathpkg "path"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+// ListTrash returns the rooms and directories the user removed, the most recently removed first
+func (u *Usecase) ListTrash(ownerToken string) ([]domain.TrashEntry, error) {
+	entries, err := u.repo.ListTrashEntries(ownerToken)
+	if err != nil {
+		return nil, fmt.Errorf("error listing trash: %w", err)
+	}
+	if u.retentionOptions.Interval > 0 {
+		for i := range entries {
+			entries[i].PurgeAt = entries[i].DeletedAt.Add(u.retentionOptions.TrashPurgeAfter)
+		}
+	}
+	return entries, nil
+}
+
+func (u *Usecase) lookupOwnedTrashEntry(id int, ownerToken string) (domain.TrashEntry, error) {
+	entry, err := u.repo.GetTrashEntry(id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return domain.TrashEntry{}, fmt.Errorf("trash entry %d: %w", id, domain.ErrNotFound)
+		}
+		return domain.TrashEntry{}, fmt.Errorf("error getting trash entry: %w", err)
+	}
+	if entry.OwnerToken != ownerToken {
+		return domain.TrashEntry{}, fmt.Errorf("trash entry %d: %w", id, domain.ErrPermissionDenied)
+	}
+	return entry, nil
+}
+
+// RestoreTrash puts a removed node back where it was and returns its path. When dstPath is
+// given the node goes there instead, into it when it is a directory, as mv does. A node whose
+// directory is gone goes to /lost+found; one whose path is taken stays in the trash.
+func (u *Usecase) RestoreTrash(id int, ownerToken, dstPath string) (domain.Path, error) {
+	entry, err := u.lookupOwnedTrashEntry(id, ownerToken)
+	if err != nil {
+		return domain.Path{}, err
+	}
+	target := domain.NewPath(entry.Path)
+	if dstPath != "" {
+		target = domain.NewPath(dstPath)
+		dstNode, err := u.repo.GetNodeByPath(target)
+		switch {
+		case err == nil && dstNode.Type == domain.NodeTypeDirectory:
+			target = domain.NewPath(pathpkg.Join(target.String(), entry.Name))
+		case err == nil:
+			return domain.Path{}, domain.NewPathError(target, domain.ErrAlreadyExists)
+		case !errors.Is(err, ErrNotFound):
+			return domain.Path{}, fmt.Errorf("error getting destination path: %w", err)
+		}
+	}
+	parent, err := u.repo.GetNodeByPath(target.Parent())
+	if errors.Is(err, ErrNotFound) && dstPath == "" {
+		target = domain.NewPath(pathpkg.Join(domain.LostAndFoundPath, entry.Name))
+		parent, err = lookupDirectory(u.repo, target.Parent())
+	} else if errors.Is(err, ErrNotFound) {
+		return domain.Path{}, domain.NewPathError(target.Parent(), domain.ErrNotFound)
+	}
+	if err != nil {
+		return domain.Path{}, fmt.Errorf("error getting parent directory: %w", err)
+	}
+	if parent.Type != domain.NodeTypeDirectory {
+		return domain.Path{}, domain.NewPathError(target.Parent(), domain.ErrNotADirectory)
+	}
+	if err := u.checkVacant(target); err != nil {
+		return domain.Path{}, err
+	}
+	if err := u.repo.RestoreTrashEntry(entry.ID, parent.ID, target.Parent().String(), target.NodeName()); err != nil {
+		if errors.Is(err, ErrAlreadyExists) {
+			return domain.Path{}, domain.NewPathError(target, domain.ErrAlreadyExists)
+		}
+		return domain.Path{}, fmt.Errorf("error restoring trash entry %d: %w", entry.ID, err)
+	}
+	u.emitNodeEvent(domain.WebhookEventCreate, entry.NodeType, target.String(), "", ownerToken)
+	return target, nil
+}
+
+// EmptyTrash deletes everything in the user's trash for good and returns how many entries went
+func (u *Usecase) EmptyTrash(ownerToken string) (int, error) {
+	purged, err := u.repo.PurgeTrashEntries(ownerToken, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("error emptying trash: %w", err)
+	}
+	return purged, nil
+}
@@ -1,8 +1,11 @@
 package usecase
 
 import (
+	"errors"
 	"fmt"
+	pathpkg "path"
 	"sync"
+	"time"
 
 	"github.com/ponyo877/chatsh/server/adaptor"
 	"github.com/ponyo877/chatsh/server/domain"
@@ -17,15 +20,41 @@ type Usecase struct {
 	rooms         sync.Map
 	streamManager domain.StreamManager
 	streamUsecase *StreamUsecase
+	moderator     *moderator
+	webhooks      *webhookDispatcher
+	messageHooks  domain.MessageHooks
+	messageLimits domain.MessageLimits
+	// retentionOptions tell how long DeletePath keeps nodes in the trash
+	retentionOptions domain.RetentionOptions
+	// admins holds the owner tokens allowed to call the admin RPCs
+	admins map[string]bool
 }
 
-func NewUsecase(repo Repository) adaptor.Usecase {
-	streamManager := domain.NewStreamManager()
+// NewUsecase also starts delivering the webhook events queued in repo, and enforcing the
+// retention policies and purging the trash unless retentionOptions turn that off
+func NewUsecase(repo Repository, messageLimits domain.MessageLimits, sessionTimeout time.Duration, webhookOptions domain.WebhookOptions, retentionOptions domain.RetentionOptions, messageHooks domain.MessageHooks, adminTokens []string) adaptor.Usecase {
+	streamManager := domain.NewStreamManager(sessionTimeout)
+	moderator := newModerator(repo)
+	webhooks := newWebhookDispatcher(repo, webhookOptions)
+	go webhooks.run()
+	admins := make(map[string]bool, len(adminTokens))
+	for _, token := range adminTokens {
+		admins[token] = true
+	}
+	if retentionOptions.Interval > 0 {
+		go newRetentionJanitor(repo, streamManager, webhooks, retentionOptions).run()
+	}
 	return &Usecase{
-		repo:          repo,
-		rooms:         sync.Map{},
-		streamManager: streamManager,
-		streamUsecase: NewStreamUsecase(repo, streamManager),
+		repo:             repo,
+		rooms:            sync.Map{},
+		streamManager:    streamManager,
+		streamUsecase:    NewStreamUsecase(repo, streamManager, moderator, webhooks, messageHooks, messageLimits, sessionTimeout),
+		moderator:        moderator,
+		webhooks:         webhooks,
+		messageHooks:     messageHooks,
+		messageLimits:    messageLimits,
+		retentionOptions: retentionOptions,
+		admins:           admins,
 	}
 }
 
@@ -42,6 +71,11 @@ func (u *Usecase) GetConfig(ownerToken string) (domain.Config, error) {
 }
 
 func (u *Usecase) SetConfig(config domain.Config) error {
+	displayName, err := domain.NormalizeDisplayName("display_name", config.DisplayName)
+	if err != nil {
+		return err
+	}
+	config.DisplayName = displayName
 	if err := u.repo.CreateConfig(config); err != nil {
 		return fmt.Errorf("error setting config: %w", err)
 	}
@@ -49,13 +83,10 @@ func (u *Usecase) SetConfig(config domain.Config) error {
 }
 
 func (u *Usecase) ListMessages(path domain.Path, limit int32) ([]domain.Message, error) {
-	node, err := u.repo.GetNodeByPath(path)
+	node, err := lookupRoom(u.repo, path)
 	if err != nil {
 		return nil, fmt.Errorf("error getting room: %w", err)
 	}
-	if node.Type != domain.NodeTypeRoom {
-		return nil, fmt.Errorf("path '%s' is not a room", path)
-	}
 
 	messages, err := u.repo.ListMessages(node.ID, int(limit), 0)
 	if err != nil {
@@ -64,131 +95,210 @@ func (u *Usecase) ListMessages(path domain.Path, limit int32) ([]domain.Message,
 	return messages, nil
 }
 
+// ListMessagesAfter returns up to limit messages of the room written after the message afterID,
+// oldest first, for paging through the whole history
+func (u *Usecase) ListMessagesAfter(path domain.Path, afterID, limit int) ([]domain.Message, error) {
+	node, err := lookupRoom(u.repo, path)
+	if err != nil {
+		return nil, fmt.Errorf("error getting room: %w", err)
+	}
+
+	messages, err := u.repo.ListMessagesAfter(node.ID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing messages for roomID %d: %w", node.ID, err)
+	}
+	return messages, nil
+}
+
 func (u *Usecase) CreateRoom(path domain.Path, ownerToken string) error {
-	parentNode, err := u.repo.GetNodeByPath(path.Parent())
+	if err := domain.ValidateNodeName("path", path.NodeName()); err != nil {
+		return err
+	}
+	parentNode, err := lookupDirectory(u.repo, path.Parent())
 	if err != nil {
-		return fmt.Errorf("error getting parent room: %w", err)
+		return fmt.Errorf("error getting parent directory: %w", err)
+	}
+	if err := u.checkVacant(path); err != nil {
+		return err
 	}
-	return u.repo.CreateRoom(parentNode.ID, path.Parent().String(), path.NodeName(), ownerToken)
+	if err := u.repo.CreateRoom(parentNode.ID, path.Parent().String(), path.NodeName(), ownerToken); err != nil {
+		return err
+	}
+	u.emitNodeEvent(domain.WebhookEventCreate, domain.NodeTypeRoom, path.String(), "", ownerToken)
+	return nil
 }
 
 func (u *Usecase) CreateDirectory(path domain.Path, ownerToken string) error {
-	parentNode, err := u.repo.GetNodeByPath(path.Parent())
+	if err := domain.ValidateNodeName("path", path.NodeName()); err != nil {
+		return err
+	}
+	parentNode, err := lookupDirectory(u.repo, path.Parent())
 	if err != nil {
 		return fmt.Errorf("error getting parent directory: %w", err)
 	}
-	return u.repo.CreateDirectory(parentNode.ID, path.Parent().String(), path.NodeName(), ownerToken)
+	if err := u.checkVacant(path); err != nil {
+		return err
+	}
+	if err := u.repo.CreateDirectory(parentNode.ID, path.Parent().String(), path.NodeName(), ownerToken); err != nil {
+		return err
+	}
+	u.emitNodeEvent(domain.WebhookEventCreate, domain.NodeTypeDirectory, path.String(), "", ownerToken)
+	return nil
+}
+
+// checkVacant fails when a room or directory already exists at path.
+// Rooms and directories live in separate tables, so the database alone cannot tell.
+func (u *Usecase) checkVacant(path domain.Path) error {
+	_, err := u.repo.GetNodeByPath(path)
+	if err == nil {
+		return domain.NewPathError(path, domain.ErrAlreadyExists)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("error getting path: %w", err)
+	}
+	return nil
 }
 
 func (u *Usecase) DeletePath(path domain.Path, ownerToken string) error {
-	node, err := u.repo.GetNodeByPath(path)
+	node, err := lookupNode(u.repo, path)
 	if err != nil {
 		return fmt.Errorf("error getting path: %w", err)
 	}
 	if node.OwnerToken != ownerToken {
-		return fmt.Errorf("permission denied")
+		return domain.NewPathError(path, domain.ErrPermissionDenied)
 	}
 
 	switch node.Type {
 	case domain.NodeTypeRoom:
-		return u.repo.DeleteRoom(node.ID)
 	case domain.NodeTypeDirectory:
-		return u.repo.DeleteDirectory(node.ID)
+		children, err := u.repo.ListNodes(node.ID)
+		if err != nil {
+			return fmt.Errorf("error listing nodes: %w", err)
+		}
+		if len(children) > 0 {
+			return domain.NewPathError(path, domain.ErrNotEmpty)
+		}
 	default:
 		return fmt.Errorf("broken node")
 	}
+	switch {
+	case u.retentionOptions.TrashPurgeAfter > 0:
+		_, err = u.repo.TrashNode(node.Type, node.ID)
+	case node.Type == domain.NodeTypeRoom:
+		err = u.repo.DeleteRoom(node.ID)
+	default:
+		err = u.repo.DeleteDirectory(node.ID)
+	}
+	if err != nil {
+		return err
+	}
+	u.emitNodeEvent(domain.WebhookEventDelete, node.Type, path.String(), "", ownerToken)
+	return nil
 }
 
 func (u *Usecase) CopyPath(srcPath, dstPath domain.Path, ownerToken string) error {
-	srcNode, err := u.repo.GetNodeByPath(srcPath)
+	srcNode, err := lookupOwnedRoom(u.repo, srcPath, ownerToken)
 	if err != nil {
 		return fmt.Errorf("error getting source path: %w", err)
 	}
-	if srcNode.Type != domain.NodeTypeRoom {
-		return fmt.Errorf("source path is not a file")
-	}
-	if srcNode.OwnerToken != ownerToken {
-		return fmt.Errorf("permission denied")
-	}
-	dstParentNode, err := u.repo.GetNodeByPath(dstPath.Parent())
+	dstParentNode, err := lookupDirectory(u.repo, dstPath.Parent())
 	if err != nil {
 		return fmt.Errorf("error getting destination path: %w", err)
 	}
-	if dstParentNode.Type != domain.NodeTypeDirectory {
-		return fmt.Errorf("source path is not a file")
-	}
 	dstNode, err := u.repo.GetNodeByPath(dstPath)
-	if err != nil && err != ErrNotFound {
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		return fmt.Errorf("error getting destination path: %w", err)
 	}
 	newName := srcNode.Name
 	newDstPath := dstPath.String()
 	newDstDirID := dstNode.ID
 
-	if err == ErrNotFound {
+	if errors.Is(err, ErrNotFound) {
 		newName = dstPath.NodeName()
 		newDstPath = dstPath.Parent().String()
 		newDstDirID = dstParentNode.ID
+		if err := domain.ValidateNodeName("destination_path", newName); err != nil {
+			return err
+		}
 	} else if dstNode.Type != domain.NodeTypeDirectory {
-		return fmt.Errorf("destination room is already exists")
+		return domain.NewPathError(dstPath, domain.ErrAlreadyExists)
 	}
 	if err := u.repo.CreateExistRoom(srcNode.ID, newDstDirID, newDstPath, newName, ownerToken); err != nil {
 		return fmt.Errorf("error copying file: %w", err)
 	}
+	u.emitNodeEvent(domain.WebhookEventCreate, domain.NodeTypeRoom, pathpkg.Join(newDstPath, newName), "", ownerToken)
 	return nil
 }
 
 func (u *Usecase) MovePath(srcPath, dstPath domain.Path, ownerToken string) error {
-	srcNode, err := u.repo.GetNodeByPath(srcPath)
+	srcNode, err := lookupOwnedRoom(u.repo, srcPath, ownerToken)
 	if err != nil {
 		return fmt.Errorf("error getting source path: %w", err)
 	}
-	if srcNode.Type != domain.NodeTypeRoom {
-		return fmt.Errorf("source path is not a file")
-	}
-	if srcNode.OwnerToken != ownerToken {
-		return fmt.Errorf("permission denied")
-	}
-	dstParentNode, err := u.repo.GetNodeByPath(dstPath.Parent())
+	dstParentNode, err := lookupDirectory(u.repo, dstPath.Parent())
 	if err != nil {
 		return fmt.Errorf("error getting destination path: %w", err)
 	}
-	if dstParentNode.Type != domain.NodeTypeDirectory {
-		return fmt.Errorf("source path is not a file")
-	}
 	dstNode, err := u.repo.GetNodeByPath(dstPath)
-	if err != nil && err != ErrNotFound {
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		return fmt.Errorf("error getting destination path: %w", err)
 	}
 	newName := srcNode.Name
 	newDstPath := dstPath.String()
 	newDstDirID := dstNode.ID
 
-	if err == ErrNotFound {
+	if errors.Is(err, ErrNotFound) {
 		newName = dstPath.NodeName()
 		newDstPath = dstPath.Parent().String()
 		newDstDirID = dstParentNode.ID
+		if err := domain.ValidateNodeName("destination_path", newName); err != nil {
+			return err
+		}
 	} else if dstNode.Type != domain.NodeTypeDirectory {
-		return fmt.Errorf("destination room is already exists")
+		return domain.NewPathError(dstPath, domain.ErrAlreadyExists)
 	}
 	if err := u.repo.UpdateRoom(srcNode.ID, newDstDirID, newDstPath, newName); err != nil {
 		return fmt.Errorf("error moving file: %w", err)
 	}
+	u.emitNodeEvent(domain.WebhookEventMove, domain.NodeTypeRoom, pathpkg.Join(newDstPath, newName), srcPath.String(), ownerToken)
 	return nil
-
 }
 
-func (u *Usecase) ListNodes(path domain.Path) ([]domain.Node, error) {
-	node, err := u.repo.GetNodeByPath(path)
+func (u *Usecase) ListNodes(path domain.Path, ownerToken string, withUnread, withRetention bool) ([]domain.Node, error) {
+	node, err := lookupNode(u.repo, path)
 	if err != nil {
 		return nil, fmt.Errorf("error getting path: %w", err)
 	}
-	if node.Type == domain.NodeTypeRoom {
-		return []domain.Node{node}, nil
+	nodes := []domain.Node{node}
+	if node.Type != domain.NodeTypeRoom {
+		nodes, err = u.repo.ListNodes(node.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error listing nodes: %w", err)
+		}
+	}
+	if withRetention {
+		if err := fillRetention(u.repo, node, path, nodes); err != nil {
+			return nil, fmt.Errorf("error getting retention policies: %w", err)
+		}
 	}
-	nodes, err := u.repo.ListNodes(node.ID)
+	if !withUnread {
+		return nodes, nil
+	}
+
+	var roomIDs []int
+	for _, n := range nodes {
+		if n.Type == domain.NodeTypeRoom {
+			roomIDs = append(roomIDs, n.ID)
+		}
+	}
+	counts, err := u.repo.CountUnreadMessages(ownerToken, roomIDs)
 	if err != nil {
-		return nil, fmt.Errorf("error listing nodes: %w", err)
+		return nil, fmt.Errorf("error counting unread messages: %w", err)
+	}
+	for i, n := range nodes {
+		if n.Type == domain.NodeTypeRoom {
+			nodes[i].UnreadCount = counts[n.ID]
+		}
 	}
 	return nodes, nil
 }
@@ -198,13 +308,10 @@ const (
 )
 
 func (u *Usecase) SearchMessage(path domain.Path, pattern string) ([]domain.Message, error) {
-	node, err := u.repo.GetNodeByPath(path)
+	node, err := lookupRoom(u.repo, path)
 	if err != nil {
 		return nil, fmt.Errorf("error getting room: %w", err)
 	}
-	if node.Type != domain.NodeTypeRoom {
-		return nil, fmt.Errorf("path is not a room")
-	}
 	messages, err := u.repo.ListMessagesByQuery(node.ID, pattern)
 	if err != nil {
 		return nil, fmt.Errorf("error searching messages: %w", err)
@@ -213,16 +320,54 @@ func (u *Usecase) SearchMessage(path domain.Path, pattern string) ([]domain.Mess
 }
 
 func (u *Usecase) WriteMessage(path domain.Path, message, ownerToken string) error {
-	node, err := u.repo.GetNodeByPath(path)
+	node, err := lookupRoom(u.repo, path)
 	if err != nil {
 		return fmt.Errorf("error getting room: %w", err)
 	}
-	if node.Type != domain.NodeTypeRoom {
-		return fmt.Errorf("path is not a room")
+	// The token is a secret: the message, and everything it reaches, carries the display name
+	senderName := displayName(u.repo, ownerToken)
+	if err := u.moderator.checkPost(node, ownerToken, senderName); err != nil {
+		return err
+	}
+	message, err = normalizeMessage(u.repo, u.messageLimits, node, message)
+	if err != nil {
+		return err
+	}
+	message, err = u.messageHooks.BeforeWrite(domain.NewHookMessage(path.String(), senderName, message))
+	if err != nil {
+		return err
+	}
+	if message == "" {
+		return &domain.InvalidMessageError{Reason: "message is empty"}
 	}
-	if err := u.repo.CreateMessage(node.ID, ownerToken, message); err != nil {
+	return u.postMessage(node, path.String(), senderName, ownerToken, message)
+}
+
+// displayName returns the name the owner of the token appears under, never the token itself
+func displayName(repo Repository, ownerToken string) string {
+	if config, err := repo.GetConfig(ownerToken); err == nil && config.DisplayName != "" {
+		return config.DisplayName
+	}
+	return domain.UnknownSenderName
+}
+
+// postMessage stores a message written outside a stream session and delivers it like one:
+// live to the sessions in the room, to webhooks and to the inboxes of mentioned users.
+// senderToken is empty for senders without one, like incoming webhooks.
+func (u *Usecase) postMessage(room domain.Node, roomPath, senderName, senderToken, message string) error {
+	if _, err := u.repo.CreateMessage(room.ID, senderName, message); err != nil {
 		return fmt.Errorf("error writing message: %w", err)
 	}
+	if u.streamManager.IsRoomActive(roomPath) {
+		if err := u.streamManager.BroadcastMessage(roomPath, senderName, message); err != nil {
+			fmt.Printf("Error broadcasting message to %s: %v\n", roomPath, err)
+		}
+	}
+	u.messageHooks.AfterWrite(domain.NewHookMessage(roomPath, senderName, message))
+	u.webhooks.emitMessage(roomPath, senderName, message)
+	if err := recordMentions(u.repo, u.streamManager, room.ID, roomPath, senderName, senderToken, message); err != nil {
+		return err
+	}
 	return nil
 }
 
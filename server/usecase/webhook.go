@@ -0,0 +1,413 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+const (
+	webhookBatchSize    = 32
+	webhookPollInterval = 5 * time.Second
+	webhookBackoffBase  = 10 * time.Second
+	webhookBackoffMax   = time.Hour
+	webhookSecretBytes  = 32
+	webhookUserAgent    = "chatsh-webhook/1"
+	// webhookResponseLimit bounds how much of a receiver's response is read, so that its
+	// connection can be reused; the response itself is never reported
+	webhookResponseLimit = 512
+)
+
+// webhookPayload is the JSON body of every delivery
+type webhookPayload struct {
+	Event     string          `json:"event"`
+	Path      string          `json:"path"`
+	OldPath   string          `json:"old_path,omitempty"`
+	NodeType  string          `json:"node_type,omitempty"`
+	Actor     string          `json:"actor,omitempty"`
+	Message   *webhookMessage `json:"message,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+type webhookMessage struct {
+	Author string `json:"author"`
+	Text   string `json:"text"`
+}
+
+func webhookNodeType(nodeType domain.NodeType) string {
+	if nodeType == domain.NodeTypeRoom {
+		return "room"
+	}
+	return "directory"
+}
+
+// webhookDispatcher queues events for matching webhooks and delivers them in the background,
+// so that slow receivers never hold up the writers
+type webhookDispatcher struct {
+	repo    Repository
+	client  *http.Client
+	options domain.WebhookOptions
+	wake    chan struct{}
+}
+
+func newWebhookDispatcher(repo Repository, options domain.WebhookOptions) *webhookDispatcher {
+	return &webhookDispatcher{
+		repo:    repo,
+		client:  newWebhookClient(options),
+		options: options,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// newWebhookClient returns a client that only connects to the addresses options allow. The check
+// runs on every connection, after the host name is resolved, so neither DNS nor redirects get
+// around it. Proxies are not used, as they would connect on the client's behalf.
+func newWebhookClient(options domain.WebhookOptions) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: options.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil {
+				return err
+			}
+			if !options.AllowsAddress(addr) {
+				return fmt.Errorf("webhook address %s is not public; operators allow it with webhook.allowed_networks", addr)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Transport: transport, Timeout: options.Timeout}
+}
+
+// emit queues the event for every webhook registered on one of paths or on their ancestors.
+// Failures are logged rather than returned because the change itself has already been made.
+func (d *webhookDispatcher) emit(event domain.WebhookEvent, payload webhookPayload, paths ...string) {
+	var candidates []string
+	for _, p := range paths {
+		for current := domain.NewPath(p); ; current = current.Parent() {
+			candidates = append(candidates, current.String())
+			if current.String() == "/" {
+				break
+			}
+		}
+	}
+	webhooks, err := d.repo.ListWebhooksByPaths(candidates)
+	if err != nil {
+		fmt.Printf("Error listing webhooks for %s event: %v\n", event, err)
+		return
+	}
+
+	payload.Event = event.String()
+	payload.Timestamp = time.Now()
+	body, err := json.Marshal(payload)
+	if err != nil {
+		fmt.Printf("Error encoding %s event: %v\n", event, err)
+		return
+	}
+	var deliveries []domain.WebhookDelivery
+	for _, webhook := range webhooks {
+		for _, p := range paths {
+			if webhook.Matches(event, p) {
+				deliveries = append(deliveries, domain.NewWebhookDelivery(0, webhook.ID, event, body, 0, payload.Timestamp, "", domain.WebhookDeliveryPending, payload.Timestamp))
+				break
+			}
+		}
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+	if err := d.repo.CreateWebhookDeliveries(deliveries); err != nil {
+		fmt.Printf("Error queueing %s event: %v\n", event, err)
+		return
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// emitMessage queues a message event; author is a display name, as receivers are chosen by room
+// owners and must never learn the owner tokens of those who post
+func (d *webhookDispatcher) emitMessage(roomPath, author, text string) {
+	d.emit(domain.WebhookEventMessage, webhookPayload{
+		Path:     roomPath,
+		NodeType: webhookNodeType(domain.NodeTypeRoom),
+		Actor:    author,
+		Message:  &webhookMessage{Author: author, Text: text},
+	}, roomPath)
+}
+
+// run delivers queued events until the process exits. Deliveries left over by a previous run
+// are picked up on start, so every event is delivered at least once.
+func (d *webhookDispatcher) run() {
+	for {
+		d.deliverDue()
+		select {
+		case <-d.wake:
+		case <-time.After(webhookPollInterval):
+		}
+	}
+}
+
+// deliverDue sends every due delivery, a batch at a time with up to Workers in flight
+func (d *webhookDispatcher) deliverDue() {
+	for {
+		deliveries, err := d.repo.ListDueWebhookDeliveries(time.Now(), webhookBatchSize)
+		if err != nil {
+			fmt.Printf("Error listing webhook deliveries: %v\n", err)
+			return
+		}
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, max(d.options.Workers, 1))
+		for _, delivery := range deliveries {
+			slots <- struct{}{}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				d.attempt(delivery)
+			}()
+		}
+		wg.Wait()
+		if len(deliveries) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// attempt makes one try at a delivery, dropping it once delivered and rescheduling it otherwise
+func (d *webhookDispatcher) attempt(delivery domain.WebhookDelivery) {
+	webhook, err := d.repo.GetWebhook(delivery.WebhookID)
+	if errors.Is(err, ErrNotFound) {
+		// The webhook was deleted while the delivery was queued
+		if err := d.repo.DeleteWebhookDelivery(delivery.ID); err != nil {
+			fmt.Printf("Error removing orphaned webhook delivery %d: %v\n", delivery.ID, err)
+		}
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error getting webhook %d: %v\n", delivery.WebhookID, err)
+		return
+	}
+
+	_, postErr := d.post(webhook, delivery.Event, strconv.Itoa(delivery.ID), delivery.Payload)
+	if postErr == nil {
+		if err := d.repo.DeleteWebhookDelivery(delivery.ID); err != nil {
+			fmt.Printf("Error removing delivered webhook delivery %d: %v\n", delivery.ID, err)
+		}
+		return
+	}
+
+	delivery.Attempts++
+	delivery.LastError = postErr.Error()
+	if delivery.Attempts >= d.options.MaxAttempts {
+		delivery.State = domain.WebhookDeliveryFailed
+		fmt.Printf("Giving up webhook delivery %d to %s after %d attempts: %v\n", delivery.ID, webhook.URL, delivery.Attempts, postErr)
+	} else {
+		delivery.NextAttemptAt = time.Now().Add(webhookBackoff(delivery.Attempts))
+	}
+	if err := d.repo.UpdateWebhookDelivery(delivery); err != nil {
+		fmt.Printf("Error rescheduling webhook delivery %d: %v\n", delivery.ID, err)
+	}
+}
+
+// webhookBackoff doubles the wait after each failed attempt, with some jitter so that
+// deliveries to a receiver that was down do not all retry at once
+func webhookBackoff(attempts int) time.Duration {
+	wait := webhookBackoffBase << min(attempts-1, 16)
+	if wait > webhookBackoffMax || wait <= 0 {
+		wait = webhookBackoffMax
+	}
+	return wait + rand.N(wait/10+1)
+}
+
+// post sends a signed payload and returns the status code; responses other than 2xx are errors
+func (d *webhookDispatcher) post(webhook domain.Webhook, event domain.WebhookEvent, deliveryID string, payload []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.options.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write(payload)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", webhookUserAgent)
+	req.Header.Set("X-Chatsh-Event", event.String())
+	req.Header.Set("X-Chatsh-Webhook", strconv.Itoa(webhook.ID))
+	req.Header.Set("X-Chatsh-Delivery", deliveryID)
+	req.Header.Set("X-Chatsh-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	res, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	// The body is not quoted in errors, lest webhooks read out whatever the server can reach
+	io.Copy(io.Discard, io.LimitReader(res.Body, webhookResponseLimit))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("receiver responded %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// validateWebhook checks the parts of a registration the caller chose. Hosts given as addresses
+// or as localhost are checked against options right away; other names when they are resolved.
+func validateWebhook(rawURL, pathGlob string, events []domain.WebhookEvent, options domain.WebhookOptions) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return &domain.InvalidMessageError{Field: "url", Reason: "webhook url must be an absolute http or https URL"}
+	}
+	host := strings.ToLower(parsed.Hostname())
+	var addrs []netip.Addr
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = []netip.Addr{addr}
+	} else if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		// These names always resolve to the loopback interface (RFC 6761)
+		addrs = []netip.Addr{netip.AddrFrom4([4]byte{127, 0, 0, 1}), netip.IPv6Loopback()}
+	}
+	if len(addrs) > 0 && !slices.ContainsFunc(addrs, options.AllowsAddress) {
+		return &domain.InvalidMessageError{Field: "url", Reason: fmt.Sprintf("webhook host %s is not public; operators allow it with webhook.allowed_networks", parsed.Hostname())}
+	}
+	if _, err := path.Match(pathGlob, ""); err != nil {
+		return &domain.InvalidMessageError{Field: "path_glob", Reason: fmt.Sprintf("bad pattern '%s'", pathGlob)}
+	}
+	for _, event := range events {
+		if event == domain.WebhookEventUnknown || event == domain.WebhookEventPing {
+			return &domain.InvalidMessageError{Field: "events", Reason: fmt.Sprintf("cannot subscribe to %s events", event)}
+		}
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	secret := make([]byte, webhookSecretBytes)
+	if _, err := cryptorand.Read(secret); err != nil {
+		return "", fmt.Errorf("error generating webhook secret: %w", err)
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// emitNodeEvent queues a filesystem event; moves are matched against both the old and the new path
+func (u *Usecase) emitNodeEvent(event domain.WebhookEvent, nodeType domain.NodeType, nodePath, oldPath, ownerToken string) {
+	paths := []string{nodePath}
+	if oldPath != "" {
+		paths = append(paths, oldPath)
+	}
+	u.webhooks.emit(event, webhookPayload{
+		Path:     nodePath,
+		OldPath:  oldPath,
+		NodeType: webhookNodeType(nodeType),
+		Actor:    displayName(u.repo, ownerToken),
+	}, paths...)
+}
+
+// CreateWebhook registers a webhook on a room or directory the caller owns. A secret is
+// generated when none is given; the result is the only place it is returned.
+func (u *Usecase) CreateWebhook(nodePath domain.Path, ownerToken, rawURL, secret string, events []domain.WebhookEvent, pathGlob string) (domain.Webhook, error) {
+	node, err := lookupNode(u.repo, nodePath)
+	if err != nil {
+		return domain.Webhook{}, fmt.Errorf("error getting path: %w", err)
+	}
+	if node.OwnerToken != ownerToken {
+		return domain.Webhook{}, domain.NewPathError(nodePath, domain.ErrPermissionDenied)
+	}
+	if err := validateWebhook(rawURL, pathGlob, events, u.webhooks.options); err != nil {
+		return domain.Webhook{}, err
+	}
+	if secret == "" {
+		if secret, err = newWebhookSecret(); err != nil {
+			return domain.Webhook{}, err
+		}
+	}
+	webhook := domain.NewWebhook(0, ownerToken, nodePath.String(), rawURL, secret, events, pathGlob, time.Now())
+	if webhook.ID, err = u.repo.CreateWebhook(webhook); err != nil {
+		return domain.Webhook{}, fmt.Errorf("error creating webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+func (u *Usecase) ListWebhooks(ownerToken string) ([]domain.Webhook, error) {
+	webhooks, err := u.repo.ListWebhooks(ownerToken)
+	if err != nil {
+		return nil, fmt.Errorf("error listing webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
+// lookupOwnedWebhook resolves a webhook that only its owner may manage
+func (u *Usecase) lookupOwnedWebhook(id int, ownerToken string) (domain.Webhook, error) {
+	webhook, err := u.repo.GetWebhook(id)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return domain.Webhook{}, fmt.Errorf("webhook %d: %w", id, domain.ErrNotFound)
+		}
+		return domain.Webhook{}, fmt.Errorf("error getting webhook: %w", err)
+	}
+	if webhook.OwnerToken != ownerToken {
+		return domain.Webhook{}, fmt.Errorf("webhook %d: %w", id, domain.ErrPermissionDenied)
+	}
+	return webhook, nil
+}
+
+// TestWebhook sends a ping event right away, bypassing the queue, and reports how the receiver answered
+func (u *Usecase) TestWebhook(id int, ownerToken string) (domain.WebhookTestResult, error) {
+	webhook, err := u.lookupOwnedWebhook(id, ownerToken)
+	if err != nil {
+		return domain.WebhookTestResult{}, err
+	}
+	payload, err := json.Marshal(webhookPayload{
+		Event:     domain.WebhookEventPing.String(),
+		Path:      webhook.Path,
+		Actor:     displayName(u.repo, ownerToken),
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		return domain.WebhookTestResult{}, fmt.Errorf("error encoding ping event: %w", err)
+	}
+	start := time.Now()
+	statusCode, err := u.webhooks.post(webhook, domain.WebhookEventPing, "test", payload)
+	result := domain.WebhookTestResult{StatusCode: statusCode, Duration: time.Since(start)}
+	if err != nil {
+		return result, fmt.Errorf("error delivering to %s: %w", webhook.URL, err)
+	}
+	return result, nil
+}
+
+func (u *Usecase) DeleteWebhook(id int, ownerToken string) error {
+	if _, err := u.lookupOwnedWebhook(id, ownerToken); err != nil {
+		return err
+	}
+	if err := u.repo.DeleteWebhook(id); err != nil {
+		return fmt.Errorf("error deleting webhook: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,374 @@
+package usecase
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ponyo877/chatsh/server/domain"
+)
+
+// webhookRepository keeps the webhooks and deliveries of a dispatcher; the rest of Repository
+// is left nil, as the dispatcher does not use it
+type webhookRepository struct {
+	Repository
+	mu         sync.Mutex
+	webhooks   map[int]domain.Webhook
+	deliveries map[int]domain.WebhookDelivery
+	nextID     int
+}
+
+func newWebhookRepository(webhooks ...domain.Webhook) *webhookRepository {
+	r := &webhookRepository{webhooks: map[int]domain.Webhook{}, deliveries: map[int]domain.WebhookDelivery{}}
+	for _, webhook := range webhooks {
+		r.webhooks[webhook.ID] = webhook
+	}
+	return r
+}
+
+func (r *webhookRepository) GetWebhook(id int) (domain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	webhook, ok := r.webhooks[id]
+	if !ok {
+		return domain.Webhook{}, ErrNotFound
+	}
+	return webhook, nil
+}
+
+func (r *webhookRepository) ListWebhooksByPaths(paths []string) ([]domain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var webhooks []domain.Webhook
+	for _, webhook := range r.webhooks {
+		for _, p := range paths {
+			if webhook.Path == p {
+				webhooks = append(webhooks, webhook)
+				break
+			}
+		}
+	}
+	return webhooks, nil
+}
+
+func (r *webhookRepository) CreateWebhookDeliveries(deliveries []domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, delivery := range deliveries {
+		r.nextID++
+		delivery.ID = r.nextID
+		r.deliveries[delivery.ID] = delivery
+	}
+	return nil
+}
+
+func (r *webhookRepository) ListDueWebhookDeliveries(now time.Time, limit int) ([]domain.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var due []domain.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.State == domain.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) && len(due) < limit {
+			due = append(due, delivery)
+		}
+	}
+	return due, nil
+}
+
+func (r *webhookRepository) UpdateWebhookDelivery(delivery domain.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries[delivery.ID] = delivery
+	return nil
+}
+
+func (r *webhookRepository) DeleteWebhookDelivery(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.deliveries, id)
+	return nil
+}
+
+// delivery returns the only delivery queued, if any
+func (r *webhookRepository) delivery(t *testing.T) (domain.WebhookDelivery, bool) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.deliveries) > 1 {
+		t.Fatalf("%d deliveries queued, want at most 1", len(r.deliveries))
+	}
+	for _, delivery := range r.deliveries {
+		return delivery, true
+	}
+	return domain.WebhookDelivery{}, false
+}
+
+// makeDue lets the queued deliveries be attempted again right away
+func (r *webhookRepository) makeDue() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, delivery := range r.deliveries {
+		delivery.NextAttemptAt = time.Now()
+		r.deliveries[id] = delivery
+	}
+}
+
+// loopbackOptions lets webhooks reach the httptest servers of the tests
+func loopbackOptions(maxAttempts int) domain.WebhookOptions {
+	return domain.NewWebhookOptions(maxAttempts, 5*time.Second, 2, []netip.Prefix{
+		netip.MustParsePrefix("127.0.0.0/8"),
+		netip.MustParsePrefix("::1/128"),
+	})
+}
+
+func TestWebhookSignature(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan received, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{r.Header, body}
+	}))
+	defer receiver.Close()
+
+	webhook := domain.NewWebhook(7, "tokA", "/srv", receiver.URL, "s3cret", nil, "", time.Now())
+	repo := newWebhookRepository(webhook)
+	d := newWebhookDispatcher(repo, loopbackOptions(3))
+	d.emitMessage("/srv/room", "alice", "hello")
+	d.deliverDue()
+
+	var got received
+	select {
+	case got = <-requests:
+	default:
+		t.Fatal("the receiver got no delivery")
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(got.body)
+	for header, want := range map[string]string{
+		"X-Chatsh-Signature": "sha256=" + hex.EncodeToString(mac.Sum(nil)),
+		"X-Chatsh-Event":     "message",
+		"X-Chatsh-Webhook":   "7",
+		"X-Chatsh-Delivery":  "1",
+		"Content-Type":       "application/json",
+		"User-Agent":         webhookUserAgent,
+	} {
+		if got.header.Get(header) != want {
+			t.Errorf("%s = %q, want %q", header, got.header.Get(header), want)
+		}
+	}
+	var payload webhookPayload
+	if err := json.Unmarshal(got.body, &payload); err != nil {
+		t.Fatalf("payload: %v", err)
+	}
+	if payload.Event != "message" || payload.Path != "/srv/room" || payload.Message == nil || payload.Message.Text != "hello" {
+		t.Errorf("payload = %+v", payload)
+	}
+	if delivery, ok := repo.delivery(t); ok {
+		t.Errorf("delivered delivery is still queued: %+v", delivery)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	const internal = "internal-only response"
+	for _, tt := range []struct {
+		name string
+		// failures is how many attempts the receiver fails before it answers 200
+		failures    int
+		maxAttempts int
+	}{
+		{"recovers", 2, 3},
+		{"gives up", 5, 3},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			attempts := 0
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				attempts++
+				if attempts <= tt.failures {
+					http.Error(w, internal, http.StatusServiceUnavailable)
+				}
+			}))
+			defer receiver.Close()
+
+			repo := newWebhookRepository(domain.NewWebhook(1, "tokA", "/srv", receiver.URL, "s3cret", nil, "", time.Now()))
+			d := newWebhookDispatcher(repo, loopbackOptions(tt.maxAttempts))
+			d.emitMessage("/srv/room", "alice", "hello")
+			for attempt := 1; attempt <= tt.maxAttempts; attempt++ {
+				start := time.Now()
+				d.deliverDue()
+				delivery, queued := repo.delivery(t)
+				if attempt > tt.failures {
+					if queued {
+						t.Fatalf("attempt %d: delivery still queued after the receiver answered: %+v", attempt, delivery)
+					}
+					break
+				}
+				if !queued {
+					t.Fatalf("attempt %d: failed delivery left the queue", attempt)
+				}
+				if delivery.Attempts != attempt {
+					t.Errorf("attempt %d: Attempts = %d", attempt, delivery.Attempts)
+				}
+				if delivery.LastError != "receiver responded 503 Service Unavailable" {
+					t.Errorf("attempt %d: LastError = %q", attempt, delivery.LastError)
+				}
+				if strings.Contains(delivery.LastError, internal) {
+					t.Errorf("attempt %d: LastError quotes the response body", attempt)
+				}
+				if attempt == tt.maxAttempts {
+					if delivery.State != domain.WebhookDeliveryFailed {
+						t.Errorf("State after %d attempts = %v, want failed", attempt, delivery.State)
+					}
+					break
+				}
+				wait := delivery.NextAttemptAt.Sub(start)
+				if base := webhookBackoffBase << (attempt - 1); wait < base || wait > base+base/10+time.Second {
+					t.Errorf("attempt %d: next attempt in %s, want %s plus up to 10%%", attempt, wait, base)
+				}
+				// Nothing is attempted before its time
+				d.deliverDue()
+				if again, _ := repo.delivery(t); again.Attempts != attempt {
+					t.Errorf("attempt %d: delivery was retried before its time", attempt)
+				}
+				repo.makeDue()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if want := min(tt.failures+1, tt.maxAttempts); attempts != want {
+				t.Errorf("receiver got %d attempts, want %d", attempts, want)
+			}
+		})
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	for _, tt := range []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{8, 1280 * time.Second},
+		{9, 2560 * time.Second},
+		{10, time.Hour},
+		{100, time.Hour},
+	} {
+		for range 20 {
+			got := webhookBackoff(tt.attempts)
+			if got < tt.want || got > tt.want+tt.want/10 {
+				t.Errorf("webhookBackoff(%d) = %s, want %s plus up to 10%%", tt.attempts, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestWebhookAddresses(t *testing.T) {
+	loopback := []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}
+	for _, tt := range []struct {
+		url     string
+		allowed []netip.Prefix
+		ok      bool
+	}{
+		{"https://example.com/hook", nil, true},
+		{"http://93.184.216.34/hook", nil, true},
+		{"http://[2606:2800:220:1:248:1893:25c8:1946]/hook", nil, true},
+		{"http://169.254.169.254/latest/meta-data/", nil, false},
+		{"http://100.100.100.200/latest/meta-data/", nil, false},
+		{"http://127.0.0.1:9000/hook", nil, false},
+		{"http://localhost:9000/hook", nil, false},
+		{"http://api.localhost/hook", nil, false},
+		{"http://[::1]/hook", nil, false},
+		{"http://[::ffff:127.0.0.1]/hook", nil, false},
+		{"http://0.0.0.0/hook", nil, false},
+		{"http://10.1.2.3/hook", nil, false},
+		{"http://192.168.1.1/hook", nil, false},
+		{"http://[fd00:ec2::254]/hook", nil, false},
+		{"http://[fe80::1%25eth0]/hook", nil, false},
+		{"http://127.0.0.1:9000/hook", loopback, true},
+		{"http://localhost:9000/hook", loopback, true},
+		{"http://10.1.2.3/hook", loopback, false},
+		{"ftp://example.com/hook", nil, false},
+	} {
+		options := domain.NewWebhookOptions(1, time.Second, 1, tt.allowed)
+		err := validateWebhook(tt.url, "", nil, options)
+		if (err == nil) != tt.ok {
+			t.Errorf("validateWebhook(%s, allowed %v) = %v, want ok %t", tt.url, tt.allowed, err, tt.ok)
+		}
+	}
+}
+
+// TestWebhookResolvedAddresses checks the addresses a delivery connects to, which registration
+// cannot know for host names
+func TestWebhookResolvedAddresses(t *testing.T) {
+	reached := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer receiver.Close()
+	port := receiver.URL[strings.LastIndex(receiver.URL, ":")+1:]
+	webhook := domain.NewWebhook(1, "tokA", "/srv", "http://localhost:"+port, "s3cret", nil, "", time.Now())
+
+	d := newWebhookDispatcher(newWebhookRepository(), domain.NewWebhookOptions(1, 5*time.Second, 1, nil))
+	if _, err := d.post(webhook, domain.WebhookEventPing, "test", []byte("{}")); err == nil || !strings.Contains(err.Error(), "not public") {
+		t.Errorf("post to a loopback receiver = %v, want it refused", err)
+	}
+	if reached {
+		t.Error("the loopback receiver was reached")
+	}
+
+	d = newWebhookDispatcher(newWebhookRepository(), loopbackOptions(1))
+	statusCode, err := d.post(webhook, domain.WebhookEventPing, "test", []byte("{}"))
+	if err != nil || statusCode != http.StatusOK {
+		t.Errorf("post to an allowed receiver = %d, %v", statusCode, err)
+	}
+}
+
+// TestWebhookActor checks that events name the display name of whoever caused them, and never
+// carry their owner token to the receiver
+func TestWebhookActor(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{"configured", "tokA", "alice"},
+		{"without a config", "tokZ", domain.UnknownSenderName},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMentionRepository()
+			repo.webhooks[7] = domain.NewWebhook(7, "tokA", "/srv", "https://hooks.example/", "s3cret", nil, "", time.Now())
+			u := &Usecase{repo: repo, webhooks: newWebhookDispatcher(repo, loopbackOptions(1))}
+			u.emitNodeEvent(domain.WebhookEventCreate, domain.NodeTypeRoom, "/srv/room", "", tt.token)
+
+			delivery, ok := repo.delivery(t)
+			if !ok {
+				t.Fatal("no delivery queued")
+			}
+			var payload webhookPayload
+			if err := json.Unmarshal(delivery.Payload, &payload); err != nil {
+				t.Fatalf("payload: %v", err)
+			}
+			if payload.Actor != tt.want {
+				t.Errorf("actor = %q, want %q", payload.Actor, tt.want)
+			}
+			if strings.Contains(string(delivery.Payload), tt.token) {
+				t.Errorf("payload %s carries the owner token", delivery.Payload)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+const (
+	// flagEndStream marks the Connect envelope that ends a stream with its status and trailers
+	flagEndStream byte = 0x02
+
+	maxUnaryBody = 4 << 20
+)
+
+// connectCodes names each gRPC code as the Connect protocol does, with the HTTP status of unary errors
+var connectCodes = map[codes.Code]struct {
+	name       string
+	httpStatus int
+}{
+	codes.Canceled:           {"canceled", 499},
+	codes.Unknown:            {"unknown", http.StatusInternalServerError},
+	codes.InvalidArgument:    {"invalid_argument", http.StatusBadRequest},
+	codes.DeadlineExceeded:   {"deadline_exceeded", http.StatusGatewayTimeout},
+	codes.NotFound:           {"not_found", http.StatusNotFound},
+	codes.AlreadyExists:      {"already_exists", http.StatusConflict},
+	codes.PermissionDenied:   {"permission_denied", http.StatusForbidden},
+	codes.ResourceExhausted:  {"resource_exhausted", http.StatusTooManyRequests},
+	codes.FailedPrecondition: {"failed_precondition", http.StatusBadRequest},
+	codes.Aborted:            {"aborted", http.StatusConflict},
+	codes.OutOfRange:         {"out_of_range", http.StatusBadRequest},
+	codes.Unimplemented:      {"unimplemented", http.StatusNotImplemented},
+	codes.Internal:           {"internal", http.StatusInternalServerError},
+	codes.Unavailable:        {"unavailable", http.StatusServiceUnavailable},
+	codes.DataLoss:           {"data_loss", http.StatusInternalServerError},
+	codes.Unauthenticated:    {"unauthenticated", http.StatusUnauthorized},
+}
+
+// connectError is the JSON form of an error in the Connect protocol
+type connectError struct {
+	Code    string          `json:"code"`
+	Message string          `json:"message,omitempty"`
+	Details []connectDetail `json:"details,omitempty"`
+}
+
+type connectDetail struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+func newConnectError(st *status.Status) *connectError {
+	e := &connectError{Code: connectCodes[st.Code()].name, Message: st.Message()}
+	if e.Code == "" {
+		e.Code = connectCodes[codes.Unknown].name
+	}
+	for _, detail := range st.Proto().GetDetails() {
+		e.Details = append(e.Details, connectDetail{
+			Type:  strings.TrimPrefix(detail.GetTypeUrl(), "type.googleapis.com/"),
+			Value: base64.RawStdEncoding.EncodeToString(detail.GetValue()),
+		})
+	}
+	return e
+}
+
+// messageCodec converts between the Connect wire format and the binary messages gRPC carries
+type messageCodec struct {
+	json   bool
+	input  protoreflect.MessageType
+	output protoreflect.MessageType
+}
+
+func newMessageCodec(method protoreflect.MethodDescriptor, useJSON bool) (messageCodec, error) {
+	input, err := protoregistry.GlobalTypes.FindMessageByName(method.Input().FullName())
+	if err != nil {
+		return messageCodec{}, err
+	}
+	output, err := protoregistry.GlobalTypes.FindMessageByName(method.Output().FullName())
+	if err != nil {
+		return messageCodec{}, err
+	}
+	return messageCodec{json: useJSON, input: input, output: output}, nil
+}
+
+// request turns a request message from the client into binary protobuf
+func (c messageCodec) request(data []byte) ([]byte, error) {
+	if !c.json {
+		return data, nil
+	}
+	msg := c.input.New().Interface()
+	if err := protojson.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+// response turns a binary protobuf response into the client's format
+func (c messageCodec) response(data []byte) ([]byte, error) {
+	if !c.json {
+		return data, nil
+	}
+	msg := c.output.New().Interface()
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return protojson.Marshal(msg)
+}
+
+// connectRequestHeaders drops the Connect transport headers and translates the timeout
+func connectRequestHeaders(r *http.Request) []string {
+	if ms, err := strconv.ParseInt(r.Header.Get("Connect-Timeout-Ms"), 10, 64); err == nil && ms > 0 {
+		r.Header.Set("Grpc-Timeout", strconv.FormatInt(ms, 10)+"m")
+	}
+	return []string{"Connect-Protocol-Version", "Connect-Timeout-Ms", "Connect-Content-Encoding", "Connect-Accept-Encoding", "Content-Encoding"}
+}
+
+// serveConnectUnary relays a unary Connect call, whose body is the bare request message
+func (h *Handler) serveConnectUnary(w http.ResponseWriter, r *http.Request, method protoreflect.MethodDescriptor, useJSON bool) {
+	contentType := "application/proto"
+	if useJSON {
+		contentType = "application/json"
+	}
+	if r.Header.Get("Content-Encoding") != "" && r.Header.Get("Content-Encoding") != "identity" {
+		writeConnectUnaryError(w, http.Header{}, status.New(codes.Unimplemented, "compressed requests are not supported"))
+		return
+	}
+	codec, err := newMessageCodec(method, useJSON)
+	if err != nil {
+		writeConnectUnaryError(w, http.Header{}, status.New(codes.Internal, err.Error()))
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxUnaryBody))
+	if err != nil {
+		writeConnectUnaryError(w, http.Header{}, status.New(codes.InvalidArgument, err.Error()))
+		return
+	}
+	payload, err := codec.request(data)
+	if err != nil {
+		writeConnectUnaryError(w, http.Header{}, status.New(codes.InvalidArgument, "invalid request message: "+err.Error()))
+		return
+	}
+
+	var response []byte
+	recorder := newGRPCRecorder(
+		func(http.Header) error { return nil },
+		func(message []byte) error {
+			if response != nil {
+				return errors.New("unary call returned several messages")
+			}
+			response = message
+			return nil
+		},
+		func() {},
+	)
+	drop := connectRequestHeaders(r)
+	h.grpc.ServeHTTP(recorder, grpcRequest(r, bytes.NewReader(appendFrame(nil, 0, payload)), drop...))
+	result := recorder.result()
+
+	if result.status.Code() != codes.OK {
+		md := result.header.Clone()
+		copyPrefixed(md, result.trailer, "Trailer-")
+		writeConnectUnaryError(w, md, result.status)
+		return
+	}
+	body, err := codec.response(response)
+	if err != nil {
+		writeConnectUnaryError(w, http.Header{}, status.New(codes.Internal, err.Error()))
+		return
+	}
+	copyHeader(w.Header(), result.header)
+	copyPrefixed(w.Header(), result.trailer, "Trailer-")
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func writeConnectUnaryError(w http.ResponseWriter, md http.Header, st *status.Status) {
+	body, _ := json.Marshal(newConnectError(st))
+	copyHeader(w.Header(), md)
+	w.Header().Set("Content-Type", "application/json")
+	httpStatus := connectCodes[st.Code()].httpStatus
+	if httpStatus == 0 {
+		httpStatus = http.StatusInternalServerError
+	}
+	w.WriteHeader(httpStatus)
+	w.Write(body)
+}
+
+// endStreamMessage is the JSON payload of the final envelope of a Connect stream
+type endStreamMessage struct {
+	Error    *connectError       `json:"error,omitempty"`
+	Metadata map[string][]string `json:"metadata,omitempty"`
+}
+
+// serveConnectStream relays a streaming Connect call. Envelopes share the gRPC framing, so JSON
+// payloads are converted one by one and the status is sent as a final end-stream envelope.
+func (h *Handler) serveConnectStream(w http.ResponseWriter, r *http.Request, method protoreflect.MethodDescriptor, useJSON bool) {
+	contentType := "application/connect+proto"
+	if useJSON {
+		contentType = "application/connect+json"
+	}
+	codec, err := newMessageCodec(method, useJSON)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Re-frame the request envelopes in the background, converting JSON payloads as they arrive
+	body, pipe := io.Pipe()
+	go func() {
+		for {
+			flag, data, err := readFrame(r.Body)
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					err = nil
+				}
+				pipe.CloseWithError(err)
+				return
+			}
+			if flag&flagCompressed != 0 {
+				pipe.CloseWithError(errors.New("compressed messages are not supported"))
+				return
+			}
+			if flag&flagEndStream != 0 {
+				pipe.Close()
+				return
+			}
+			payload, err := codec.request(data)
+			if err != nil {
+				pipe.CloseWithError(fmt.Errorf("invalid request message: %w", err))
+				return
+			}
+			if _, err := pipe.Write(appendFrame(nil, 0, payload)); err != nil {
+				return
+			}
+		}
+	}()
+	defer body.Close()
+
+	started := false
+	recorder := newGRPCRecorder(
+		func(md http.Header) error {
+			copyHeader(w.Header(), md)
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(http.StatusOK)
+			started = true
+			return nil
+		},
+		func(message []byte) error {
+			payload, err := codec.response(message)
+			if err != nil {
+				return err
+			}
+			_, err = w.Write(appendFrame(nil, 0, payload))
+			return err
+		},
+		flusher(w),
+	)
+	drop := connectRequestHeaders(r)
+	h.grpc.ServeHTTP(recorder, grpcRequest(r, body, drop...))
+	result := recorder.result()
+
+	if !started {
+		copyHeader(w.Header(), result.header)
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusOK)
+	}
+	end := endStreamMessage{}
+	if result.status.Code() != codes.OK {
+		end.Error = newConnectError(result.status)
+	}
+	if len(result.trailer) > 0 {
+		end.Metadata = result.trailer
+	}
+	payload, _ := json.Marshal(end)
+	w.Write(appendFrame(nil, flagEndStream, payload))
+}
+
+// copyPrefixed copies src into dst with prefix added to every key
+func copyPrefixed(dst, src http.Header, prefix string) {
+	for key, values := range src {
+		dst[http.CanonicalHeaderKey(prefix+key)] = append([]string(nil), values...)
+	}
+}
@@ -0,0 +1,200 @@
+package web
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+)
+
+func TestConnectUnary(t *testing.T) {
+	ts := newTestServer(t, nil)
+	t.Run("json", func(t *testing.T) {
+		resp, data := post(t, ts, "GetConfig", "application/json", []byte(`{"ownerToken": "alice"}`))
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d: %s", resp.StatusCode, data)
+		}
+		var got map[string]string
+		if err := json.Unmarshal(data, &got); err != nil || got["displayName"] != "hello alice" {
+			t.Errorf("body = %s (%v), want the display name hello alice", data, err)
+		}
+		for key, want := range map[string]string{
+			"Content-Type":   "application/json",
+			"X-Request":      "r1",
+			"Trailer-X-Cost": "3",
+		} {
+			if got := resp.Header.Get(key); got != want {
+				t.Errorf("%s = %q, want %q", key, got, want)
+			}
+		}
+	})
+	t.Run("proto", func(t *testing.T) {
+		resp, data := post(t, ts, "GetConfig", "application/proto", marshal(t, &pb.GetConfigRequest{OwnerToken: "bob"}))
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/proto" {
+			t.Fatalf("got %d %s: %s", resp.StatusCode, resp.Header.Get("Content-Type"), data)
+		}
+		got := &pb.GetConfigResponse{}
+		unmarshal(t, data, got)
+		if got.GetDisplayName() != "hello bob" {
+			t.Errorf("display name = %q, want hello bob", got.GetDisplayName())
+		}
+	})
+	t.Run("invalid json", func(t *testing.T) {
+		resp, data := post(t, ts, "GetConfig", "application/json", []byte(`{"ownerToken": 1}`))
+		var got connectError
+		if err := json.Unmarshal(data, &got); err != nil || resp.StatusCode != http.StatusBadRequest || got.Code != "invalid_argument" {
+			t.Errorf("got %d %s, want 400 invalid_argument", resp.StatusCode, data)
+		}
+	})
+}
+
+func TestConnectUnaryErrors(t *testing.T) {
+	ts := newTestServer(t, nil)
+	for code, want := range connectCodes {
+		t.Run(want.name, func(t *testing.T) {
+			token := "code=" + strconv.Itoa(int(code))
+			resp, data := post(t, ts, "GetConfig", "application/json", []byte(`{"ownerToken": "`+token+`"}`))
+			var got connectError
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("body %s: %v", data, err)
+			}
+			if resp.StatusCode != want.httpStatus || got.Code != want.name {
+				t.Errorf("got %d %s, want %d %s", resp.StatusCode, got.Code, want.httpStatus, want.name)
+			}
+			if got.Message != "failed with "+strconv.Itoa(int(code)) {
+				t.Errorf("message = %q", got.Message)
+			}
+			if cost := resp.Header.Get("Trailer-X-Cost"); cost != "3" {
+				t.Errorf("Trailer-X-Cost = %q, want 3", cost)
+			}
+		})
+	}
+	t.Run("unknown code", func(t *testing.T) {
+		resp, data := post(t, ts, "GetConfig", "application/json", []byte(`{"ownerToken": "code=99"}`))
+		var got connectError
+		if err := json.Unmarshal(data, &got); err != nil || resp.StatusCode != http.StatusInternalServerError || got.Code != "unknown" {
+			t.Errorf("got %d %s, want 500 unknown", resp.StatusCode, data)
+		}
+	})
+	t.Run("details", func(t *testing.T) {
+		resp, data := post(t, ts, "GetConfig", "application/json", []byte(`{"ownerToken": "retry"}`))
+		var got connectError
+		if err := json.Unmarshal(data, &got); err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("got %d %s, want 429", resp.StatusCode, data)
+		}
+		if len(got.Details) != 1 || got.Details[0].Type != "google.rpc.RetryInfo" {
+			t.Fatalf("details = %v, want one google.rpc.RetryInfo", got.Details)
+		}
+		raw, err := base64.RawStdEncoding.DecodeString(got.Details[0].Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info := &errdetails.RetryInfo{}
+		unmarshal(t, raw, info)
+		if info.GetRetryDelay().AsDuration().Seconds() != 2 {
+			t.Errorf("retry delay = %s, want 2s", info.GetRetryDelay().AsDuration())
+		}
+	})
+}
+
+// readEndStream splits a Connect stream body into its messages and the end-stream message
+func readEndStream(t *testing.T, data []byte) ([][]byte, endStreamMessage) {
+	t.Helper()
+	frames := readFrames(t, data)
+	if len(frames) == 0 || frames[len(frames)-1].flag != flagEndStream {
+		t.Fatalf("stream %q does not end with an end-stream message", data)
+	}
+	var messages [][]byte
+	for _, f := range frames[:len(frames)-1] {
+		if f.flag != 0 {
+			t.Fatalf("message with flag %#x before the end of the stream", f.flag)
+		}
+		messages = append(messages, f.payload)
+	}
+	var end endStreamMessage
+	if err := json.Unmarshal(frames[len(frames)-1].payload, &end); err != nil {
+		t.Fatal(err)
+	}
+	return messages, end
+}
+
+func TestConnectServerStream(t *testing.T) {
+	ts := newTestServer(t, nil)
+	for _, tt := range []struct {
+		token  string
+		chunks []string
+		code   string
+	}{
+		{"abc", []string{"YQ==", "Yg==", "Yw=="}, ""},
+		{"ab!", []string{"YQ==", "Yg=="}, "unavailable"},
+		{"!", nil, "unavailable"},
+	} {
+		t.Run(tt.token, func(t *testing.T) {
+			resp, data := post(t, ts, "Backup", "application/connect+json",
+				appendFrame(nil, 0, []byte(`{"ownerToken": "`+tt.token+`"}`)))
+			if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/connect+json" {
+				t.Fatalf("got %d %s: %s", resp.StatusCode, resp.Header.Get("Content-Type"), data)
+			}
+			if got := resp.Header.Get("X-Request"); got != "r1" {
+				t.Errorf("X-Request header = %q, want r1", got)
+			}
+			messages, end := readEndStream(t, data)
+			if len(messages) != len(tt.chunks) {
+				t.Fatalf("got %d messages, want %d", len(messages), len(tt.chunks))
+			}
+			for i, message := range messages {
+				var chunk map[string]string
+				if err := json.Unmarshal(message, &chunk); err != nil || chunk["data"] != tt.chunks[i] {
+					t.Errorf("message %d = %s (%v), want data %s", i, message, err, tt.chunks[i])
+				}
+			}
+			if got := end.Metadata["X-Cost"]; len(got) != 1 || got[0] != "3" {
+				t.Errorf("end-stream metadata = %v, want X-Cost 3", end.Metadata)
+			}
+			switch {
+			case tt.code == "" && end.Error != nil:
+				t.Errorf("stream ended with %+v, want success", end.Error)
+			case tt.code != "" && (end.Error == nil || end.Error.Code != tt.code):
+				t.Errorf("stream ended with %+v, want %s", end.Error, tt.code)
+			case tt.code != "" && end.Error.Message != "backup stopped at 50% — disk full":
+				t.Errorf("error message = %q", end.Error.Message)
+			}
+		})
+	}
+}
+
+// TestConnectBidiStream sends a whole half-duplex StreamMessage call in the request body
+func TestConnectBidiStream(t *testing.T) {
+	ts := newTestServer(t, nil)
+	var body []byte
+	for _, text := range []string{"one", "two"} {
+		body = appendFrame(body, 0, marshal(t, &pb.ClientMessage{Payload: &pb.ClientMessage_Chat{Chat: &pb.Chat{Text: text}}}))
+	}
+	resp, data := post(t, ts, "StreamMessage", "application/connect+proto", body)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/connect+proto" {
+		t.Fatalf("got %d %s: %s", resp.StatusCode, resp.Header.Get("Content-Type"), data)
+	}
+	messages, end := readEndStream(t, data)
+	if end.Error != nil {
+		t.Errorf("stream ended with %+v", end.Error)
+	}
+	var texts []string
+	for _, message := range messages {
+		got := &pb.ServerMessage{}
+		unmarshal(t, message, got)
+		texts = append(texts, got.GetText())
+	}
+	if len(texts) != 2 || texts[0] != "one" || texts[1] != "two" {
+		t.Errorf("echoed %q, want one and two", texts)
+	}
+
+	// A request message that does not parse ends the call with an error
+	resp, data = post(t, ts, "StreamMessage", "application/connect+json", appendFrame(nil, 0, []byte(`{"chat": 1}`)))
+	if _, end := readEndStream(t, data); resp.StatusCode != http.StatusOK || end.Error == nil {
+		t.Errorf("got %d ending with %+v, want an error", resp.StatusCode, end.Error)
+	}
+}
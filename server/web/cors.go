@@ -0,0 +1,103 @@
+package web
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS configures which browser origins may call the service
+type CORS struct {
+	// AllowedOrigins lists the permitted origins; "*" permits every origin and an empty list none
+	AllowedOrigins []string
+	// AllowedHeaders adds request headers to those the protocols need
+	AllowedHeaders []string
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// protocolHeaders are the request headers gRPC-Web and Connect clients send
+var protocolHeaders = []string{
+	"Authorization",
+	"Connect-Accept-Encoding",
+	"Connect-Content-Encoding",
+	"Connect-Protocol-Version",
+	"Connect-Timeout-Ms",
+	"Content-Type",
+	"Grpc-Timeout",
+	"X-Grpc-Web",
+	"X-User-Agent",
+}
+
+// exposedHeaders are the response headers browsers must let clients read
+var exposedHeaders = []string{
+	"Grpc-Message",
+	"Grpc-Status",
+	"Grpc-Status-Details-Bin",
+	"Retry-After",
+}
+
+type cors struct {
+	allowAll       bool
+	origins        map[string]bool
+	allowedHeaders string
+	exposedHeaders string
+	maxAge         string
+}
+
+func newCORS(c CORS) *cors {
+	origins := map[string]bool{}
+	for _, origin := range c.AllowedOrigins {
+		origins[strings.TrimRight(origin, "/")] = true
+	}
+	return &cors{
+		allowAll:       origins["*"],
+		origins:        origins,
+		allowedHeaders: strings.Join(append(slices.Clone(protocolHeaders), c.AllowedHeaders...), ", "),
+		exposedHeaders: strings.Join(exposedHeaders, ", "),
+		maxAge:         strconv.Itoa(int(c.MaxAge.Seconds())),
+	}
+}
+
+// WithCORS applies c to the browser requests of next
+func WithCORS(c CORS, next http.Handler) http.Handler {
+	policy := newCORS(c)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !policy.handle(w, r) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// handle adds the CORS headers for allowed origins and answers preflight requests, in which
+// case it reports true
+func (c *cors) handle(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	if !c.allowAll && !c.origins[origin] {
+		if preflight {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		return preflight
+	}
+
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Set("Access-Control-Allow-Origin", origin)
+	if !preflight {
+		h.Set("Access-Control-Expose-Headers", c.exposedHeaders)
+		return false
+	}
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	h.Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+	h.Set("Access-Control-Allow-Headers", c.allowedHeaders)
+	h.Set("Access-Control-Max-Age", c.maxAge)
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
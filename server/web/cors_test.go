@@ -0,0 +1,124 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	policy := CORS{
+		AllowedOrigins: []string{"https://app.example", "https://admin.example/"},
+		AllowedHeaders: []string{"X-Extra"},
+		MaxAge:         10 * time.Minute,
+	}
+	for _, tt := range []struct {
+		name      string
+		policy    CORS
+		method    string
+		origin    string
+		preflight bool
+		status    int
+		// allowed is the Access-Control-Allow-Origin the response should carry
+		allowed string
+		passed  bool
+	}{
+		{"same origin", policy, http.MethodPost, "", false, http.StatusOK, "", true},
+		{"allowed origin", policy, http.MethodPost, "https://app.example", false, http.StatusOK, "https://app.example", true},
+		{"origin configured with a slash", policy, http.MethodPost, "https://admin.example", false, http.StatusOK, "https://admin.example", true},
+		{"other origin", policy, http.MethodPost, "https://evil.example", false, http.StatusOK, "", true},
+		{"preflight", policy, http.MethodOptions, "https://app.example", true, http.StatusNoContent, "https://app.example", false},
+		{"preflight from another origin", policy, http.MethodOptions, "https://evil.example", true, http.StatusForbidden, "", false},
+		{"options that is not a preflight", policy, http.MethodOptions, "https://app.example", false, http.StatusOK, "https://app.example", true},
+		{"any origin", CORS{AllowedOrigins: []string{"*"}}, http.MethodPost, "https://evil.example", false, http.StatusOK, "https://evil.example", true},
+		{"no origins", CORS{}, http.MethodOptions, "https://app.example", true, http.StatusForbidden, "", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			passed := false
+			handler := WithCORS(tt.policy, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				passed = true
+			}))
+			req := httptest.NewRequest(tt.method, "/fs.ChatshService/GetConfig", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+				req.Header.Set("Access-Control-Request-Headers", "content-type,x-extra")
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			h := w.Result().Header
+			if w.Code != tt.status || passed != tt.passed {
+				t.Errorf("got %d, passed on %t; want %d, passed on %t", w.Code, passed, tt.status, tt.passed)
+			}
+			if got := h.Get("Access-Control-Allow-Origin"); got != tt.allowed {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.allowed)
+			}
+			if tt.allowed == "" {
+				return
+			}
+			if !strings.Contains(strings.Join(h.Values("Vary"), ", "), "Origin") {
+				t.Errorf("Vary = %v, want Origin", h.Values("Vary"))
+			}
+			if tt.preflight {
+				for key, want := range map[string]string{
+					"Access-Control-Allow-Methods": "POST",
+					"Access-Control-Allow-Headers": "X-Extra",
+					"Access-Control-Max-Age":       "600",
+				} {
+					if got := h.Get(key); !strings.Contains(got, want) {
+						t.Errorf("%s = %q, want it to contain %q", key, got, want)
+					}
+				}
+				if !strings.Contains(h.Get("Access-Control-Allow-Headers"), "Connect-Protocol-Version") {
+					t.Errorf("Access-Control-Allow-Headers = %q, want the protocol headers", h.Get("Access-Control-Allow-Headers"))
+				}
+				return
+			}
+			if got := h.Get("Access-Control-Expose-Headers"); !strings.Contains(got, "Grpc-Status") {
+				t.Errorf("Access-Control-Expose-Headers = %q, want Grpc-Status", got)
+			}
+		})
+	}
+}
+
+// TestCORSCall makes the requests a browser makes for a Connect call from another origin
+func TestCORSCall(t *testing.T) {
+	ts := newTestServer(t, WithCORS(CORS{AllowedOrigins: []string{"https://app.example"}}, newTestHandler(t)))
+
+	req, err := http.NewRequest(http.MethodOptions, ts.URL+"/fs.ChatshService/GetConfig", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "https://app.example")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	req.Header.Set("Access-Control-Request-Headers", "content-type,connect-protocol-version")
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get("Access-Control-Allow-Origin") != "https://app.example" {
+		t.Fatalf("preflight got %d with origin %q", resp.StatusCode, resp.Header.Get("Access-Control-Allow-Origin"))
+	}
+
+	req, err = http.NewRequest(http.MethodPost, ts.URL+"/fs.ChatshService/GetConfig", strings.NewReader(`{"ownerToken": "code=8"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "https://app.example")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connect-Protocol-Version", "1")
+	resp, err = ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Access-Control-Allow-Origin") != "https://app.example" {
+		t.Errorf("call got %d with origin %q, want 429 readable by the page", resp.StatusCode, resp.Header.Get("Access-Control-Allow-Origin"))
+	}
+}
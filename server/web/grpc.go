@@ -0,0 +1,239 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// frameHeaderLen is the flag byte and big-endian length that precede every gRPC message
+	frameHeaderLen = 5
+	// maxFrameLen bounds the messages accepted from HTTP clients
+	maxFrameLen = 4 << 20
+
+	flagCompressed byte = 0x01
+)
+
+// readFrame reads one length-prefixed message. io.EOF means the stream ended between messages.
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [frameHeaderLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil, fmt.Errorf("truncated message header")
+		}
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length > maxFrameLen {
+		return 0, nil, fmt.Errorf("message of %d bytes exceeds the limit of %d", length, maxFrameLen)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, fmt.Errorf("truncated message: %w", err)
+	}
+	return header[0], payload, nil
+}
+
+// appendFrame appends payload to dst with a message header carrying flag
+func appendFrame(dst []byte, flag byte, payload []byte) []byte {
+	dst = append(dst, flag)
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(payload)))
+	return append(dst, payload...)
+}
+
+// callResult is the outcome of a call once the gRPC handler has returned
+type callResult struct {
+	// header holds the response metadata sent before the first message
+	header http.Header
+	// trailer holds the response metadata sent with the status
+	trailer http.Header
+	status  *status.Status
+}
+
+// grpcRecorder is the ResponseWriter handed to the gRPC server. It splits the response body into
+// messages and passes them on as they arrive, so server streams reach HTTP clients unbuffered.
+type grpcRecorder struct {
+	header http.Header
+	// headerWritten is set when the handler sends its headers explicitly, before any message
+	headerWritten bool
+	sent          http.Header
+	pending       bytes.Buffer
+	onHeader      func(http.Header) error
+	onMessage     func([]byte) error
+	flush         func()
+	err           error
+}
+
+func newGRPCRecorder(onHeader func(http.Header) error, onMessage func([]byte) error, flush func()) *grpcRecorder {
+	return &grpcRecorder{
+		header:    http.Header{},
+		onHeader:  onHeader,
+		onMessage: onMessage,
+		flush:     flush,
+	}
+}
+
+func (g *grpcRecorder) Header() http.Header {
+	return g.header
+}
+
+func (g *grpcRecorder) WriteHeader(int) {
+	g.headerWritten = true
+}
+
+// sendHeader passes the response metadata on once, before the first message
+func (g *grpcRecorder) sendHeader() error {
+	if g.sent != nil {
+		return nil
+	}
+	g.sent = responseMetadata(g.header)
+	return g.onHeader(g.sent)
+}
+
+func (g *grpcRecorder) Write(p []byte) (int, error) {
+	if g.err != nil {
+		return 0, g.err
+	}
+	g.pending.Write(p)
+	for g.pending.Len() >= frameHeaderLen {
+		buf := g.pending.Bytes()
+		length := int(binary.BigEndian.Uint32(buf[1:frameHeaderLen]))
+		if len(buf) < frameHeaderLen+length {
+			break
+		}
+		if buf[0]&flagCompressed != 0 {
+			g.err = errors.New("compressed responses are not supported")
+			return 0, g.err
+		}
+		payload := make([]byte, length)
+		copy(payload, buf[frameHeaderLen:frameHeaderLen+length])
+		g.pending.Next(frameHeaderLen + length)
+		if g.err = g.sendHeader(); g.err != nil {
+			return 0, g.err
+		}
+		if g.err = g.onMessage(payload); g.err != nil {
+			return 0, g.err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush passes explicitly sent headers and complete messages on. The gRPC handler also flushes
+// right before writing the status of a call without messages; that response stays trailers-only.
+func (g *grpcRecorder) Flush() {
+	if g.err != nil || g.pending.Len() > 0 {
+		return
+	}
+	if g.sent == nil {
+		if !g.headerWritten {
+			return
+		}
+		if g.err = g.sendHeader(); g.err != nil {
+			return
+		}
+	}
+	g.flush()
+}
+
+// result reads the status and trailers the gRPC handler left in the header map
+func (g *grpcRecorder) result() callResult {
+	result := callResult{header: g.sent, trailer: http.Header{}}
+	if result.header == nil {
+		result.header = responseMetadata(g.header)
+	}
+	for key, values := range g.header {
+		if name, ok := strings.CutPrefix(key, http.TrailerPrefix); ok {
+			result.trailer[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	result.status = headerStatus(g.header)
+	if g.err != nil && result.status.Code() == codes.OK {
+		result.status = status.New(codes.Internal, g.err.Error())
+	}
+	return result
+}
+
+// reservedHeaders are set by the gRPC transport rather than by the service
+var reservedHeaders = map[string]bool{
+	"Content-Type":            true,
+	"Trailer":                 true,
+	"Grpc-Status":             true,
+	"Grpc-Message":            true,
+	"Grpc-Status-Details-Bin": true,
+	"Grpc-Encoding":           true,
+	"Grpc-Accept-Encoding":    true,
+}
+
+func responseMetadata(h http.Header) http.Header {
+	md := http.Header{}
+	for key, values := range h {
+		if reservedHeaders[key] || strings.HasPrefix(key, http.TrailerPrefix) {
+			continue
+		}
+		md[key] = append([]string(nil), values...)
+	}
+	return md
+}
+
+// headerStatus decodes the grpc-status, grpc-message and grpc-status-details-bin trailers
+func headerStatus(h http.Header) *status.Status {
+	value := h.Get("Grpc-Status")
+	if value == "" {
+		return status.New(codes.Internal, "server closed the call without a status")
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil {
+		return status.New(codes.Internal, "malformed grpc-status "+value)
+	}
+	message, err := url.PathUnescape(h.Get("Grpc-Message"))
+	if err != nil {
+		message = h.Get("Grpc-Message")
+	}
+	if details := h.Get("Grpc-Status-Details-Bin"); details != "" {
+		raw, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(details, "="))
+		if err == nil {
+			st := &spb.Status{}
+			if proto.Unmarshal(raw, st) == nil && st.GetCode() == int32(code) {
+				return status.FromProto(st)
+			}
+		}
+	}
+	return status.New(codes.Code(code), message)
+}
+
+// grpcRequest builds the HTTP/2 gRPC request that carries an HTTP client's call to the gRPC
+// server. Metadata headers are kept; the transport headers of the original protocol are not.
+func grpcRequest(r *http.Request, body io.Reader, dropHeaders ...string) *http.Request {
+	req := r.Clone(r.Context())
+	req.Method = http.MethodPost
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2.0", 2, 0
+	req.Body = io.NopCloser(body)
+	req.ContentLength = -1
+	req.Header.Del("Content-Length")
+	req.Header.Del("Accept-Encoding")
+	req.Header.Del("Grpc-Encoding")
+	req.Header.Del("Grpc-Accept-Encoding")
+	for _, key := range dropHeaders {
+		req.Header.Del(key)
+	}
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Set("Te", "trailers")
+	return req
+}
+
+func marshalStatus(st *status.Status) ([]byte, error) {
+	return proto.Marshal(st.Proto())
+}
@@ -0,0 +1,128 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TestNativeGRPC calls the Handler with a gRPC client over unencrypted HTTP/2, which it passes
+// straight to the gRPC server
+func TestNativeGRPC(t *testing.T) {
+	ts := newTestServer(t, nil)
+	conn, err := grpc.NewClient("passthrough:///"+strings.TrimPrefix(ts.URL, "http://"), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	client := pb.NewChatshServiceClient(conn)
+	ctx := context.Background()
+
+	var header, trailer metadata.MD
+	resp, err := client.GetConfig(ctx, &pb.GetConfigRequest{OwnerToken: "alice"}, grpc.Header(&header), grpc.Trailer(&trailer))
+	if err != nil || resp.GetDisplayName() != "hello alice" {
+		t.Fatalf("GetConfig = %v, %v, want hello alice", resp, err)
+	}
+	if got := header.Get("x-request"); len(got) != 1 || got[0] != "r1" {
+		t.Errorf("header = %v, want x-request r1", header)
+	}
+	if got := trailer.Get("x-cost"); len(got) != 1 || got[0] != "3" {
+		t.Errorf("trailer = %v, want x-cost 3", trailer)
+	}
+
+	_, err = client.GetConfig(ctx, &pb.GetConfigRequest{OwnerToken: "code=7"})
+	if st := status.Convert(err); st.Code() != codes.PermissionDenied || st.Message() != "failed with 7" {
+		t.Errorf("GetConfig = %v, want PermissionDenied", err)
+	}
+
+	stream, err := client.Backup(ctx, &pb.BackupRequest{OwnerToken: "ab!"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks string
+	for {
+		chunk, err := stream.Recv()
+		if err != nil {
+			if st := status.Convert(err); st.Code() != codes.Unavailable || st.Message() != "backup stopped at 50% — disk full" {
+				t.Errorf("stream ended with %v, want Unavailable", err)
+			}
+			break
+		}
+		chunks += string(chunk.GetData())
+	}
+	if chunks != "ab" {
+		t.Errorf("chunks = %q, want ab", chunks)
+	}
+	if got := stream.Trailer().Get("x-cost"); len(got) != 1 || got[0] != "3" {
+		t.Errorf("trailer = %v, want x-cost 3", stream.Trailer())
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	oversized := binary.BigEndian.AppendUint32([]byte{0}, maxFrameLen+1)
+	for _, tt := range []struct {
+		name    string
+		data    []byte
+		flag    byte
+		payload string
+		err     string
+	}{
+		{"message", appendFrame(nil, 0, []byte("hi")), 0, "hi", ""},
+		{"flagged", appendFrame(nil, flagTrailer, []byte("x: y")), flagTrailer, "x: y", ""},
+		{"empty message", appendFrame(nil, 0, nil), 0, "", ""},
+		{"end of stream", nil, 0, "", "EOF"},
+		{"truncated header", []byte{0, 0, 0}, 0, "", "truncated message header"},
+		{"truncated message", appendFrame(nil, 0, []byte("hello"))[:7], 0, "", "truncated message"},
+		{"oversized", oversized, 0, "", "exceeds the limit"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			flag, payload, err := readFrame(bytes.NewReader(tt.data))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("readFrame = %v, want an error containing %q", err, tt.err)
+				}
+				if tt.err == "EOF" && !errors.Is(err, io.EOF) {
+					t.Errorf("readFrame = %v, want io.EOF", err)
+				}
+				return
+			}
+			if err != nil || flag != tt.flag || string(payload) != tt.payload {
+				t.Errorf("readFrame = %#x, %q, %v, want %#x, %q", flag, payload, err, tt.flag, tt.payload)
+			}
+		})
+	}
+}
+
+func TestHeaderStatus(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		header  http.Header
+		code    codes.Code
+		message string
+	}{
+		{"ok", http.Header{"Grpc-Status": {"0"}}, codes.OK, ""},
+		{"error", http.Header{"Grpc-Status": {"5"}, "Grpc-Message": {"no%20such%20room"}}, codes.NotFound, "no such room"},
+		{"bad escape", http.Header{"Grpc-Status": {"13"}, "Grpc-Message": {"100%"}}, codes.Internal, "100%"},
+		{"missing", http.Header{}, codes.Internal, "server closed the call without a status"},
+		{"malformed", http.Header{"Grpc-Status": {"five"}}, codes.Internal, "malformed grpc-status five"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			st := headerStatus(tt.header)
+			if st.Code() != tt.code || st.Message() != tt.message {
+				t.Errorf("headerStatus = %s %q, want %s %q", st.Code(), st.Message(), tt.code, tt.message)
+			}
+		})
+	}
+}
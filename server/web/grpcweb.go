@@ -0,0 +1,113 @@
+package web
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// flagTrailer marks the gRPC-Web message that carries the trailers
+const flagTrailer byte = 0x80
+
+// serveGRPCWeb relays a gRPC-Web call. Requests are already framed like gRPC, so only the text
+// encoding and the trailers, which gRPC-Web sends as a final message, need translating.
+func (h *Handler) serveGRPCWeb(w http.ResponseWriter, r *http.Request, text bool) {
+	var body io.Reader = r.Body
+	contentType := "application/grpc-web+proto"
+	if text {
+		body = base64.NewDecoder(base64.StdEncoding, r.Body)
+		contentType = "application/grpc-web-text+proto"
+	}
+	write := func(frame []byte) error {
+		if text {
+			frame = []byte(base64.StdEncoding.EncodeToString(frame))
+		}
+		_, err := w.Write(frame)
+		return err
+	}
+
+	recorder := newGRPCRecorder(
+		func(md http.Header) error {
+			copyHeader(w.Header(), md)
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(http.StatusOK)
+			return nil
+		},
+		func(payload []byte) error {
+			return write(appendFrame(nil, 0, payload))
+		},
+		flusher(w),
+	)
+	h.grpc.ServeHTTP(recorder, grpcRequest(r, body, "X-Grpc-Web", "X-User-Agent"))
+	result := recorder.result()
+
+	if recorder.sent == nil {
+		// Trailers-only response: the status and trailers travel in the headers
+		copyHeader(w.Header(), result.header)
+		copyHeader(w.Header(), result.trailer)
+		w.Header().Set("Content-Type", contentType)
+		setStatusHeaders(w.Header(), result)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	trailer := http.Header{}
+	copyHeader(trailer, result.trailer)
+	setStatusHeaders(trailer, result)
+	_ = write(appendFrame(nil, flagTrailer, encodeTrailer(trailer)))
+}
+
+// setStatusHeaders writes the gRPC status of result into h
+func setStatusHeaders(h http.Header, result callResult) {
+	h.Set("Grpc-Status", strconv.Itoa(int(result.status.Code())))
+	if message := result.status.Message(); message != "" {
+		h.Set("Grpc-Message", encodeGRPCMessage(message))
+	}
+	if details := result.status.Proto().GetDetails(); len(details) > 0 {
+		if raw, err := marshalStatus(result.status); err == nil {
+			h.Set("Grpc-Status-Details-Bin", base64.RawStdEncoding.EncodeToString(raw))
+		}
+	}
+}
+
+// encodeTrailer renders trailers in the HTTP/1 header format gRPC-Web clients parse
+func encodeTrailer(h http.Header) []byte {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		for _, value := range h[key] {
+			b.WriteString(strings.ToLower(key))
+			b.WriteString(": ")
+			b.WriteString(value)
+			b.WriteString("\r\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+// encodeGRPCMessage percent-encodes the characters grpc-message may not carry verbatim
+func encodeGRPCMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func copyHeader(dst, src http.Header) {
+	for key, values := range src {
+		dst[key] = append(dst[key], values...)
+	}
+}
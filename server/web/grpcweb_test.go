@@ -0,0 +1,175 @@
+package web
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// parseTrailer reads a gRPC-Web trailer message into a map of lower-case keys
+func parseTrailer(t *testing.T, payload []byte) map[string]string {
+	t.Helper()
+	trailer := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(payload), "\r\n"), "\r\n") {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			t.Fatalf("malformed trailer line %q", line)
+		}
+		trailer[key] = value
+	}
+	return trailer
+}
+
+func TestGRPCWebUnary(t *testing.T) {
+	ts := newTestServer(t, nil)
+	request := appendFrame(nil, 0, marshal(t, &pb.GetConfigRequest{OwnerToken: "alice"}))
+	for _, tt := range []struct {
+		contentType string
+		text        bool
+	}{
+		{"application/grpc-web", false},
+		{"application/grpc-web+proto", false},
+		{"application/grpc-web-text", true},
+		{"application/grpc-web-text+proto", true},
+	} {
+		t.Run(tt.contentType, func(t *testing.T) {
+			body := request
+			if tt.text {
+				body = []byte(base64.StdEncoding.EncodeToString(body))
+			}
+			resp, data := post(t, ts, "GetConfig", tt.contentType, body)
+			if resp.StatusCode != 200 {
+				t.Fatalf("status = %d: %s", resp.StatusCode, data)
+			}
+			want := "application/grpc-web+proto"
+			if tt.text {
+				want = "application/grpc-web-text+proto"
+				data = decodeText(t, data)
+			}
+			if got := resp.Header.Get("Content-Type"); got != want {
+				t.Errorf("Content-Type = %s, want %s", got, want)
+			}
+			if got := resp.Header.Get("X-Request"); got != "r1" {
+				t.Errorf("X-Request header = %q, want r1", got)
+			}
+			frames := readFrames(t, data)
+			if len(frames) != 2 || frames[0].flag != 0 || frames[1].flag != flagTrailer {
+				t.Fatalf("got frames %v, want a message and the trailer", frames)
+			}
+			got := &pb.GetConfigResponse{}
+			unmarshal(t, frames[0].payload, got)
+			if got.GetDisplayName() != "hello alice" {
+				t.Errorf("display name = %q, want hello alice", got.GetDisplayName())
+			}
+			trailer := parseTrailer(t, frames[1].payload)
+			if trailer["grpc-status"] != "0" || trailer["x-cost"] != "3" {
+				t.Errorf("trailer = %v, want grpc-status 0 and x-cost 3", trailer)
+			}
+		})
+	}
+}
+
+// TestGRPCWebTrailersOnly checks calls that fail before sending a message, whose status travels
+// in the response headers
+func TestGRPCWebTrailersOnly(t *testing.T) {
+	ts := newTestServer(t, nil)
+	resp, data := post(t, ts, "GetConfig", "application/grpc-web+proto",
+		appendFrame(nil, 0, marshal(t, &pb.GetConfigRequest{OwnerToken: "code=5"})))
+	if resp.StatusCode != 200 || len(data) != 0 {
+		t.Fatalf("got %d with %q, want 200 without a body", resp.StatusCode, data)
+	}
+	for key, want := range map[string]string{
+		"Grpc-Status":  "5",
+		"Grpc-Message": "failed with 5",
+		"X-Cost":       "3",
+	} {
+		if got := resp.Header.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+
+	resp, _ = post(t, ts, "GetConfig", "application/grpc-web+proto",
+		appendFrame(nil, 0, marshal(t, &pb.GetConfigRequest{OwnerToken: "retry"})))
+	raw, err := base64.RawStdEncoding.DecodeString(resp.Header.Get("Grpc-Status-Details-Bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := &spb.Status{}
+	unmarshal(t, raw, st)
+	details := status.FromProto(st).Details()
+	if st.GetCode() != int32(codes.ResourceExhausted) || len(details) != 1 {
+		t.Fatalf("status details = %v, want ResourceExhausted with one detail", st)
+	}
+	if info, ok := details[0].(*errdetails.RetryInfo); !ok || info.GetRetryDelay().AsDuration().Seconds() != 2 {
+		t.Errorf("detail = %v, want a RetryInfo of 2s", details[0])
+	}
+}
+
+func TestGRPCWebServerStream(t *testing.T) {
+	ts := newTestServer(t, nil)
+	for _, tt := range []struct {
+		token   string
+		chunks  string
+		status  string
+		message string
+	}{
+		{"abc", "abc", "0", ""},
+		{"ab!", "ab", "14", "backup stopped at 50% — disk full"},
+	} {
+		t.Run(tt.token, func(t *testing.T) {
+			resp, data := post(t, ts, "Backup", "application/grpc-web-text",
+				[]byte(base64.StdEncoding.EncodeToString(appendFrame(nil, 0, marshal(t, &pb.BackupRequest{OwnerToken: tt.token})))))
+			if resp.StatusCode != 200 {
+				t.Fatalf("status = %d: %s", resp.StatusCode, data)
+			}
+			if got := resp.Header.Get("X-Request"); got != "r1" {
+				t.Errorf("X-Request header = %q, want r1", got)
+			}
+			frames := readFrames(t, decodeText(t, data))
+			if len(frames) != len(tt.chunks)+1 {
+				t.Fatalf("got %d frames, want %d messages and the trailer", len(frames), len(tt.chunks))
+			}
+			var chunks string
+			for _, f := range frames[:len(frames)-1] {
+				chunk := &pb.BackupChunk{}
+				unmarshal(t, f.payload, chunk)
+				chunks += string(chunk.GetData())
+			}
+			if chunks != tt.chunks {
+				t.Errorf("chunks = %q, want %q", chunks, tt.chunks)
+			}
+			last := frames[len(frames)-1]
+			if last.flag != flagTrailer {
+				t.Fatalf("last frame has flag %#x, want the trailer", last.flag)
+			}
+			trailer := parseTrailer(t, last.payload)
+			if trailer["grpc-status"] != tt.status || trailer["x-cost"] != "3" {
+				t.Errorf("trailer = %v, want grpc-status %s and x-cost 3", trailer, tt.status)
+			}
+			message, err := url.PathUnescape(trailer["grpc-message"])
+			if err != nil || message != tt.message {
+				t.Errorf("grpc-message %q decodes to %q, %v, want %q", trailer["grpc-message"], message, err, tt.message)
+			}
+		})
+	}
+}
+
+func TestEncodeGRPCMessage(t *testing.T) {
+	for message, want := range map[string]string{
+		"plain text":  "plain text",
+		"50%":         "50%25",
+		"line\nbreak": "line%0Abreak",
+		"日本":          "%E6%97%A5%E6%9C%AC",
+	} {
+		if got := encodeGRPCMessage(message); got != want {
+			t.Errorf("encodeGRPCMessage(%q) = %q, want %q", message, got, want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package web serves the gRPC-Web and Connect protocols, so that browsers and plain HTTP clients
+// can call the chatsh service without a proxy. Calls are translated into gRPC and handed to the
+// unmodified gRPC server, keeping its interceptors and Adaptor in charge of every request.
+//
+// HTTP/1.1 cannot carry a full-duplex call, so over HTTP/1.1 StreamMessage is half-duplex: the
+// client sends its requests (typically a single Tail, or a Join and its Chat messages) in the
+// body and then reads the stream.
+package web
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// Handler routes native gRPC, gRPC-Web and Connect requests for the same set of services
+type Handler struct {
+	grpc    http.Handler
+	methods map[string]protoreflect.MethodDescriptor
+}
+
+// NewHandler serves the services named by serviceNames (e.g. "fs.ChatshService") from grpcServer,
+// which is usually a *grpc.Server
+func NewHandler(grpcServer http.Handler, serviceNames ...string) (*Handler, error) {
+	methods := map[string]protoreflect.MethodDescriptor{}
+	for _, name := range serviceNames {
+		desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			return nil, err
+		}
+		service, ok := desc.(protoreflect.ServiceDescriptor)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a service", name)
+		}
+		for i := 0; i < service.Methods().Len(); i++ {
+			method := service.Methods().Get(i)
+			methods["/"+name+"/"+string(method.Name())] = method
+		}
+	}
+	return &Handler{
+		grpc:    grpcServer,
+		methods: methods,
+	}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case contentType == "application/grpc" || strings.HasPrefix(contentType, "application/grpc+"):
+		if r.ProtoMajor != 2 {
+			http.Error(w, "gRPC requires HTTP/2; use gRPC-Web or Connect over HTTP/1.1", http.StatusHTTPVersionNotSupported)
+			return
+		}
+		h.grpc.ServeHTTP(w, r)
+		return
+	case r.Method != http.MethodPost:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	method, ok := h.methods[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	// Lets handlers keep reading the body of HTTP/1.1 requests after the response has started
+	_ = http.NewResponseController(w).EnableFullDuplex()
+
+	switch contentType {
+	case "application/grpc-web", "application/grpc-web+proto":
+		h.serveGRPCWeb(w, r, false)
+	case "application/grpc-web-text", "application/grpc-web-text+proto":
+		h.serveGRPCWeb(w, r, true)
+	case "application/proto", "application/json":
+		if method.IsStreamingClient() || method.IsStreamingServer() {
+			http.Error(w, "streaming methods need application/connect+proto or application/connect+json", http.StatusUnsupportedMediaType)
+			return
+		}
+		h.serveConnectUnary(w, r, method, contentType == "application/json")
+	case "application/connect+proto", "application/connect+json":
+		h.serveConnectStream(w, r, method, contentType == "application/connect+json")
+	default:
+		http.Error(w, "unsupported content type "+contentType, http.StatusUnsupportedMediaType)
+	}
+}
+
+func flusher(w http.ResponseWriter) func() {
+	return func() {
+		_ = http.NewResponseController(w).Flush()
+	}
+}
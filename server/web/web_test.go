@@ -0,0 +1,223 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/ponyo877/chatsh/grpc"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// testService answers the calls the tests make. Every call sends an x-request header and an
+// x-cost trailer, so that the tests can follow metadata through each protocol.
+type testService struct {
+	pb.UnimplementedChatshServiceServer
+}
+
+// GetConfig greets the owner token, or fails with the code it names as "code=N". "retry" fails
+// with a RetryInfo detail.
+func (testService) GetConfig(ctx context.Context, req *pb.GetConfigRequest) (*pb.GetConfigResponse, error) {
+	grpc.SetHeader(ctx, metadata.Pairs("x-request", "r1"))
+	grpc.SetTrailer(ctx, metadata.Pairs("x-cost", "3"))
+	token := req.GetOwnerToken()
+	if code, ok := strings.CutPrefix(token, "code="); ok {
+		n, err := strconv.Atoi(code)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Error(codes.Code(n), "failed with "+code)
+	}
+	if token == "retry" {
+		st, err := status.New(codes.ResourceExhausted, "slow down").WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(2 * time.Second)})
+		if err != nil {
+			return nil, err
+		}
+		return nil, st.Err()
+	}
+	return &pb.GetConfigResponse{DisplayName: "hello " + token}, nil
+}
+
+// Backup streams the owner token back a byte at a time, and fails once done if it ends with "!"
+func (testService) Backup(req *pb.BackupRequest, stream pb.ChatshService_BackupServer) error {
+	stream.SetHeader(metadata.Pairs("x-request", "r1"))
+	stream.SetTrailer(metadata.Pairs("x-cost", "3"))
+	token := req.GetOwnerToken()
+	for i := 0; i < len(strings.TrimSuffix(token, "!")); i++ {
+		if err := stream.Send(&pb.BackupChunk{Data: []byte{token[i]}}); err != nil {
+			return err
+		}
+	}
+	if strings.HasSuffix(token, "!") {
+		return status.Error(codes.Unavailable, "backup stopped at 50% — disk full")
+	}
+	return nil
+}
+
+// StreamMessage echoes the text of every Chat it receives
+func (testService) StreamMessage(stream pb.ChatshService_StreamMessageServer) error {
+	stream.SetTrailer(metadata.Pairs("x-cost", "3"))
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&pb.ServerMessage{Text: msg.GetChat().GetText()}); err != nil {
+			return err
+		}
+	}
+}
+
+// newTestHandler serves testService through a Handler
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	s := grpc.NewServer()
+	pb.RegisterChatshServiceServer(s, testService{})
+	handler, err := NewHandler(s, pb.ChatshService_ServiceDesc.ServiceName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handler
+}
+
+// newTestServer serves handler, newTestHandler when nil, over HTTP/1.1 and unencrypted HTTP/2
+func newTestServer(t *testing.T, handler http.Handler) *httptest.Server {
+	t.Helper()
+	if handler == nil {
+		handler = newTestHandler(t)
+	}
+	ts := httptest.NewUnstartedServer(handler)
+	ts.Config.Protocols = new(http.Protocols)
+	ts.Config.Protocols.SetHTTP1(true)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// post sends body to method of ts as contentType and returns the response with its body read
+func post(t *testing.T, ts *httptest.Server, method, contentType string, body []byte) (*http.Response, []byte) {
+	t.Helper()
+	resp, err := ts.Client().Post(ts.URL+"/fs.ChatshService/"+method, contentType, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, data
+}
+
+// frame is one length-prefixed message of a response body
+type frame struct {
+	flag    byte
+	payload []byte
+}
+
+func readFrames(t *testing.T, body []byte) []frame {
+	t.Helper()
+	var frames []frame
+	r := bytes.NewReader(body)
+	for {
+		flag, payload, err := readFrame(r)
+		if errors.Is(err, io.EOF) {
+			return frames
+		}
+		if err != nil {
+			t.Fatalf("reading frames of %q: %v", body, err)
+		}
+		frames = append(frames, frame{flag, payload})
+	}
+}
+
+func marshal(t *testing.T, msg proto.Message) []byte {
+	t.Helper()
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func unmarshal(t *testing.T, data []byte, msg proto.Message) {
+	t.Helper()
+	if err := proto.Unmarshal(data, msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// decodeText decodes a grpc-web-text body. The server encodes every frame on its own, so padding
+// may appear anywhere a four-character group ends.
+func decodeText(t *testing.T, body []byte) []byte {
+	t.Helper()
+	var decoded []byte
+	for len(body) > 0 {
+		n := min(4, len(body))
+		group, err := base64.StdEncoding.DecodeString(string(body[:n]))
+		if err != nil {
+			t.Fatalf("decoding %q: %v", body, err)
+		}
+		decoded = append(decoded, group...)
+		body = body[n:]
+	}
+	return decoded
+}
+
+func TestHandlerRouting(t *testing.T) {
+	ts := newTestServer(t, nil)
+	for _, tt := range []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		want        int
+	}{
+		{"native grpc over http/1.1", http.MethodPost, "/fs.ChatshService/GetConfig", "application/grpc", http.StatusHTTPVersionNotSupported},
+		{"get", http.MethodGet, "/fs.ChatshService/GetConfig", "application/json", http.StatusMethodNotAllowed},
+		{"unknown method", http.MethodPost, "/fs.ChatshService/Nothing", "application/json", http.StatusNotFound},
+		{"unknown service", http.MethodPost, "/fs.Other/GetConfig", "application/json", http.StatusNotFound},
+		{"unsupported content type", http.MethodPost, "/fs.ChatshService/GetConfig", "text/plain", http.StatusUnsupportedMediaType},
+		{"unary content type for a stream", http.MethodPost, "/fs.ChatshService/Backup", "application/json", http.StatusUnsupportedMediaType},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.path, strings.NewReader("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", tt.contentType)
+			resp, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+	if _, err := NewHandler(grpc.NewServer(), "fs.Nothing"); err == nil {
+		t.Error("NewHandler with an unknown service succeeded")
+	}
+	if _, err := NewHandler(grpc.NewServer(), "fs.ChatshService.GetConfig"); err == nil {
+		t.Error("NewHandler with a method name succeeded")
+	}
+}
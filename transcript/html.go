@@ -0,0 +1,73 @@
+package transcript
+
+import (
+	"html"
+	"strconv"
+	"time"
+)
+
+// htmlRenderer writes a page that needs nothing but itself: the style is inline and there are
+// no scripts or external resources. Every message can be linked to as #m<id>.
+type htmlRenderer struct {
+	started bool
+}
+
+const htmlStyle = `body{font-family:-apple-system,"Segoe UI",Helvetica,Arial,sans-serif;line-height:1.5;max-width:60rem;margin:2rem auto;padding:0 1rem;color:#1f2328;background:#fff}
+h1{font-size:1.4rem;border-bottom:1px solid #d1d9e0;padding-bottom:.3rem}
+h2{font-size:1rem;color:#59636e;margin-top:1.5rem}
+.message{display:grid;grid-template-columns:6rem 9rem 1fr;gap:.5rem;padding:.2rem 0}
+.message:target{background:#fff8c5}
+time{color:#59636e;font-variant-numeric:tabular-nums}
+time a{color:inherit;text-decoration:none}
+.author{font-weight:600;overflow:hidden;text-overflow:ellipsis}
+.text{white-space:pre-wrap;overflow-wrap:anywhere;margin:0;font:inherit}
+footer{margin-top:2rem;color:#59636e;font-size:.85rem}
+@media (prefers-color-scheme:dark){body{color:#f0f6fc;background:#0d1117}h1{border-color:#3d444d}.message:target{background:#3b2e0a}}`
+
+func (r *htmlRenderer) start(out *output, title string) {
+	r.started = true
+	if out.options.Title != "" {
+		title = out.options.Title
+	}
+	out.print(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="generator" content="chatsh">
+<title>`, html.EscapeString(title), `</title>
+<style>
+`, htmlStyle, `
+</style>
+</head>
+<body>
+`)
+}
+
+func (r *htmlRenderer) room(out *output, path string) {
+	if !r.started {
+		r.start(out, path)
+	} else {
+		out.print("</section>\n")
+	}
+	out.print("<section>\n<h1>", html.EscapeString(path), "</h1>\n")
+}
+
+func (r *htmlRenderer) message(out *output, message Message) {
+	if out.newDay(message) {
+		out.print("<h2>", html.EscapeString(dayHeading(message)), "</h2>\n")
+	}
+	id := "m" + strconv.FormatInt(message.ID, 10)
+	out.print(`<div class="message" id="`, id, `"><time datetime="`, message.Time.Format(time.RFC3339), `"><a href="#`, id, `">`,
+		message.Time.Format("15:04:05"), `</a></time><span class="author">`, html.EscapeString(message.Author),
+		`</span><pre class="text">`, html.EscapeString(message.Text), "</pre></div>\n")
+}
+
+func (r *htmlRenderer) end(out *output) {
+	if !r.started {
+		r.start(out, "chatsh transcript")
+	} else {
+		out.print("</section>\n")
+	}
+	out.print("<footer>Exported from chatsh</footer>\n</body>\n</html>\n")
+}
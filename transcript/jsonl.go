@@ -0,0 +1,45 @@
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"time"
+)
+
+// jsonLinesRenderer writes a JSON object per message, which names its room:
+//
+//	{"room":"/srv/proj/incident","id":12,"author":"alice","text":"deploy started","time":"2025-06-01T10:02:03+09:00"}
+type jsonLinesRenderer struct {
+	encoder *json.Encoder
+}
+
+type jsonMessage struct {
+	Room   string    `json:"room"`
+	ID     int64     `json:"id"`
+	Author string    `json:"author"`
+	Text   string    `json:"text"`
+	Time   time.Time `json:"time"`
+}
+
+func newJSONLinesRenderer(w *bufio.Writer) *jsonLinesRenderer {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &jsonLinesRenderer{encoder: encoder}
+}
+
+func (r *jsonLinesRenderer) room(out *output, path string) {}
+
+func (r *jsonLinesRenderer) message(out *output, message Message) {
+	if out.err != nil {
+		return
+	}
+	out.err = r.encoder.Encode(jsonMessage{
+		Room:   out.room,
+		ID:     message.ID,
+		Author: message.Author,
+		Text:   message.Text,
+		Time:   message.Time,
+	})
+}
+
+func (r *jsonLinesRenderer) end(out *output) {}
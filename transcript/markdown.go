@@ -0,0 +1,55 @@
+package transcript
+
+import (
+	"regexp"
+	"strings"
+)
+
+// markdownRenderer writes a section per room and a heading per day, under which every message
+// is a list item. The text is escaped, so that it reads as it was written rather than as
+// Markdown, and keeps its line breaks.
+//
+//	# /srv/proj/incident
+//
+//	## Sunday, 2025-06-01 (UTC+09:00)
+//
+//	- 10:02:03 **alice**: deploy started
+//	- 10:02:09 **bob**: rolling back:\
+//	  kubectl rollout undo deploy/api
+type markdownRenderer struct{}
+
+// markdownSpecial matches the characters that mean something in Markdown anywhere in a line
+var markdownSpecial = regexp.MustCompile("[\\\\`*_\\[\\]<>|~#!]")
+
+// markdownBlockStart matches the starts of lines that would make them a list, heading or quote
+var markdownBlockStart = regexp.MustCompile(`^(\s*)([-+=]|\d+[.)])`)
+
+func (markdownRenderer) room(out *output, path string) {
+	if out.rooms > 1 {
+		out.print("\n")
+	}
+	out.print("# ", escapeMarkdown(path), "\n")
+}
+
+func (markdownRenderer) message(out *output, message Message) {
+	if out.newDay(message) {
+		out.print("\n## ", dayHeading(message), "\n\n")
+	}
+	out.print("- ", message.Time.Format("15:04:05"), " **", escapeMarkdown(message.Author), "**:")
+	for i, line := range lines(message.Text) {
+		line = escapeMarkdown(line)
+		if i == 0 {
+			out.print(" ", line)
+			continue
+		}
+		// A backslash at the end of a line is a hard line break
+		out.print("\\\n  ", markdownBlockStart.ReplaceAllString(line, `$1\$2`))
+	}
+	out.print("\n")
+}
+
+func (markdownRenderer) end(out *output) {}
+
+func escapeMarkdown(s string) string {
+	return strings.TrimRight(markdownSpecial.ReplaceAllString(s, `\$0`), " ")
+}
@@ -0,0 +1,71 @@
+package transcript
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mboxDomain is the domain of the addresses and message ids of an mbox, which are made up
+// and must never reach anyone; .invalid is reserved for that
+const mboxDomain = "chatsh.invalid"
+
+// mboxRenderer writes an mbox in the mboxrd flavour, with a mail per message. The mails of a
+// room share its path as subject and refer to its first mail, so that mail clients show the
+// room as one thread. Bodies are quoted-printable, which keeps long lines within what mail allows.
+type mboxRenderer struct {
+	// thread is the message id of the first mail of the room, which the others refer to
+	thread string
+}
+
+// mboxFromLine matches the body lines mboxrd quotes with one more '>', lest they start a mail
+var mboxFromLine = regexp.MustCompile(`(?m)^(>*From )`)
+
+// mailLocalPart matches what cannot be in the local part of the made-up addresses
+var mailLocalPart = regexp.MustCompile(`[^A-Za-z0-9._+-]+`)
+
+func (r *mboxRenderer) room(out *output, path string) {
+	r.thread = ""
+}
+
+func (r *mboxRenderer) message(out *output, message Message) {
+	local := strings.Trim(mailLocalPart.ReplaceAllString(message.Author, "-"), ".-")
+	if local == "" {
+		local = "unknown"
+	}
+	from := mail.Address{Name: message.Author, Address: local + "@" + mboxDomain}
+	roomHash := sha256.Sum256([]byte(out.room))
+	id := "<" + strconv.FormatInt(message.ID, 10) + "." + hex.EncodeToString(roomHash[:6]) + "@" + mboxDomain + ">"
+
+	out.print("From ", from.Address, " ", message.Time.UTC().Format(time.ANSIC), "\n")
+	out.print("From: ", from.String(), "\n")
+	out.print("Date: ", message.Time.Format(time.RFC1123Z), "\n")
+	out.print("Subject: ", mime.QEncoding.Encode("utf-8", out.room), "\n")
+	out.print("Message-ID: ", id, "\n")
+	if r.thread == "" {
+		r.thread = id
+	} else {
+		out.print("In-Reply-To: ", r.thread, "\n", "References: ", r.thread, "\n")
+	}
+	out.print("X-Chatsh-Room: ", mime.QEncoding.Encode("utf-8", out.room), "\n")
+	out.print("MIME-Version: 1.0\n",
+		"Content-Type: text/plain; charset=utf-8\n",
+		"Content-Transfer-Encoding: quoted-printable\n\n")
+
+	var body bytes.Buffer
+	encoder := quotedprintable.NewWriter(&body)
+	encoder.Write([]byte(strings.Join(lines(message.Text), "\n")))
+	encoder.Close()
+	// The encoder ends lines the way mail does on the wire; an mbox ends them the local way
+	text := strings.ReplaceAll(body.String(), "\r\n", "\n")
+	out.print(mboxFromLine.ReplaceAllString(text, ">$1"), "\n\n")
+}
+
+func (r *mboxRenderer) end(out *output) {}
@@ -0,0 +1,33 @@
+package transcript
+
+// textRenderer writes a line per message, headed by its time and author, the way a chat log
+// reads; further lines of a message are indented under the first
+//
+//	==> /srv/proj/incident <==
+//	2025-06-01 10:02:03 +0900 alice: deploy started
+//	2025-06-01 10:02:09 +0900 bob: rolling back:
+//		kubectl rollout undo deploy/api
+type textRenderer struct{}
+
+func (textRenderer) room(out *output, path string) {
+	if out.rooms > 1 {
+		out.print("\n")
+	}
+	out.print("==> ", path, " <==\n")
+}
+
+func (textRenderer) message(out *output, message Message) {
+	out.print(message.Time.Format("2006-01-02 15:04:05 -0700"), " ", message.Author, ":")
+	for i, line := range lines(message.Text) {
+		switch {
+		case i == 0:
+			out.print(" ", line, "\n")
+		case line == "":
+			out.print("\n")
+		default:
+			out.print("\t", line, "\n")
+		}
+	}
+}
+
+func (textRenderer) end(out *output) {}
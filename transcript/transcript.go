@@ -0,0 +1,170 @@
+// Package transcript renders the history of chatsh rooms for reading outside chatsh: as plain
+// text, Markdown, JSON lines, a standalone HTML page or an mbox file that mail clients import.
+//
+// A Writer renders messages as they come, so a history of any length goes through it without
+// being held in memory:
+//
+//	w, err := transcript.NewWriter(os.Stdout, transcript.Markdown, transcript.Options{Location: loc})
+//	w.StartRoom("/srv/proj/incident")
+//	for _, message := range page {
+//		w.WriteMessage(message)
+//	}
+//	err = w.Close()
+//
+// A transcript may hold several rooms, each started with StartRoom before its messages.
+package transcript
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Format names a way of rendering a transcript, as given to chatsh cat --format
+type Format string
+
+const (
+	Text      Format = "txt"
+	Markdown  Format = "md"
+	JSONLines Format = "jsonl"
+	HTML      Format = "html"
+	Mbox      Format = "mbox"
+)
+
+// Formats lists the formats NewWriter knows
+var Formats = []Format{Text, Markdown, JSONLines, HTML, Mbox}
+
+// Message is a message of a room as a transcript shows it
+type Message struct {
+	ID     int64
+	Author string
+	Text   string
+	Time   time.Time
+}
+
+type Options struct {
+	// Location is the time zone times are written in; nil means UTC
+	Location *time.Location
+	// Title names an HTML transcript, which is named after its first room otherwise
+	Title string
+}
+
+// Writer renders a transcript. Close ends the document, and must be called even for a
+// transcript without rooms; it does not close the underlying writer.
+type Writer interface {
+	// StartRoom starts the messages of the room at path
+	StartRoom(path string) error
+	WriteMessage(message Message) error
+	Close() error
+}
+
+// renderer is what sets the formats apart; output calls it and keeps the first write error
+type renderer interface {
+	room(out *output, path string)
+	message(out *output, message Message)
+	end(out *output)
+}
+
+// NewWriter returns a Writer that renders a transcript in format to w
+func NewWriter(w io.Writer, format Format, options Options) (Writer, error) {
+	if options.Location == nil {
+		options.Location = time.UTC
+	}
+	out := &output{w: bufio.NewWriter(w), options: options}
+	switch format {
+	case Text:
+		out.renderer = &textRenderer{}
+	case Markdown:
+		out.renderer = &markdownRenderer{}
+	case JSONLines:
+		out.renderer = newJSONLinesRenderer(out.w)
+	case HTML:
+		out.renderer = &htmlRenderer{}
+	case Mbox:
+		out.renderer = &mboxRenderer{}
+	default:
+		return nil, fmt.Errorf("unknown transcript format %q", format)
+	}
+	return out, nil
+}
+
+// output is the Writer of every format
+type output struct {
+	w        *bufio.Writer
+	options  Options
+	renderer renderer
+	// rooms counts the rooms started so far; room is the current one
+	rooms int
+	room  string
+	// day is the date of the last message of the room, in Location, for the formats that head days
+	day    string
+	err    error
+	closed bool
+}
+
+func (o *output) StartRoom(path string) error {
+	if o.closed {
+		return fmt.Errorf("transcript: StartRoom after Close")
+	}
+	o.rooms++
+	o.room, o.day = path, ""
+	o.renderer.room(o, path)
+	return o.err
+}
+
+func (o *output) WriteMessage(message Message) error {
+	if o.closed {
+		return fmt.Errorf("transcript: WriteMessage after Close")
+	}
+	if o.rooms == 0 {
+		return fmt.Errorf("transcript: WriteMessage before StartRoom")
+	}
+	message.Time = message.Time.In(o.options.Location)
+	o.renderer.message(o, message)
+	return o.err
+}
+
+func (o *output) Close() error {
+	if o.closed {
+		return o.err
+	}
+	o.closed = true
+	o.renderer.end(o)
+	if o.err == nil {
+		o.err = o.w.Flush()
+	}
+	return o.err
+}
+
+// print writes strings to the transcript unless a write failed before
+func (o *output) print(s ...string) {
+	for _, part := range s {
+		if o.err != nil {
+			return
+		}
+		_, o.err = o.w.WriteString(part)
+	}
+}
+
+// newDay reports whether message is the first of its day in the room, and remembers its day
+func (o *output) newDay(message Message) bool {
+	day := message.Time.Format(time.DateOnly)
+	if day == o.day {
+		return false
+	}
+	o.day = day
+	return true
+}
+
+// dayHeading names the day of message with the offset of its time zone, for the formats that head days
+func dayHeading(message Message) string {
+	return message.Time.Format("Monday, 2006-01-02 (UTC-07:00)")
+}
+
+// lines splits text into its lines, whatever its line endings
+func lines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(strings.ReplaceAll(text, "\r", "\n"), "\n")
+}